@@ -0,0 +1,25 @@
+package main
+
+import (
+	"dooreye-backend/internal/api"
+	"dooreye-backend/internal/metrics"
+	"net/http"
+	"time"
+)
+
+// newAdminServer serves operational endpoints on a listener of their own,
+// so they are never reachable through the public port. Its /readyz carries
+// the dependency errors the public probe leaves out.
+func newAdminServer(addr string, server *api.Handler) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", metrics.Handler())
+	mux.HandleFunc("GET /readyz", server.ServeReadiness)
+
+	return &http.Server{
+		Addr:         addr,
+		Handler:      mux,
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 30 * time.Second,
+		IdleTimeout:  60 * time.Second,
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"dooreye-backend/internal/config"
+	"dooreye-backend/internal/telemetry"
+	"fmt"
+	"log/slog"
+	"os"
+)
+
+// printConfig shows the settings the server would start with, then fails
+// if it would refuse to start with them.
+func printConfig(file string) error {
+	cfg, err := config.Load(file)
+	if err != nil {
+		return err
+	}
+	if err := cfg.Print(os.Stdout); err != nil {
+		return err
+	}
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	return nil
+}
+
+func newLogger(cfg config.Log) *slog.Logger {
+	var level slog.Level
+	// Validate has already checked the level.
+	_ = level.UnmarshalText([]byte(cfg.Level))
+
+	opts := &slog.HandlerOptions{Level: level}
+
+	var h slog.Handler
+	if cfg.Format == config.LogText {
+		h = slog.NewTextHandler(os.Stdout, opts)
+	} else {
+		h = slog.NewJSONHandler(os.Stdout, opts)
+	}
+	return slog.New(telemetry.NewLogHandler(h))
+}
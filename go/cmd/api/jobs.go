@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"dooreye-backend/internal/alerts"
+	"dooreye-backend/internal/announce"
+	"dooreye-backend/internal/billing"
+	"dooreye-backend/internal/helpdesk"
+	"dooreye-backend/internal/jobs"
+	"dooreye-backend/internal/model"
+	"dooreye-backend/internal/notify"
+	"dooreye-backend/internal/overstay"
+	"dooreye-backend/internal/polls"
+	"dooreye-backend/internal/store"
+	"log/slog"
+	"time"
+)
+
+// Finished jobs are kept this long for inspection via the admin API.
+const jobRetention = 30 * 24 * time.Hour
+
+// Rate limit state idle this long is dropped; buckets have refilled and
+// failure windows have passed by then.
+const rateLimitRetention = 24 * time.Hour
+
+func registerJobs(s *jobs.Scheduler, db *store.DB, notifier notify.Notifier, log *slog.Logger) error {
+	scanner := overstay.NewScanner(db, notifier, log)
+	if err := s.Every("overstay-scan", "* * * * *", scanner.Job); err != nil {
+		return err
+	}
+
+	alertService := alerts.NewService(db, notifier, log)
+	if err := s.Every("alert-escalation", "@every 15s", alertService.Escalate); err != nil {
+		return err
+	}
+
+	publisher := announce.NewPublisher(db, notifier, log)
+	s.Handle(store.JobAnnouncementFanout, publisher.Fanout)
+
+	helpdeskMonitor := helpdesk.NewMonitor(db, notifier, log)
+	if err := s.Every("ticket-sla-check", "* * * * *", helpdeskMonitor.CheckSLA); err != nil {
+		return err
+	}
+	if err := s.Every("ticket-auto-close", "@hourly", helpdeskMonitor.AutoClose); err != nil {
+		return err
+	}
+
+	sealer := polls.NewSealer(db, notifier, log)
+	if err := s.Every("poll-seal", "* * * * *", sealer.Seal); err != nil {
+		return err
+	}
+
+	invoicer := billing.NewInvoicer(db, notifier, log)
+	if err := s.Every("generate-invoices", "15 0 * * *", invoicer.Generate); err != nil {
+		return err
+	}
+	if err := s.Every("apply-late-fees", "30 0 * * *", invoicer.ApplyLateFees); err != nil {
+		return err
+	}
+
+	err := s.Every("expire-tenancies", "5 0 * * *", func(ctx context.Context, _ *model.Job) error {
+		n, err := db.EndExpiredTenancies(ctx)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			log.InfoContext(ctx, "ended expired tenancies", "count", n)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = s.Every("purge-rate-limits", "@hourly", func(ctx context.Context, _ *model.Job) error {
+		n, err := db.PurgeRateLimits(ctx, time.Now().Add(-rateLimitRetention))
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			log.InfoContext(ctx, "purged idle rate limit state", "count", n)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.Every("purge-finished-jobs", "@daily", func(ctx context.Context, _ *model.Job) error {
+		n, err := db.PurgeFinishedJobs(ctx, time.Now().Add(-jobRetention))
+		if err != nil {
+			return err
+		}
+		log.InfoContext(ctx, "purged finished jobs", "count", n)
+		return nil
+	})
+}
@@ -2,70 +2,181 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
 	"fmt"
 	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
-	"github.com/joho/godotenv"
-	"dooreye-backend/internal/store"
 	"dooreye-backend/internal/api"
+	"dooreye-backend/internal/certs"
+	"dooreye-backend/internal/config"
+	"dooreye-backend/internal/jobs"
+	"dooreye-backend/internal/metrics"
+	"dooreye-backend/internal/notify"
+	"dooreye-backend/internal/payment"
+	"dooreye-backend/internal/ratelimit"
+	"dooreye-backend/internal/store"
+	"dooreye-backend/internal/telemetry"
 )
 
 func main() {
-	if err := run(); err != nil {
-		slog.Error("startup error", "error", err)
-		os.Exit(1)
+	configFile := flag.String("config", "", "dotenv `file` to read settings from (default .env.$GO_ENV, or .env in production)")
+	flag.Usage = usage
+	flag.Parse()
+
+	switch args := flag.Args(); {
+	case len(args) == 0:
+		if err := run(*configFile); err != nil {
+			slog.Error("startup error", "error", err)
+			os.Exit(1)
+		}
+	case len(args) == 2 && args[0] == "config" && args[1] == "print":
+		if err := printConfig(*configFile); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	default:
+		flag.Usage()
+		os.Exit(2)
 	}
 }
 
-func run() error {
-	// Load environment
-	env := os.Getenv("GO_ENV")
-	if env == "" {
-		env = "development"
-	}
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), `Usage:
+  %[1]s [-config file]               run the server
+  %[1]s [-config file] config print  show the effective configuration
 
-	envFile := ".env"
-	if env != "production" {
-		envFile = fmt.Sprintf(".env.%s", env)
+`, os.Args[0])
+	flag.PrintDefaults()
+}
+
+func run(configFile string) error {
+	cfg, err := config.Load(configFile)
+	if err != nil {
+		return err
 	}
-	if err := godotenv.Load(envFile); err != nil {
-		fmt.Printf("No %s file found\n", envFile)
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
 	}
 
-	// Setup logger
-	var logHandler slog.Handler
-	if env == "development" {
-		logHandler = slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
-			Level: slog.LevelDebug,
-		})
-	} else {
-		logHandler = slog.NewJSONHandler(os.Stdout, nil)
+	log := newLogger(cfg.Log)
+	if cfg.File() == "" {
+		log.Info("no config file found", "file", config.DefaultFile(cfg.Env))
 	}
-	log := slog.New(logHandler)
+
+	shutdownTracing, err := telemetry.Setup(context.Background(), cfg.Tracing.Exporter, "dooreye-api")
+	if err != nil {
+		return fmt.Errorf("setting up tracing: %w", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			log.Error("flushing traces", "error", err)
+		}
+	}()
 
 	// Initialize store with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Database.ConnectTimeout)
 	defer cancel()
 
-	db, err := store.New(ctx, os.Getenv("DATABASE_URL"))
+	db, err := store.New(ctx, cfg.Database.URL, store.Options{
+		MaxConns:        int32(cfg.Database.MaxConns),
+		MinConns:        int32(cfg.Database.MinConns),
+		MaxConnLifetime: cfg.Database.MaxConnLifetime,
+		MaxConnIdleTime: cfg.Database.MaxConnIdleTime,
+	})
 	if err != nil {
 		return fmt.Errorf("failed to connect to database: %w", err)
 	}
 	defer db.Close()
 
-	server := api.NewHandler(db, log)
+	notifier := notify.NewLogNotifier(log)
+
+	// The fake provider lets development and test setups complete payments
+	// by posting webhooks signed with this secret.
+	var providers []payment.Provider
+	if secret := cfg.Payments.FakeSecret; secret != "" {
+		providers = append(providers, payment.NewFake(secret))
+	}
+	payments := payment.NewRegistry(providers...)
+
+	var rateLimits ratelimit.Backend
+	switch cfg.RateLimit.Backend {
+	case config.RateLimitMemory:
+		rateLimits = ratelimit.NewMemory()
+	case config.RateLimitPostgres:
+		rateLimits = ratelimit.NewPostgres(db)
+	}
 
-	serverErrors := make(chan error, 1)
+	// Without a reverse proxy in front, the server terminates TLS itself
+	// and picks up renewed certificates as they are written.
+	var tlsConfig *tls.Config
+	if cfg.TLS.Enabled() {
+		reloader, err := certs.NewReloader(cfg.TLS.CertFile, cfg.TLS.KeyFile, log)
+		if err != nil {
+			return err
+		}
+		watchCtx, stopWatching := context.WithCancel(context.Background())
+		defer stopWatching()
+		go reloader.Watch(watchCtx, cfg.TLS.ReloadInterval)
+		tlsConfig = reloader.TLSConfig()
+	}
+
+	server := api.NewHandler(db, notifier, payments, log, api.Options{
+		DrainDelay:   cfg.Server.DrainDelay,
+		RateLimits:   rateLimits,
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
+		Docs:         cfg.Features.Docs,
+		CORS: api.CORSOptions{
+			AllowedOrigins:   cfg.CORS.AllowedOrigins,
+			AllowedMethods:   cfg.CORS.AllowedMethods,
+			AllowCredentials: cfg.CORS.AllowCredentials,
+			MaxAge:           cfg.CORS.MaxAge,
+		},
+		HSTSMaxAge:     cfg.Server.HSTSMaxAge,
+		MaxBodyBytes:   int64(cfg.Server.MaxBodyBytes),
+		TLS:            tlsConfig,
+		TrustedProxies: cfg.Server.TrustedProxies,
+	})
+
+	if err := metrics.RegisterStore(db); err != nil {
+		return fmt.Errorf("registering store metrics: %w", err)
+	}
+	admin := newAdminServer(cfg.Server.AdminAddr, server)
+
+	scheduler := jobs.NewScheduler(db, log, jobs.Options{})
+	if err := registerJobs(scheduler, db, notifier, log); err != nil {
+		return fmt.Errorf("registering jobs: %w", err)
+	}
+	if cfg.Features.Jobs {
+		scheduler.Start()
+	} else {
+		log.Info("jobs disabled by FEATURE_JOBS")
+	}
+
+	serverErrors := make(chan error, 2)
+	go func() {
+		log.Info("starting admin server", "addr", cfg.Server.AdminAddr)
+		if err := admin.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErrors <- fmt.Errorf("admin server: %w", err)
+		}
+	}()
 	go func() {
 		log.Info("starting server",
-			"port", os.Getenv("PORT"),
-			"env", env,
+			"port", cfg.Server.Port,
+			"env", cfg.Env,
+			"tls", cfg.TLS.Enabled(),
 		)
-		serverErrors <- server.Run(":" + os.Getenv("PORT"))
+		serverErrors <- server.Run(cfg.Server.Addr())
 	}()
 
 	shutdown := make(chan os.Signal, 1)
@@ -73,20 +184,37 @@ func run() error {
 
 	select {
 	case err := <-serverErrors:
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+		defer cancel()
+		scheduler.Stop(ctx)
+		admin.Close()
 		return fmt.Errorf("server error: %w", err)
 
 	case sig := <-shutdown:
 		log.Info("shutdown signal received", "signal", sig)
 
 		// Give outstanding requests a deadline for completion
-		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.DrainDelay+cfg.Server.ShutdownTimeout)
 		defer cancel()
 
 		// Shutdown gracefully
 		if err := server.Shutdown(ctx); err != nil {
 			server.Close()
+			scheduler.Stop(ctx)
+			admin.Close()
 			return fmt.Errorf("could not stop server gracefully: %w", err)
 		}
+
+		// Let running jobs finish before the pool closes
+		if err := scheduler.Stop(ctx); err != nil {
+			admin.Close()
+			return fmt.Errorf("could not stop jobs gracefully: %w", err)
+		}
+
+		// Metrics stay scrapeable until the rest has drained
+		if err := admin.Shutdown(ctx); err != nil {
+			return fmt.Errorf("could not stop admin server gracefully: %w", err)
+		}
 	}
 
 	return nil
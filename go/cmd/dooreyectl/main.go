@@ -0,0 +1,161 @@
+// Command dooreyectl runs operational tasks directly against the database,
+// such as creating the first ADMIN, which the API cannot do for lack of an
+// authenticated caller.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+
+	"dooreye-backend/internal/config"
+	"dooreye-backend/internal/store"
+)
+
+// errUsage is returned by commands given bad arguments; their flag set has
+// already printed what was wrong.
+var errUsage = errors.New("usage")
+
+// env is what every command runs with. The database is connected on
+// first use, so bad flags and -h do not need one.
+type env struct {
+	configFile string
+	out        *printer
+	db         *store.DB
+}
+
+func (e *env) connect(ctx context.Context) (*store.DB, error) {
+	if e.db != nil {
+		return e.db, nil
+	}
+
+	cfg, err := config.Load(e.configFile)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Database.URL == "" {
+		return nil, errors.New("DATABASE_URL is required")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.Database.ConnectTimeout)
+	defer cancel()
+
+	db, err := store.New(ctx, cfg.Database.URL, store.Options{MaxConns: 2})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	e.db = db
+	return db, nil
+}
+
+func (e *env) close() {
+	if e.db != nil {
+		e.db.Close()
+	}
+}
+
+type command struct {
+	name    string
+	summary string
+	run     func(ctx context.Context, e *env, args []string) error
+}
+
+var commands = []command{
+	{"bootstrap-admin", "create the first ADMIN user and print their access code", bootstrapAdmin},
+	{"user list", "list users", listUsers},
+	{"user deactivate", "deactivate a user and sign out their devices", deactivateUser},
+	{"user rebind-device", "replace a user's devices with a new device ID", rebindDevice},
+	{"access-code generate", "generate unused access codes", generateAccessCodes},
+	{"society create", "create a society", createSociety},
+	{"export", "export a society's data as JSON", exportSociety},
+	{"seed", "generate demo societies, users and visit history", seedData},
+}
+
+func main() {
+	configFile := flag.String("config", "", "dotenv `file` to read settings from (default .env.$GO_ENV, or .env in production)")
+	format := flag.String("o", formatTable, "output `format`: table or json")
+	flag.Usage = usage
+	flag.Parse()
+
+	cmd, args, ok := findCommand(flag.Args())
+	if !ok {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	out, err := newPrinter(os.Stdout, *format)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	e := &env{configFile: *configFile, out: out}
+	err = cmd.run(ctx, e, args)
+	e.close()
+
+	switch {
+	case errors.Is(err, errUsage):
+		os.Exit(2)
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "%s: %v\n", cmd.name, err)
+		os.Exit(1)
+	}
+}
+
+// findCommand matches the longest command name at the start of args.
+func findCommand(args []string) (command, []string, bool) {
+	for n := 2; n >= 1; n-- {
+		if len(args) < n {
+			continue
+		}
+		name := strings.Join(args[:n], " ")
+		for _, c := range commands {
+			if c.name == name {
+				return c, args[n:], true
+			}
+		}
+	}
+	return command{}, nil, false
+}
+
+func usage() {
+	w := flag.CommandLine.Output()
+	fmt.Fprintf(w, "Usage: %s [flags] <command> [command flags]\n\nCommands:\n", os.Args[0])
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-22s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintf(w, "\nRun a command with -h for its flags.\n\nFlags:\n")
+	flag.PrintDefaults()
+}
+
+// newFlagSet returns a flag set for a command that returns errors rather
+// than exiting, so the database is closed on the way out.
+func newFlagSet(name, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s %s %s\n", os.Args[0], name, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parse parses a command's flags and checks it got want positional
+// arguments.
+func parse(fs *flag.FlagSet, args []string, want int) error {
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if fs.NArg() != want {
+		fmt.Fprintf(fs.Output(), "expected %d argument(s), got %d\n", want, fs.NArg())
+		fs.Usage()
+		return errUsage
+	}
+	return nil
+}
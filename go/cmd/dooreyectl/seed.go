@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"dooreye-backend/internal/seed"
+)
+
+// seedData fills the database with demo data for development, QA and load
+// tests. Run it against a migrated database; it adds to whatever is there.
+func seedData(ctx context.Context, e *env, args []string) error {
+	fs := newFlagSet("seed", "[-seed N] [size flags] [-until TIME]")
+	var opts seed.Options
+	fs.Uint64Var(&opts.Seed, "seed", 1, "random seed; the same seed, sizes and -until give the same data")
+	fs.IntVar(&opts.Cities, "cities", 1, "cities to create")
+	fs.IntVar(&opts.SocietiesPerCity, "societies", 2, "societies per city")
+	fs.IntVar(&opts.BlocksPerSociety, "blocks", 4, "blocks per society")
+	fs.IntVar(&opts.FloorsPerBlock, "floors", 8, "floors per block")
+	fs.IntVar(&opts.UnitsPerFloor, "units", 4, "residences per floor")
+	fs.IntVar(&opts.GuardsPerSociety, "guards", 4, "security guards per society")
+	fs.IntVar(&opts.Months, "months", 3, "months of visit history")
+	fs.Float64Var(&opts.VisitsPerDay, "visits-per-day", 2.5, "average visits per occupied residence a day, besides domestic staff")
+	until := fs.String("until", "", "end of the history, as RFC 3339 or YYYY-MM-DD (default now)")
+	if err := parse(fs, args, 0); err != nil {
+		return err
+	}
+
+	if *until != "" {
+		t, err := parseTime(*until)
+		if err != nil {
+			return err
+		}
+		opts.Until = t
+	}
+
+	db, err := e.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	summary, err := seed.Run(ctx, db, opts)
+	if err != nil {
+		return err
+	}
+
+	if e.out.format == formatJSON {
+		return e.out.json(summary)
+	}
+
+	tables := make([]string, 0, len(summary.Counts))
+	for t := range summary.Counts {
+		tables = append(tables, t)
+	}
+	sort.Strings(tables)
+
+	rows := make([][]string, 0, len(tables))
+	for _, t := range tables {
+		rows = append(rows, []string{t, strconv.FormatInt(summary.Counts[t], 10)})
+	}
+	if err := e.out.print(nil, []string{"TABLE", "ROWS"}, rows); err != nil {
+		return err
+	}
+	fmt.Fprintf(e.out.w, "\nseeded in %s; sign in with these device IDs:\n\n", time.Since(start).Round(time.Millisecond))
+
+	rows = rows[:0]
+	for _, l := range summary.Logins {
+		rows = append(rows, []string{string(l.Role), l.Name, optInt(l.SocietyID), l.DeviceID})
+	}
+	return e.out.print(nil, []string{"ROLE", "NAME", "SOCIETY", "DEVICE ID"}, rows)
+}
+
+func parseTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("-until: want RFC 3339 or YYYY-MM-DD, got %q", s)
+	}
+	return t, nil
+}
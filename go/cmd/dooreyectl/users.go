@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"dooreye-backend/internal/accesscode"
+	"dooreye-backend/internal/model"
+	"dooreye-backend/internal/store"
+)
+
+// codeAttempts bounds retries when a generated access code is taken.
+const codeAttempts = 5
+
+// bootstrapAdmin creates a pending ADMIN. The admin activates the account
+// by entering the printed code in the app, which binds their device the
+// same way as for any invited user.
+func bootstrapAdmin(ctx context.Context, e *env, args []string) error {
+	fs := newFlagSet("bootstrap-admin", "-name NAME [-force]")
+	name := fs.String("name", "", "the admin's name")
+	force := fs.Bool("force", false, "create an admin even if one already exists")
+	if err := parse(fs, args, 0); err != nil {
+		return err
+	}
+	if *name == "" {
+		fmt.Fprintln(fs.Output(), "-name is required")
+		fs.Usage()
+		return errUsage
+	}
+
+	db, err := e.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !*force {
+		role := model.RoleAdmin
+		admins, err := db.ListUsers(ctx, store.ListUsersParams{Role: &role})
+		if err != nil {
+			return err
+		}
+		for _, a := range admins {
+			if a.DeactivatedAt == nil {
+				return fmt.Errorf("admin %s (%s) already exists; use the API, or -force", a.Name, a.ID)
+			}
+		}
+	}
+
+	var user *store.User
+	for range codeAttempts {
+		code, err := accesscode.Generate()
+		if err != nil {
+			return err
+		}
+
+		user, err = db.CreatePendingUser(ctx, store.CreatePendingUserParams{
+			AccessCode: code,
+			Name:       *name,
+			Role:       model.RoleAdmin,
+		})
+		if errors.Is(err, store.ErrDuplicateAccessCode) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		break
+	}
+	if user == nil {
+		return fmt.Errorf("no unused access code after %d attempts", codeAttempts)
+	}
+
+	return e.out.print(user,
+		[]string{"ID", "NAME", "ROLE", "ACCESS CODE"},
+		[][]string{{user.ID, user.Name, user.Role, optString(user.AccessCode)}})
+}
+
+// userView is a user as listed, without the access code: until it is
+// redeemed the code signs in as the user.
+type userView struct {
+	ID            string     `json:"id"`
+	Name          string     `json:"name"`
+	ResidenceID   *int64     `json:"residence_id,omitempty"`
+	SocietyID     *int64     `json:"society_id,omitempty"`
+	Role          string     `json:"role"`
+	IsActive      bool       `json:"is_active"`
+	InvitedBy     *string    `json:"invited_by,omitempty"`
+	ActivatedBy   *string    `json:"activated_by"`
+	ActivatedAt   *time.Time `json:"activated_at"`
+	DeactivatedAt *time.Time `json:"deactivated_at,omitempty"`
+}
+
+func newUserView(u *store.User) userView {
+	return userView{
+		ID:            u.ID,
+		Name:          u.Name,
+		ResidenceID:   u.ResidenceID,
+		SocietyID:     u.SocietyID,
+		Role:          u.Role,
+		IsActive:      u.IsActive,
+		InvitedBy:     u.InvitedBy,
+		ActivatedBy:   u.ActivatedBy,
+		ActivatedAt:   u.ActivatedAt,
+		DeactivatedAt: u.DeactivatedAt,
+	}
+}
+
+func listUsers(ctx context.Context, e *env, args []string) error {
+	fs := newFlagSet("user list", "[-society ID] [-role ROLE] [-active]")
+	societyID := fs.Int64("society", 0, "only users of this society")
+	role := fs.String("role", "", "only users with this role")
+	active := fs.Bool("active", false, "only active users")
+	if err := parse(fs, args, 0); err != nil {
+		return err
+	}
+
+	params := store.ListUsersParams{ActiveOnly: *active}
+	if *societyID != 0 {
+		params.SocietyID = societyID
+	}
+	if *role != "" {
+		r := model.UserRole(*role)
+		if !r.Valid() {
+			return fmt.Errorf("unknown role %q", *role)
+		}
+		params.Role = &r
+	}
+
+	db, err := e.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	users, err := db.ListUsers(ctx, params)
+	if err != nil {
+		return err
+	}
+
+	views := make([]userView, len(users))
+	rows := make([][]string, len(users))
+	for i, u := range users {
+		views[i] = newUserView(&u)
+		rows[i] = []string{
+			u.ID, u.Name, u.Role,
+			optInt(u.SocietyID), optInt(u.ResidenceID),
+			strconv.FormatBool(u.IsActive), optTime(u.ActivatedAt), optTime(u.DeactivatedAt),
+		}
+	}
+	return e.out.print(views,
+		[]string{"ID", "NAME", "ROLE", "SOCIETY", "RESIDENCE", "ACTIVE", "ACTIVATED", "DEACTIVATED"},
+		rows)
+}
+
+func deactivateUser(ctx context.Context, e *env, args []string) error {
+	fs := newFlagSet("user deactivate", "USER_ID")
+	if err := parse(fs, args, 1); err != nil {
+		return err
+	}
+	userID := fs.Arg(0)
+
+	db, err := e.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := db.DeactivateUser(ctx, userID); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return fmt.Errorf("no active user %s", userID)
+		}
+		return err
+	}
+
+	user, err := db.GetUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	return e.out.print(newUserView(user),
+		[]string{"ID", "NAME", "ACTIVE", "DEACTIVATED"},
+		[][]string{{user.ID, user.Name, strconv.FormatBool(user.IsActive), optTime(user.DeactivatedAt)}})
+}
+
+// rebindDevice is for users who replaced their phone: the old devices are
+// signed out and the new one signs in as the user.
+func rebindDevice(ctx context.Context, e *env, args []string) error {
+	fs := newFlagSet("user rebind-device", "-device-id DEVICE_ID USER_ID")
+	deviceID := fs.String("device-id", "", "the new device's ID")
+	if err := parse(fs, args, 1); err != nil {
+		return err
+	}
+	if *deviceID == "" {
+		fmt.Fprintln(fs.Output(), "-device-id is required")
+		fs.Usage()
+		return errUsage
+	}
+	userID := fs.Arg(0)
+
+	db, err := e.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	if _, err := db.GetUser(ctx, userID); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return fmt.Errorf("no user %s", userID)
+		}
+		return err
+	}
+
+	device, err := db.RebindUserDevice(ctx, userID, *deviceID)
+	if err != nil {
+		return err
+	}
+
+	return e.out.print(device,
+		[]string{"ID", "USER", "DEVICE ID", "CREATED"},
+		[][]string{{strconv.FormatInt(device.ID, 10), device.UserID.String(), device.DeviceID, optTime(&device.CreatedAt)}})
+}
+
+// generateAccessCodes prints codes no user holds yet, for handing to the
+// createUser endpoint or an invite.
+func generateAccessCodes(ctx context.Context, e *env, args []string) error {
+	fs := newFlagSet("access-code generate", "[-n COUNT]")
+	count := fs.Int("n", 1, "how many codes to generate")
+	if err := parse(fs, args, 0); err != nil {
+		return err
+	}
+	if *count < 1 || *count > 1000 {
+		return fmt.Errorf("-n must be between 1 and 1000")
+	}
+
+	db, err := e.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool, *count)
+	codes := make([]string, 0, *count)
+	for attempts := 0; len(codes) < *count; attempts++ {
+		if attempts >= *count*codeAttempts {
+			return fmt.Errorf("no unused access code after %d attempts", attempts)
+		}
+
+		code, err := accesscode.Generate()
+		if err != nil {
+			return err
+		}
+		if seen[code] {
+			continue
+		}
+		inUse, err := db.AccessCodeInUse(ctx, code)
+		if err != nil {
+			return err
+		}
+		if inUse {
+			continue
+		}
+
+		seen[code] = true
+		codes = append(codes, code)
+	}
+
+	rows := make([][]string, len(codes))
+	for i, code := range codes {
+		rows[i] = []string{code}
+	}
+	return e.out.print(codes, []string{"ACCESS CODE"}, rows)
+}
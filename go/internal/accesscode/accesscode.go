@@ -0,0 +1,30 @@
+package accesscode
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+)
+
+// Length matches the users.access_code CHAR(8) column.
+const Length = 8
+
+// alphabet leaves out characters that are easy to misread when a code is
+// read out over the phone (0/O, 1/I/L).
+const alphabet = "ABCDEFGHJKMNPQRSTUVWXYZ23456789"
+
+// Generate returns a random access code.
+func Generate() (string, error) {
+	code := make([]byte, Length)
+	max := big.NewInt(int64(len(alphabet)))
+
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", fmt.Errorf("generating access code: %w", err)
+		}
+		code[i] = alphabet[n.Int64()]
+	}
+
+	return string(code), nil
+}
@@ -0,0 +1,135 @@
+package alerts
+
+import (
+	"context"
+	"dooreye-backend/internal/model"
+	"dooreye-backend/internal/notify"
+	"dooreye-backend/internal/store"
+	"errors"
+	"fmt"
+	"log/slog"
+)
+
+var ErrGateNotInSociety = errors.New("gate does not belong to this society")
+
+// Service raises emergency alerts and pages the people responsible for
+// handling them.
+type Service struct {
+	db       *store.DB
+	notifier notify.Notifier
+	log      *slog.Logger
+}
+
+func NewService(db *store.DB, notifier notify.Notifier, log *slog.Logger) *Service {
+	return &Service{
+		db:       db,
+		notifier: notifier,
+		log:      log.With("component", "alerts"),
+	}
+}
+
+// Raise stores the alert and fans it out to every guard and manager of the
+// society at critical priority.
+func (s *Service) Raise(ctx context.Context, params store.CreateAlertParams) (*model.Alert, error) {
+	if params.GateID != nil {
+		gate, err := s.db.GetGate(ctx, *params.GateID)
+		if err != nil {
+			return nil, err
+		}
+		if gate.SocietyID != params.SocietyID {
+			return nil, ErrGateNotInSociety
+		}
+	}
+
+	alert, err := s.db.CreateAlert(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.page(ctx, alert, 0, model.RoleSecurity, model.RoleSocietyManager); err != nil {
+		// The alert is stored and will be escalated; don't fail the panic
+		// button because a push provider hiccupped.
+		s.log.ErrorContext(ctx, "paging for alert", "alert_id", alert.ID, "error", err)
+	}
+
+	return alert, nil
+}
+
+// Escalate is the periodic job entry point. It re-pages a wider audience for
+// every alert nobody has acknowledged within its next step's delay.
+func (s *Service) Escalate(ctx context.Context, _ *model.Job) error {
+	alerts, steps, err := s.db.GetAlertsDueForEscalation(ctx)
+	if err != nil {
+		return err
+	}
+
+	for i, alert := range alerts {
+		step := steps[i]
+
+		// Claim the step first so concurrent runs don't page twice.
+		if err := s.db.MarkAlertEscalated(ctx, alert.ID.String(), step.Level); err != nil {
+			if !errors.Is(err, store.ErrNotFound) {
+				s.log.ErrorContext(ctx, "escalating alert", "alert_id", alert.ID, "error", err)
+			}
+			continue
+		}
+
+		s.log.WarnContext(ctx, "alert unacknowledged, escalating", "alert_id", alert.ID, "level", step.Level)
+		if err := s.page(ctx, &alert, step.Level, step.Roles...); err != nil {
+			s.log.ErrorContext(ctx, "paging for escalated alert", "alert_id", alert.ID, "error", err)
+		}
+	}
+
+	return nil
+}
+
+func (s *Service) page(ctx context.Context, alert *model.Alert, level int, roles ...model.UserRole) error {
+	var recipients []string
+
+	for _, role := range roles {
+		if role == model.RoleAdmin {
+			ids, err := s.db.GetUserIDsByRole(ctx, model.RoleAdmin)
+			if err != nil {
+				return err
+			}
+			recipients = append(recipients, ids...)
+			continue
+		}
+
+		ids, err := s.db.GetSocietyUserIDsByRole(ctx, alert.SocietyID, role)
+		if err != nil {
+			return err
+		}
+		recipients = append(recipients, ids...)
+	}
+
+	title := fmt.Sprintf("%s alert", alert.Type)
+	if level > 0 {
+		title = fmt.Sprintf("UNACKNOWLEDGED %s alert (escalation %d)", alert.Type, level)
+	}
+
+	body := "Emergency raised"
+	if alert.Message != nil {
+		body = *alert.Message
+	}
+
+	data := map[string]string{
+		"alert_id": alert.ID.String(),
+		"type":     string(alert.Type),
+	}
+	if alert.ResidenceID != nil {
+		data["residence_id"] = fmt.Sprint(*alert.ResidenceID)
+	}
+	if alert.GateID != nil {
+		data["gate_id"] = fmt.Sprint(*alert.GateID)
+	}
+
+	return s.notifier.Send(ctx, notify.Message{
+		UserIDs:  recipients,
+		Kind:     "ALERT",
+		Title:    title,
+		Body:     body,
+		Priority: notify.PriorityCritical,
+		Data:     data,
+	})
+}
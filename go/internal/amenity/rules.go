@@ -0,0 +1,146 @@
+// Package amenity holds the booking rules for society amenities. Times are
+// judged in the society's local time zone.
+package amenity
+
+import (
+	"dooreye-backend/internal/model"
+	"errors"
+	"fmt"
+	"time"
+
+	// Embedded so operating hours work on hosts without zoneinfo.
+	_ "time/tzdata"
+)
+
+var (
+	ErrOutsideHours     = errors.New("booking is outside the amenity's operating hours")
+	ErrNotSlotAligned   = errors.New("booking must start and end on slot boundaries")
+	ErrInPast           = errors.New("booking cannot start in the past")
+	ErrTooFarAhead      = errors.New("booking is beyond the advance booking window")
+	ErrCancelNotAllowed = errors.New("this amenity's bookings can only be cancelled by the society manager")
+	ErrCancelTooLate    = errors.New("the cancellation window for this booking has closed")
+)
+
+// Location resolves the amenity's society time zone.
+func Location(a *model.Amenity) (*time.Location, error) {
+	loc, err := time.LoadLocation(a.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("loading time zone %q: %w", a.Timezone, err)
+	}
+	return loc, nil
+}
+
+// CheckBooking validates a requested [from, to) against the amenity's hours,
+// slot size and advance window. Per-week limits need the database and are
+// enforced by the store.
+func CheckBooking(a *model.Amenity, from, to, now time.Time) error {
+	loc, err := Location(a)
+	if err != nil {
+		return err
+	}
+
+	from, to, now = from.In(loc), to.In(loc), now.In(loc)
+
+	if !to.After(from) {
+		return ErrNotSlotAligned
+	}
+
+	if from.Before(now) {
+		return ErrInPast
+	}
+
+	day := midnight(from)
+	opens := at(day, a.OpensAt)
+	closes := at(day, a.ClosesAt)
+	if from.Before(opens) || to.After(closes) {
+		return ErrOutsideHours
+	}
+
+	slot := time.Duration(a.SlotMinutes) * time.Minute
+	if from.Sub(opens)%slot != 0 || to.Sub(from)%slot != 0 {
+		return ErrNotSlotAligned
+	}
+
+	if !from.Before(midnight(now).AddDate(0, 0, a.AdvanceDays+1)) {
+		return ErrTooFarAhead
+	}
+
+	return nil
+}
+
+// Week returns the Monday to Monday local week containing t, the window the
+// max_per_week rule counts bookings in.
+func Week(a *model.Amenity, t time.Time) (time.Time, time.Time, error) {
+	loc, err := Location(a)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	day := midnight(t.In(loc))
+	offset := (int(day.Weekday()) + 6) % 7
+	start := day.AddDate(0, 0, -offset)
+
+	return start, start.AddDate(0, 0, 7), nil
+}
+
+// Day returns the local opening and closing instants of the amenity on the
+// given calendar date.
+func Day(a *model.Amenity, date time.Time) (time.Time, time.Time, error) {
+	loc, err := Location(a)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	day := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, loc)
+	return at(day, a.OpensAt), at(day, a.ClosesAt), nil
+}
+
+// Slots splits a day's operating hours into slots and counts the units
+// still free in each, given that day's confirmed bookings.
+func Slots(a *model.Amenity, date time.Time, bookings []model.AmenityBooking) ([]model.AmenitySlot, error) {
+	opens, closes, err := Day(a, date)
+	if err != nil {
+		return nil, err
+	}
+
+	slot := time.Duration(a.SlotMinutes) * time.Minute
+	var slots []model.AmenitySlot
+	for start := opens; !start.Add(slot).After(closes); start = start.Add(slot) {
+		end := start.Add(slot)
+		free := a.Capacity
+		for _, b := range bookings {
+			if b.Status == model.AmenityBookingConfirmed && b.StartsAt.Before(end) && b.EndsAt.After(start) {
+				free--
+			}
+		}
+		if free < 0 {
+			free = 0
+		}
+		slots = append(slots, model.AmenitySlot{StartsAt: start, EndsAt: end, Free: free})
+	}
+
+	return slots, nil
+}
+
+// CheckCancel applies the amenity's cancellation policy to a resident
+// cancelling their own booking. Managers are not bound by it.
+func CheckCancel(a *model.Amenity, b *model.AmenityBooking, now time.Time) error {
+	if a.CancelCutoffHours == nil {
+		return ErrCancelNotAllowed
+	}
+
+	cutoff := b.StartsAt.Add(-time.Duration(*a.CancelCutoffHours) * time.Hour)
+	if now.After(cutoff) {
+		return ErrCancelTooLate
+	}
+
+	return nil
+}
+
+func midnight(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+func at(day time.Time, clock model.ClockTime) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), int(clock)/60, int(clock)%60, 0, 0, day.Location())
+}
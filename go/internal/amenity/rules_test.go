@@ -0,0 +1,180 @@
+package amenity
+
+import (
+	"dooreye-backend/internal/model"
+	"errors"
+	"testing"
+	"time"
+)
+
+var kolkata, _ = time.LoadLocation("Asia/Kolkata")
+
+// clubhouse opens 06:00 to 22:00 Kolkata time in one-hour slots, bookable
+// up to 7 days ahead.
+func clubhouse() *model.Amenity {
+	return &model.Amenity{
+		ID:          1,
+		Capacity:    2,
+		SlotMinutes: 60,
+		OpensAt:     6 * 60,
+		ClosesAt:    22 * 60,
+		AdvanceDays: 7,
+		Timezone:    "Asia/Kolkata",
+	}
+}
+
+func local(day, hour, minute int) time.Time {
+	return time.Date(2026, 3, day, hour, minute, 0, 0, kolkata)
+}
+
+func TestCheckBooking(t *testing.T) {
+	// Monday 2 March 2026, 09:15 local.
+	now := local(2, 9, 15)
+
+	tests := []struct {
+		name     string
+		from, to time.Time
+		err      error
+	}{
+		{"later today", local(2, 10, 0), local(2, 12, 0), nil},
+		{"last day of the window", local(9, 21, 0), local(9, 22, 0), nil},
+		{"first day past the window", local(10, 6, 0), local(10, 7, 0), ErrTooFarAhead},
+		{"already started", local(2, 9, 0), local(2, 10, 0), ErrInPast},
+		{"before opening", local(3, 5, 0), local(3, 7, 0), ErrOutsideHours},
+		{"past closing", local(3, 21, 0), local(3, 23, 0), ErrOutsideHours},
+		{"ending at closing", local(3, 20, 0), local(3, 22, 0), nil},
+		{"off the slot grid", local(3, 10, 30), local(3, 11, 30), ErrNotSlotAligned},
+		{"partial slot", local(3, 10, 0), local(3, 10, 45), ErrNotSlotAligned},
+		{"empty", local(3, 10, 0), local(3, 10, 0), ErrNotSlotAligned},
+		{"reversed", local(3, 11, 0), local(3, 10, 0), ErrNotSlotAligned},
+		// 00:30 UTC on the 3rd is 06:00 in Kolkata, so hours are judged in
+		// the society's zone rather than the caller's.
+		{"given in UTC", time.Date(2026, 3, 3, 0, 30, 0, 0, time.UTC),
+			time.Date(2026, 3, 3, 1, 30, 0, 0, time.UTC), nil},
+	}
+
+	for _, tt := range tests {
+		if err := CheckBooking(clubhouse(), tt.from, tt.to, now); !errors.Is(err, tt.err) {
+			t.Errorf("%s: CheckBooking = %v, want %v", tt.name, err, tt.err)
+		}
+	}
+
+	bad := clubhouse()
+	bad.Timezone = "Mars/Olympus_Mons"
+	if err := CheckBooking(bad, local(3, 10, 0), local(3, 11, 0), now); err == nil {
+		t.Error("CheckBooking accepted an unknown time zone")
+	}
+}
+
+// TestWeek covers the window the per-residence weekly quota counts
+// bookings in.
+func TestWeek(t *testing.T) {
+	monday, nextMonday := local(2, 0, 0), local(9, 0, 0)
+
+	tests := []struct {
+		name string
+		t    time.Time
+		from time.Time
+		to   time.Time
+	}{
+		{"monday midnight", local(2, 0, 0), monday, nextMonday},
+		{"midweek", local(4, 13, 0), monday, nextMonday},
+		{"sunday night", local(8, 23, 59), monday, nextMonday},
+		{"next monday", local(9, 0, 0), nextMonday, nextMonday.AddDate(0, 0, 7)},
+		// 20:00 UTC on Sunday is already Monday 01:30 in Kolkata.
+		{"sunday in UTC", time.Date(2026, 3, 8, 20, 0, 0, 0, time.UTC), nextMonday, nextMonday.AddDate(0, 0, 7)},
+	}
+
+	for _, tt := range tests {
+		from, to, err := Week(clubhouse(), tt.t)
+		if err != nil {
+			t.Errorf("%s: Week: %v", tt.name, err)
+			continue
+		}
+		if !from.Equal(tt.from) || !to.Equal(tt.to) {
+			t.Errorf("%s: Week = [%s, %s), want [%s, %s)", tt.name, from, to, tt.from, tt.to)
+		}
+	}
+}
+
+// TestSlots checks how overlapping bookings use up a slot's capacity.
+func TestSlots(t *testing.T) {
+	booking := func(from, to time.Time, status model.AmenityBookingStatus) model.AmenityBooking {
+		return model.AmenityBooking{StartsAt: from, EndsAt: to, Status: status}
+	}
+
+	tests := []struct {
+		name     string
+		bookings []model.AmenityBooking
+		// free maps a slot's starting hour to its free units; other slots
+		// are fully free.
+		free map[int]int
+	}{
+		{"no bookings", nil, nil},
+		{"one booking", []model.AmenityBooking{
+			booking(local(3, 10, 0), local(3, 11, 0), model.AmenityBookingConfirmed),
+		}, map[int]int{10: 1}},
+		{"two hours", []model.AmenityBooking{
+			booking(local(3, 10, 0), local(3, 12, 0), model.AmenityBookingConfirmed),
+		}, map[int]int{10: 1, 11: 1}},
+		{"overlapping bookings", []model.AmenityBooking{
+			booking(local(3, 10, 0), local(3, 12, 0), model.AmenityBookingConfirmed),
+			booking(local(3, 11, 0), local(3, 13, 0), model.AmenityBookingConfirmed),
+		}, map[int]int{10: 1, 11: 0, 12: 1}},
+		{"over capacity", []model.AmenityBooking{
+			booking(local(3, 10, 0), local(3, 11, 0), model.AmenityBookingConfirmed),
+			booking(local(3, 10, 0), local(3, 11, 0), model.AmenityBookingConfirmed),
+			booking(local(3, 10, 0), local(3, 11, 0), model.AmenityBookingConfirmed),
+		}, map[int]int{10: 0}},
+		{"cancelled", []model.AmenityBooking{
+			booking(local(3, 10, 0), local(3, 11, 0), model.AmenityBookingCancelled),
+		}, nil},
+	}
+
+	for _, tt := range tests {
+		slots, err := Slots(clubhouse(), local(3, 0, 0), tt.bookings)
+		if err != nil {
+			t.Errorf("%s: Slots: %v", tt.name, err)
+			continue
+		}
+		if len(slots) != 16 {
+			t.Errorf("%s: got %d slots, want 16", tt.name, len(slots))
+			continue
+		}
+
+		for _, s := range slots {
+			want, ok := tt.free[s.StartsAt.Hour()]
+			if !ok {
+				want = 2
+			}
+			if s.Free != want {
+				t.Errorf("%s: slot at %02d:00 has %d free, want %d", tt.name, s.StartsAt.Hour(), s.Free, want)
+			}
+		}
+	}
+}
+
+func TestCheckCancel(t *testing.T) {
+	cutoff := 24
+	b := &model.AmenityBooking{StartsAt: local(5, 18, 0)}
+
+	tests := []struct {
+		name   string
+		cutoff *int
+		now    time.Time
+		err    error
+	}{
+		{"well ahead", &cutoff, local(3, 12, 0), nil},
+		{"at the cutoff", &cutoff, local(4, 18, 0), nil},
+		{"past the cutoff", &cutoff, local(4, 18, 1), ErrCancelTooLate},
+		{"no policy", nil, local(3, 12, 0), ErrCancelNotAllowed},
+	}
+
+	for _, tt := range tests {
+		a := clubhouse()
+		a.CancelCutoffHours = tt.cutoff
+		if err := CheckCancel(a, b, tt.now); !errors.Is(err, tt.err) {
+			t.Errorf("%s: CheckCancel = %v, want %v", tt.name, err, tt.err)
+		}
+	}
+}
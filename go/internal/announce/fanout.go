@@ -0,0 +1,109 @@
+// Package announce delivers published announcements to their audience.
+package announce
+
+import (
+	"context"
+	"dooreye-backend/internal/model"
+	"dooreye-backend/internal/notify"
+	"dooreye-backend/internal/store"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+)
+
+// batchSize caps the recipients of a single notification so large societies
+// do not produce one enormous message.
+const batchSize = 500
+
+// previewLength is how much of the body goes into the notification.
+const previewLength = 140
+
+type Publisher struct {
+	db       *store.DB
+	notifier notify.Notifier
+	log      *slog.Logger
+}
+
+func NewPublisher(db *store.DB, notifier notify.Notifier, log *slog.Logger) *Publisher {
+	return &Publisher{
+		db:       db,
+		notifier: notifier,
+		log:      log.With("component", "announcements"),
+	}
+}
+
+type fanoutPayload struct {
+	AnnouncementID int64 `json:"announcement_id"`
+}
+
+// Fanout handles store.JobAnnouncementFanout. It runs when the announcement
+// goes live and skips ones withdrawn or already announced by an earlier
+// attempt. Delivery is at least once: a retry after a failed batch resends
+// the batches before it.
+func (p *Publisher) Fanout(ctx context.Context, job *model.Job) error {
+	var payload fanoutPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return fmt.Errorf("decoding fan-out payload: %w", err)
+	}
+
+	a, err := p.db.GetAnnouncement(ctx, payload.AnnouncementID)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if a.WithdrawnAt != nil || a.NotifiedAt != nil || a.PublishedAt == nil {
+		return nil
+	}
+	if a.ExpiresAt != nil && a.ExpiresAt.Before(time.Now()) {
+		return nil
+	}
+
+	recipients, err := p.db.GetAnnouncementRecipients(ctx, a.ID)
+	if err != nil {
+		return err
+	}
+
+	priority := notify.PriorityNormal
+	if a.IsUrgent {
+		priority = notify.PriorityHigh
+	}
+
+	for start := 0; start < len(recipients); start += batchSize {
+		end := start + batchSize
+		if end > len(recipients) {
+			end = len(recipients)
+		}
+
+		err := p.notifier.Send(ctx, notify.Message{
+			UserIDs:  recipients[start:end],
+			Kind:     "ANNOUNCEMENT",
+			Title:    a.Title,
+			Body:     preview(a.Body),
+			Priority: priority,
+			Data: map[string]string{
+				"announcement_id": strconv.FormatInt(a.ID, 10),
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("sending announcement batch: %w", err)
+		}
+	}
+
+	p.log.InfoContext(ctx, "announcement delivered", "announcement_id", a.ID, "recipients", len(recipients))
+
+	return p.db.MarkAnnouncementNotified(ctx, a.ID)
+}
+
+func preview(body string) string {
+	r := []rune(body)
+	if len(r) <= previewLength {
+		return body
+	}
+	return string(r[:previewLength-1]) + "…"
+}
@@ -0,0 +1,232 @@
+package api
+
+import (
+	"dooreye-backend/internal/alerts"
+	"dooreye-backend/internal/model"
+	"dooreye-backend/internal/store"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+)
+
+var ErrAlertLocationRequired = errors.New("residence_id or gate_id is required")
+
+type RaiseAlertRequest struct {
+	Type        model.AlertType `json:"type" binding:"required"`
+	Message     *string         `json:"message"`
+	ResidenceID *int64          `json:"residence_id"`
+	GateID      *int64          `json:"gate_id"`
+}
+
+func (h *Handler) raiseAlert(c *gin.Context) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	var req RaiseAlertRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if !req.Type.Valid() {
+		h.respondError(c, http.StatusBadRequest, fmt.Errorf("invalid alert type %q", req.Type))
+		return
+	}
+
+	params := store.CreateAlertParams{
+		Type:     req.Type,
+		Message:  req.Message,
+		RaisedBy: user.ID,
+	}
+
+	switch user.Role {
+	case model.RoleOwner, model.RoleResident:
+		// A resident's panic button always points at their own home.
+		if user.ResidenceID == nil {
+			h.respondError(c, http.StatusForbidden, ErrUnauthorizedRole)
+			return
+		}
+		params.ResidenceID = user.ResidenceID
+	default:
+		if req.ResidenceID == nil && req.GateID == nil {
+			h.respondError(c, http.StatusBadRequest, ErrAlertLocationRequired)
+			return
+		}
+		params.ResidenceID = req.ResidenceID
+		params.GateID = req.GateID
+	}
+
+	params.SocietyID, err = h.userSocietyID(c, user)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if params.ResidenceID != nil && user.ResidenceID == nil {
+		societyID, err := h.db.GetResidenceSocietyID(c.Request.Context(), *params.ResidenceID)
+		if err != nil || societyID != params.SocietyID {
+			h.respondError(c, http.StatusBadRequest, fmt.Errorf("residence %d is not in this society", *params.ResidenceID))
+			return
+		}
+	}
+
+	alert, err := h.alerts.Raise(c.Request.Context(), params)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, store.ErrNotFound), errors.Is(err, alerts.ErrGateNotInSociety):
+			status = http.StatusBadRequest
+		}
+		h.respondError(c, status, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": alert})
+}
+
+func (h *Handler) getAlerts(c *gin.Context) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	filter := store.AlertFilter{
+		Status:   model.AlertStatus(c.Query("status")),
+		OnlyOpen: c.Query("open") == "true",
+	}
+
+	switch user.Role {
+	case model.RoleOwner, model.RoleResident:
+		if user.ResidenceID == nil {
+			h.respondError(c, http.StatusForbidden, ErrUnauthorizedRole)
+			return
+		}
+		filter.ResidenceID = user.ResidenceID
+	case model.RoleAdmin:
+		if c.Query("society_id") != "" {
+			filter.SocietyID, err = societyIDParam(c, user)
+			if err != nil {
+				h.respondError(c, http.StatusBadRequest, err)
+				return
+			}
+		}
+	default:
+		filter.SocietyID, err = societyIDParam(c, user)
+		if err != nil {
+			h.respondError(c, http.StatusForbidden, err)
+			return
+		}
+	}
+
+	list, err := h.db.GetAlerts(c.Request.Context(), filter)
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": list})
+}
+
+func (h *Handler) getAlert(c *gin.Context) {
+	alert, ok := h.loadAlert(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": alert})
+}
+
+func (h *Handler) acknowledgeAlert(c *gin.Context) {
+	alert, ok := h.loadAlert(c)
+	if !ok {
+		return
+	}
+
+	alert, err := h.db.AcknowledgeAlert(c.Request.Context(), alert.ID.String(), c.GetString("user_id"))
+	if err != nil {
+		h.respondAlertError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": alert})
+}
+
+func (h *Handler) resolveAlert(c *gin.Context) {
+	alert, ok := h.loadAlert(c)
+	if !ok {
+		return
+	}
+
+	// Residents may only stand down their own alert, e.g. a false alarm.
+	role := c.MustGet(string(UserRoleKey)).(model.UserRole)
+	userID := c.GetString("user_id")
+	if (role == model.RoleOwner || role == model.RoleResident) && alert.RaisedBy.String() != userID {
+		h.respondError(c, http.StatusForbidden, ErrUnauthorizedRole)
+		return
+	}
+
+	alert, err := h.db.ResolveAlert(c.Request.Context(), alert.ID.String(), userID)
+	if err != nil {
+		h.respondAlertError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": alert})
+}
+
+// loadAlert fetches the alert named in the path and checks the caller may see
+// it. It writes the error response itself and reports whether to continue.
+func (h *Handler) loadAlert(c *gin.Context) (*model.Alert, bool) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return nil, false
+	}
+
+	id, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, fmt.Errorf("invalid alert id: %w", err))
+		return nil, false
+	}
+
+	alert, err := h.db.GetAlert(c.Request.Context(), id.String())
+	if err != nil {
+		h.respondAlertError(c, err)
+		return nil, false
+	}
+
+	allowed := false
+	switch user.Role {
+	case model.RoleAdmin:
+		allowed = true
+	case model.RoleOwner, model.RoleResident:
+		allowed = user.ResidenceID != nil && alert.ResidenceID != nil && *user.ResidenceID == *alert.ResidenceID
+	default:
+		allowed = user.SocietyID != nil && *user.SocietyID == alert.SocietyID
+	}
+
+	if !allowed {
+		h.respondError(c, http.StatusNotFound, store.ErrNotFound)
+		return nil, false
+	}
+
+	return alert, true
+}
+
+func (h *Handler) respondAlertError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, store.ErrAlertClosed):
+		status = http.StatusConflict
+	}
+	h.respondError(c, status, err)
+}
@@ -0,0 +1,459 @@
+package api
+
+import (
+	"dooreye-backend/internal/amenity"
+	"dooreye-backend/internal/model"
+	"dooreye-backend/internal/store"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxCalendarDays bounds the manager calendar query.
+const maxCalendarDays = 62
+
+type AmenityRequest struct {
+	Name              string           `json:"name" binding:"required,max=100"`
+	Description       *string          `json:"description"`
+	Capacity          int              `json:"capacity" binding:"omitempty,min=1,max=100"`
+	SlotMinutes       int              `json:"slot_minutes" binding:"required,min=5,max=1440"`
+	OpensAt           *model.ClockTime `json:"opens_at" binding:"required"`
+	ClosesAt          *model.ClockTime `json:"closes_at" binding:"required"`
+	MaxPerWeek        *int             `json:"max_per_week" binding:"omitempty,min=1"`
+	AdvanceDays       *int             `json:"advance_days" binding:"omitempty,min=0,max=365"`
+	FeePaise          int64            `json:"fee_paise" binding:"min=0"`
+	CancelCutoffHours *int             `json:"cancel_cutoff_hours" binding:"omitempty,min=0"`
+}
+
+func (r AmenityRequest) params() store.AmenityParams {
+	params := store.AmenityParams{
+		Name:              r.Name,
+		Description:       r.Description,
+		Capacity:          r.Capacity,
+		SlotMinutes:       r.SlotMinutes,
+		OpensAt:           *r.OpensAt,
+		ClosesAt:          *r.ClosesAt,
+		MaxPerWeek:        r.MaxPerWeek,
+		AdvanceDays:       7,
+		FeePaise:          r.FeePaise,
+		CancelCutoffHours: r.CancelCutoffHours,
+	}
+	if params.Capacity == 0 {
+		params.Capacity = 1
+	}
+	if r.AdvanceDays != nil {
+		params.AdvanceDays = *r.AdvanceDays
+	}
+	return params
+}
+
+func (h *Handler) getAmenities(c *gin.Context) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	societyID, err := h.userSocietyID(c, user)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	includeInactive := c.Query("include_inactive") == "true" && isSocietyStaff(user)
+
+	amenities, err := h.db.GetAmenities(c.Request.Context(), societyID, includeInactive)
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": amenities})
+}
+
+func (h *Handler) createAmenity(c *gin.Context) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	var req AmenityRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	societyID, err := societyIDParam(c, user)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	a, err := h.db.CreateAmenity(c.Request.Context(), *societyID, req.params())
+	if err != nil {
+		h.respondError(c, errorStatus(err), err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": a})
+}
+
+func (h *Handler) updateAmenity(c *gin.Context) {
+	a, ok := h.amenityInScope(c)
+	if !ok {
+		return
+	}
+
+	var req AmenityRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	a, err := h.db.UpdateAmenity(c.Request.Context(), a.ID, req.params())
+	if err != nil {
+		h.respondError(c, errorStatus(err), err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": a})
+}
+
+func (h *Handler) deleteAmenity(c *gin.Context) {
+	a, ok := h.amenityInScope(c)
+	if !ok {
+		return
+	}
+
+	if err := h.db.DeactivateAmenity(c.Request.Context(), a.ID); err != nil {
+		h.respondError(c, errorStatus(err), err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// getAmenityAvailability lists a day's slots with the units still free.
+func (h *Handler) getAmenityAvailability(c *gin.Context) {
+	a, ok := h.amenityInScope(c)
+	if !ok {
+		return
+	}
+
+	date, err := amenityDate(a, c.Query("date"))
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	opens, closes, err := amenity.Day(a, date)
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	bookings, err := h.db.GetAmenityBookings(c.Request.Context(), store.AmenityBookingFilter{
+		AmenityID:     &a.ID,
+		From:          &opens,
+		To:            &closes,
+		OnlyConfirmed: true,
+	})
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	slots, err := amenity.Slots(a, date, bookings)
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": slots})
+}
+
+// getAmenityCalendar gives managers every booking of an amenity between two
+// dates, inclusive, with the booking residence.
+func (h *Handler) getAmenityCalendar(c *gin.Context) {
+	a, ok := h.amenityInScope(c)
+	if !ok {
+		return
+	}
+
+	from, err := amenityDate(a, c.Query("from"))
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	to := from.AddDate(0, 0, 6)
+	if raw := c.Query("to"); raw != "" {
+		if to, err = amenityDate(a, raw); err != nil {
+			h.respondError(c, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	if to.Before(from) || to.Sub(from) > maxCalendarDays*24*time.Hour {
+		h.respondError(c, http.StatusBadRequest, fmt.Errorf("to must be on or after from and at most %d days later", maxCalendarDays))
+		return
+	}
+
+	end := to.AddDate(0, 0, 1)
+	bookings, err := h.db.GetAmenityBookings(c.Request.Context(), store.AmenityBookingFilter{
+		AmenityID:     &a.ID,
+		From:          &from,
+		To:            &end,
+		OnlyConfirmed: c.Query("include_cancelled") != "true",
+	})
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": bookings})
+}
+
+type CreateAmenityBookingRequest struct {
+	StartsAt time.Time `json:"starts_at" binding:"required"`
+	EndsAt   time.Time `json:"ends_at" binding:"required"`
+	Notes    *string   `json:"notes" binding:"omitempty,max=500"`
+}
+
+func (h *Handler) createAmenityBooking(c *gin.Context) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	a, ok := h.amenityInScope(c)
+	if !ok {
+		return
+	}
+
+	var req CreateAmenityBookingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	residenceID, ok := h.residenceInScope(c, user, nil)
+	if !ok {
+		return
+	}
+
+	if !a.IsActive {
+		h.respondError(c, http.StatusConflict, store.ErrAmenityInactive)
+		return
+	}
+
+	if err := amenity.CheckBooking(a, req.StartsAt, req.EndsAt, time.Now()); err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	weekFrom, weekTo, err := amenity.Week(a, req.StartsAt)
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	booking, err := h.db.CreateAmenityBooking(c.Request.Context(), store.CreateAmenityBookingParams{
+		Amenity:     a,
+		ResidenceID: residenceID,
+		BookedBy:    user.ID,
+		From:        req.StartsAt,
+		To:          req.EndsAt,
+		Notes:       req.Notes,
+		WeekFrom:    weekFrom,
+		WeekTo:      weekTo,
+	})
+	if err != nil {
+		h.respondError(c, errorStatus(err), err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": booking})
+}
+
+func (h *Handler) getAmenityBookings(c *gin.Context) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	filter := store.AmenityBookingFilter{
+		OnlyConfirmed: c.Query("include_cancelled") != "true",
+	}
+
+	if c.Query("upcoming") == "true" {
+		now := time.Now()
+		filter.From = &now
+	}
+
+	if raw := c.Query("amenity_id"); raw != "" {
+		id, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			h.respondError(c, http.StatusBadRequest, fmt.Errorf("invalid amenity_id: %w", err))
+			return
+		}
+		filter.AmenityID = &id
+	}
+
+	if isSocietyStaff(user) {
+		societyID, err := societyIDParam(c, user)
+		if err != nil {
+			h.respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		filter.SocietyID = societyID
+	} else {
+		residenceID, ok := h.residenceInScope(c, user, nil)
+		if !ok {
+			return
+		}
+		filter.ResidenceID = &residenceID
+	}
+
+	bookings, err := h.db.GetAmenityBookings(c.Request.Context(), filter)
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": bookings})
+}
+
+type CancelAmenityBookingRequest struct {
+	Reason *string `json:"reason" binding:"omitempty,max=500"`
+}
+
+// cancelAmenityBooking applies the amenity's cancellation policy to
+// residents. Managers may cancel any booking in their society, e.g. for
+// maintenance, and should give a reason.
+func (h *Handler) cancelAmenityBooking(c *gin.Context) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, fmt.Errorf("invalid booking id: %w", err))
+		return
+	}
+
+	var req CancelAmenityBookingRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	booking, err := h.db.GetAmenityBooking(c.Request.Context(), id)
+	if err != nil {
+		h.respondError(c, errorStatus(err), err)
+		return
+	}
+
+	a, err := h.db.GetAmenity(c.Request.Context(), booking.AmenityID)
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	switch user.Role {
+	case model.RoleAdmin, model.RoleSocietyManager:
+		if user.Role != model.RoleAdmin && (user.SocietyID == nil || *user.SocietyID != a.SocietyID) {
+			h.respondError(c, http.StatusForbidden, ErrUnauthorizedRole)
+			return
+		}
+	default:
+		if _, ok := h.residenceInScope(c, user, &booking.ResidenceID); !ok {
+			return
+		}
+		if err := amenity.CheckCancel(a, booking, time.Now()); err != nil {
+			h.respondError(c, http.StatusConflict, err)
+			return
+		}
+	}
+
+	booking, err = h.db.CancelAmenityBooking(c.Request.Context(), id, user.ID, req.Reason)
+	if err != nil {
+		h.respondError(c, errorStatus(err), err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": booking})
+}
+
+// amenityInScope loads the amenity named by :id and checks it belongs to
+// the caller's society. It writes the error response itself.
+func (h *Handler) amenityInScope(c *gin.Context) (*model.Amenity, bool) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return nil, false
+	}
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, fmt.Errorf("invalid amenity id: %w", err))
+		return nil, false
+	}
+
+	a, err := h.db.GetAmenity(c.Request.Context(), id)
+	if err != nil {
+		h.respondError(c, errorStatus(err), err)
+		return nil, false
+	}
+
+	if user.Role == model.RoleAdmin {
+		return a, true
+	}
+
+	societyID, err := h.userSocietyID(c, user)
+	if err != nil || societyID != a.SocietyID {
+		h.respondError(c, http.StatusForbidden, ErrUnauthorizedRole)
+		return nil, false
+	}
+
+	return a, true
+}
+
+// amenityDate parses a YYYY-MM-DD date in the amenity's time zone,
+// defaulting to today there.
+func amenityDate(a *model.Amenity, raw string) (time.Time, error) {
+	loc, err := amenity.Location(a)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if raw == "" {
+		now := time.Now().In(loc)
+		return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc), nil
+	}
+
+	date, err := time.ParseInLocation("2006-01-02", raw, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("date must be YYYY-MM-DD: %w", err)
+	}
+
+	return date, nil
+}
+
+func isSocietyStaff(user *AuthUser) bool {
+	switch user.Role {
+	case model.RoleAdmin, model.RoleSocietyManager, model.RoleSecurity:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,374 @@
+package api
+
+import (
+	"dooreye-backend/internal/model"
+	"dooreye-backend/internal/store"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AnnouncementAttachmentRequest struct {
+	URL         string  `json:"url" binding:"required,url"`
+	Name        string  `json:"name" binding:"required,max=200"`
+	ContentType *string `json:"content_type" binding:"omitempty,max=100"`
+}
+
+type CreateAnnouncementRequest struct {
+	Title       string                          `json:"title" binding:"required,max=200"`
+	Body        string                          `json:"body" binding:"required,max=20000"`
+	BlockIDs    []int64                         `json:"block_ids" binding:"max=100"`
+	Roles       []model.UserRole                `json:"roles" binding:"max=5"`
+	IsUrgent    bool                            `json:"is_urgent"`
+	IsPinned    bool                            `json:"is_pinned"`
+	ExpiresAt   *time.Time                      `json:"expires_at"`
+	Attachments []AnnouncementAttachmentRequest `json:"attachments" binding:"max=10,dive"`
+	// Publish now unless a later publish_at is given. Drafts are saved
+	// unpublished.
+	Draft     bool       `json:"draft"`
+	PublishAt *time.Time `json:"publish_at"`
+}
+
+func (h *Handler) createAnnouncement(c *gin.Context) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	var req CreateAnnouncementRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	for _, r := range req.Roles {
+		if !r.Valid() || r == model.RoleAdmin {
+			h.respondError(c, http.StatusBadRequest, fmt.Errorf("invalid role %q", r))
+			return
+		}
+	}
+
+	societyID, err := societyIDParam(c, user)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	params := store.CreateAnnouncementParams{
+		SocietyID: *societyID,
+		Title:     req.Title,
+		Body:      req.Body,
+		BlockIDs:  req.BlockIDs,
+		Roles:     req.Roles,
+		IsUrgent:  req.IsUrgent,
+		IsPinned:  req.IsPinned,
+		ExpiresAt: req.ExpiresAt,
+		CreatedBy: user.ID,
+	}
+	for _, att := range req.Attachments {
+		params.Attachments = append(params.Attachments, store.AnnouncementAttachmentParams{
+			URL:         att.URL,
+			Name:        att.Name,
+			ContentType: att.ContentType,
+		})
+	}
+
+	if !req.Draft {
+		at := publishTime(req.PublishAt)
+		params.PublishAt = &at
+	}
+
+	announcement, err := h.db.CreateAnnouncement(c.Request.Context(), params)
+	if err != nil {
+		h.respondError(c, errorStatus(err), err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": announcement})
+}
+
+type PublishAnnouncementRequest struct {
+	PublishAt *time.Time `json:"publish_at"`
+}
+
+func (h *Handler) publishAnnouncement(c *gin.Context) {
+	announcement, ok := h.managedAnnouncement(c)
+	if !ok {
+		return
+	}
+
+	var req PublishAnnouncementRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	announcement, err := h.db.PublishAnnouncement(c.Request.Context(), announcement.ID, publishTime(req.PublishAt))
+	if err != nil {
+		h.respondError(c, errorStatus(err), err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": announcement})
+}
+
+type UpdateAnnouncementRequest struct {
+	Title       *string    `json:"title" binding:"omitempty,max=200"`
+	Body        *string    `json:"body" binding:"omitempty,max=20000"`
+	IsPinned    *bool      `json:"is_pinned"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	ClearExpiry bool       `json:"clear_expiry"`
+}
+
+// updateAnnouncement pins, unpins or changes the expiry of an announcement.
+// The title and body can only be edited while it is still a draft.
+func (h *Handler) updateAnnouncement(c *gin.Context) {
+	announcement, ok := h.managedAnnouncement(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateAnnouncementRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	announcement, err := h.db.UpdateAnnouncement(c.Request.Context(), announcement.ID, store.UpdateAnnouncementParams{
+		Title:       req.Title,
+		Body:        req.Body,
+		IsPinned:    req.IsPinned,
+		ExpiresAt:   req.ExpiresAt,
+		ClearExpiry: req.ClearExpiry,
+	})
+	if err != nil {
+		h.respondError(c, errorStatus(err), err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": announcement})
+}
+
+func (h *Handler) withdrawAnnouncement(c *gin.Context) {
+	announcement, ok := h.managedAnnouncement(c)
+	if !ok {
+		return
+	}
+
+	if err := h.db.WithdrawAnnouncement(c.Request.Context(), announcement.ID); err != nil {
+		h.respondError(c, errorStatus(err), err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// getAllAnnouncements is the manager's view of the notice board, including
+// drafts, scheduled and withdrawn announcements with read counts.
+func (h *Handler) getAllAnnouncements(c *gin.Context) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	societyID, err := societyIDParam(c, user)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	offset, _ := strconv.Atoi(c.Query("offset"))
+
+	announcements, err := h.db.GetAnnouncements(c.Request.Context(), *societyID, limit, offset)
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": announcements})
+}
+
+// getAnnouncementFeed is the notice board as the caller sees it: live
+// announcements addressed to them, pinned first, with read state and the
+// total unread count.
+func (h *Handler) getAnnouncementFeed(c *gin.Context) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	societyID, err := h.userSocietyID(c, user)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	params := store.AnnouncementFeedParams{
+		UserID:     user.ID,
+		SocietyID:  societyID,
+		OnlyUnread: c.Query("unread") == "true",
+	}
+	params.Limit, _ = strconv.Atoi(c.Query("limit"))
+	params.Offset, _ = strconv.Atoi(c.Query("offset"))
+
+	feed, err := h.db.GetAnnouncementFeed(c.Request.Context(), params)
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	unread, err := h.db.CountUnreadAnnouncements(c.Request.Context(), user.ID, societyID)
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": feed,
+		"meta": gin.H{
+			"unread_count": unread,
+			"limit":        params.Limit,
+			"offset":       params.Offset,
+		},
+	})
+}
+
+// getAnnouncement opens one announcement. Managers get it with its read
+// count; everyone else only if it is addressed to them, and opening it
+// records their read receipt.
+func (h *Handler) getAnnouncement(c *gin.Context) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	if user.Role == model.RoleAdmin || user.Role == model.RoleSocietyManager {
+		announcement, ok := h.managedAnnouncement(c)
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"data": announcement})
+		return
+	}
+
+	id, ok := h.markAnnouncementRead(c, user)
+	if !ok {
+		return
+	}
+
+	announcement, err := h.db.GetAnnouncement(c.Request.Context(), id)
+	if err != nil {
+		h.respondError(c, errorStatus(err), err)
+		return
+	}
+	announcement.ReadCount = nil
+
+	c.JSON(http.StatusOK, gin.H{"data": announcement})
+}
+
+func (h *Handler) readAnnouncement(c *gin.Context) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	if _, ok := h.markAnnouncementRead(c, user); !ok {
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *Handler) readAllAnnouncements(c *gin.Context) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	societyID, err := h.userSocietyID(c, user)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	n, err := h.db.MarkAllAnnouncementsRead(c.Request.Context(), user.ID, societyID)
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{"marked": n}})
+}
+
+// markAnnouncementRead records the caller's read receipt for :id. It writes
+// the error response itself.
+func (h *Handler) markAnnouncementRead(c *gin.Context, user *AuthUser) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, fmt.Errorf("invalid announcement id: %w", err))
+		return 0, false
+	}
+
+	societyID, err := h.userSocietyID(c, user)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return 0, false
+	}
+
+	if err := h.db.MarkAnnouncementRead(c.Request.Context(), id, user.ID, societyID); err != nil {
+		h.respondError(c, errorStatus(err), err)
+		return 0, false
+	}
+
+	return id, true
+}
+
+// managedAnnouncement loads the announcement named by :id for a manager of
+// its society. It writes the error response itself.
+func (h *Handler) managedAnnouncement(c *gin.Context) (*model.Announcement, bool) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return nil, false
+	}
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, fmt.Errorf("invalid announcement id: %w", err))
+		return nil, false
+	}
+
+	announcement, err := h.db.GetAnnouncement(c.Request.Context(), id)
+	if err != nil {
+		h.respondError(c, errorStatus(err), err)
+		return nil, false
+	}
+
+	if user.Role != model.RoleAdmin && (user.SocietyID == nil || *user.SocietyID != announcement.SocietyID) {
+		h.respondError(c, http.StatusForbidden, ErrUnauthorizedRole)
+		return nil, false
+	}
+
+	return announcement, true
+}
+
+// publishTime defaults a missing or past publish time to now.
+func publishTime(at *time.Time) time.Time {
+	now := time.Now()
+	if at == nil || at.Before(now) {
+		return now
+	}
+	return *at
+}
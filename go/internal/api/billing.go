@@ -0,0 +1,710 @@
+package api
+
+import (
+	"dooreye-backend/internal/billing"
+	"dooreye-backend/internal/model"
+	"dooreye-backend/internal/payment"
+	"dooreye-backend/internal/store"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxWebhookBytes bounds the body of a payment webhook.
+const maxWebhookBytes = 64 << 10
+
+var (
+	ErrBillingNotSetUp  = errors.New("billing has not been set up for this society")
+	ErrNothingDue       = errors.New("nothing is due for this residence")
+	ErrInvalidMethod    = errors.New("method must be CASH, CHEQUE or BANK_TRANSFER")
+	ErrInvalidRuleKind  = errors.New("kind must be FLAT, PER_SQFT or PER_FLOOR")
+	ErrStatementPeriod  = errors.New("to must be after from")
+	ErrInvalidCycle     = errors.New("cycle_months must be 1, 3, 6 or 12")
+	ErrOverpaymentLimit = errors.New("amount exceeds what is due")
+)
+
+type BillingSettingsRequest struct {
+	CycleMonths  int   `json:"cycle_months" binding:"required"`
+	DueDays      int   `json:"due_days" binding:"min=0,max=90"`
+	LateFeePaise int64 `json:"late_fee_paise" binding:"min=0"`
+	GraceDays    int   `json:"grace_days" binding:"min=0,max=90"`
+}
+
+func (h *Handler) getBillingSettings(c *gin.Context) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	societyID, err := societyIDParam(c, user)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	settings, err := h.db.GetBillingSettings(c.Request.Context(), *societyID)
+	if errors.Is(err, store.ErrNotFound) {
+		h.respondError(c, http.StatusNotFound, ErrBillingNotSetUp)
+		return
+	}
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": settings})
+}
+
+func (h *Handler) updateBillingSettings(c *gin.Context) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	var req BillingSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	switch req.CycleMonths {
+	case 1, 3, 6, 12:
+	default:
+		h.respondError(c, http.StatusBadRequest, ErrInvalidCycle)
+		return
+	}
+
+	societyID, err := societyIDParam(c, user)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	settings, err := h.db.UpsertBillingSettings(c.Request.Context(), *societyID, store.BillingSettingsParams{
+		CycleMonths:  req.CycleMonths,
+		DueDays:      req.DueDays,
+		LateFeePaise: req.LateFeePaise,
+		GraceDays:    req.GraceDays,
+	})
+	if err != nil {
+		h.respondError(c, errorStatus(err), err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": settings})
+}
+
+type BillingRuleRequest struct {
+	BlockID   *int64                `json:"block_id"`
+	Name      string                `json:"name" binding:"required,max=100"`
+	Kind      model.BillingRuleKind `json:"kind" binding:"required"`
+	RatePaise int64                 `json:"rate_paise" binding:"required,min=1"`
+}
+
+func (r BillingRuleRequest) params() store.BillingRuleParams {
+	return store.BillingRuleParams{
+		BlockID:   r.BlockID,
+		Name:      r.Name,
+		Kind:      r.Kind,
+		RatePaise: r.RatePaise,
+	}
+}
+
+func (h *Handler) getBillingRules(c *gin.Context) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	societyID, err := societyIDParam(c, user)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	rules, err := h.db.GetBillingRules(c.Request.Context(), *societyID, c.Query("include_inactive") == "true")
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": rules})
+}
+
+func (h *Handler) createBillingRule(c *gin.Context) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	var req BillingRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	if !req.Kind.Valid() {
+		h.respondError(c, http.StatusBadRequest, ErrInvalidRuleKind)
+		return
+	}
+
+	societyID, err := societyIDParam(c, user)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	rule, err := h.db.CreateBillingRule(c.Request.Context(), *societyID, req.params())
+	if err != nil {
+		h.respondError(c, errorStatus(err), err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": rule})
+}
+
+func (h *Handler) updateBillingRule(c *gin.Context) {
+	rule, ok := h.billingRuleInScope(c)
+	if !ok {
+		return
+	}
+
+	var req BillingRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	if !req.Kind.Valid() {
+		h.respondError(c, http.StatusBadRequest, ErrInvalidRuleKind)
+		return
+	}
+
+	rule, err := h.db.UpdateBillingRule(c.Request.Context(), rule.ID, req.params())
+	if err != nil {
+		h.respondError(c, errorStatus(err), err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": rule})
+}
+
+func (h *Handler) deactivateBillingRule(c *gin.Context) {
+	rule, ok := h.billingRuleInScope(c)
+	if !ok {
+		return
+	}
+
+	if err := h.db.DeactivateBillingRule(c.Request.Context(), rule.ID); err != nil {
+		h.respondError(c, errorStatus(err), err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+type ResidenceAreaRequest struct {
+	AreaSqft *int `json:"area_sqft" binding:"omitempty,min=1,max=100000"`
+}
+
+// setResidenceArea records the area per-sqft rules bill a residence on.
+func (h *Handler) setResidenceArea(c *gin.Context) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	residenceID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, fmt.Errorf("invalid residence id: %w", err))
+		return
+	}
+
+	var req ResidenceAreaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if _, ok := h.residenceInScope(c, user, &residenceID); !ok {
+		return
+	}
+
+	residence, err := h.db.SetResidenceArea(c.Request.Context(), residenceID, req.AreaSqft)
+	if err != nil {
+		h.respondError(c, errorStatus(err), err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": residence})
+}
+
+type GenerateInvoicesRequest struct {
+	// Any day of the period to bill; defaults to today.
+	Date *Date `json:"date"`
+}
+
+// generateInvoices issues a period's invoices on demand, e.g. after rules
+// change or for a period the daily job has not reached yet. Residences
+// already invoiced for the period are left alone.
+func (h *Handler) generateInvoices(c *gin.Context) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	var req GenerateInvoicesRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	societyID, err := societyIDParam(c, user)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	settings, err := h.db.GetBillingSettings(c.Request.Context(), *societyID)
+	if errors.Is(err, store.ErrNotFound) {
+		h.respondError(c, http.StatusConflict, ErrBillingNotSetUp)
+		return
+	}
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	var day time.Time
+	if req.Date != nil {
+		day = req.Date.Time
+	} else if day, err = billing.Today(settings, time.Now()); err != nil {
+		h.respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	invoices, err := h.invoicer.GenerateForSociety(c.Request.Context(), settings, day)
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"created":  len(invoices),
+		"invoices": invoices,
+	}})
+}
+
+// getInvoices lists the caller's residence invoices, or for managers the
+// society's, optionally for one residence.
+func (h *Handler) getInvoices(c *gin.Context) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	filter := store.InvoiceFilter{UnpaidOnly: c.Query("unpaid") == "true"}
+	filter.Limit, _ = strconv.Atoi(c.Query("limit"))
+	filter.Offset, _ = strconv.Atoi(c.Query("offset"))
+
+	filter.SocietyID, filter.ResidenceID, err = h.billingScope(c, user)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	invoices, err := h.db.GetInvoices(c.Request.Context(), filter)
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": invoices})
+}
+
+func (h *Handler) getInvoice(c *gin.Context) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, fmt.Errorf("invalid invoice id: %w", err))
+		return
+	}
+
+	invoice, err := h.db.GetInvoice(c.Request.Context(), id)
+	if err != nil {
+		h.respondError(c, errorStatus(err), err)
+		return
+	}
+
+	if !h.canSeeResidenceAccount(c, user, invoice.SocietyID, invoice.ResidenceID) {
+		h.respondError(c, http.StatusNotFound, store.ErrNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": invoice})
+}
+
+// getStatement returns a residence's account for a window, by default the
+// last twelve months.
+func (h *Handler) getStatement(c *gin.Context) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	requested, err := optionalInt64Query(c, "residence_id")
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	residenceID, ok := h.residenceInScope(c, user, requested)
+	if !ok {
+		return
+	}
+
+	to := time.Now()
+	from := to.AddDate(-1, 0, 0)
+	if raw := c.Query("from"); raw != "" {
+		if from, err = time.Parse(time.DateOnly, raw); err != nil {
+			h.respondError(c, http.StatusBadRequest, fmt.Errorf("invalid from: %w", err))
+			return
+		}
+	}
+	if raw := c.Query("to"); raw != "" {
+		if to, err = time.Parse(time.DateOnly, raw); err != nil {
+			h.respondError(c, http.StatusBadRequest, fmt.Errorf("invalid to: %w", err))
+			return
+		}
+		to = to.AddDate(0, 0, 1)
+	}
+	if !to.After(from) {
+		h.respondError(c, http.StatusBadRequest, ErrStatementPeriod)
+		return
+	}
+
+	statement, err := h.db.GetStatement(c.Request.Context(), residenceID, from, to)
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": statement})
+}
+
+func (h *Handler) getPayments(c *gin.Context) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	var filter store.PaymentFilter
+	filter.Limit, _ = strconv.Atoi(c.Query("limit"))
+	filter.Offset, _ = strconv.Atoi(c.Query("offset"))
+
+	filter.SocietyID, filter.ResidenceID, err = h.billingScope(c, user)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	payments, err := h.db.GetPayments(c.Request.Context(), filter)
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": payments})
+}
+
+type RecordPaymentRequest struct {
+	ResidenceID int64               `json:"residence_id" binding:"required"`
+	AmountPaise int64               `json:"amount_paise" binding:"required,min=1"`
+	Method      model.PaymentMethod `json:"method" binding:"required"`
+	Reference   *string             `json:"reference" binding:"omitempty,max=100"`
+}
+
+// recordPayment books a payment collected outside the app, such as cash
+// at the society office or a cheque.
+func (h *Handler) recordPayment(c *gin.Context) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	var req RecordPaymentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	if !req.Method.Offline() {
+		h.respondError(c, http.StatusBadRequest, ErrInvalidMethod)
+		return
+	}
+
+	societyID, err := societyIDParam(c, user)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	if !h.residenceInSociety(c, req.ResidenceID, *societyID) {
+		return
+	}
+
+	p, err := h.db.RecordPayment(c.Request.Context(), store.RecordPaymentParams{
+		SocietyID:   *societyID,
+		ResidenceID: req.ResidenceID,
+		AmountPaise: req.AmountPaise,
+		Method:      req.Method,
+		Reference:   req.Reference,
+		RecordedBy:  user.ID,
+	})
+	if err != nil {
+		h.respondError(c, errorStatus(err), err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": p})
+}
+
+type CheckoutRequest struct {
+	Provider string `json:"provider" binding:"required"`
+	// Defaults to the residence's outstanding balance.
+	AmountPaise *int64 `json:"amount_paise" binding:"omitempty,min=1"`
+}
+
+// createCheckout starts an online payment of the caller's dues with a
+// payment provider. The residence is credited when the provider's webhook
+// confirms the payment.
+func (h *Handler) createCheckout(c *gin.Context) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	var req CheckoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	provider, err := h.payments.Get(req.Provider)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	residenceID, ok := h.residenceInScope(c, user, nil)
+	if !ok {
+		return
+	}
+
+	societyID, err := h.userSocietyID(c, user)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	balance, err := h.db.GetResidenceBalance(c.Request.Context(), residenceID)
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	if balance <= 0 {
+		h.respondError(c, http.StatusConflict, ErrNothingDue)
+		return
+	}
+
+	amount := balance
+	if req.AmountPaise != nil {
+		amount = *req.AmountPaise
+	}
+	if amount > balance {
+		h.respondError(c, http.StatusBadRequest, ErrOverpaymentLimit)
+		return
+	}
+
+	session, err := provider.CreateCheckout(c.Request.Context(), payment.Checkout{
+		SocietyID:   societyID,
+		ResidenceID: residenceID,
+		AmountPaise: amount,
+		Description: "Society maintenance",
+	})
+	if err != nil {
+		h.respondError(c, http.StatusBadGateway, err)
+		return
+	}
+
+	p, err := h.db.CreateGatewayPayment(c.Request.Context(), store.CreateGatewayPaymentParams{
+		SocietyID:   societyID,
+		ResidenceID: residenceID,
+		AmountPaise: amount,
+		Provider:    session.Provider,
+		Reference:   session.Reference,
+		CreatedBy:   user.ID,
+	})
+	if err != nil {
+		h.respondError(c, errorStatus(err), err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": gin.H{
+		"payment":  p,
+		"checkout": session,
+	}})
+}
+
+// handlePaymentWebhook receives a provider's verdict on a payment. It is
+// unauthenticated; the provider's signature is the authentication.
+// Redelivered webhooks are acknowledged without effect.
+func (h *Handler) handlePaymentWebhook(c *gin.Context) {
+	provider, err := h.payments.Get(c.Param("provider"))
+	if err != nil {
+		h.respondError(c, http.StatusNotFound, err)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxWebhookBytes))
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	event, err := provider.ParseWebhook(c.Request.Header, body)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, payment.ErrInvalidSignature) {
+			status = http.StatusUnauthorized
+		}
+		h.respondError(c, status, err)
+		return
+	}
+
+	p, err := h.db.CompletePayment(c.Request.Context(), store.CompletePaymentParams{
+		Provider:      provider.Name(),
+		Reference:     event.Reference,
+		Status:        event.Status,
+		AmountPaise:   event.AmountPaise,
+		FailureReason: event.FailureReason,
+	})
+	switch {
+	case errors.Is(err, store.ErrPaymentNotPending):
+		c.JSON(http.StatusOK, gin.H{"data": gin.H{"status": p.Status, "duplicate": true}})
+		return
+	case err != nil:
+		h.respondError(c, errorStatus(err), err)
+		return
+	}
+
+	h.log.InfoContext(c.Request.Context(), "payment completed",
+		"payment_id", p.ID,
+		"provider", provider.Name(),
+		"event_id", event.ID,
+		"status", p.Status,
+	)
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{"status": p.Status}})
+}
+
+// billingScope picks the society and residence a listing covers: the
+// caller's own residence for residents, the society and an optional
+// residence_id for managers.
+func (h *Handler) billingScope(c *gin.Context, user *AuthUser) (int64, *int64, error) {
+	if user.Role == model.RoleOwner || user.Role == model.RoleResident {
+		if user.ResidenceID == nil {
+			return 0, nil, ErrUnauthorizedRole
+		}
+		societyID, err := h.userSocietyID(c, user)
+		if err != nil {
+			return 0, nil, err
+		}
+		return societyID, user.ResidenceID, nil
+	}
+
+	societyID, err := societyIDParam(c, user)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	residenceID, err := optionalInt64Query(c, "residence_id")
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return *societyID, residenceID, nil
+}
+
+// canSeeResidenceAccount lets residents see their own residence's billing
+// and managers their society's.
+func (h *Handler) canSeeResidenceAccount(c *gin.Context, user *AuthUser, societyID, residenceID int64) bool {
+	switch user.Role {
+	case model.RoleAdmin:
+		return true
+	case model.RoleSocietyManager:
+		return user.SocietyID != nil && *user.SocietyID == societyID
+	}
+	return user.ResidenceID != nil && *user.ResidenceID == residenceID
+}
+
+// billingRuleInScope loads the rule named by :id if it belongs to the
+// caller's society. It writes the error response itself.
+func (h *Handler) billingRuleInScope(c *gin.Context) (*model.BillingRule, bool) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return nil, false
+	}
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, fmt.Errorf("invalid rule id: %w", err))
+		return nil, false
+	}
+
+	rule, err := h.db.GetBillingRule(c.Request.Context(), id)
+	if err != nil {
+		h.respondError(c, errorStatus(err), err)
+		return nil, false
+	}
+
+	if user.Role != model.RoleAdmin && (user.SocietyID == nil || *user.SocietyID != rule.SocietyID) {
+		h.respondError(c, http.StatusNotFound, store.ErrNotFound)
+		return nil, false
+	}
+
+	return rule, true
+}
+
+func optionalInt64Query(c *gin.Context, name string) (*int64, error) {
+	raw := c.Query(name)
+	if raw == "" {
+		return nil, nil
+	}
+
+	v, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", name, err)
+	}
+
+	return &v, nil
+}
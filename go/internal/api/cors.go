@@ -0,0 +1,99 @@
+package api
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CORSOptions says which browser origins may call the API. With no
+// origins, no CORS headers are sent and browsers keep the same-origin
+// policy.
+type CORSOptions struct {
+	// AllowedOrigins are matched exactly against the Origin header; "*"
+	// allows any origin.
+	AllowedOrigins []string
+	// AllowedMethods default to every method the API routes.
+	AllowedMethods []string
+	// AllowCredentials lets browsers send cookies and HTTP auth. It cannot
+	// be combined with "*".
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache a preflight answer.
+	MaxAge time.Duration
+}
+
+var defaultCORSMethods = []string{
+	http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete,
+}
+
+// corsAllowedHeaders are the request headers clients send beyond the
+// CORS-safelisted ones.
+var corsAllowedHeaders = []string{
+	"Authorization", "Content-Type", ResidenceHeader, RequestIDHeader, "traceparent", "tracestate",
+}
+
+// corsExposedHeaders are the response headers scripts may read.
+var corsExposedHeaders = []string{
+	RequestIDHeader, "Retry-After", "Content-Disposition",
+}
+
+// CORS answers preflight requests and marks responses to allowed origins.
+// It runs before routing, so preflights for any path are answered here.
+func (h *Handler) CORS(opts CORSOptions) gin.HandlerFunc {
+	methods := opts.AllowedMethods
+	if len(methods) == 0 {
+		methods = defaultCORSMethods
+	}
+	anyOrigin := slices.Contains(opts.AllowedOrigins, "*")
+
+	allowMethods := strings.Join(methods, ", ")
+	allowHeaders := strings.Join(corsAllowedHeaders, ", ")
+	exposeHeaders := strings.Join(corsExposedHeaders, ", ")
+	maxAge := strconv.Itoa(int(opts.MaxAge.Seconds()))
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" || len(opts.AllowedOrigins) == 0 {
+			c.Next()
+			return
+		}
+
+		c.Writer.Header().Add("Vary", "Origin")
+		if !anyOrigin && !slices.Contains(opts.AllowedOrigins, origin) {
+			// The browser blocks the response for lack of headers.
+			c.Next()
+			return
+		}
+
+		if anyOrigin {
+			c.Header("Access-Control-Allow-Origin", "*")
+		} else {
+			c.Header("Access-Control-Allow-Origin", origin)
+		}
+		if opts.AllowCredentials {
+			c.Header("Access-Control-Allow-Credentials", "true")
+		}
+
+		preflight := c.Request.Method == http.MethodOptions &&
+			c.GetHeader("Access-Control-Request-Method") != ""
+		if !preflight {
+			c.Header("Access-Control-Expose-Headers", exposeHeaders)
+			c.Next()
+			return
+		}
+
+		// Preflights reach here through the router's 405 handling, which
+		// has already set Allow for the path's non-OPTIONS methods.
+		c.Writer.Header().Del("Allow")
+		c.Header("Access-Control-Allow-Methods", allowMethods)
+		c.Header("Access-Control-Allow-Headers", allowHeaders)
+		if opts.MaxAge > 0 {
+			c.Header("Access-Control-Max-Age", maxAge)
+		}
+		c.AbortWithStatus(http.StatusNoContent)
+	}
+}
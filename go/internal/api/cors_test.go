@@ -0,0 +1,109 @@
+package api
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newTestHandler builds the full router without a database, for requests
+// answered before any handler needs one.
+func newTestHandler(opts Options) *Handler {
+	return NewHandler(nil, nil, nil, slog.New(slog.NewTextHandler(io.Discard, nil)), opts)
+}
+
+func serve(h *Handler, req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.router.ServeHTTP(w, req)
+	return w
+}
+
+func TestCORS(t *testing.T) {
+	const app = "https://app.dooreye.example"
+
+	preflight := func(origin string) *http.Request {
+		req := httptest.NewRequest(http.MethodOptions, "/api/visits", nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		req.Header.Set("Access-Control-Request-Headers", "authorization, content-type")
+		return req
+	}
+	get := func(path, origin string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		return req
+	}
+
+	allowApp := CORSOptions{AllowedOrigins: []string{app}, AllowCredentials: true, MaxAge: 10 * time.Minute}
+
+	tests := []struct {
+		name   string
+		cors   CORSOptions
+		req    *http.Request
+		status int
+		// want maps headers to their expected value; "" means absent.
+		want map[string]string
+	}{
+		{"allowed origin", allowApp, get("/livez", app), http.StatusOK, map[string]string{
+			"Access-Control-Allow-Origin":      app,
+			"Access-Control-Allow-Credentials": "true",
+			"Access-Control-Expose-Headers":    "X-Request-ID, Retry-After, Content-Disposition",
+			"Vary":                             "Origin",
+		}},
+		{"rejected origin", allowApp, get("/livez", "https://evil.example"), http.StatusOK, map[string]string{
+			"Access-Control-Allow-Origin":      "",
+			"Access-Control-Allow-Credentials": "",
+			"Vary":                             "Origin",
+		}},
+		{"same origin", allowApp, get("/livez", ""), http.StatusOK, map[string]string{
+			"Access-Control-Allow-Origin": "",
+			"Vary":                        "",
+		}},
+		{"cors disabled", CORSOptions{}, get("/livez", app), http.StatusOK, map[string]string{
+			"Access-Control-Allow-Origin": "",
+		}},
+		{"any origin", CORSOptions{AllowedOrigins: []string{"*"}}, get("/livez", "https://other.example"), http.StatusOK, map[string]string{
+			"Access-Control-Allow-Origin":      "*",
+			"Access-Control-Allow-Credentials": "",
+		}},
+		{"preflight", allowApp, preflight(app), http.StatusNoContent, map[string]string{
+			"Access-Control-Allow-Origin":  app,
+			"Access-Control-Allow-Methods": "GET, POST, PUT, PATCH, DELETE",
+			"Access-Control-Allow-Headers": "Authorization, Content-Type, X-Residence-ID, X-Request-ID, traceparent, tracestate",
+			"Access-Control-Max-Age":       "600",
+			"Allow":                        "",
+		}},
+		{"preflight from rejected origin", allowApp, preflight("https://evil.example"), http.StatusMethodNotAllowed, map[string]string{
+			"Access-Control-Allow-Origin":  "",
+			"Access-Control-Allow-Methods": "",
+		}},
+		{"preflight for unknown path", allowApp, func() *http.Request {
+			req := preflight(app)
+			req.URL.Path = "/nowhere"
+			return req
+		}(), http.StatusNoContent, map[string]string{
+			"Access-Control-Allow-Origin": app,
+		}},
+		{"error response", allowApp, get("/api/visits", app), http.StatusUnauthorized, map[string]string{
+			"Access-Control-Allow-Origin":   app,
+			"Access-Control-Expose-Headers": "X-Request-ID, Retry-After, Content-Disposition",
+		}},
+	}
+
+	for _, tt := range tests {
+		w := serve(newTestHandler(Options{CORS: tt.cors}), tt.req)
+		if w.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.status)
+		}
+		for name, want := range tt.want {
+			if got := w.Header().Get(name); got != want {
+				t.Errorf("%s: %s = %q, want %q", tt.name, name, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"dooreye-backend/internal/store"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *Handler) getMe(c *gin.Context) {
+	authUser, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	user, err := h.db.GetUser(c.Request.Context(), authUser.ID)
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"user":                user,
+		"memberships":         authUser.Memberships,
+		"active_residence_id": authUser.ResidenceID,
+		"active_role":         authUser.Role,
+	}})
+}
+
+func (h *Handler) getDevices(c *gin.Context) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	devices, err := h.db.GetUserDevices(c.Request.Context(), user.ID)
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"devices":        devices,
+		"current_device": user.DeviceRowID,
+	}})
+}
+
+type AddDeviceRequest struct {
+	DeviceID string  `json:"device_id" binding:"required,max=255"`
+	Name     *string `json:"name" binding:"omitempty,max=100"`
+}
+
+// addDevice registers another device for the caller, e.g. a tablet next to
+// a phone. It must be called from a device that is already signed in.
+func (h *Handler) addDevice(c *gin.Context) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	var req AddDeviceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	device, err := h.db.AddUserDevice(c.Request.Context(), user.ID, req.DeviceID, req.Name)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, store.ErrDeviceTaken) {
+			status = http.StatusConflict
+		}
+		h.respondError(c, status, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": device})
+}
+
+func (h *Handler) revokeDevice(c *gin.Context) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, fmt.Errorf("invalid device id: %w", err))
+		return
+	}
+
+	if err := h.db.RevokeUserDevice(c.Request.Context(), user.ID, id); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, store.ErrNotFound) {
+			status = http.StatusNotFound
+		}
+		h.respondError(c, status, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
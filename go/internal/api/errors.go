@@ -0,0 +1,426 @@
+package api
+
+import (
+	"dooreye-backend/internal/amenity"
+	"dooreye-backend/internal/billing"
+	"dooreye-backend/internal/payment"
+	"dooreye-backend/internal/plate"
+	"dooreye-backend/internal/store"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/jackc/pgconn"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	ErrMissingAuthHeader = errors.New("authorization header required")
+	ErrRateLimited       = errors.New("too many requests, try again later")
+	ErrRouteNotFound     = errors.New("no such endpoint")
+	ErrMethodNotAllowed  = errors.New("method not allowed on this endpoint")
+	ErrInternal          = errors.New("internal server error")
+)
+
+// ProblemContentType is the media type of every error response.
+const ProblemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 error body. Code is stable and meant for clients
+// to branch on; Detail is meant for people and may change.
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Code      string       `json:"code"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError reports one invalid field of a request body.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Error is an error with the status and code it is reported with. Handlers
+// use it, through withCode, where a sentinel's generic code is too vague,
+// e.g. VISITOR_NOT_FOUND rather than NOT_FOUND.
+type Error struct {
+	Status int
+	Code   string
+	Err    error
+}
+
+func (e *Error) Error() string { return e.Err.Error() }
+func (e *Error) Unwrap() error { return e.Err }
+
+func withCode(status int, code string, err error) error {
+	return &Error{Status: status, Code: code, Err: err}
+}
+
+type errorSpec struct {
+	err    error
+	status int
+	code   string
+}
+
+// errorSpecs maps sentinel errors to how they are reported. Codes are part
+// of the API contract: add new ones freely, never rename existing ones.
+var errorSpecs = []errorSpec{
+	{store.ErrNotFound, http.StatusNotFound, "NOT_FOUND"},
+	{store.ErrVisitApproved, http.StatusConflict, "VISIT_ALREADY_APPROVED"},
+	{ErrGateNotFound, http.StatusNotFound, "GATE_NOT_FOUND"},
+	{ErrResidenceNotFound, http.StatusNotFound, "RESIDENCE_NOT_FOUND"},
+	{store.ErrAlreadyExists, http.StatusConflict, "ALREADY_EXISTS"},
+
+	// Auth and access.
+	{ErrMissingAuthHeader, http.StatusUnauthorized, "AUTH_HEADER_REQUIRED"},
+	{ErrInvalidAuthHeader, http.StatusUnauthorized, "INVALID_AUTH_HEADER"},
+	{ErrInvalidToken, http.StatusUnauthorized, "INVALID_TOKEN"},
+	{ErrUserInactive, http.StatusForbidden, "USER_INACTIVE"},
+	{ErrUnauthorizedRole, http.StatusForbidden, "ROLE_NOT_ALLOWED"},
+	{ErrPermissionDenied, http.StatusForbidden, "PERMISSION_DENIED"},
+	{ErrNotAMember, http.StatusForbidden, "NOT_A_MEMBER"},
+	{ErrSocietyRequired, http.StatusBadRequest, "SOCIETY_REQUIRED"},
+	{ErrRateLimited, http.StatusTooManyRequests, "RATE_LIMITED"},
+	{ErrLockedOut, http.StatusTooManyRequests, "LOCKED_OUT"},
+	{ErrRouteNotFound, http.StatusNotFound, "ROUTE_NOT_FOUND"},
+	{ErrMethodNotAllowed, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED"},
+	{ErrBodyTooLarge, http.StatusRequestEntityTooLarge, "BODY_TOO_LARGE"},
+
+	// Users, devices and households.
+	{store.ErrDuplicateAccessCode, http.StatusConflict, "ACCESS_CODE_TAKEN"},
+	{store.ErrInvalidAccessCode, http.StatusNotFound, "ACCESS_CODE_INVALID"},
+	{store.ErrDeviceTaken, http.StatusConflict, "DEVICE_TAKEN"},
+	{store.ErrInvalidUserType, http.StatusBadRequest, "USER_TYPE_INVALID"},
+	{ErrOutsideSociety, http.StatusForbidden, "OUTSIDE_SOCIETY"},
+	{ErrLeaseEndRequired, http.StatusBadRequest, "LEASE_END_REQUIRED"},
+
+	// Visits, vehicles and parking.
+	{ErrPhoneRequired, http.StatusBadRequest, "PHONE_REQUIRED"},
+	{ErrVisitorNotYours, http.StatusForbidden, "VISITOR_NOT_YOURS"},
+	{plate.ErrInvalidPlate, http.StatusBadRequest, "PLATE_INVALID"},
+	{store.ErrPlateRegistered, http.StatusConflict, "PLATE_REGISTERED"},
+	{store.ErrVehicleInside, http.StatusConflict, "VEHICLE_INSIDE"},
+	{store.ErrVehicleNotInside, http.StatusConflict, "VEHICLE_NOT_INSIDE"},
+	{ErrSlotNotAssigned, http.StatusBadRequest, "SLOT_NOT_ASSIGNED"},
+	{store.ErrSlotHasBookings, http.StatusConflict, "SLOT_HAS_BOOKINGS"},
+	{store.ErrSlotUnavailable, http.StatusConflict, "SLOT_UNAVAILABLE"},
+	{store.ErrVisitorAlreadyBooked, http.StatusConflict, "VISITOR_ALREADY_BOOKED"},
+	{store.ErrNoGuestSlot, http.StatusConflict, "NO_GUEST_SLOT"},
+	{ErrInvalidBookingTime, http.StatusBadRequest, "BOOKING_TIME_INVALID"},
+
+	// Alerts and headcounts.
+	{ErrAlertLocationRequired, http.StatusBadRequest, "ALERT_LOCATION_REQUIRED"},
+	{store.ErrAlertClosed, http.StatusConflict, "ALERT_CLOSED"},
+	{store.ErrHeadcountOpen, http.StatusConflict, "HEADCOUNT_OPEN"},
+	{store.ErrHeadcountClosed, http.StatusConflict, "HEADCOUNT_CLOSED"},
+	{store.ErrOnShift, http.StatusConflict, "ALREADY_ON_SHIFT"},
+
+	// Amenities.
+	{store.ErrInvalidAmenity, http.StatusBadRequest, "AMENITY_INVALID"},
+	{store.ErrAmenityFull, http.StatusConflict, "AMENITY_FULL"},
+	{store.ErrAmenityAlreadyBooked, http.StatusConflict, "AMENITY_ALREADY_BOOKED"},
+	{store.ErrAmenityWeeklyLimit, http.StatusConflict, "AMENITY_WEEKLY_LIMIT"},
+	{store.ErrAmenityInactive, http.StatusConflict, "AMENITY_INACTIVE"},
+	{store.ErrBookingClosed, http.StatusConflict, "BOOKING_CLOSED"},
+	{amenity.ErrOutsideHours, http.StatusBadRequest, "OUTSIDE_HOURS"},
+	{amenity.ErrNotSlotAligned, http.StatusBadRequest, "NOT_SLOT_ALIGNED"},
+	{amenity.ErrInPast, http.StatusBadRequest, "BOOKING_IN_PAST"},
+	{amenity.ErrTooFarAhead, http.StatusBadRequest, "BOOKING_TOO_FAR_AHEAD"},
+	{amenity.ErrCancelNotAllowed, http.StatusConflict, "CANCEL_NOT_ALLOWED"},
+	{amenity.ErrCancelTooLate, http.StatusConflict, "CANCEL_TOO_LATE"},
+
+	// Tickets.
+	{ErrTicketLocation, http.StatusBadRequest, "TICKET_LOCATION_INVALID"},
+	{ErrTicketNotForVisit, http.StatusBadRequest, "TICKET_NOT_FOR_VISIT"},
+	{ErrInvalidAssignee, http.StatusBadRequest, "ASSIGNEE_INVALID"},
+	{store.ErrInvalidTransition, http.StatusConflict, "TICKET_TRANSITION_INVALID"},
+	{store.ErrTicketClosed, http.StatusConflict, "TICKET_CLOSED"},
+
+	// Announcements and polls.
+	{store.ErrInvalidBlocks, http.StatusBadRequest, "BLOCKS_INVALID"},
+	{store.ErrInvalidExpiry, http.StatusBadRequest, "EXPIRY_INVALID"},
+	{store.ErrAnnouncementPublished, http.StatusConflict, "ANNOUNCEMENT_PUBLISHED"},
+	{ErrInvalidPollWindow, http.StatusBadRequest, "POLL_WINDOW_INVALID"},
+	{ErrDuplicateOption, http.StatusBadRequest, "POLL_OPTION_DUPLICATE"},
+	{ErrResultsNotFinal, http.StatusForbidden, "POLL_RESULTS_NOT_FINAL"},
+	{store.ErrInvalidOption, http.StatusBadRequest, "POLL_OPTION_INVALID"},
+	{store.ErrNotEligible, http.StatusForbidden, "NOT_ELIGIBLE_TO_VOTE"},
+	{store.ErrAlreadyVoted, http.StatusConflict, "ALREADY_VOTED"},
+	{store.ErrPollNotOpen, http.StatusConflict, "POLL_NOT_OPEN"},
+	{store.ErrPollNotEditable, http.StatusConflict, "POLL_NOT_EDITABLE"},
+
+	// Billing and payments.
+	{ErrBillingNotSetUp, http.StatusNotFound, "BILLING_NOT_SET_UP"},
+	{ErrInvalidCycle, http.StatusBadRequest, "BILLING_CYCLE_INVALID"},
+	{ErrInvalidRuleKind, http.StatusBadRequest, "BILLING_RULE_KIND_INVALID"},
+	{ErrInvalidMethod, http.StatusBadRequest, "PAYMENT_METHOD_INVALID"},
+	{ErrStatementPeriod, http.StatusBadRequest, "STATEMENT_PERIOD_INVALID"},
+	{ErrNothingDue, http.StatusConflict, "NOTHING_DUE"},
+	{ErrOverpaymentLimit, http.StatusBadRequest, "OVERPAYMENT_LIMIT"},
+	{store.ErrBlockNotInSociety, http.StatusBadRequest, "BLOCK_NOT_IN_SOCIETY"},
+	{store.ErrPaymentNotPending, http.StatusConflict, "PAYMENT_NOT_PENDING"},
+	{store.ErrPaymentAmountMismatch, http.StatusBadRequest, "PAYMENT_AMOUNT_MISMATCH"},
+	{billing.ErrAreaUnknown, http.StatusConflict, "RESIDENCE_AREA_UNKNOWN"},
+	{payment.ErrUnknownProvider, http.StatusNotFound, "PAYMENT_PROVIDER_UNKNOWN"},
+	{payment.ErrInvalidSignature, http.StatusUnauthorized, "WEBHOOK_SIGNATURE_INVALID"},
+
+	// Guest invites and events.
+	{ErrInvalidInviteWindow, http.StatusBadRequest, "INVITE_WINDOW_INVALID"},
+	{store.ErrInviteClosed, http.StatusGone, "INVITE_CLOSED"},
+	{store.ErrInviteNotStarted, http.StatusGone, "INVITE_NOT_STARTED"},
+	{store.ErrGuestRegistered, http.StatusConflict, "GUEST_ALREADY_REGISTERED"},
+	{store.ErrPassNotValid, http.StatusConflict, "PASS_NOT_VALID"},
+	{store.ErrPassUsed, http.StatusConflict, "PASS_USED"},
+	{ErrInvalidEventWindow, http.StatusBadRequest, "EVENT_WINDOW_INVALID"},
+	{ErrEventVenueRequired, http.StatusBadRequest, "EVENT_VENUE_REQUIRED"},
+	{store.ErrInvalidVenue, http.StatusBadRequest, "EVENT_VENUE_INVALID"},
+	{store.ErrEventClosed, http.StatusConflict, "EVENT_CLOSED"},
+	{store.ErrEventNotStarted, http.StatusConflict, "EVENT_NOT_STARTED"},
+	{store.ErrEventFull, http.StatusConflict, "EVENT_FULL"},
+	{store.ErrGuestArrived, http.StatusConflict, "GUEST_ALREADY_ARRIVED"},
+	{store.ErrDuplicateEventGuest, http.StatusConflict, "EVENT_GUEST_DUPLICATE"},
+}
+
+// errorStatus is the status a known error is reported with, else 500.
+func errorStatus(err error) int {
+	if spec, ok := lookupError(err); ok {
+		return spec.status
+	}
+	return http.StatusInternalServerError
+}
+
+func lookupError(err error) (errorSpec, bool) {
+	var coded *Error
+	if errors.As(err, &coded) {
+		return errorSpec{err: coded.Err, status: coded.Status, code: coded.Code}, true
+	}
+	for _, spec := range errorSpecs {
+		if errors.Is(err, spec.err) {
+			return spec, true
+		}
+	}
+	return errorSpec{}, false
+}
+
+// problemFor builds the response for err. The status a handler chose wins
+// over the table, except that a known error reaching a handler's 500
+// fallback gets its proper status. Details of unknown errors stay out of
+// 5xx responses, as do database errors in any response.
+func problemFor(status int, err error) Problem {
+	p := Problem{Type: "about:blank"}
+
+	// A body cut off by LimitBody surfaces as a read error from binding,
+	// which handlers report as a bad request.
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		status = http.StatusRequestEntityTooLarge
+		err = fmt.Errorf("%w: limit is %d bytes", ErrBodyTooLarge, tooLarge.Limit)
+	}
+
+	if fields, ok := fieldErrors(err); ok {
+		p.Status = http.StatusBadRequest
+		p.Code = "VALIDATION_FAILED"
+		p.Detail = "request body failed validation"
+		p.Errors = fields
+	} else if spec, ok := lookupError(err); ok {
+		p.Status = status
+		if status == http.StatusInternalServerError {
+			p.Status = spec.status
+		}
+		p.Code = spec.code
+		p.Detail = err.Error()
+	} else if isBodyError(err) {
+		p.Status = http.StatusBadRequest
+		p.Code = "INVALID_BODY"
+		p.Detail = err.Error()
+	} else {
+		p.Status = status
+		p.Code = statusCode(status)
+		p.Detail = err.Error()
+	}
+
+	var pgErr *pgconn.PgError
+	if p.Status >= http.StatusInternalServerError || errors.As(err, &pgErr) {
+		p.Detail = http.StatusText(p.Status)
+	}
+	if p.Status >= http.StatusInternalServerError {
+		p.Code = "INTERNAL"
+	}
+
+	p.Title = http.StatusText(p.Status)
+	return p
+}
+
+// statusCode names an error no sentinel describes after its status.
+func statusCode(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "BAD_REQUEST"
+	case http.StatusUnauthorized:
+		return "UNAUTHORIZED"
+	case http.StatusForbidden:
+		return "FORBIDDEN"
+	case http.StatusNotFound:
+		return "NOT_FOUND"
+	case http.StatusConflict:
+		return "CONFLICT"
+	case http.StatusGone:
+		return "GONE"
+	case http.StatusRequestEntityTooLarge:
+		return "BODY_TOO_LARGE"
+	case http.StatusUnprocessableEntity:
+		return "UNPROCESSABLE"
+	case http.StatusTooManyRequests:
+		return "RATE_LIMITED"
+	case http.StatusServiceUnavailable:
+		return "UNAVAILABLE"
+	}
+	if status >= http.StatusInternalServerError {
+		return "INTERNAL"
+	}
+	return "ERROR"
+}
+
+// isBodyError reports whether err came from decoding a malformed body.
+func isBodyError(err error) bool {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var timeErr *time.ParseError
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.As(err, &syntaxErr) || errors.As(err, &typeErr) || errors.As(err, &timeErr)
+}
+
+// fieldErrors turns binding validation failures into per-field errors
+// named by their JSON paths.
+func fieldErrors(err error) ([]FieldError, bool) {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return []FieldError{{
+			Field:   typeErr.Field,
+			Code:    "TYPE",
+			Message: fmt.Sprintf("must be a %s", typeErr.Type),
+		}}, true
+	}
+
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		return nil, false
+	}
+
+	fields := make([]FieldError, len(verrs))
+	for i, fe := range verrs {
+		fields[i] = FieldError{
+			Field:   fieldPath(fe.Namespace()),
+			Code:    strings.ToUpper(fe.Tag()),
+			Message: fieldMessage(fe),
+		}
+	}
+	return fields, true
+}
+
+// fieldPath drops the request struct's name from a validator namespace,
+// leaving e.g. guests[0].name.
+func fieldPath(namespace string) string {
+	if i := strings.IndexByte(namespace, '.'); i >= 0 {
+		return namespace[i+1:]
+	}
+	return namespace
+}
+
+func fieldMessage(fe validator.FieldError) string {
+	unit := ""
+	switch fe.Kind() {
+	case reflect.String:
+		unit = " characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		unit = " items"
+	}
+
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "min", "gte":
+		return "must be at least " + fe.Param() + unit
+	case "max", "lte":
+		return "must be at most " + fe.Param() + unit
+	case "len":
+		return "must be exactly " + fe.Param() + unit
+	case "oneof":
+		return "must be one of " + fe.Param()
+	case "url":
+		return "must be a valid URL"
+	case "email":
+		return "must be a valid email address"
+	}
+	return "failed the " + fe.Tag() + " check"
+}
+
+func init() {
+	// Report fields by their JSON names rather than Go field names.
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(f reflect.StructField) string {
+			name := strings.SplitN(f.Tag.Get("json"), ",", 2)[0]
+			if name == "-" {
+				return ""
+			}
+			if name == "" {
+				return f.Name
+			}
+			return name
+		})
+	}
+}
+
+// respondError writes err as problem+json and logs it with the request's
+// context. Server errors are logged in full and recorded on the request's
+// span; clients only see that one happened.
+func (h *Handler) respondError(c *gin.Context, status int, err error) {
+	p := problemFor(status, err)
+	p.Instance = c.Request.URL.Path
+	p.RequestID = c.GetString(string(RequestIDKey))
+
+	attrs := []any{
+		"error", err,
+		"status", p.Status,
+		"code", p.Code,
+		"path", c.Request.URL.Path,
+		"client_ip", c.ClientIP(),
+	}
+	ctx := c.Request.Context()
+	if p.Status >= http.StatusInternalServerError {
+		h.log.ErrorContext(ctx, "handler error", attrs...)
+		trace.SpanFromContext(ctx).RecordError(err)
+	} else {
+		h.log.WarnContext(ctx, "handler error", attrs...)
+	}
+
+	c.Header("Content-Type", ProblemContentType)
+	c.Render(p.Status, problemRender{p})
+	c.Abort()
+}
+
+// problemRender writes a Problem with the problem+json content type, which
+// c.JSON would overwrite.
+type problemRender struct{ p Problem }
+
+func (r problemRender) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	return json.NewEncoder(w).Encode(r.p)
+}
+
+func (r problemRender) WriteContentType(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", ProblemContentType)
+}
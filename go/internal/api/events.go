@@ -0,0 +1,427 @@
+package api
+
+import (
+	"dooreye-backend/internal/accesscode"
+	"dooreye-backend/internal/metrics"
+	"dooreye-backend/internal/model"
+	"dooreye-backend/internal/notify"
+	"dooreye-backend/internal/store"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	ErrInvalidEventWindow = errors.New("ends_at must be after starts_at and in the future")
+	ErrEventVenueRequired = errors.New("amenity_id is required for society events")
+)
+
+// maxEventDuration bounds how long a guest list stays open at the gate.
+const maxEventDuration = 3 * 24 * time.Hour
+
+type CreateEventRequest struct {
+	Title     string    `json:"title" binding:"required,max=200"`
+	StartsAt  time.Time `json:"starts_at" binding:"required"`
+	EndsAt    time.Time `json:"ends_at" binding:"required"`
+	Capacity  int       `json:"capacity" binding:"required,min=1,max=5000"`
+	AmenityID *int64    `json:"amenity_id"`
+}
+
+// createEvent creates an event. Residents host at their residence, or at an
+// amenity they name; managers create society events, which must name the
+// amenity they are held at.
+func (h *Handler) createEvent(c *gin.Context) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	var req CreateEventRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if !req.EndsAt.After(req.StartsAt) || !req.EndsAt.After(time.Now()) ||
+		req.EndsAt.Sub(req.StartsAt) > maxEventDuration {
+		h.respondError(c, http.StatusBadRequest, ErrInvalidEventWindow)
+		return
+	}
+
+	params := store.CreateEventParams{
+		AmenityID: req.AmenityID,
+		Title:     req.Title,
+		StartsAt:  req.StartsAt,
+		EndsAt:    req.EndsAt,
+		Capacity:  req.Capacity,
+		CreatedBy: user.ID,
+	}
+
+	if isSocietyStaff(user) {
+		if req.AmenityID == nil {
+			h.respondError(c, http.StatusBadRequest, ErrEventVenueRequired)
+			return
+		}
+
+		societyID, err := societyIDParam(c, user)
+		if err != nil {
+			h.respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		params.SocietyID = *societyID
+	} else {
+		residenceID, ok := h.residenceInScope(c, user, nil)
+		if !ok {
+			return
+		}
+		params.ResidenceID = &residenceID
+
+		params.SocietyID, err = h.userSocietyID(c, user)
+		if err != nil {
+			h.respondError(c, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	event, err := h.db.CreateEvent(c.Request.Context(), params)
+	if err != nil {
+		h.respondError(c, errorStatus(err), err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": event})
+}
+
+// getEvents lists a resident's own events, or every event of the society
+// for staff. ?open=true narrows it to events the gate is admitting for.
+func (h *Handler) getEvents(c *gin.Context) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	filter := store.EventFilter{OnlyOpen: c.Query("open") == "true"}
+	filter.Limit, _ = strconv.Atoi(c.Query("limit"))
+	filter.Offset, _ = strconv.Atoi(c.Query("offset"))
+
+	if isSocietyStaff(user) {
+		societyID, err := societyIDParam(c, user)
+		if err != nil {
+			h.respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		filter.SocietyID = *societyID
+	} else {
+		residenceID, ok := h.residenceInScope(c, user, nil)
+		if !ok {
+			return
+		}
+		filter.ResidenceID = &residenceID
+
+		filter.SocietyID, err = h.userSocietyID(c, user)
+		if err != nil {
+			h.respondError(c, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	events, err := h.db.GetEvents(c.Request.Context(), filter)
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": events})
+}
+
+// getEvent returns the event with its live expected and arrived counts.
+func (h *Handler) getEvent(c *gin.Context) {
+	event, ok := h.eventInScope(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": event})
+}
+
+// closeEvent stops guest list check-in before the scheduled end. Later
+// arrivals go through normal visitor approval.
+func (h *Handler) closeEvent(c *gin.Context) {
+	event, ok := h.eventInScope(c)
+	if !ok {
+		return
+	}
+
+	event, err := h.db.CloseEvent(c.Request.Context(), event.ID)
+	if err != nil {
+		h.respondError(c, errorStatus(err), err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": event})
+}
+
+type EventGuestRequest struct {
+	Name      string  `json:"name" binding:"required,max=100"`
+	Phone     *string `json:"phone" binding:"omitempty,min=6,max=20"`
+	PartySize int     `json:"party_size" binding:"omitempty,min=1,max=20"`
+}
+
+type AddEventGuestsRequest struct {
+	Guests []EventGuestRequest `json:"guests" binding:"required,min=1,max=500,dive"`
+}
+
+// addEventGuests adds guests to the list, each with their own gate code.
+// The whole batch is added or none of it.
+func (h *Handler) addEventGuests(c *gin.Context) {
+	event, ok := h.eventInScope(c)
+	if !ok {
+		return
+	}
+
+	var req AddEventGuestsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	guests := make([]store.NewEventGuest, len(req.Guests))
+	for i, g := range req.Guests {
+		guests[i] = store.NewEventGuest{
+			Name:      strings.TrimSpace(g.Name),
+			Phone:     g.Phone,
+			PartySize: g.PartySize,
+		}
+		if guests[i].PartySize == 0 {
+			guests[i].PartySize = 1
+		}
+	}
+
+	var added []model.EventGuest
+	var err error
+	for i := 0; i < passCodeAttempts; i++ {
+		for j := range guests {
+			guests[j].Code, err = accesscode.Generate()
+			if err != nil {
+				break
+			}
+		}
+		if err != nil {
+			break
+		}
+
+		added, err = h.db.AddEventGuests(c.Request.Context(), event.ID, guests)
+		if !errors.Is(err, store.ErrDuplicatePassCode) {
+			break
+		}
+	}
+	if err != nil {
+		h.respondError(c, errorStatus(err), err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": added})
+}
+
+// getEventGuests searches the guest list by name, phone or code; this is
+// how the gate finds a guest. ?arrived=true|false filters by arrival.
+func (h *Handler) getEventGuests(c *gin.Context) {
+	event, ok := h.eventInScope(c)
+	if !ok {
+		return
+	}
+
+	filter := store.EventGuestFilter{Search: c.Query("q")}
+	filter.Limit, _ = strconv.Atoi(c.Query("limit"))
+	filter.Offset, _ = strconv.Atoi(c.Query("offset"))
+
+	if raw := c.Query("arrived"); raw != "" {
+		arrived, err := strconv.ParseBool(raw)
+		if err != nil {
+			h.respondError(c, http.StatusBadRequest, fmt.Errorf("invalid arrived: %w", err))
+			return
+		}
+		filter.Arrived = &arrived
+	}
+
+	guests, err := h.db.GetEventGuests(c.Request.Context(), event.ID, filter)
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": guests})
+}
+
+func (h *Handler) removeEventGuest(c *gin.Context) {
+	event, ok := h.eventInScope(c)
+	if !ok {
+		return
+	}
+
+	guestID, err := strconv.ParseInt(c.Param("guestId"), 10, 64)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, fmt.Errorf("invalid guest id: %w", err))
+		return
+	}
+
+	if err := h.db.RemoveEventGuest(c.Request.Context(), event.ID, guestID); err != nil {
+		h.respondError(c, errorStatus(err), err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+type CheckInEventGuestRequest struct {
+	GateID *int64 `json:"gate_id"`
+}
+
+// checkInEventGuest admits a guest the guard found on the list.
+func (h *Handler) checkInEventGuest(c *gin.Context) {
+	event, ok := h.eventInScope(c)
+	if !ok {
+		return
+	}
+
+	guestID, err := strconv.ParseInt(c.Param("guestId"), 10, 64)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, fmt.Errorf("invalid guest id: %w", err))
+		return
+	}
+
+	var req CheckInEventGuestRequest
+	if err := c.ShouldBindJSON(&req); err != nil && c.Request.ContentLength > 0 {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	h.admitEventGuest(c, store.CheckInEventGuestParams{
+		SocietyID: event.SocietyID,
+		EventID:   &event.ID,
+		GuestID:   &guestID,
+		GateID:    req.GateID,
+	})
+}
+
+type CheckInEventCodeRequest struct {
+	Code   string `json:"code" binding:"required"`
+	GateID *int64 `json:"gate_id"`
+}
+
+// checkInEventCode admits a guest by the code on their invitation, across
+// the guard's society's open events.
+func (h *Handler) checkInEventCode(c *gin.Context) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	var req CheckInEventCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	societyID, err := societyIDParam(c, user)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	h.admitEventGuest(c, store.CheckInEventGuestParams{
+		SocietyID: *societyID,
+		Code:      strings.ToUpper(strings.TrimSpace(req.Code)),
+		GateID:    req.GateID,
+	})
+}
+
+func (h *Handler) admitEventGuest(c *gin.Context, params store.CheckInEventGuestParams) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return
+	}
+	params.GuardID = user.ID
+
+	guest, event, err := h.db.CheckInEventGuest(c.Request.Context(), params)
+	if err != nil {
+		h.respondError(c, errorStatus(err), err)
+		return
+	}
+
+	metrics.CheckIn(model.VisitorGuest, guest.PartySize)
+
+	// Tell the host once, when the last place is taken.
+	if event.ArrivedCount == event.Capacity {
+		h.notifyEventHost(c, event, "EVENT_FULL",
+			event.Title+" is at capacity",
+			fmt.Sprintf("All %d places are taken; the gate is turning further guests away.", event.Capacity))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"guest": guest,
+		"event": event,
+	}})
+}
+
+func (h *Handler) notifyEventHost(c *gin.Context, event *model.Event, kind, title, body string) {
+	err := h.notifier.Send(c.Request.Context(), notify.Message{
+		UserIDs:  []string{event.CreatedBy.String()},
+		Kind:     kind,
+		Title:    title,
+		Body:     body,
+		Priority: notify.PriorityHigh,
+		Data: map[string]string{
+			"event_id": strconv.FormatInt(event.ID, 10),
+		},
+	})
+	if err != nil {
+		h.log.ErrorContext(c.Request.Context(), "notifying event host", "event_id", event.ID, "kind", kind, "error", err)
+	}
+}
+
+// eventInScope loads the event named by :id. Staff reach every event of
+// their society; residents the events of their residence. It writes the
+// error response itself.
+func (h *Handler) eventInScope(c *gin.Context) (*model.Event, bool) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return nil, false
+	}
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, fmt.Errorf("invalid event id: %w", err))
+		return nil, false
+	}
+
+	event, err := h.db.GetEvent(c.Request.Context(), id)
+	if err != nil {
+		h.respondError(c, errorStatus(err), err)
+		return nil, false
+	}
+
+	switch {
+	case user.Role == model.RoleAdmin:
+		return event, true
+	case isSocietyStaff(user):
+		if user.SocietyID != nil && *user.SocietyID == event.SocietyID {
+			return event, true
+		}
+	case event.ResidenceID != nil && user.ResidenceID != nil && *event.ResidenceID == *user.ResidenceID:
+		return event, true
+	}
+
+	h.respondError(c, http.StatusNotFound, store.ErrNotFound)
+	return nil, false
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"dooreye-backend/internal/store"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *Handler) getGates(c *gin.Context) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	societyID, err := h.userSocietyID(c, user)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	gates, err := h.db.GetGates(c.Request.Context(), societyID)
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gates})
+}
+
+type CreateGateRequest struct {
+	Name string `json:"name" binding:"required,max=50"`
+}
+
+func (h *Handler) createGate(c *gin.Context) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	var req CreateGateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	societyID, err := societyIDParam(c, user)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	gate, err := h.db.CreateGate(c.Request.Context(), *societyID, req.Name)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, store.ErrAlreadyExists) {
+			status = http.StatusConflict
+		}
+		h.respondError(c, status, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": gate})
+}
@@ -2,47 +2,423 @@ package api
 
 import (
 	"context"
+	"crypto/tls"
+	"dooreye-backend/internal/alerts"
+	"dooreye-backend/internal/billing"
+	"dooreye-backend/internal/model"
+	"dooreye-backend/internal/notify"
+	"dooreye-backend/internal/payment"
+	"dooreye-backend/internal/ratelimit"
 	"dooreye-backend/internal/store"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 type Handler struct {
-	db     *store.DB
-	log    *slog.Logger
-	router *gin.Engine
-	srv    *http.Server
+	db       *store.DB
+	notifier notify.Notifier
+	alerts   *alerts.Service
+	invoicer *billing.Invoicer
+	payments *payment.Registry
+	log      *slog.Logger
+	router   *gin.Engine
+	srv      *http.Server
+
+	activationLockout *ratelimit.Lockout
+	authLockout       *ratelimit.Lockout
+
+	// draining is set once Shutdown begins, failing readiness so load
+	// balancers stop sending traffic before the listener closes.
+	draining   atomic.Bool
+	drainDelay time.Duration
+
+	// readyMu guards ready, the last readiness result served publicly.
+	readyMu sync.Mutex
+	ready   Readiness
+
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+	idleTimeout  time.Duration
+	tlsConfig    *tls.Config
+}
+
+// Options tunes a Handler. The zero value is usable.
+type Options struct {
+	// DrainDelay is how long Shutdown keeps serving, with readiness
+	// failing, before it closes the listener. It should exceed the load
+	// balancer's probe interval.
+	DrainDelay time.Duration
+	// RateLimits holds rate limit and lockout state. It defaults to memory,
+	// which limits each instance separately.
+	RateLimits ratelimit.Backend
+	// ReadTimeout, WriteTimeout and IdleTimeout bound each connection, as
+	// on http.Server. They default to 10s, 10s and 60s.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+	// Docs serves the API reference at /docs.
+	Docs bool
+	// CORS lets browser apps on other origins call the API.
+	CORS CORSOptions
+	// HSTSMaxAge, if positive, tells browsers to use only HTTPS for this
+	// long. Set it only when the API is reached over HTTPS.
+	HSTSMaxAge time.Duration
+	// MaxBodyBytes caps request bodies. It defaults to 1 MiB.
+	MaxBodyBytes int64
+	// TrustedProxies lists the IPs and CIDRs whose X-Forwarded-For header
+	// names the client. By default none are, and the client is the peer.
+	TrustedProxies []string
+	// TLS, if set, makes Run serve HTTPS with this configuration.
+	TLS *tls.Config
 }
 
-func NewHandler(db *store.DB, log *slog.Logger) *Handler {
+func NewHandler(db *store.DB, notifier notify.Notifier, payments *payment.Registry, log *slog.Logger, opts Options) *Handler {
+	limits := opts.RateLimits
+	if limits == nil {
+		limits = ratelimit.NewMemory()
+	}
+	if opts.ReadTimeout <= 0 {
+		opts.ReadTimeout = 10 * time.Second
+	}
+	if opts.WriteTimeout <= 0 {
+		opts.WriteTimeout = 10 * time.Second
+	}
+	if opts.IdleTimeout <= 0 {
+		opts.IdleTimeout = 60 * time.Second
+	}
+	if opts.MaxBodyBytes <= 0 {
+		opts.MaxBodyBytes = defaultMaxBodyBytes
+	}
+
 	h := &Handler{
-		db:  db,
-		log: log,
+		db:         db,
+		notifier:   notifier,
+		alerts:     alerts.NewService(db, notifier, log),
+		invoicer:   billing.NewInvoicer(db, notifier, log),
+		payments:   payments,
+		log:        log,
+		drainDelay: opts.DrainDelay,
+
+		readTimeout:  opts.ReadTimeout,
+		writeTimeout: opts.WriteTimeout,
+		idleTimeout:  opts.IdleTimeout,
+		tlsConfig:    opts.TLS,
+
+		activationLockout: newActivationLockout(limits),
+		authLockout:       newAuthLockout(limits),
 	}
 
 	router := gin.New()
 
-	router.Use(gin.Recovery())
+	// Rate limits and lockouts key on ClientIP, so a forwarded address is
+	// only believed from a proxy we run. Gin trusts every proxy by default
+	// and keeps doing so if the list is rejected.
+	if err := router.SetTrustedProxies(opts.TrustedProxies); err != nil {
+		log.Error("invalid trusted proxies, trusting none", "error", err)
+		_ = router.SetTrustedProxies(nil)
+	}
+
+	router.HandleMethodNotAllowed = true
+	router.NoRoute(func(c *gin.Context) { h.respondError(c, http.StatusNotFound, ErrRouteNotFound) })
+	router.NoMethod(func(c *gin.Context) { h.respondError(c, http.StatusMethodNotAllowed, ErrMethodNotAllowed) })
+
+	router.Use(h.Tracing())
+	router.Use(h.RequestID())
 	router.Use(h.LoggerMiddleware())
+	router.Use(h.RequestMetrics())
+	router.Use(gin.CustomRecovery(func(c *gin.Context, recovered any) {
+		h.respondError(c, http.StatusInternalServerError, fmt.Errorf("%w: panic: %v", ErrInternal, recovered))
+	}))
+	router.Use(h.SecurityHeaders(opts.HSTSMaxAge))
+	router.Use(h.CORS(opts.CORS))
+	router.Use(h.LimitBody(opts.MaxBodyBytes))
 
-	router.GET("/health", h.handleHealth())
+	router.GET("/livez", h.handleLivez)
+	router.GET("/readyz", h.handleReadyz)
+	router.GET("/health", h.handleReadyz)
+	router.GET("/openapi.json", h.handleOpenAPI)
+	if opts.Docs {
+		router.GET("/docs", h.handleDocs)
+	}
+	router.POST("/activate",
+		h.RateLimit(ratelimit.NewLimiter("activate", activateRate, limits), byIP),
+		h.redeemAccessCode)
+	router.POST("/webhooks/payments/:provider", h.handlePaymentWebhook)
+
+	invitePages := router.Group("/invites/:token")
+	invitePages.Use(h.RateLimit(ratelimit.NewLimiter("invites", inviteRate, limits), byIP))
+	{
+		invitePages.GET("", h.getPublicGuestInvite)
+		invitePages.POST("/register", h.registerGuest)
+	}
 
 	api := router.Group("/api")
+	api.Use(h.RateLimit(ratelimit.NewLimiter("api-ip", apiIPRate, limits), byIP))
 	api.Use(h.AuthMiddleware())
+	api.Use(h.RateLimit(ratelimit.NewLimiter("api-user", apiUserRate, limits), byUser))
 	{
 		api.GET("/visitors", h.getVisitorByPhone)
 		api.GET("/visits", h.getVisits)
+		api.GET("/visits/overstays", h.getOverstays)
 
 		api.POST("/visits/security", h.createVisitAsSecurity)
+		api.POST("/visits/:id/approve",
+			h.RequireRoles(model.RoleOwner, model.RoleResident),
+			h.approveVisit)
 		api.POST(
 			"/visitors/pre-approved",
 			h.createPreApprovedVisitor,
 		)
 		api.POST("/users/activate",
+			h.RequireRoles(model.RoleAdmin, model.RoleSocietyManager),
 			h.createUser)
+
+		api.GET("/stay-limits",
+			h.RequireRoles(model.RoleAdmin, model.RoleSocietyManager, model.RoleSecurity),
+			h.getStayLimits)
+		api.PUT("/stay-limits/:visitor_type",
+			h.RequireRoles(model.RoleAdmin, model.RoleSocietyManager),
+			h.updateStayLimit)
+
+		api.GET("/gates", h.getGates)
+		api.POST("/gates",
+			h.RequireRoles(model.RoleAdmin, model.RoleSocietyManager),
+			h.createGate)
+
+		api.POST("/alerts", h.raiseAlert)
+		api.GET("/alerts", h.getAlerts)
+		api.GET("/alerts/:id", h.getAlert)
+		api.POST("/alerts/:id/acknowledge",
+			h.RequireRoles(model.RoleAdmin, model.RoleSocietyManager, model.RoleSecurity),
+			h.acknowledgeAlert)
+		api.POST("/alerts/:id/resolve", h.resolveAlert)
+
+		api.PUT("/presence",
+			h.RequireRoles(model.RoleOwner, model.RoleResident),
+			h.setPresence)
+		api.POST("/staff/punch-in",
+			h.RequireRoles(model.RoleSocietyManager, model.RoleSecurity),
+			h.punchIn)
+		api.POST("/staff/punch-out",
+			h.RequireRoles(model.RoleSocietyManager, model.RoleSecurity),
+			h.punchOut)
+	}
+
+	me := api.Group("/me")
+	{
+		me.GET("", h.getMe)
+		me.GET("/devices", h.getDevices)
+		me.POST("/devices", h.addDevice)
+		me.DELETE("/devices/:id", h.revokeDevice)
+	}
+
+	vehicles := api.Group("/vehicles")
+	{
+		vehicles.GET("", h.getVehicles)
+		vehicles.POST("",
+			h.RequireRoles(model.RoleAdmin, model.RoleSocietyManager, model.RoleOwner, model.RoleResident),
+			h.registerVehicle)
+		vehicles.DELETE("/:id",
+			h.RequireRoles(model.RoleAdmin, model.RoleSocietyManager, model.RoleOwner, model.RoleResident),
+			h.deleteVehicle)
+
+		gate := vehicles.Group("")
+		gate.Use(h.RequireRoles(model.RoleAdmin, model.RoleSocietyManager, model.RoleSecurity))
+		gate.GET("/lookup", h.lookupVehicle)
+		gate.GET("/entries", h.getVehicleEntries)
+		gate.POST("/entries", h.logVehicleEntry)
+		gate.POST("/exits", h.logVehicleExit)
+		gate.GET("/occupancy", h.getParkingOccupancy)
+	}
+
+	parking := api.Group("/parking")
+	{
+		parking.GET("/slots", h.getParkingSlots)
+		parking.GET("/guest-availability", h.getGuestParkingAvailability)
+		parking.GET("/bookings", h.getGuestBookings)
+		parking.POST("/bookings",
+			h.RequireRoles(model.RoleOwner, model.RoleResident),
+			h.createGuestBooking)
+		parking.DELETE("/bookings/:id",
+			h.RequireRoles(model.RoleAdmin, model.RoleSocietyManager, model.RoleOwner, model.RoleResident),
+			h.cancelGuestBooking)
+
+		manage := parking.Group("/slots")
+		manage.Use(h.RequireRoles(model.RoleAdmin, model.RoleSocietyManager))
+		manage.POST("", h.createParkingSlot)
+		manage.PUT("/:id/assignment", h.assignParkingSlot)
+		manage.DELETE("/:id", h.retireParkingSlot)
+	}
+
+	amenities := api.Group("/amenities")
+	{
+		amenities.GET("", h.getAmenities)
+		amenities.GET("/bookings", h.getAmenityBookings)
+		amenities.POST("/bookings/:id/cancel",
+			h.RequireRoles(model.RoleAdmin, model.RoleSocietyManager, model.RoleOwner, model.RoleResident),
+			h.cancelAmenityBooking)
+		amenities.GET("/:id/availability", h.getAmenityAvailability)
+		amenities.POST("/:id/bookings",
+			h.RequireRoles(model.RoleOwner, model.RoleResident),
+			h.createAmenityBooking)
+
+		manage := amenities.Group("")
+		manage.Use(h.RequireRoles(model.RoleAdmin, model.RoleSocietyManager))
+		manage.POST("", h.createAmenity)
+		manage.PUT("/:id", h.updateAmenity)
+		manage.DELETE("/:id", h.deleteAmenity)
+		manage.GET("/:id/calendar", h.getAmenityCalendar)
+	}
+
+	tickets := api.Group("/tickets")
+	{
+		tickets.GET("", h.getTickets)
+		tickets.POST("",
+			h.RequireRoles(model.RoleOwner, model.RoleResident),
+			h.createTicket)
+		tickets.GET("/:id", h.getTicket)
+		tickets.POST("/:id/comments", h.addTicketComment)
+		tickets.POST("/:id/photos", h.addTicketPhoto)
+		tickets.POST("/:id/close",
+			h.RequireRoles(model.RoleOwner, model.RoleResident),
+			h.closeTicket)
+		tickets.POST("/:id/reopen",
+			h.RequireRoles(model.RoleOwner, model.RoleResident),
+			h.reopenTicket)
+		tickets.POST("/:id/status",
+			h.RequireRoles(model.RoleAdmin, model.RoleSocietyManager, model.RoleSecurity),
+			h.updateTicketStatus)
+
+		manage := tickets.Group("")
+		manage.Use(h.RequireRoles(model.RoleAdmin, model.RoleSocietyManager))
+		manage.POST("/:id/assign", h.assignTicket)
+		manage.GET("/sla-policies", h.getTicketSLAPolicies)
+		manage.PUT("/sla-policies/:priority", h.updateTicketSLAPolicy)
+	}
+
+	announcements := api.Group("/announcements")
+	{
+		announcements.GET("", h.getAnnouncementFeed)
+		announcements.POST("/read-all", h.readAllAnnouncements)
+		announcements.GET("/:id", h.getAnnouncement)
+		announcements.POST("/:id/read", h.readAnnouncement)
+
+		manage := announcements.Group("")
+		manage.Use(h.RequireRoles(model.RoleAdmin, model.RoleSocietyManager))
+		manage.GET("/all", h.getAllAnnouncements)
+		manage.POST("", h.createAnnouncement)
+		manage.PATCH("/:id", h.updateAnnouncement)
+		manage.POST("/:id/publish", h.publishAnnouncement)
+		manage.DELETE("/:id", h.withdrawAnnouncement)
+	}
+
+	polls := api.Group("/polls")
+	{
+		polls.GET("", h.getPolls)
+		polls.GET("/:id", h.getPoll)
+		polls.POST("/:id/votes",
+			h.RequireRoles(model.RoleOwner, model.RoleResident),
+			h.castVote)
+		polls.GET("/:id/results", h.getPollResults)
+		polls.GET("/:id/export", h.exportPoll)
+		polls.GET("/:id/verify", h.verifyPoll)
+
+		manage := polls.Group("")
+		manage.Use(h.RequireRoles(model.RoleAdmin, model.RoleSocietyManager))
+		manage.POST("", h.createPoll)
+		manage.POST("/:id/close", h.closePoll)
+		manage.POST("/:id/cancel", h.cancelPoll)
+	}
+
+	bills := api.Group("/billing")
+	{
+		bills.GET("/invoices", h.getInvoices)
+		bills.GET("/invoices/:id", h.getInvoice)
+		bills.GET("/payments", h.getPayments)
+		bills.GET("/statement", h.getStatement)
+		bills.POST("/checkout",
+			h.RequireRoles(model.RoleOwner, model.RoleResident),
+			h.createCheckout)
+
+		manage := bills.Group("")
+		manage.Use(h.RequireRoles(model.RoleAdmin, model.RoleSocietyManager))
+		manage.GET("/settings", h.getBillingSettings)
+		manage.PUT("/settings", h.updateBillingSettings)
+		manage.GET("/rules", h.getBillingRules)
+		manage.POST("/rules", h.createBillingRule)
+		manage.PUT("/rules/:id", h.updateBillingRule)
+		manage.DELETE("/rules/:id", h.deactivateBillingRule)
+		manage.PUT("/residences/:id/area", h.setResidenceArea)
+		manage.POST("/invoices/generate", h.generateInvoices)
+		manage.POST("/payments", h.recordPayment)
+	}
+
+	invites := api.Group("/guest-invites")
+	{
+		host := invites.Group("")
+		host.Use(h.RequireRoles(model.RoleOwner, model.RoleResident))
+		host.GET("", h.getGuestInvites)
+		host.POST("", h.createGuestInvite)
+		host.GET("/:id", h.getGuestInvite)
+		host.POST("/:id/revoke", h.revokeGuestInvite)
+
+		gate := invites.Group("/passes")
+		gate.Use(h.RequireRoles(model.RoleAdmin, model.RoleSocietyManager, model.RoleSecurity))
+		gate.GET("/:code", h.getGuestPass)
+		gate.POST("/:code/check-in", h.checkInGuestPass)
+	}
+
+	events := api.Group("/events")
+	{
+		events.GET("", h.getEvents)
+		events.GET("/:id", h.getEvent)
+		events.GET("/:id/guests", h.getEventGuests)
+
+		host := events.Group("")
+		host.Use(h.RequireRoles(model.RoleAdmin, model.RoleSocietyManager, model.RoleOwner, model.RoleResident))
+		host.POST("", h.createEvent)
+		host.POST("/:id/close", h.closeEvent)
+		host.POST("/:id/guests", h.addEventGuests)
+		host.DELETE("/:id/guests/:guestId", h.removeEventGuest)
+
+		gate := events.Group("")
+		gate.Use(h.RequireRoles(model.RoleAdmin, model.RoleSocietyManager, model.RoleSecurity))
+		gate.POST("/check-in", h.checkInEventCode)
+		gate.POST("/:id/guests/:guestId/check-in", h.checkInEventGuest)
+	}
+
+	household := api.Group("/household")
+	household.Use(h.RequireRoles(model.RoleOwner))
+	{
+		household.GET("/members", h.getHouseholdMembers)
+		household.POST("/members", h.inviteHouseholdMember)
+		household.PATCH("/members/:id", h.updateHouseholdMember)
+		household.DELETE("/members/:id", h.removeHouseholdMember)
+	}
+
+	headcount := api.Group("/headcount")
+	headcount.Use(h.RequireRoles(model.RoleAdmin, model.RoleSocietyManager, model.RoleSecurity))
+	{
+		headcount.GET("", h.getHeadcount)
+		headcount.POST("/events", h.startHeadcount)
+		headcount.GET("/events/:id", h.getHeadcountEvent)
+		headcount.POST("/events/:id/close", h.closeHeadcountEvent)
+		headcount.POST("/events/:id/marks", h.markAccounted)
+		headcount.DELETE("/events/:id/marks/:subject_type/:subject_id", h.unmarkAccounted)
+	}
+
+	admin := api.Group("/admin")
+	admin.Use(h.RequireRoles(model.RoleAdmin))
+	{
+		admin.GET("/jobs", h.getJobs)
+		admin.GET("/jobs/:id", h.getJob)
+		admin.POST("/jobs/:id/retry", h.retryJob)
 	}
 	h.router = router
 	return h
@@ -52,15 +428,31 @@ func (h *Handler) Run(addr string) error {
 	h.srv = &http.Server{
 		Addr:         addr,
 		Handler:      h.router,
-		ReadTimeout:  10 * time.Second,
-		WriteTimeout: 10 * time.Second,
-		IdleTimeout:  60 * time.Second,
+		ReadTimeout:  h.readTimeout,
+		WriteTimeout: h.writeTimeout,
+		IdleTimeout:  h.idleTimeout,
+		TLSConfig:    h.tlsConfig,
 	}
 
+	if h.tlsConfig != nil {
+		// Certificates come from TLSConfig, so no files are named here.
+		return h.srv.ListenAndServeTLS("", "")
+	}
 	return h.srv.ListenAndServe()
 }
 
+// Shutdown fails readiness at once, waits out the drain delay, then stops
+// accepting connections and waits for in-flight requests.
 func (h *Handler) Shutdown(ctx context.Context) error {
+	h.draining.Store(true)
+
+	if h.drainDelay > 0 {
+		select {
+		case <-time.After(h.drainDelay):
+		case <-ctx.Done():
+		}
+	}
+
 	return h.srv.Shutdown(ctx)
 }
 
@@ -68,21 +460,32 @@ func (h *Handler) Close() error {
 	return h.srv.Close()
 }
 
-func (h *Handler) handleHealth() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{
-			"status": "ok",
-			"time":   time.Now(),
-		})
+// Helper methods
+
+// userSocietyID returns the society of the authenticated user. Residents are
+// attached to a residence rather than a society, so theirs is looked up;
+// admins pick one via ?society_id.
+func (h *Handler) userSocietyID(c *gin.Context, user *AuthUser) (int64, error) {
+	if user.Role == model.RoleAdmin {
+		societyID, err := societyIDParam(c, user)
+		if err != nil {
+			return 0, err
+		}
+		return *societyID, nil
 	}
-}
 
-// Helper methods
-func (h *Handler) respondError(c *gin.Context, status int, err error) {
-	h.log.Error("handler error",
-		"error", err,
-		"path", c.Request.URL.Path,
-		"client_ip", c.ClientIP(),
-	)
-	c.JSON(status, gin.H{"error": err.Error()})
+	if user.SocietyID != nil {
+		return *user.SocietyID, nil
+	}
+
+	if user.ResidenceID != nil {
+		for _, m := range user.Memberships {
+			if m.ResidenceID == *user.ResidenceID {
+				return m.SocietyID, nil
+			}
+		}
+		return h.db.GetResidenceSocietyID(c.Request.Context(), *user.ResidenceID)
+	}
+
+	return 0, ErrSocietyRequired
 }
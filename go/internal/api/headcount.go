@@ -0,0 +1,387 @@
+package api
+
+import (
+	"dooreye-backend/internal/model"
+	"dooreye-backend/internal/store"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+)
+
+type SetPresenceRequest struct {
+	IsHome *bool `json:"is_home" binding:"required"`
+}
+
+func (h *Handler) setPresence(c *gin.Context) {
+	var req SetPresenceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.db.SetResidentPresence(c.Request.Context(), c.GetString("user_id"), *req.IsHome); err != nil {
+		h.respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{"is_home": *req.IsHome}})
+}
+
+type PunchInRequest struct {
+	GateID *int64 `json:"gate_id"`
+}
+
+// punchIn starts the caller's shift, so headcounts list them while they
+// are on premises.
+func (h *Handler) punchIn(c *gin.Context) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return
+	}
+	if user.SocietyID == nil {
+		h.respondError(c, http.StatusBadRequest, ErrSocietyRequired)
+		return
+	}
+
+	var req PunchInRequest
+	if err := c.ShouldBindJSON(&req); err != nil && c.Request.ContentLength > 0 {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if req.GateID != nil {
+		gate, err := h.db.GetGate(c.Request.Context(), *req.GateID)
+		if err == nil && gate.SocietyID != *user.SocietyID {
+			err = ErrGateNotFound
+		}
+		if err != nil {
+			h.respondError(c, errorStatus(err), err)
+			return
+		}
+	}
+
+	shift, err := h.db.PunchIn(c.Request.Context(), store.PunchInParams{
+		SocietyID: *user.SocietyID,
+		UserID:    user.ID,
+		GateID:    req.GateID,
+	})
+	if err != nil {
+		h.respondError(c, errorStatus(err), err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": shift})
+}
+
+// punchOut ends the caller's shift.
+func (h *Handler) punchOut(c *gin.Context) {
+	shift, err := h.db.PunchOut(c.Request.Context(), c.GetString(string(UserIDKey)))
+	if err != nil {
+		h.respondError(c, errorStatus(err), err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": shift})
+}
+
+// getHeadcount returns the live roll call without an event, for a quick look
+// at who is inside.
+func (h *Handler) getHeadcount(c *gin.Context) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	societyID, err := societyIDParam(c, user)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	entries, err := h.db.GetHeadcount(c.Request.Context(), store.HeadcountFilter{
+		SocietyID:        *societyID,
+		IncludeResidents: c.Query("include_residents") == "true",
+	})
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	h.respondRollCall(c, nil, entries)
+}
+
+type StartHeadcountRequest struct {
+	Reason           *string `json:"reason"`
+	IncludeResidents *bool   `json:"include_residents"`
+}
+
+func (h *Handler) startHeadcount(c *gin.Context) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	var req StartHeadcountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	societyID, err := societyIDParam(c, user)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	includeResidents := true
+	if req.IncludeResidents != nil {
+		includeResidents = *req.IncludeResidents
+	}
+
+	event, err := h.db.StartHeadcountEvent(c.Request.Context(), store.StartHeadcountParams{
+		SocietyID:        *societyID,
+		Reason:           req.Reason,
+		IncludeResidents: includeResidents,
+		StartedBy:        user.ID,
+	})
+	if err != nil {
+		h.respondHeadcountError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": event})
+}
+
+func (h *Handler) getHeadcountEvent(c *gin.Context) {
+	event, ok := h.loadHeadcountEvent(c)
+	if !ok {
+		return
+	}
+
+	eventID := event.ID.String()
+	entries, err := h.db.GetHeadcount(c.Request.Context(), store.HeadcountFilter{
+		SocietyID:        event.SocietyID,
+		IncludeResidents: event.IncludeResidents,
+		EventID:          &eventID,
+	})
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	h.respondRollCall(c, event, entries)
+}
+
+func (h *Handler) closeHeadcountEvent(c *gin.Context) {
+	event, ok := h.loadHeadcountEvent(c)
+	if !ok {
+		return
+	}
+
+	event, err := h.db.CloseHeadcountEvent(c.Request.Context(), event.ID.String(), c.GetString("user_id"))
+	if err != nil {
+		h.respondHeadcountError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": event})
+}
+
+type MarkAccountedRequest struct {
+	SubjectType model.HeadcountSubject `json:"subject_type" binding:"required,oneof=VISIT RESIDENT STAFF"`
+	SubjectID   uuid.UUID              `json:"subject_id" binding:"required"`
+}
+
+func (h *Handler) markAccounted(c *gin.Context) {
+	event, ok := h.loadHeadcountEvent(c)
+	if !ok {
+		return
+	}
+
+	var req MarkAccountedRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	err := h.db.MarkAccounted(c.Request.Context(), event.ID.String(),
+		req.SubjectType, req.SubjectID.String(), c.GetString("user_id"))
+	if err != nil {
+		h.respondHeadcountError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *Handler) unmarkAccounted(c *gin.Context) {
+	event, ok := h.loadHeadcountEvent(c)
+	if !ok {
+		return
+	}
+
+	subjectType := model.HeadcountSubject(c.Param("subject_type"))
+	if subjectType != model.HeadcountVisit && subjectType != model.HeadcountResident && subjectType != model.HeadcountStaff {
+		h.respondError(c, http.StatusBadRequest, fmt.Errorf("invalid subject_type %q", subjectType))
+		return
+	}
+
+	subjectID, err := uuid.FromString(c.Param("subject_id"))
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, fmt.Errorf("invalid subject_id: %w", err))
+		return
+	}
+
+	err = h.db.UnmarkAccounted(c.Request.Context(), event.ID.String(), subjectType, subjectID.String())
+	if err != nil {
+		h.respondHeadcountError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *Handler) loadHeadcountEvent(c *gin.Context) (*model.HeadcountEvent, bool) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return nil, false
+	}
+
+	id, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, fmt.Errorf("invalid headcount id: %w", err))
+		return nil, false
+	}
+
+	event, err := h.db.GetHeadcountEvent(c.Request.Context(), id.String())
+	if err != nil {
+		h.respondHeadcountError(c, err)
+		return nil, false
+	}
+
+	if user.Role != model.RoleAdmin && (user.SocietyID == nil || *user.SocietyID != event.SocietyID) {
+		h.respondError(c, http.StatusNotFound, store.ErrNotFound)
+		return nil, false
+	}
+
+	return event, true
+}
+
+func (h *Handler) respondHeadcountError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, store.ErrHeadcountOpen), errors.Is(err, store.ErrHeadcountClosed):
+		status = http.StatusConflict
+	}
+	h.respondError(c, status, err)
+}
+
+// respondRollCall writes the roll call grouped by block and gate, or as a
+// flat CSV sheet for printing when ?format=csv is given.
+func (h *Handler) respondRollCall(c *gin.Context, event *model.HeadcountEvent, entries []model.HeadcountEntry) {
+	if c.Query("format") == "csv" {
+		name := fmt.Sprintf("roll-call-%s.csv", time.Now().Format("20060102-1504"))
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+		c.Header("Content-Type", "text/csv")
+		c.Status(http.StatusOK)
+
+		if err := writeRollCallCSV(c.Writer, entries); err != nil {
+			h.log.ErrorContext(c.Request.Context(), "writing roll call csv", "error", err)
+		}
+		return
+	}
+
+	accounted := 0
+	for _, e := range entries {
+		if e.AccountedAt != nil {
+			accounted++
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"event":     event,
+		"total":     len(entries),
+		"accounted": accounted,
+		"groups":    groupHeadcount(entries),
+	}})
+}
+
+func groupHeadcount(entries []model.HeadcountEntry) []model.HeadcountGroup {
+	type key struct{ block, gate int64 }
+
+	var groups []model.HeadcountGroup
+	index := make(map[key]int)
+
+	for _, e := range entries {
+		var k key
+		if e.BlockID != nil {
+			k.block = *e.BlockID
+		}
+		if e.GateID != nil {
+			k.gate = *e.GateID
+		}
+
+		i, ok := index[k]
+		if !ok {
+			i = len(groups)
+			index[k] = i
+			groups = append(groups, model.HeadcountGroup{
+				BlockID:   e.BlockID,
+				BlockName: e.BlockName,
+				GateID:    e.GateID,
+				GateName:  e.GateName,
+			})
+		}
+
+		groups[i].Total++
+		if e.AccountedAt != nil {
+			groups[i].Accounted++
+		}
+		groups[i].Entries = append(groups[i].Entries, e)
+	}
+
+	return groups
+}
+
+func writeRollCallCSV(w http.ResponseWriter, entries []model.HeadcountEntry) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{
+		"block", "gate", "residence", "category", "name", "phone",
+		"inside_since", "accounted", "subject_type", "subject_id",
+	})
+
+	for _, e := range entries {
+		accounted := ""
+		if e.AccountedAt != nil {
+			accounted = e.AccountedAt.Format(time.RFC3339)
+		}
+
+		cw.Write([]string{
+			deref(e.BlockName), deref(e.GateName), deref(e.ResidenceNumber),
+			string(e.Category), e.Name, deref(e.Phone),
+			e.Since.Format(time.RFC3339), accounted,
+			string(e.SubjectType), e.SubjectID.String(),
+		})
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,248 @@
+package api
+
+import (
+	"context"
+	"dooreye-backend/internal/store"
+	"dooreye-backend/migrations"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// readinessCheckTimeout bounds each dependency check, so a hung dependency
+// makes the probe fail rather than hang.
+const readinessCheckTimeout = 2 * time.Second
+
+// readinessCacheTTL is how long the public probe reuses a result, so
+// anonymous callers cannot make every hit query the database.
+const readinessCacheTTL = time.Second
+
+// Readiness states reported by /readyz.
+const (
+	StatusReady    = "ready"
+	StatusDegraded = "degraded"
+	StatusNotReady = "not_ready"
+	StatusDraining = "draining"
+)
+
+// Dependency check results.
+const (
+	CheckOK        = "ok"
+	CheckFailing   = "failing"
+	CheckUnchecked = "unchecked"
+)
+
+// HealthChecker is implemented by dependencies that can report whether
+// they are usable, such as a notification provider.
+type HealthChecker interface {
+	Check(ctx context.Context) error
+}
+
+// dependencyCheck is one entry of the readiness report. A failing critical
+// check makes the server not ready; other failures only degrade it.
+type dependencyCheck struct {
+	name     string
+	critical bool
+	check    func(ctx context.Context) error
+}
+
+// CheckResult is one dependency's status. Latency and error are reported
+// only on the admin listener; they can name hosts and schema versions.
+type CheckResult struct {
+	Status    string `json:"status"`
+	Critical  bool   `json:"critical"`
+	LatencyMS int64  `json:"latency_ms,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+type Readiness struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+	Time   time.Time              `json:"time"`
+}
+
+var (
+	ErrMigrationsDirty   = errors.New("last migration failed and left the schema dirty")
+	ErrMigrationsMissing = errors.New("schema_migrations has no version")
+)
+
+// dependencyChecks lists what /readyz verifies. The notifier is checked
+// only if it can report its own health.
+func (h *Handler) dependencyChecks() []dependencyCheck {
+	checks := []dependencyCheck{
+		{name: "database", critical: true, check: h.db.Ping},
+		{name: "migrations", critical: true, check: h.checkMigrations},
+	}
+
+	if checker, ok := h.notifier.(HealthChecker); ok {
+		checks = append(checks, dependencyCheck{name: "notifier", check: checker.Check})
+	} else {
+		checks = append(checks, dependencyCheck{name: "notifier"})
+	}
+
+	return checks
+}
+
+// checkMigrations fails unless the database is at exactly the version this
+// build ships, so a new release is not sent traffic before it is migrated
+// and an old one stops taking traffic once the schema moves past it.
+func (h *Handler) checkMigrations(ctx context.Context) error {
+	want, err := migrations.Latest()
+	if err != nil {
+		return err
+	}
+
+	got, dirty, err := h.db.MigrationVersion(ctx)
+	if errors.Is(err, store.ErrNotFound) {
+		return ErrMigrationsMissing
+	}
+	if err != nil {
+		return err
+	}
+	if dirty {
+		return fmt.Errorf("%w at version %d", ErrMigrationsDirty, got)
+	}
+	if got != want {
+		return fmt.Errorf("schema is at version %d, expected %d", got, want)
+	}
+	return nil
+}
+
+// readiness runs every dependency check concurrently.
+func (h *Handler) readiness(ctx context.Context) Readiness {
+	checks := h.dependencyChecks()
+	results := make([]CheckResult, len(checks))
+
+	var wg sync.WaitGroup
+	for i, dc := range checks {
+		if dc.check == nil {
+			results[i] = CheckResult{Status: CheckUnchecked, Critical: dc.critical}
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, dc dependencyCheck) {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(ctx, readinessCheckTimeout)
+			defer cancel()
+
+			start := time.Now()
+			err := dc.check(ctx)
+
+			results[i] = CheckResult{
+				Status:    CheckOK,
+				Critical:  dc.critical,
+				LatencyMS: time.Since(start).Milliseconds(),
+			}
+			if err != nil {
+				results[i].Status = CheckFailing
+				results[i].Error = err.Error()
+			}
+		}(i, dc)
+	}
+	wg.Wait()
+
+	for i, dc := range checks {
+		if results[i].Status == CheckFailing {
+			h.log.WarnContext(ctx, "dependency check failing",
+				"check", dc.name, "critical", dc.critical, "error", results[i].Error)
+		}
+	}
+
+	r := Readiness{
+		Status: StatusReady,
+		Checks: make(map[string]CheckResult, len(checks)),
+		Time:   time.Now(),
+	}
+	for i, dc := range checks {
+		res := results[i]
+		r.Checks[dc.name] = res
+		if res.Status != CheckFailing {
+			continue
+		}
+		if res.Critical {
+			r.Status = StatusNotReady
+		} else if r.Status == StatusReady {
+			r.Status = StatusDegraded
+		}
+	}
+
+	return r
+}
+
+// handleLivez reports that the process is serving. It checks no
+// dependencies, so a database outage does not get the server restarted.
+func (h *Handler) handleLivez(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status": "ok",
+		"time":   time.Now(),
+	})
+}
+
+// cachedReadiness returns the last readiness result if it is fresh enough,
+// and runs the checks otherwise. Concurrent callers share one run.
+func (h *Handler) cachedReadiness(ctx context.Context) Readiness {
+	h.readyMu.Lock()
+	defer h.readyMu.Unlock()
+
+	if time.Since(h.ready.Time) < readinessCacheTTL {
+		return h.ready
+	}
+
+	h.ready = h.readiness(ctx)
+	return h.ready
+}
+
+// handleReadyz reports whether the server should be sent traffic. It
+// answers 503 while a critical dependency fails or once Shutdown begins.
+// Only the status of each check is shown; the details are logged and
+// served on the admin listener by ServeReadiness.
+func (h *Handler) handleReadyz(c *gin.Context) {
+	status, r := h.readinessReport(h.cachedReadiness)
+	for name, res := range r.Checks {
+		r.Checks[name] = CheckResult{Status: res.Status, Critical: res.Critical}
+	}
+	c.JSON(status, r)
+}
+
+// ServeReadiness serves the full readiness report, with each check's
+// latency and error, for the admin listener.
+func (h *Handler) ServeReadiness(w http.ResponseWriter, r *http.Request) {
+	status, report := h.readinessReport(h.readiness)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		h.log.WarnContext(r.Context(), "writing readiness report", "error", err)
+	}
+}
+
+func (h *Handler) readinessReport(run func(context.Context) Readiness) (int, Readiness) {
+	if h.draining.Load() {
+		return http.StatusServiceUnavailable, Readiness{Status: StatusDraining, Time: time.Now()}
+	}
+
+	// Checks are bounded by readinessCheckTimeout rather than the request,
+	// so a cached result is never one cut short by a caller hanging up.
+	r := run(context.Background())
+
+	status := http.StatusOK
+	if r.Status == StatusNotReady {
+		status = http.StatusServiceUnavailable
+	}
+
+	// Copy the map so callers can trim it without touching the cache.
+	checks := make(map[string]CheckResult, len(r.Checks))
+	for name, res := range r.Checks {
+		checks[name] = res
+	}
+	r.Checks = checks
+
+	return status, r
+}
@@ -0,0 +1,265 @@
+package api
+
+import (
+	"dooreye-backend/internal/accesscode"
+	"dooreye-backend/internal/store"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+)
+
+var ErrLeaseEndRequired = errors.New("lease_end is required for tenants")
+
+// inviteAttempts bounds retries when a generated access code collides.
+const inviteAttempts = 3
+
+type InviteHouseholdMemberRequest struct {
+	Name               string `json:"name" binding:"required,max=100"`
+	IsTenant           bool   `json:"is_tenant"`
+	LeaseEnd           *Date  `json:"lease_end"`
+	CanApproveVisitors *bool  `json:"can_approve_visitors"`
+	CanCreatePasses    *bool  `json:"can_create_passes"`
+}
+
+func (h *Handler) getHouseholdMembers(c *gin.Context) {
+	residenceID, ok := h.ownerResidence(c)
+	if !ok {
+		return
+	}
+
+	members, err := h.db.GetHouseholdMembers(c.Request.Context(), residenceID)
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": members})
+}
+
+func (h *Handler) inviteHouseholdMember(c *gin.Context) {
+	residenceID, ok := h.ownerResidence(c)
+	if !ok {
+		return
+	}
+
+	var req InviteHouseholdMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if req.IsTenant && req.LeaseEnd == nil {
+		h.respondError(c, http.StatusBadRequest, ErrLeaseEndRequired)
+		return
+	}
+
+	params := store.InviteHouseholdMemberParams{
+		Name:               req.Name,
+		ResidenceID:        residenceID,
+		IsTenant:           req.IsTenant,
+		LeaseEnd:           req.LeaseEnd.Ptr(),
+		CanApproveVisitors: boolOr(req.CanApproveVisitors, true),
+		CanCreatePasses:    boolOr(req.CanCreatePasses, true),
+		InvitedBy:          c.GetString("user_id"),
+	}
+
+	var member *store.HouseholdMember
+	var err error
+	for i := 0; i < inviteAttempts; i++ {
+		params.AccessCode, err = accesscode.Generate()
+		if err != nil {
+			break
+		}
+
+		member, err = h.db.InviteHouseholdMember(c.Request.Context(), params)
+		if !errors.Is(err, store.ErrDuplicateAccessCode) {
+			break
+		}
+	}
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": member})
+}
+
+type UpdateHouseholdMemberRequest struct {
+	CanApproveVisitors *bool `json:"can_approve_visitors"`
+	CanCreatePasses    *bool `json:"can_create_passes"`
+	IsTenant           *bool `json:"is_tenant"`
+	LeaseEnd           *Date `json:"lease_end"`
+}
+
+func (h *Handler) updateHouseholdMember(c *gin.Context) {
+	residenceID, ok := h.ownerResidence(c)
+	if !ok {
+		return
+	}
+
+	memberID, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, fmt.Errorf("invalid member id: %w", err))
+		return
+	}
+
+	var req UpdateHouseholdMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	params := store.UpdateHouseholdMemberParams{
+		CanApproveVisitors: req.CanApproveVisitors,
+		CanCreatePasses:    req.CanCreatePasses,
+		IsTenant:           req.IsTenant,
+		LeaseEnd:           req.LeaseEnd.Ptr(),
+		// Turning a tenant into a household member drops the lease.
+		ClearLeaseEnd: req.IsTenant != nil && !*req.IsTenant,
+	}
+	if req.IsTenant != nil && *req.IsTenant && req.LeaseEnd == nil {
+		h.respondError(c, http.StatusBadRequest, ErrLeaseEndRequired)
+		return
+	}
+
+	member, err := h.db.UpdateHouseholdMember(c.Request.Context(), residenceID, memberID.String(), params)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, store.ErrNotFound) {
+			status = http.StatusNotFound
+		}
+		h.respondError(c, status, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": member})
+}
+
+func (h *Handler) removeHouseholdMember(c *gin.Context) {
+	residenceID, ok := h.ownerResidence(c)
+	if !ok {
+		return
+	}
+
+	memberID, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, fmt.Errorf("invalid member id: %w", err))
+		return
+	}
+
+	if err := h.db.RemoveHouseholdMember(c.Request.Context(), residenceID, memberID.String()); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, store.ErrNotFound) {
+			status = http.StatusNotFound
+		}
+		h.respondError(c, status, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ownerResidence returns the residence the authenticated owner manages. The
+// residence always comes from the owner's own record, never from the
+// request, so an owner can only manage their own household.
+func (h *Handler) ownerResidence(c *gin.Context) (int64, bool) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return 0, false
+	}
+
+	if user.ResidenceID == nil {
+		h.respondError(c, http.StatusForbidden, ErrUnauthorizedRole)
+		return 0, false
+	}
+
+	return *user.ResidenceID, true
+}
+
+type RedeemAccessCodeRequest struct {
+	AccessCode string `json:"access_code" binding:"required,len=8"`
+	DeviceID   string `json:"device_id" binding:"required"`
+}
+
+// redeemAccessCode lets an invited member activate their account from their
+// own device. It is unauthenticated: the access code is the credential.
+func (h *Handler) redeemAccessCode(c *gin.Context) {
+	var req RedeemAccessCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	// Failures count against the client's IP, the device and the code, so
+	// neither rotating devices nor rotating IPs gets unlimited guesses, and
+	// a code being tried from many clients locks itself out.
+	keys := []string{byIP(c), byDeviceID(req.DeviceID), byAccessCode(req.AccessCode)}
+	for _, key := range keys {
+		if h.lockedOut(c, h.activationLockout, key) {
+			return
+		}
+	}
+
+	user, err := h.db.RedeemAccessCode(c.Request.Context(), req.AccessCode, req.DeviceID)
+	if errors.Is(err, store.ErrInvalidAccessCode) {
+		for _, key := range keys {
+			h.recordFailure(c, h.activationLockout, key)
+		}
+	}
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, store.ErrInvalidAccessCode):
+			status = http.StatusNotFound
+		case errors.Is(err, store.ErrDeviceTaken):
+			status = http.StatusConflict
+		}
+		h.respondError(c, status, err)
+		return
+	}
+
+	for _, key := range keys {
+		h.clearFailures(c, h.activationLockout, key)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": user})
+}
+
+func boolOr(b *bool, def bool) bool {
+	if b == nil {
+		return def
+	}
+	return *b
+}
+
+// Date is a calendar date in JSON, written as "2006-01-02".
+type Date struct {
+	time.Time
+}
+
+func (d *Date) UnmarshalJSON(b []byte) error {
+	t, err := time.Parse(`"2006-01-02"`, string(b))
+	if err != nil {
+		return fmt.Errorf("date must be YYYY-MM-DD: %w", err)
+	}
+	d.Time = t
+	return nil
+}
+
+func (d Date) MarshalJSON() ([]byte, error) {
+	return []byte(d.Format(`"2006-01-02"`)), nil
+}
+
+// Ptr converts an optional date into the *time.Time the store expects.
+func (d *Date) Ptr() *time.Time {
+	if d == nil {
+		return nil
+	}
+	t := d.Time
+	return &t
+}
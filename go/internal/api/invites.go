@@ -0,0 +1,374 @@
+package api
+
+import (
+	"crypto/rand"
+	"dooreye-backend/internal/accesscode"
+	"dooreye-backend/internal/metrics"
+	"dooreye-backend/internal/model"
+	"dooreye-backend/internal/notify"
+	"dooreye-backend/internal/store"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var ErrInvalidInviteWindow = errors.New("valid_until must be after valid_from and in the future")
+
+const (
+	// maxInviteDuration bounds how long an invite link stays open.
+	maxInviteDuration = 30 * 24 * time.Hour
+
+	// passCodeAttempts bounds retries when a generated pass code collides.
+	passCodeAttempts = 3
+
+	// PassQRPrefix marks a guest pass in the QR payload the gate app scans.
+	PassQRPrefix = "DOOREYE-PASS:"
+)
+
+type CreateGuestInviteRequest struct {
+	Title      string     `json:"title" binding:"required,max=200"`
+	MaxUses    int        `json:"max_uses" binding:"omitempty,min=1,max=500"`
+	ValidFrom  *time.Time `json:"valid_from"`
+	ValidUntil time.Time  `json:"valid_until" binding:"required"`
+}
+
+// createGuestInvite creates a shareable link guests use to register
+// themselves for the host's residence. max_uses of 1, the default, makes a
+// single-use link.
+func (h *Handler) createGuestInvite(c *gin.Context) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	if !user.CanCreatePasses {
+		h.respondError(c, http.StatusForbidden, ErrPermissionDenied)
+		return
+	}
+
+	var req CreateGuestInviteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	now := time.Now()
+	validFrom := now
+	if req.ValidFrom != nil && req.ValidFrom.After(now) {
+		validFrom = *req.ValidFrom
+	}
+	if !req.ValidUntil.After(validFrom) || req.ValidUntil.Sub(now) > maxInviteDuration {
+		h.respondError(c, http.StatusBadRequest, ErrInvalidInviteWindow)
+		return
+	}
+	if req.MaxUses == 0 {
+		req.MaxUses = 1
+	}
+
+	residenceID, ok := h.residenceInScope(c, user, nil)
+	if !ok {
+		return
+	}
+
+	societyID, err := h.userSocietyID(c, user)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	token, err := inviteToken()
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	invite, err := h.db.CreateGuestInvite(c.Request.Context(), store.CreateGuestInviteParams{
+		Token:       token,
+		SocietyID:   societyID,
+		ResidenceID: residenceID,
+		Title:       req.Title,
+		MaxUses:     req.MaxUses,
+		ValidFrom:   validFrom,
+		ValidUntil:  req.ValidUntil,
+		CreatedBy:   user.ID,
+	})
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": gin.H{
+		"invite":    invite,
+		"link_path": "/invites/" + invite.Token,
+	}})
+}
+
+func (h *Handler) getGuestInvites(c *gin.Context) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	residenceID, ok := h.residenceInScope(c, user, nil)
+	if !ok {
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	offset, _ := strconv.Atoi(c.Query("offset"))
+
+	invites, err := h.db.GetGuestInvites(c.Request.Context(), residenceID, limit, offset)
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": invites})
+}
+
+// getGuestInvite shows the host who has registered through the link and
+// which of them have arrived.
+func (h *Handler) getGuestInvite(c *gin.Context) {
+	invite, ok := h.guestInviteInScope(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": invite})
+}
+
+func (h *Handler) revokeGuestInvite(c *gin.Context) {
+	invite, ok := h.guestInviteInScope(c)
+	if !ok {
+		return
+	}
+
+	invite, err := h.db.RevokeGuestInvite(c.Request.Context(), invite.ID)
+	if err != nil {
+		h.respondError(c, errorStatus(err), err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": invite})
+}
+
+// getPublicGuestInvite is what the guest sees on opening the link. It is
+// unauthenticated: the token is the credential.
+func (h *Handler) getPublicGuestInvite(c *gin.Context) {
+	invite, err := h.db.GetPublicGuestInvite(c.Request.Context(), c.Param("token"))
+	if err != nil {
+		h.respondError(c, errorStatus(err), err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": invite})
+}
+
+type RegisterGuestRequest struct {
+	Name     string  `json:"name" binding:"required,max=100"`
+	Phone    string  `json:"phone" binding:"required,min=6,max=20"`
+	PhotoURL *string `json:"photo_url" binding:"omitempty,url,max=2000"`
+}
+
+// registerGuest signs a guest up through an invite link and returns their
+// gate pass. It is unauthenticated and rate limited per client.
+func (h *Handler) registerGuest(c *gin.Context) {
+	var req RegisterGuestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	params := store.RegisterGuestParams{
+		Token:    c.Param("token"),
+		Name:     strings.TrimSpace(req.Name),
+		Phone:    strings.TrimSpace(req.Phone),
+		PhotoURL: req.PhotoURL,
+	}
+
+	var reg *model.GuestRegistration
+	var invite *model.GuestInvite
+	var err error
+	for i := 0; i < passCodeAttempts; i++ {
+		params.PassCode, err = accesscode.Generate()
+		if err != nil {
+			break
+		}
+
+		reg, invite, err = h.db.RegisterGuest(c.Request.Context(), params)
+		if !errors.Is(err, store.ErrDuplicatePassCode) {
+			break
+		}
+	}
+	if err != nil {
+		h.respondError(c, errorStatus(err), err)
+		return
+	}
+
+	h.notifyHost(c, invite, "GUEST_REGISTERED",
+		reg.Name+" registered",
+		fmt.Sprintf("%s signed up for %q", reg.Name, invite.Title))
+
+	c.JSON(http.StatusCreated, gin.H{"data": gin.H{
+		"name":        reg.Name,
+		"pass_code":   reg.PassCode,
+		"qr_payload":  PassQRPrefix + reg.PassCode,
+		"valid_from":  invite.ValidFrom,
+		"valid_until": invite.ValidUntil,
+	}})
+}
+
+// getGuestPass lets the gate look up a scanned pass before admitting the
+// guest.
+func (h *Handler) getGuestPass(c *gin.Context) {
+	pass, ok := h.guestPassInScope(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": pass})
+}
+
+type CheckInGuestPassRequest struct {
+	GateID *int64 `json:"gate_id"`
+}
+
+// checkInGuestPass admits the holder of a pass and tells the host.
+func (h *Handler) checkInGuestPass(c *gin.Context) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	var req CheckInGuestPassRequest
+	if err := c.ShouldBindJSON(&req); err != nil && c.Request.ContentLength > 0 {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	societyID, err := societyIDParam(c, user)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	pass, err := h.db.CheckInGuestPass(c.Request.Context(), store.CheckInGuestPassParams{
+		PassCode:  passCodeParam(c),
+		SocietyID: *societyID,
+		GateID:    req.GateID,
+		GuardID:   user.ID,
+	})
+	if err != nil {
+		h.respondError(c, errorStatus(err), err)
+		return
+	}
+
+	metrics.CheckIn(model.VisitorGuest, 1)
+
+	invite := &model.GuestInvite{ID: pass.InviteID, ResidenceID: pass.ResidenceID, Title: pass.InviteTitle}
+	h.notifyHost(c, invite, "GUEST_ARRIVED",
+		pass.Name+" has arrived",
+		fmt.Sprintf("%s checked in at the gate for %q", pass.Name, pass.InviteTitle))
+
+	c.JSON(http.StatusOK, gin.H{"data": pass})
+}
+
+// notifyHost tells the residence that created an invite about its guests.
+// Failures are logged; they never fail the request.
+func (h *Handler) notifyHost(c *gin.Context, invite *model.GuestInvite, kind, title, body string) {
+	recipients, err := h.db.GetResidenceUserIDs(c.Request.Context(), invite.ResidenceID)
+	if err == nil {
+		err = h.notifier.Send(c.Request.Context(), notify.Message{
+			UserIDs:  recipients,
+			Kind:     kind,
+			Title:    title,
+			Body:     body,
+			Priority: notify.PriorityNormal,
+			Data: map[string]string{
+				"invite_id": strconv.FormatInt(invite.ID, 10),
+			},
+		})
+	}
+	if err != nil {
+		h.log.ErrorContext(c.Request.Context(), "notifying invite host", "invite_id", invite.ID, "kind", kind, "error", err)
+	}
+}
+
+// guestInviteInScope loads the invite named by :id if it belongs to the
+// caller's residence. It writes the error response itself.
+func (h *Handler) guestInviteInScope(c *gin.Context) (*model.GuestInvite, bool) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return nil, false
+	}
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, fmt.Errorf("invalid invite id: %w", err))
+		return nil, false
+	}
+
+	invite, err := h.db.GetGuestInvite(c.Request.Context(), id)
+	if err != nil {
+		h.respondError(c, errorStatus(err), err)
+		return nil, false
+	}
+
+	if user.ResidenceID == nil || *user.ResidenceID != invite.ResidenceID {
+		h.respondError(c, http.StatusNotFound, store.ErrNotFound)
+		return nil, false
+	}
+
+	return invite, true
+}
+
+// guestPassInScope loads the pass named by :code if it was issued for the
+// guard's society. It writes the error response itself.
+func (h *Handler) guestPassInScope(c *gin.Context) (*model.GuestPass, bool) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return nil, false
+	}
+
+	pass, err := h.db.GetGuestPass(c.Request.Context(), passCodeParam(c))
+	if err != nil {
+		h.respondError(c, errorStatus(err), err)
+		return nil, false
+	}
+
+	if user.Role != model.RoleAdmin {
+		societyID, err := h.db.GetResidenceSocietyID(c.Request.Context(), pass.ResidenceID)
+		if err != nil || user.SocietyID == nil || *user.SocietyID != societyID {
+			h.respondError(c, http.StatusNotFound, store.ErrNotFound)
+			return nil, false
+		}
+	}
+
+	return pass, true
+}
+
+// passCodeParam accepts the code as typed by the guard or the raw QR
+// payload.
+func passCodeParam(c *gin.Context) string {
+	code := strings.TrimPrefix(c.Param("code"), PassQRPrefix)
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+func inviteToken() (string, error) {
+	b := make([]byte, 18)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating invite token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"dooreye-backend/internal/model"
+	"dooreye-backend/internal/store"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *Handler) getJobs(c *gin.Context) {
+	filter := store.JobFilter{
+		Kind:   c.Query("kind"),
+		Status: model.JobStatus(c.Query("status")),
+	}
+
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil {
+			h.respondError(c, http.StatusBadRequest, fmt.Errorf("invalid limit: %w", err))
+			return
+		}
+		filter.Limit = n
+	}
+
+	jobs, err := h.db.GetJobs(c.Request.Context(), filter)
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": jobs})
+}
+
+func (h *Handler) getJob(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, fmt.Errorf("invalid job id: %w", err))
+		return
+	}
+
+	job, err := h.db.GetJob(c.Request.Context(), id)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, store.ErrNotFound) {
+			status = http.StatusNotFound
+		}
+		h.respondError(c, status, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": job})
+}
+
+func (h *Handler) retryJob(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, fmt.Errorf("invalid job id: %w", err))
+		return
+	}
+
+	job, err := h.db.RetryJob(c.Request.Context(), id)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, store.ErrNotFound) {
+			status = http.StatusNotFound
+		}
+		h.respondError(c, status, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": job})
+}
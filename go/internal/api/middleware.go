@@ -1,14 +1,24 @@
 package api
 
 import (
+	"dooreye-backend/internal/metrics"
 	"dooreye-backend/internal/model"
 	"dooreye-backend/internal/store"
+	"dooreye-backend/internal/telemetry"
 	"errors"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var (
@@ -16,28 +26,58 @@ var (
 	ErrUserInactive      = errors.New("user is inactive")
 	ErrInvalidAuthHeader = errors.New("invalid authorization header")
 	ErrUnauthorizedRole  = errors.New("unauthorized role")
+	ErrPermissionDenied  = errors.New("permission denied")
+	ErrNotAMember        = errors.New("not a member of the selected residence")
 )
 
+// ResidenceHeader selects which of a user's residences a request acts on.
+// Without it the user's default residence is used.
+const ResidenceHeader = "X-Residence-ID"
+
+// RequestIDHeader carries the request ID. A caller's own ID is kept so
+// logs can be matched across services; otherwise one is generated.
+const RequestIDHeader = "X-Request-ID"
+
+// deviceTouchInterval throttles last_seen_at updates to one write per device
+// per interval.
+const deviceTouchInterval = 5 * time.Minute
+
 type contextKey string
 
 const (
-	UserIDKey    contextKey = "user_id"
-	UserRoleKey  contextKey = "user_role"
-	SocietyIDKey contextKey = "society_id"
+	RequestIDKey   contextKey = "request_id"
+	UserIDKey      contextKey = "user_id"
+	UserRoleKey    contextKey = "user_role"
+	SocietyIDKey   contextKey = "society_id"
+	ResidenceIDKey contextKey = "residence_id"
+	MembershipsKey contextKey = "memberships"
+	DeviceKey      contextKey = "device"
+
+	CanApproveVisitorsKey contextKey = "can_approve_visitors"
+	CanCreatePassesKey    contextKey = "can_create_passes"
 )
 
 type AuthUser struct {
-	ID        string
-	Role      model.UserRole
-	SocietyID *int64
-	IsActive  bool
+	ID          string
+	Role        model.UserRole
+	SocietyID   *int64
+	ResidenceID *int64
+	IsActive    bool
+
+	CanApproveVisitors bool
+	CanCreatePasses    bool
+
+	// Memberships lists every residence the user belongs to. ResidenceID
+	// and Role reflect the one selected for this request.
+	Memberships []model.Membership
+	DeviceRowID int64
 }
 
 func (h *Handler) AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		token := c.GetHeader("Authorization")
 		if token == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "authorization header required"})
+			h.respondError(c, http.StatusUnauthorized, ErrMissingAuthHeader)
 			c.Abort()
 			return
 		}
@@ -51,26 +91,72 @@ func (h *Handler) AuthMiddleware() gin.HandlerFunc {
 
 		deviceID := parts[1]
 
+		if h.lockedOut(c, h.authLockout, byIP(c)) {
+			return
+		}
+
 		user, err := h.db.GetUserByDeviceID(c.Request.Context(), deviceID)
+		if errors.Is(err, store.ErrNotFound) {
+			h.recordFailure(c, h.authLockout, byIP(c))
+			h.respondError(c, http.StatusUnauthorized, ErrInvalidToken)
+			c.Abort()
+			return
+		}
 		if err != nil {
-			var status int
-			switch {
-			case errors.Is(err, store.ErrNotFound):
-				status = http.StatusNotFound
-				err = ErrInvalidToken
-			default:
-				status = http.StatusUnauthorized
-			}
-			c.JSON(status, gin.H{"error": err.Error()})
+			h.respondError(c, http.StatusInternalServerError, err)
 			c.Abort()
 			return
 		}
 
+		if !user.IsActive {
+			h.respondError(c, http.StatusForbidden, ErrUserInactive)
+			c.Abort()
+			return
+		}
+
+		memberships, err := h.db.GetUserMemberships(c.Request.Context(), user.ID)
+		if err != nil {
+			h.respondError(c, http.StatusInternalServerError, err)
+			c.Abort()
+			return
+		}
+
+		// Permissions are per residence; staff without one hold them all.
+		role := model.UserRole(user.Role)
+		residenceID := user.ResidenceID
+		canApprove, canCreatePasses := true, true
+		if len(memberships) > 0 {
+			active, err := selectMembership(c.GetHeader(ResidenceHeader), user.ResidenceID, memberships)
+			if err != nil {
+				h.respondError(c, http.StatusForbidden, err)
+				c.Abort()
+				return
+			}
+			role = active.Role
+			residenceID = &active.ResidenceID
+			canApprove, canCreatePasses = active.CanApproveVisitors, active.CanCreatePasses
+		}
+
+		if user.DeviceLastSeenAt == nil || time.Since(*user.DeviceLastSeenAt) > deviceTouchInterval {
+			if err := h.db.TouchDevice(c.Request.Context(), user.DeviceRowID); err != nil {
+				h.log.WarnContext(c.Request.Context(), "touching device", "error", err)
+			}
+		}
+
+		annotateRequest(c, user.ID, role, user.SocietyID)
+
 		c.Set(string(UserIDKey), user.ID)
-		c.Set(string(UserRoleKey), user.Role)
+		c.Set(string(UserRoleKey), role)
 		if user.SocietyID != nil {
 			c.Set(string(SocietyIDKey), *user.SocietyID)
 		}
+		if residenceID != nil {
+			c.Set(string(ResidenceIDKey), *residenceID)
+		}
+		c.Set(string(MembershipsKey), memberships)
+		c.Set(string(DeviceKey), user.DeviceRowID)
+		c.Set(string(CanApproveVisitorsKey), canApprove)
+		c.Set(string(CanCreatePassesKey), canCreatePasses)
 
 		c.Next()
 	}
@@ -88,8 +174,10 @@ func GetAuthUser(c *gin.Context) (*AuthUser, error) {
 	}
 
 	user := &AuthUser{
-		ID:   userID.(string),
-		Role: userRole.(model.UserRole),
+		ID:                 userID.(string),
+		Role:               userRole.(model.UserRole),
+		CanApproveVisitors: c.GetBool(string(CanApproveVisitorsKey)),
+		CanCreatePasses:    c.GetBool(string(CanCreatePassesKey)),
 	}
 
 	if societyID, exists := c.Get(string(SocietyIDKey)); exists {
@@ -97,9 +185,177 @@ func GetAuthUser(c *gin.Context) (*AuthUser, error) {
 		user.SocietyID = &sid
 	}
 
+	if residenceID, exists := c.Get(string(ResidenceIDKey)); exists {
+		rid := residenceID.(int64)
+		user.ResidenceID = &rid
+	}
+
+	if memberships, exists := c.Get(string(MembershipsKey)); exists {
+		user.Memberships = memberships.([]model.Membership)
+	}
+	user.DeviceRowID = c.GetInt64(string(DeviceKey))
+
 	return user, nil
 }
 
+// selectMembership picks the residence context for a request: the one named
+// in the header if the user belongs to it, else the default residence, else
+// the first membership.
+func selectMembership(header string, defaultResidence *int64, memberships []model.Membership) (*model.Membership, error) {
+	if header != "" {
+		id, err := strconv.ParseInt(header, 10, 64)
+		if err != nil {
+			return nil, ErrNotAMember
+		}
+		for i := range memberships {
+			if memberships[i].ResidenceID == id {
+				return &memberships[i], nil
+			}
+		}
+		return nil, ErrNotAMember
+	}
+
+	if defaultResidence != nil {
+		for i := range memberships {
+			if memberships[i].ResidenceID == *defaultResidence {
+				return &memberships[i], nil
+			}
+		}
+	}
+
+	return &memberships[0], nil
+}
+
+// RequireRoles aborts the request unless the authenticated user holds one of
+// the given roles. It must run after AuthMiddleware.
+func (h *Handler) RequireRoles(roles ...model.UserRole) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := GetAuthUser(c)
+		if err != nil {
+			h.respondError(c, http.StatusUnauthorized, err)
+			c.Abort()
+			return
+		}
+
+		for _, r := range roles {
+			if user.Role == r {
+				c.Next()
+				return
+			}
+		}
+
+		h.respondError(c, http.StatusForbidden, ErrUnauthorizedRole)
+		c.Abort()
+	}
+}
+
+// RequestID tags the request with an ID, echoed in the response header and
+// included in logs and error bodies.
+func (h *Handler) RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.Must(uuid.NewV4()).String()
+		}
+
+		c.Set(string(RequestIDKey), id)
+		c.Header(RequestIDHeader, id)
+
+		trace.SpanFromContext(c.Request.Context()).SetAttributes(attribute.String("request_id", id))
+		c.Request = c.Request.WithContext(telemetry.WithLogAttrs(c.Request.Context(), "request_id", id))
+
+		c.Next()
+	}
+}
+
+// Tracing starts a server span for the request, continuing the caller's
+// trace when the request carries a traceparent header.
+func (h *Handler) Tracing() gin.HandlerFunc {
+	tracer := otel.Tracer("dooreye-backend/internal/api")
+
+	return func(c *gin.Context) {
+		ctx := otel.GetTextMapPropagator().Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+
+		// Unmatched requests are named by method alone, so probing random
+		// paths cannot create unbounded span names.
+		route := c.FullPath()
+		name := c.Request.Method
+		if route != "" {
+			name += " " + route
+		}
+
+		ctx, span := tracer.Start(ctx, name,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(c.Request.Method),
+				semconv.HTTPRoute(route),
+				semconv.URLPath(c.Request.URL.Path),
+				semconv.ClientAddress(c.ClientIP()),
+				semconv.UserAgentOriginal(c.Request.UserAgent()),
+			),
+		)
+		defer span.End()
+
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	}
+}
+
+// annotateRequest attaches the authenticated user to the request's span and
+// to everything logged with the request's context.
+func annotateRequest(c *gin.Context, userID string, role model.UserRole, societyID *int64) {
+	span := trace.SpanFromContext(c.Request.Context())
+	span.SetAttributes(
+		semconv.EnduserID(userID),
+		semconv.EnduserRole(string(role)),
+	)
+
+	logAttrs := []any{"user_id", userID, "role", role}
+	if societyID != nil {
+		span.SetAttributes(attribute.Int64("society_id", *societyID))
+		logAttrs = append(logAttrs, "society_id", *societyID)
+	}
+
+	c.Request = c.Request.WithContext(telemetry.WithLogAttrs(c.Request.Context(), logAttrs...))
+}
+
+// validRequestID accepts short printable IDs, so a caller cannot inject
+// arbitrary text into logs.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, r := range id {
+		if r < '!' || r > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// RequestMetrics records the duration of every request by route pattern.
+// Unmatched requests share one label so random paths add no series.
+func (h *Handler) RequestMetrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		metrics.ObserveRequest(c.Request.Method, route, c.Writer.Status(), time.Since(start))
+	}
+}
+
 func (s *Handler) LoggerMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		start := time.Now()
@@ -107,7 +363,7 @@ func (s *Handler) LoggerMiddleware() gin.HandlerFunc {
 
 		c.Next()
 
-		s.log.Info("request completed",
+		s.log.InfoContext(c.Request.Context(), "request completed",
 			"path", path,
 			"method", c.Request.Method,
 			"status", c.Writer.Status(),
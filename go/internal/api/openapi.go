@@ -0,0 +1,834 @@
+package api
+
+import (
+	"dooreye-backend/internal/model"
+	"dooreye-backend/internal/payment"
+	"dooreye-backend/internal/store"
+	_ "embed"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+)
+
+// The OpenAPI document is generated from the operations table below and the
+// Go types it names, then committed as openapi.json so clients can read it
+// without running the server. After changing a route, a request struct or a
+// response model, regenerate it with
+//
+//	go test ./internal/api -run TestOpenAPISpec -update
+//
+// The contract tests fail while the committed document is stale.
+
+//go:embed openapi.json
+var openAPIDocument []byte
+
+// queryParam documents a query string parameter; typ is a JSON schema type.
+type queryParam struct {
+	name string
+	typ  string
+}
+
+var pageParams = []queryParam{{"limit", "integer"}, {"offset", "integer"}}
+
+// operation documents one route. handler is the Handler method serving it,
+// which the contract tests check against the router.
+type operation struct {
+	method  string
+	path    string
+	handler string
+	tag     string
+	summary string
+	query   []queryParam
+
+	// request is a zero value of the JSON body type, nil for none.
+	request any
+	// optional marks request bodies the handler lets callers omit.
+	optional bool
+	// response is a zero value of what goes in the {"data": ...} envelope,
+	// nil for an empty response.
+	response any
+	// status is the success status, 200 when zero.
+	status int
+	// bare marks responses written without the data envelope.
+	bare bool
+	// csv marks routes that also answer with text/csv for ?format=csv.
+	csv bool
+	// unavailable marks routes that send the same body with a 503.
+	unavailable bool
+}
+
+var operations = []operation{
+	{method: "GET", path: "/livez", handler: "handleLivez", tag: "system", summary: "Liveness check",
+		bare: true, response: struct {
+			Status string    `json:"status"`
+			Time   time.Time `json:"time"`
+		}{}},
+	{method: "GET", path: "/readyz", handler: "handleReadyz", tag: "system", summary: "Readiness and dependency status",
+		bare: true, unavailable: true, response: Readiness{}},
+	{method: "GET", path: "/health", handler: "handleReadyz", tag: "system", summary: "Readiness, under its old path",
+		bare: true, unavailable: true, response: Readiness{}},
+	{method: "GET", path: "/openapi.json", handler: "handleOpenAPI", tag: "system", summary: "This document",
+		bare: true, response: map[string]any{}},
+	{method: "POST", path: "/activate", handler: "redeemAccessCode", tag: "users", summary: "Redeem an access code on a new device",
+		request: RedeemAccessCodeRequest{}, response: store.User{}},
+	{method: "POST", path: "/webhooks/payments/:provider", handler: "handlePaymentWebhook", tag: "billing", summary: "Payment gateway webhook",
+		response: struct {
+			Status    model.PaymentStatus `json:"status"`
+			Duplicate bool                `json:"duplicate,omitempty"`
+		}{}},
+	{method: "GET", path: "/invites/:token", handler: "getPublicGuestInvite", tag: "guest-invites", summary: "Open a guest invite link",
+		response: model.PublicGuestInvite{}},
+	{method: "POST", path: "/invites/:token/register", handler: "registerGuest", tag: "guest-invites", summary: "Register as a guest and get a gate pass",
+		request: RegisterGuestRequest{}, status: http.StatusCreated, response: struct {
+			Name       string    `json:"name"`
+			PassCode   string    `json:"pass_code"`
+			QRPayload  string    `json:"qr_payload"`
+			ValidFrom  time.Time `json:"valid_from"`
+			ValidUntil time.Time `json:"valid_until"`
+		}{}},
+
+	// Visits.
+	{method: "GET", path: "/api/visitors", handler: "getVisitorByPhone", tag: "visits", summary: "Look a visitor up by phone",
+		query: []queryParam{{"phone", "string"}}, response: model.Visitor{}},
+	{method: "GET", path: "/api/visits", handler: "getVisits", tag: "visits", summary: "List visits",
+		query: []queryParam{{"residence_id", "integer"}, {"ongoing", "boolean"}}, response: []model.VisitWithVisitor{}},
+	{method: "GET", path: "/api/visits/overstays", handler: "getOverstays", tag: "visits", summary: "List visitors past their stay limit",
+		query: []queryParam{{"society_id", "integer"}}, response: []model.OverstayVisit{}},
+	{method: "POST", path: "/api/visits/security", handler: "createVisitAsSecurity", tag: "visits", summary: "Check a visitor in at the gate",
+		request: CreateVisitRequest{}, status: http.StatusCreated, bare: true, response: model.Visit{}},
+	{method: "POST", path: "/api/visits/:id/approve", handler: "approveVisit", tag: "visits", summary: "Approve a visit to your residence",
+		response: model.Visit{}},
+	{method: "POST", path: "/api/visitors/pre-approved", handler: "createPreApprovedVisitor", tag: "visits", summary: "Pre-approve a visitor",
+		request: CreatePreApprovedVisitorRequest{}, status: http.StatusCreated, response: store.PreApprovedVisitor{}},
+	{method: "POST", path: "/api/users/activate", handler: "createUser", tag: "users", summary: "Create a user and issue their access code",
+		request: CreateUserRequest{}, status: http.StatusCreated, bare: true, response: store.User{}},
+	{method: "GET", path: "/api/stay-limits", handler: "getStayLimits", tag: "visits", summary: "List stay limits by visitor type",
+		query: []queryParam{{"society_id", "integer"}}, response: []model.StayLimit{}},
+	{method: "PUT", path: "/api/stay-limits/:visitor_type", handler: "updateStayLimit", tag: "visits", summary: "Set a stay limit",
+		query: []queryParam{{"society_id", "integer"}}, request: UpdateStayLimitRequest{}, response: model.StayLimit{}},
+
+	// Gates and alerts.
+	{method: "GET", path: "/api/gates", handler: "getGates", tag: "gates", summary: "List gates",
+		query: []queryParam{{"society_id", "integer"}}, response: []model.Gate{}},
+	{method: "POST", path: "/api/gates", handler: "createGate", tag: "gates", summary: "Create a gate",
+		query: []queryParam{{"society_id", "integer"}}, request: CreateGateRequest{}, status: http.StatusCreated, response: model.Gate{}},
+	{method: "POST", path: "/api/alerts", handler: "raiseAlert", tag: "alerts", summary: "Raise an emergency alert",
+		query: []queryParam{{"society_id", "integer"}}, request: RaiseAlertRequest{}, status: http.StatusCreated, response: model.Alert{}},
+	{method: "GET", path: "/api/alerts", handler: "getAlerts", tag: "alerts", summary: "List alerts",
+		query: []queryParam{{"status", "string"}, {"open", "boolean"}, {"society_id", "integer"}}, response: []model.Alert{}},
+	{method: "GET", path: "/api/alerts/:id", handler: "getAlert", tag: "alerts", summary: "Get an alert",
+		response: model.Alert{}},
+	{method: "POST", path: "/api/alerts/:id/acknowledge", handler: "acknowledgeAlert", tag: "alerts", summary: "Acknowledge an alert",
+		response: model.Alert{}},
+	{method: "POST", path: "/api/alerts/:id/resolve", handler: "resolveAlert", tag: "alerts", summary: "Resolve an alert",
+		response: model.Alert{}},
+	{method: "PUT", path: "/api/presence", handler: "setPresence", tag: "headcount", summary: "Set whether the caller is at home",
+		request: SetPresenceRequest{}, response: struct {
+			IsHome bool `json:"is_home"`
+		}{}},
+
+	// The caller and their devices.
+	{method: "GET", path: "/api/me", handler: "getMe", tag: "users", summary: "The caller, their memberships and active residence",
+		response: struct {
+			User              store.User         `json:"user"`
+			Memberships       []model.Membership `json:"memberships"`
+			ActiveResidenceID *int64             `json:"active_residence_id"`
+			ActiveRole        model.UserRole     `json:"active_role"`
+		}{}},
+	{method: "GET", path: "/api/me/devices", handler: "getDevices", tag: "users", summary: "List the caller's devices",
+		response: struct {
+			Devices       []model.Device `json:"devices"`
+			CurrentDevice int64          `json:"current_device"`
+		}{}},
+	{method: "POST", path: "/api/me/devices", handler: "addDevice", tag: "users", summary: "Register another device",
+		request: AddDeviceRequest{}, status: http.StatusCreated, response: model.Device{}},
+	{method: "DELETE", path: "/api/me/devices/:id", handler: "revokeDevice", tag: "users", summary: "Sign a device out",
+		status: http.StatusNoContent},
+
+	// Vehicles and parking.
+	{method: "GET", path: "/api/vehicles", handler: "getVehicles", tag: "vehicles", summary: "List registered vehicles",
+		query: []queryParam{{"residence_id", "integer"}}, response: []model.Vehicle{}},
+	{method: "POST", path: "/api/vehicles", handler: "registerVehicle", tag: "vehicles", summary: "Register a vehicle",
+		request: RegisterVehicleRequest{}, status: http.StatusCreated, response: model.Vehicle{}},
+	{method: "DELETE", path: "/api/vehicles/:id", handler: "deleteVehicle", tag: "vehicles", summary: "Remove a vehicle",
+		status: http.StatusNoContent},
+	{method: "GET", path: "/api/vehicles/lookup", handler: "lookupVehicle", tag: "vehicles", summary: "Look a plate up at the gate",
+		query: []queryParam{{"plate", "string"}}, response: struct {
+			Plate         string               `json:"plate"`
+			Display       string               `json:"display"`
+			Registered    bool                 `json:"registered"`
+			Vehicle       *model.Vehicle       `json:"vehicle"`
+			RecentEntries []model.VehicleEntry `json:"recent_entries"`
+		}{}},
+	{method: "GET", path: "/api/vehicles/entries", handler: "getVehicleEntries", tag: "vehicles", summary: "List vehicle entries",
+		query: []queryParam{{"inside", "boolean"}, {"plate", "string"}}, response: []model.VehicleEntry{}},
+	{method: "POST", path: "/api/vehicles/entries", handler: "logVehicleEntry", tag: "vehicles", summary: "Log a vehicle entering",
+		request: VehicleEntryRequest{}, status: http.StatusCreated, response: model.VehicleEntry{}},
+	{method: "POST", path: "/api/vehicles/exits", handler: "logVehicleExit", tag: "vehicles", summary: "Log a vehicle leaving",
+		request: VehicleExitRequest{}, response: model.VehicleEntry{}},
+	{method: "GET", path: "/api/vehicles/occupancy", handler: "getParkingOccupancy", tag: "vehicles", summary: "Vehicles inside and parking use",
+		response: model.ParkingOccupancy{}},
+	{method: "GET", path: "/api/parking/slots", handler: "getParkingSlots", tag: "parking", summary: "List parking slots",
+		query: []queryParam{{"guest", "boolean"}}, response: []model.ParkingSlot{}},
+	{method: "GET", path: "/api/parking/guest-availability", handler: "getGuestParkingAvailability", tag: "parking", summary: "Guest slots free for a time range",
+		query: []queryParam{{"starts_at", "string"}, {"ends_at", "string"}, {"vehicle_type", "string"}}, response: []model.ParkingSlot{}},
+	{method: "GET", path: "/api/parking/bookings", handler: "getGuestBookings", tag: "parking", summary: "List guest parking bookings",
+		query: []queryParam{{"upcoming", "boolean"}}, response: []model.GuestParkingBooking{}},
+	{method: "POST", path: "/api/parking/bookings", handler: "createGuestBooking", tag: "parking", summary: "Book a guest slot for a pre-approved visitor",
+		request: CreateGuestBookingRequest{}, status: http.StatusCreated, response: model.GuestParkingBooking{}},
+	{method: "DELETE", path: "/api/parking/bookings/:id", handler: "cancelGuestBooking", tag: "parking", summary: "Cancel a guest booking",
+		status: http.StatusNoContent},
+	{method: "POST", path: "/api/parking/slots", handler: "createParkingSlot", tag: "parking", summary: "Create a parking slot",
+		request: CreateParkingSlotRequest{}, status: http.StatusCreated, response: model.ParkingSlot{}},
+	{method: "PUT", path: "/api/parking/slots/:id/assignment", handler: "assignParkingSlot", tag: "parking", summary: "Assign a slot to a residence",
+		request: AssignParkingSlotRequest{}, response: model.ParkingSlot{}},
+	{method: "DELETE", path: "/api/parking/slots/:id", handler: "retireParkingSlot", tag: "parking", summary: "Retire a parking slot",
+		status: http.StatusNoContent},
+
+	// Amenities.
+	{method: "GET", path: "/api/amenities", handler: "getAmenities", tag: "amenities", summary: "List amenities",
+		query: []queryParam{{"include_inactive", "boolean"}}, response: []model.Amenity{}},
+	{method: "GET", path: "/api/amenities/bookings", handler: "getAmenityBookings", tag: "amenities", summary: "List amenity bookings",
+		query: []queryParam{{"include_cancelled", "boolean"}, {"upcoming", "boolean"}, {"amenity_id", "integer"}}, response: []model.AmenityBooking{}},
+	{method: "POST", path: "/api/amenities/bookings/:id/cancel", handler: "cancelAmenityBooking", tag: "amenities", summary: "Cancel an amenity booking",
+		optional: true, request: CancelAmenityBookingRequest{}, response: model.AmenityBooking{}},
+	{method: "GET", path: "/api/amenities/:id/availability", handler: "getAmenityAvailability", tag: "amenities", summary: "Slots of an amenity on a day",
+		query: []queryParam{{"date", "string"}}, response: []model.AmenitySlot{}},
+	{method: "POST", path: "/api/amenities/:id/bookings", handler: "createAmenityBooking", tag: "amenities", summary: "Book an amenity",
+		request: CreateAmenityBookingRequest{}, status: http.StatusCreated, response: model.AmenityBooking{}},
+	{method: "POST", path: "/api/amenities", handler: "createAmenity", tag: "amenities", summary: "Create an amenity",
+		request: AmenityRequest{}, status: http.StatusCreated, response: model.Amenity{}},
+	{method: "PUT", path: "/api/amenities/:id", handler: "updateAmenity", tag: "amenities", summary: "Update an amenity",
+		request: AmenityRequest{}, response: model.Amenity{}},
+	{method: "DELETE", path: "/api/amenities/:id", handler: "deleteAmenity", tag: "amenities", summary: "Deactivate an amenity",
+		status: http.StatusNoContent},
+	{method: "GET", path: "/api/amenities/:id/calendar", handler: "getAmenityCalendar", tag: "amenities", summary: "Bookings of an amenity over a date range",
+		query: []queryParam{{"from", "string"}, {"to", "string"}, {"include_cancelled", "boolean"}}, response: []model.AmenityBooking{}},
+
+	// Tickets.
+	{method: "GET", path: "/api/tickets", handler: "getTickets", tag: "tickets", summary: "List helpdesk tickets",
+		query: append([]queryParam{{"assigned_to", "string"}, {"breached", "boolean"}, {"status", "string"},
+			{"category", "string"}, {"open", "boolean"}}, pageParams...), response: []model.Ticket{}},
+	{method: "POST", path: "/api/tickets", handler: "createTicket", tag: "tickets", summary: "Raise a ticket",
+		request: CreateTicketRequest{}, status: http.StatusCreated, response: model.Ticket{}},
+	{method: "GET", path: "/api/tickets/:id", handler: "getTicket", tag: "tickets", summary: "Get a ticket with its history",
+		response: struct {
+			Ticket   model.Ticket             `json:"ticket"`
+			Comments []model.TicketComment    `json:"comments"`
+			Events   []model.TicketEvent      `json:"events"`
+			Visits   []model.VisitWithVisitor `json:"visits"`
+		}{}},
+	{method: "POST", path: "/api/tickets/:id/comments", handler: "addTicketComment", tag: "tickets", summary: "Comment on a ticket",
+		request: AddTicketCommentRequest{}, status: http.StatusCreated, response: model.TicketComment{}},
+	{method: "POST", path: "/api/tickets/:id/photos", handler: "addTicketPhoto", tag: "tickets", summary: "Attach a photo to a ticket",
+		request: AddTicketPhotoRequest{}, status: http.StatusCreated, response: model.TicketPhoto{}},
+	{method: "POST", path: "/api/tickets/:id/close", handler: "closeTicket", tag: "tickets", summary: "Close and rate a resolved ticket",
+		request: CloseTicketRequest{}, response: model.Ticket{}},
+	{method: "POST", path: "/api/tickets/:id/reopen", handler: "reopenTicket", tag: "tickets", summary: "Reopen a ticket",
+		request: ReopenTicketRequest{}, response: model.Ticket{}},
+	{method: "POST", path: "/api/tickets/:id/status", handler: "updateTicketStatus", tag: "tickets", summary: "Move a ticket to another status",
+		request: UpdateTicketStatusRequest{}, response: model.Ticket{}},
+	{method: "POST", path: "/api/tickets/:id/assign", handler: "assignTicket", tag: "tickets", summary: "Assign a ticket",
+		request: AssignTicketRequest{}, response: model.Ticket{}},
+	{method: "GET", path: "/api/tickets/sla-policies", handler: "getTicketSLAPolicies", tag: "tickets", summary: "List SLA policies",
+		query: []queryParam{{"society_id", "integer"}}, response: []model.TicketSLAPolicy{}},
+	{method: "PUT", path: "/api/tickets/sla-policies/:priority", handler: "updateTicketSLAPolicy", tag: "tickets", summary: "Set the SLA for a priority",
+		query: []queryParam{{"society_id", "integer"}}, request: UpdateTicketSLAPolicyRequest{}, response: model.TicketSLAPolicy{}},
+
+	// Announcements.
+	{method: "GET", path: "/api/announcements", handler: "getAnnouncementFeed", tag: "announcements", summary: "The caller's announcement feed",
+		query: append([]queryParam{{"unread", "boolean"}}, pageParams...), bare: true, response: struct {
+			Data []model.Announcement `json:"data"`
+			Meta struct {
+				UnreadCount int `json:"unread_count"`
+				Limit       int `json:"limit"`
+				Offset      int `json:"offset"`
+			} `json:"meta"`
+		}{}},
+	{method: "POST", path: "/api/announcements/read-all", handler: "readAllAnnouncements", tag: "announcements", summary: "Mark the whole feed read",
+		response: struct {
+			Marked int64 `json:"marked"`
+		}{}},
+	{method: "GET", path: "/api/announcements/:id", handler: "getAnnouncement", tag: "announcements", summary: "Get an announcement",
+		response: model.Announcement{}},
+	{method: "POST", path: "/api/announcements/:id/read", handler: "readAnnouncement", tag: "announcements", summary: "Mark an announcement read",
+		status: http.StatusNoContent},
+	{method: "GET", path: "/api/announcements/all", handler: "getAllAnnouncements", tag: "announcements", summary: "List every announcement of the society",
+		query: append([]queryParam{{"society_id", "integer"}}, pageParams...), response: []model.Announcement{}},
+	{method: "POST", path: "/api/announcements", handler: "createAnnouncement", tag: "announcements", summary: "Draft or publish an announcement",
+		query: []queryParam{{"society_id", "integer"}}, request: CreateAnnouncementRequest{}, status: http.StatusCreated, response: model.Announcement{}},
+	{method: "PATCH", path: "/api/announcements/:id", handler: "updateAnnouncement", tag: "announcements", summary: "Edit an announcement",
+		request: UpdateAnnouncementRequest{}, response: model.Announcement{}},
+	{method: "POST", path: "/api/announcements/:id/publish", handler: "publishAnnouncement", tag: "announcements", summary: "Publish a draft",
+		optional: true, request: PublishAnnouncementRequest{}, response: model.Announcement{}},
+	{method: "DELETE", path: "/api/announcements/:id", handler: "withdrawAnnouncement", tag: "announcements", summary: "Withdraw an announcement",
+		status: http.StatusNoContent},
+
+	// Polls.
+	{method: "GET", path: "/api/polls", handler: "getPolls", tag: "polls", summary: "List polls",
+		query: append([]queryParam{{"status", "string"}}, pageParams...), response: []model.Poll{}},
+	{method: "GET", path: "/api/polls/:id", handler: "getPoll", tag: "polls", summary: "Get a poll",
+		response: struct {
+			Poll     model.Poll         `json:"poll"`
+			HasVoted *bool              `json:"has_voted,omitempty"`
+			Results  *model.PollResults `json:"results,omitempty"`
+		}{}},
+	{method: "POST", path: "/api/polls/:id/votes", handler: "castVote", tag: "polls", summary: "Vote for the caller's residence",
+		request: CastVoteRequest{}, status: http.StatusCreated, response: struct {
+			PollID   int64        `json:"poll_id"`
+			OptionID int64        `json:"option_id"`
+			Seq      int          `json:"seq"`
+			Receipt  model.Digest `json:"receipt"`
+		}{}},
+	{method: "GET", path: "/api/polls/:id/results", handler: "getPollResults", tag: "polls", summary: "Poll results",
+		response: model.PollResults{}},
+	{method: "GET", path: "/api/polls/:id/export", handler: "exportPoll", tag: "polls", summary: "Export ballots for audit",
+		query: []queryParam{{"format", "string"}}, csv: true, response: struct {
+			Poll    model.Poll         `json:"poll"`
+			Results model.PollResults  `json:"results"`
+			Ballots []model.PollBallot `json:"ballots"`
+		}{}},
+	{method: "GET", path: "/api/polls/:id/verify", handler: "verifyPoll", tag: "polls", summary: "Verify the ballot hash chain",
+		response: struct {
+			PollID      int64        `json:"poll_id"`
+			BallotCount int          `json:"ballot_count"`
+			HeadHash    model.Digest `json:"head_hash"`
+			Valid       bool         `json:"valid"`
+			Error       string       `json:"error,omitempty"`
+		}{}},
+	{method: "POST", path: "/api/polls", handler: "createPoll", tag: "polls", summary: "Create a poll",
+		query: []queryParam{{"society_id", "integer"}}, request: CreatePollRequest{}, status: http.StatusCreated, response: model.Poll{}},
+	{method: "POST", path: "/api/polls/:id/close", handler: "closePoll", tag: "polls", summary: "Close a poll early",
+		response: model.Poll{}},
+	{method: "POST", path: "/api/polls/:id/cancel", handler: "cancelPoll", tag: "polls", summary: "Cancel a poll",
+		response: model.Poll{}},
+
+	// Billing.
+	{method: "GET", path: "/api/billing/invoices", handler: "getInvoices", tag: "billing", summary: "List invoices",
+		query: append([]queryParam{{"residence_id", "integer"}, {"unpaid", "boolean"}}, pageParams...), response: []model.Invoice{}},
+	{method: "GET", path: "/api/billing/invoices/:id", handler: "getInvoice", tag: "billing", summary: "Get an invoice with its lines",
+		response: model.Invoice{}},
+	{method: "GET", path: "/api/billing/payments", handler: "getPayments", tag: "billing", summary: "List payments",
+		query: append([]queryParam{{"residence_id", "integer"}}, pageParams...), response: []model.Payment{}},
+	{method: "GET", path: "/api/billing/statement", handler: "getStatement", tag: "billing", summary: "Account statement of a residence",
+		query: []queryParam{{"residence_id", "integer"}, {"from", "string"}, {"to", "string"}}, response: model.Statement{}},
+	{method: "POST", path: "/api/billing/checkout", handler: "createCheckout", tag: "billing", summary: "Start an online payment",
+		request: CheckoutRequest{}, status: http.StatusCreated, response: struct {
+			Payment  model.Payment   `json:"payment"`
+			Checkout payment.Session `json:"checkout"`
+		}{}},
+	{method: "GET", path: "/api/billing/settings", handler: "getBillingSettings", tag: "billing", summary: "Billing settings of the society",
+		query: []queryParam{{"society_id", "integer"}}, response: model.BillingSettings{}},
+	{method: "PUT", path: "/api/billing/settings", handler: "updateBillingSettings", tag: "billing", summary: "Set up or change billing",
+		query: []queryParam{{"society_id", "integer"}}, request: BillingSettingsRequest{}, response: model.BillingSettings{}},
+	{method: "GET", path: "/api/billing/rules", handler: "getBillingRules", tag: "billing", summary: "List charge rules",
+		query: []queryParam{{"society_id", "integer"}, {"include_inactive", "boolean"}}, response: []model.BillingRule{}},
+	{method: "POST", path: "/api/billing/rules", handler: "createBillingRule", tag: "billing", summary: "Create a charge rule",
+		query: []queryParam{{"society_id", "integer"}}, request: BillingRuleRequest{}, status: http.StatusCreated, response: model.BillingRule{}},
+	{method: "PUT", path: "/api/billing/rules/:id", handler: "updateBillingRule", tag: "billing", summary: "Change a charge rule",
+		request: BillingRuleRequest{}, response: model.BillingRule{}},
+	{method: "DELETE", path: "/api/billing/rules/:id", handler: "deactivateBillingRule", tag: "billing", summary: "Deactivate a charge rule",
+		status: http.StatusNoContent},
+	{method: "PUT", path: "/api/billing/residences/:id/area", handler: "setResidenceArea", tag: "billing", summary: "Set a residence's area",
+		request: ResidenceAreaRequest{}, response: model.BillableResidence{}},
+	{method: "POST", path: "/api/billing/invoices/generate", handler: "generateInvoices", tag: "billing", summary: "Generate this period's invoices now",
+		query: []queryParam{{"society_id", "integer"}}, optional: true, request: GenerateInvoicesRequest{}, response: struct {
+			Created  int             `json:"created"`
+			Invoices []model.Invoice `json:"invoices"`
+		}{}},
+	{method: "POST", path: "/api/billing/payments", handler: "recordPayment", tag: "billing", summary: "Record an offline payment",
+		request: RecordPaymentRequest{}, status: http.StatusCreated, response: model.Payment{}},
+
+	// Guest invites.
+	{method: "GET", path: "/api/guest-invites", handler: "getGuestInvites", tag: "guest-invites", summary: "List the residence's invite links",
+		query: pageParams, response: []model.GuestInvite{}},
+	{method: "POST", path: "/api/guest-invites", handler: "createGuestInvite", tag: "guest-invites", summary: "Create an invite link",
+		request: CreateGuestInviteRequest{}, status: http.StatusCreated, response: struct {
+			Invite   model.GuestInvite `json:"invite"`
+			LinkPath string            `json:"link_path"`
+		}{}},
+	{method: "GET", path: "/api/guest-invites/:id", handler: "getGuestInvite", tag: "guest-invites", summary: "Get an invite with its registrations",
+		response: model.GuestInvite{}},
+	{method: "POST", path: "/api/guest-invites/:id/revoke", handler: "revokeGuestInvite", tag: "guest-invites", summary: "Revoke an invite link",
+		response: model.GuestInvite{}},
+	{method: "GET", path: "/api/guest-invites/passes/:code", handler: "getGuestPass", tag: "guest-invites", summary: "Look up a guest pass at the gate",
+		response: model.GuestPass{}},
+	{method: "POST", path: "/api/guest-invites/passes/:code/check-in", handler: "checkInGuestPass", tag: "guest-invites", summary: "Admit the holder of a guest pass",
+		query: []queryParam{{"society_id", "integer"}}, request: CheckInGuestPassRequest{}, response: model.GuestPass{}},
+
+	// Events.
+	{method: "GET", path: "/api/events", handler: "getEvents", tag: "events", summary: "List events",
+		query: append([]queryParam{{"society_id", "integer"}, {"open", "boolean"}}, pageParams...), response: []model.Event{}},
+	{method: "GET", path: "/api/events/:id", handler: "getEvent", tag: "events", summary: "Get an event with live counts",
+		response: model.Event{}},
+	{method: "GET", path: "/api/events/:id/guests", handler: "getEventGuests", tag: "events", summary: "Search an event's guest list",
+		query: append([]queryParam{{"q", "string"}, {"arrived", "boolean"}}, pageParams...), response: []model.EventGuest{}},
+	{method: "POST", path: "/api/events", handler: "createEvent", tag: "events", summary: "Create an event",
+		query: []queryParam{{"society_id", "integer"}}, request: CreateEventRequest{}, status: http.StatusCreated, response: model.Event{}},
+	{method: "POST", path: "/api/events/:id/close", handler: "closeEvent", tag: "events", summary: "Stop guest list check-in",
+		response: model.Event{}},
+	{method: "POST", path: "/api/events/:id/guests", handler: "addEventGuests", tag: "events", summary: "Add guests to the list",
+		request: AddEventGuestsRequest{}, status: http.StatusCreated, response: []model.EventGuest{}},
+	{method: "DELETE", path: "/api/events/:id/guests/:guestId", handler: "removeEventGuest", tag: "events", summary: "Take a guest off the list",
+		status: http.StatusNoContent},
+	{method: "POST", path: "/api/events/check-in", handler: "checkInEventCode", tag: "events", summary: "Admit a guest by invitation code",
+		query: []queryParam{{"society_id", "integer"}}, request: CheckInEventCodeRequest{}, response: eventCheckIn{}},
+	{method: "POST", path: "/api/events/:id/guests/:guestId/check-in", handler: "checkInEventGuest", tag: "events", summary: "Admit a guest found on the list",
+		request: CheckInEventGuestRequest{}, response: eventCheckIn{}},
+
+	// Household.
+	{method: "GET", path: "/api/household/members", handler: "getHouseholdMembers", tag: "household", summary: "List household members",
+		response: []store.HouseholdMember{}},
+	{method: "POST", path: "/api/household/members", handler: "inviteHouseholdMember", tag: "household", summary: "Invite a household member",
+		request: InviteHouseholdMemberRequest{}, status: http.StatusCreated, response: store.HouseholdMember{}},
+	{method: "PATCH", path: "/api/household/members/:id", handler: "updateHouseholdMember", tag: "household", summary: "Change a member's permissions",
+		request: UpdateHouseholdMemberRequest{}, response: store.HouseholdMember{}},
+	{method: "DELETE", path: "/api/household/members/:id", handler: "removeHouseholdMember", tag: "household", summary: "Remove a household member",
+		status: http.StatusNoContent},
+
+	// Headcount.
+	{method: "POST", path: "/api/staff/punch-in", handler: "punchIn", tag: "headcount", summary: "Start the caller's shift on premises",
+		request: PunchInRequest{}, status: http.StatusCreated, response: model.StaffShift{}},
+	{method: "POST", path: "/api/staff/punch-out", handler: "punchOut", tag: "headcount", summary: "End the caller's shift",
+		response: model.StaffShift{}},
+	{method: "GET", path: "/api/headcount", handler: "getHeadcount", tag: "headcount", summary: "Who is inside right now",
+		query: []queryParam{{"society_id", "integer"}, {"include_residents", "boolean"}, {"format", "string"}}, csv: true, response: rollCall{}},
+	{method: "POST", path: "/api/headcount/events", handler: "startHeadcount", tag: "headcount", summary: "Start an emergency headcount",
+		query: []queryParam{{"society_id", "integer"}}, request: StartHeadcountRequest{}, status: http.StatusCreated, response: model.HeadcountEvent{}},
+	{method: "GET", path: "/api/headcount/events/:id", handler: "getHeadcountEvent", tag: "headcount", summary: "Roll call of a headcount",
+		query: []queryParam{{"format", "string"}}, csv: true, response: rollCall{}},
+	{method: "POST", path: "/api/headcount/events/:id/close", handler: "closeHeadcountEvent", tag: "headcount", summary: "Close a headcount",
+		response: model.HeadcountEvent{}},
+	{method: "POST", path: "/api/headcount/events/:id/marks", handler: "markAccounted", tag: "headcount", summary: "Mark someone accounted for",
+		request: MarkAccountedRequest{}, status: http.StatusNoContent},
+	{method: "DELETE", path: "/api/headcount/events/:id/marks/:subject_type/:subject_id", handler: "unmarkAccounted", tag: "headcount", summary: "Undo a mark",
+		status: http.StatusNoContent},
+
+	// Admin.
+	{method: "GET", path: "/api/admin/jobs", handler: "getJobs", tag: "admin", summary: "List background job runs",
+		query: []queryParam{{"kind", "string"}, {"status", "string"}, {"limit", "integer"}}, response: []model.Job{}},
+	{method: "GET", path: "/api/admin/jobs/:id", handler: "getJob", tag: "admin", summary: "Get a job run",
+		response: model.Job{}},
+	{method: "POST", path: "/api/admin/jobs/:id/retry", handler: "retryJob", tag: "admin", summary: "Retry a failed job",
+		response: model.Job{}},
+}
+
+// eventCheckIn and rollCall document responses the handlers build inline.
+type eventCheckIn struct {
+	Guest model.EventGuest `json:"guest"`
+	Event model.Event      `json:"event"`
+}
+
+type rollCall struct {
+	Event     *model.HeadcountEvent  `json:"event"`
+	Total     int                    `json:"total"`
+	Accounted int                    `json:"accounted"`
+	Groups    []model.HeadcountGroup `json:"groups"`
+}
+
+func (h *Handler) handleOpenAPI(c *gin.Context) {
+	c.Data(http.StatusOK, "application/json", openAPIDocument)
+}
+
+// handleDocs renders the document with Redoc. It is only routed when
+// Options.Docs is set.
+func (h *Handler) handleDocs(c *gin.Context) {
+	c.Header("Content-Security-Policy",
+		"default-src 'none'; script-src https://cdn.redoc.ly 'unsafe-inline'; style-src 'unsafe-inline' https://fonts.googleapis.com; "+
+			"font-src https://fonts.gstatic.com; img-src data: https:; worker-src blob:; connect-src 'self'; frame-ancestors 'none'")
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(`<!DOCTYPE html>
+<html>
+<head>
+<title>DoorEye API</title>
+<meta charset="utf-8">
+</head>
+<body>
+<redoc spec-url="/openapi.json"></redoc>
+<script src="https://cdn.redoc.ly/redoc/latest/bundles/redoc.standalone.js"></script>
+</body>
+</html>
+`))
+}
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// schemaOverrides describes types whose JSON form is not their Go shape.
+// A type that marshals itself must be listed here or generation fails.
+var schemaOverrides = map[reflect.Type]map[string]any{
+	timeType:                            {"type": "string", "format": "date-time"},
+	reflect.TypeOf(uuid.UUID{}):         {"type": "string", "format": "uuid"},
+	reflect.TypeOf(json.RawMessage{}):   {"description": "Any JSON value."},
+	reflect.TypeOf(Date{}):              {"type": "string", "format": "date"},
+	reflect.TypeOf(model.ClockTime(0)):  {"type": "string", "pattern": `^\d{2}:\d{2}$`, "example": "06:30"},
+	reflect.TypeOf(model.Digest(nil)):   {"type": "string", "pattern": "^[0-9a-f]*$", "description": "Hex-encoded SHA-256."},
+	reflect.TypeOf(map[string]any{}):    {"type": "object"},
+	reflect.TypeOf(map[string]string{}): {"type": "object", "additionalProperties": map[string]any{"type": "string"}},
+}
+
+type specBuilder struct {
+	schemas map[string]any
+	names   map[reflect.Type]string
+	err     error
+}
+
+// buildOpenAPI generates the document from the operations table.
+func buildOpenAPI() ([]byte, error) {
+	b := &specBuilder{schemas: map[string]any{}, names: map[reflect.Type]string{}}
+	b.schemas["Problem"] = b.structSchema(reflect.TypeOf(Problem{}))
+
+	paths := map[string]map[string]any{}
+	for _, op := range operations {
+		path, params := openAPIPath(op.path)
+		if paths[path] == nil {
+			paths[path] = map[string]any{}
+		}
+		paths[path][strings.ToLower(op.method)] = b.operation(op, params)
+	}
+
+	doc := map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   "DoorEye API",
+			"version": "1.0",
+			"description": "Errors are application/problem+json bodies with a stable `code`. " +
+				"Routes under /api authenticate with the device ID as a bearer token.",
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": b.schemas,
+			"securitySchemes": map[string]any{
+				"device": map[string]any{
+					"type":        "http",
+					"scheme":      "bearer",
+					"description": "The device ID registered when the access code was redeemed.",
+				},
+			},
+			"parameters": map[string]any{
+				"ResidenceID": map[string]any{
+					"name":        ResidenceHeader,
+					"in":          "header",
+					"description": "Residence to act for, for users who belong to several.",
+					"schema":      map[string]any{"type": "integer", "format": "int64"},
+				},
+			},
+		},
+	}
+
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	out, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(out, '\n'), nil
+}
+
+func (b *specBuilder) operation(op operation, pathParams []string) map[string]any {
+	o := map[string]any{
+		"operationId": op.handler,
+		"summary":     op.summary,
+		"tags":        []string{op.tag},
+	}
+
+	var params []any
+	for _, name := range pathParams {
+		params = append(params, map[string]any{
+			"name": name, "in": "path", "required": true,
+			"schema": map[string]any{"type": "string"},
+		})
+	}
+	for _, q := range op.query {
+		params = append(params, map[string]any{
+			"name": q.name, "in": "query",
+			"schema": map[string]any{"type": q.typ},
+		})
+	}
+	if strings.HasPrefix(op.path, "/api/") {
+		params = append(params, map[string]any{"$ref": "#/components/parameters/ResidenceID"})
+		o["security"] = []any{map[string]any{"device": []string{}}}
+	}
+	if len(params) > 0 {
+		o["parameters"] = params
+	}
+
+	if op.request != nil {
+		o["requestBody"] = map[string]any{
+			"required": !op.optional,
+			"content": map[string]any{
+				"application/json": map[string]any{"schema": b.schema(reflect.TypeOf(op.request))},
+			},
+		}
+	}
+
+	status := op.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	success := map[string]any{"description": http.StatusText(status)}
+	if op.response != nil {
+		body := b.schema(reflect.TypeOf(op.response))
+		if !op.bare {
+			body = map[string]any{
+				"type":       "object",
+				"required":   []string{"data"},
+				"properties": map[string]any{"data": body},
+			}
+		}
+		content := map[string]any{"application/json": map[string]any{"schema": body}}
+		if op.csv {
+			content["text/csv"] = map[string]any{"schema": map[string]any{"type": "string"}}
+		}
+		success["content"] = content
+	}
+
+	responses := map[string]any{
+		strconv.Itoa(status): success,
+		"default": map[string]any{
+			"description": "Error",
+			"content": map[string]any{
+				ProblemContentType: map[string]any{"schema": map[string]any{"$ref": "#/components/schemas/Problem"}},
+			},
+		},
+	}
+	if op.unavailable {
+		responses[strconv.Itoa(http.StatusServiceUnavailable)] = map[string]any{
+			"description": http.StatusText(http.StatusServiceUnavailable),
+			"content":     success["content"],
+		}
+	}
+	o["responses"] = responses
+
+	return o
+}
+
+// openAPIPath turns a gin path into an OpenAPI one and lists its parameters.
+func openAPIPath(path string) (string, []string) {
+	var params []string
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		if strings.HasPrefix(s, ":") {
+			params = append(params, s[1:])
+			segments[i] = "{" + s[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
+
+func (b *specBuilder) schema(t reflect.Type) map[string]any {
+	if s, ok := schemaOverrides[t]; ok {
+		return copySchema(s)
+	}
+
+	if t.Kind() == reflect.Pointer {
+		s := b.schema(t.Elem())
+		if _, ok := s["$ref"]; ok {
+			return map[string]any{"allOf": []any{s}, "nullable": true}
+		}
+		s["nullable"] = true
+		return s
+	}
+
+	if t.Implements(jsonMarshalerType) || t.Implements(textMarshalerType) {
+		b.fail(fmt.Errorf("openapi: %s marshals itself; describe it in schemaOverrides", t))
+		return map[string]any{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int32, reflect.Int16, reflect.Int8, reflect.Uint16, reflect.Uint8, reflect.Uint32:
+		return map[string]any{"type": "integer", "format": "int32"}
+	case reflect.Int64, reflect.Uint, reflect.Uint64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": b.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": b.schema(t.Elem())}
+	case reflect.Interface:
+		return map[string]any{}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.structSchema(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + b.register(t)}
+	}
+
+	b.fail(fmt.Errorf("openapi: cannot describe %s", t))
+	return map[string]any{}
+}
+
+// register adds a named struct to the components, qualifying its name with
+// its package if another package already uses it.
+func (b *specBuilder) register(t reflect.Type) string {
+	if name, ok := b.names[t]; ok {
+		return name
+	}
+
+	name := t.Name()
+	if _, taken := b.schemas[name]; taken {
+		pkg := t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:]
+		name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	}
+
+	b.names[t] = name
+	b.schemas[name] = nil // reserve the name for recursive types
+	b.schemas[name] = b.structSchema(t)
+	return name
+}
+
+// structSchema describes a struct by its JSON fields. Request structs, which
+// carry binding tags, take required fields and limits from those tags;
+// other structs require every field that is always present.
+func (b *specBuilder) structSchema(t reflect.Type) map[string]any {
+	props := map[string]any{}
+	var required []string
+	b.fields(t, hasBindingTags(t), props, &required)
+
+	s := map[string]any{"type": "object", "properties": props}
+	if len(required) > 0 {
+		sort.Strings(required)
+		s["required"] = required
+	}
+	return s
+}
+
+func (b *specBuilder) fields(t reflect.Type, request bool, props map[string]any, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" || (!f.IsExported() && !f.Anonymous) {
+			continue
+		}
+
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				b.fields(ft, request, props, required)
+				continue
+			}
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+
+		s := b.schema(f.Type)
+		binding := f.Tag.Get("binding")
+		applyBinding(s, f.Type, binding)
+		props[name] = s
+
+		omitempty := strings.Contains(opts, "omitempty")
+		if request && hasRule(binding, "required") ||
+			!request && !omitempty && f.Type.Kind() != reflect.Pointer {
+			*required = append(*required, name)
+		}
+	}
+}
+
+func hasBindingTags(t reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		if _, ok := t.Field(i).Tag.Lookup("binding"); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func hasRule(binding, rule string) bool {
+	for _, r := range strings.Split(binding, ",") {
+		if r == "dive" {
+			return false
+		}
+		if r == rule {
+			return true
+		}
+	}
+	return false
+}
+
+// applyBinding carries validator limits over to the schema. Rules after
+// dive apply to elements and are described on the element type instead.
+func applyBinding(s map[string]any, t reflect.Type, binding string) {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	var minKey, maxKey string
+	switch t.Kind() {
+	case reflect.String:
+		minKey, maxKey = "minLength", "maxLength"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		minKey, maxKey = "minItems", "maxItems"
+	default:
+		minKey, maxKey = "minimum", "maximum"
+	}
+
+	for _, r := range strings.Split(binding, ",") {
+		rule, param, _ := strings.Cut(r, "=")
+		switch rule {
+		case "dive":
+			return
+		case "min", "gte":
+			s[minKey] = number(param)
+		case "max", "lte":
+			s[maxKey] = number(param)
+		case "len":
+			s[minKey] = number(param)
+			s[maxKey] = number(param)
+		case "oneof":
+			s["enum"] = strings.Fields(param)
+		case "url":
+			s["format"] = "uri"
+		case "email":
+			s["format"] = "email"
+		}
+	}
+}
+
+func number(s string) any {
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return n
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f
+	}
+	return s
+}
+
+func copySchema(s map[string]any) map[string]any {
+	c := make(map[string]any, len(s))
+	for k, v := range s {
+		c[k] = v
+	}
+	return c
+}
+
+func (b *specBuilder) fail(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
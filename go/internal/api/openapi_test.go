@@ -0,0 +1,381 @@
+package api
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+var update = flag.Bool("update", false, "rewrite openapi.json from the operations table")
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// TestOpenAPISpec fails when a request or response type changed without
+// openapi.json being regenerated.
+func TestOpenAPISpec(t *testing.T) {
+	spec, err := buildOpenAPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *update {
+		if err := os.WriteFile("openapi.json", spec, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	if !bytes.Equal(spec, openAPIDocument) {
+		t.Fatal("openapi.json is out of date; run go test ./internal/api -run TestOpenAPISpec -update")
+	}
+}
+
+var handlerName = regexp.MustCompile(`\(\*Handler\)\.(\w+)`)
+
+// TestOpenAPIRoutes checks that every route is documented by the handler
+// serving it, and that every documented operation is routed.
+func TestOpenAPIRoutes(t *testing.T) {
+	h := newTestHandler(Options{})
+
+	documented := map[string]string{}
+	for _, op := range operations {
+		key := op.method + " " + op.path
+		if _, dup := documented[key]; dup {
+			t.Errorf("%s is documented twice", key)
+		}
+		documented[key] = op.handler
+	}
+
+	routed := map[string]bool{}
+	for _, r := range h.router.Routes() {
+		if r.Path == "/docs" {
+			continue
+		}
+
+		key := r.Method + " " + r.Path
+		routed[key] = true
+
+		want, ok := documented[key]
+		if !ok {
+			t.Errorf("%s is not in the operations table", key)
+			continue
+		}
+		if m := handlerName.FindStringSubmatch(r.Handler); m == nil || m[1] != want {
+			t.Errorf("%s is served by %s, documented as %s", key, r.Handler, want)
+		}
+	}
+
+	for key := range documented {
+		if !routed[key] {
+			t.Errorf("%s is documented but not routed", key)
+		}
+	}
+}
+
+// TestOpenAPIRequestBodies checks each operation's request type against the
+// variable its handler binds the JSON body into.
+func TestOpenAPIRequestBodies(t *testing.T) {
+	bodies := boundBodies(t)
+
+	for _, op := range operations {
+		got, binds := bodies[op.handler]
+
+		var want string
+		if op.request != nil {
+			want = reflect.TypeOf(op.request).Name()
+		}
+
+		switch {
+		case op.request == nil && binds:
+			t.Errorf("%s binds %s but documents no request body", op.handler, got.typ)
+		case op.request != nil && !binds:
+			t.Errorf("%s documents %s but binds no request body", op.handler, want)
+		case binds && got.typ != want:
+			t.Errorf("%s binds %s, documented as %s", op.handler, got.typ, want)
+		case binds && got.optional != op.optional:
+			t.Errorf("%s: optional body is %v, documented as %v", op.handler, got.optional, op.optional)
+		}
+	}
+}
+
+type boundBody struct {
+	typ      string
+	optional bool
+}
+
+// boundBodies finds, for each Handler method, the type of the variable it
+// passes to ShouldBindJSON and whether an empty body is tolerated.
+func boundBodies(t *testing.T) map[string]boundBody {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, ".", func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bodies := map[string]boundBody{}
+	for _, file := range pkgs["api"].Files {
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Recv == nil || fn.Body == nil {
+				continue
+			}
+
+			vars := map[string]string{}
+			ast.Inspect(fn.Body, func(n ast.Node) bool {
+				switch n := n.(type) {
+				case *ast.ValueSpec:
+					if ident, ok := n.Type.(*ast.Ident); ok {
+						for _, name := range n.Names {
+							vars[name.Name] = ident.Name
+						}
+					}
+				case *ast.IfStmt:
+					call := bindCall(n.Init)
+					if call == nil {
+						return true
+					}
+					arg, ok := call.Args[0].(*ast.UnaryExpr)
+					if !ok {
+						return true
+					}
+					ident, ok := arg.X.(*ast.Ident)
+					if !ok {
+						return true
+					}
+					bodies[fn.Name.Name] = boundBody{
+						typ:      vars[ident.Name],
+						optional: mentions(n.Cond, "EOF"),
+					}
+				}
+				return true
+			})
+		}
+	}
+	return bodies
+}
+
+func bindCall(stmt ast.Stmt) *ast.CallExpr {
+	assign, ok := stmt.(*ast.AssignStmt)
+	if !ok || len(assign.Rhs) != 1 {
+		return nil
+	}
+	call, ok := assign.Rhs[0].(*ast.CallExpr)
+	if !ok || len(call.Args) != 1 {
+		return nil
+	}
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || sel.Sel.Name != "ShouldBindJSON" {
+		return nil
+	}
+	return call
+}
+
+func mentions(n ast.Node, name string) bool {
+	found := false
+	ast.Inspect(n, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Ident); ok && ident.Name == name {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// TestOpenAPIResponses checks each operation's response type and success
+// status against the c.JSON calls in its handler.
+func TestOpenAPIResponses(t *testing.T) {
+	written := writtenResponses(t)
+
+	for _, op := range operations {
+		want := op.status
+		if want == 0 {
+			want = http.StatusOK
+		}
+
+		for _, got := range written[op.handler] {
+			if got.status != 0 && got.status != want {
+				t.Errorf("%s answers %d, documented as %d", op.handler, got.status, want)
+			}
+			if got.enveloped == op.bare {
+				t.Errorf("%s: bare response is %v, documented as %v", op.handler, !got.enveloped, op.bare)
+				continue
+			}
+			if doc := reflectTypeName(reflect.TypeOf(op.response)); got.typ != "" && doc != "" && got.typ != doc {
+				t.Errorf("%s responds with %s, documented as %s", op.handler, got.typ, doc)
+			}
+		}
+	}
+}
+
+type writtenResponse struct {
+	// status is 0 when it is not a constant.
+	status    int
+	enveloped bool
+	// typ is the body's type name, or the {"data": ...} value's when
+	// enveloped, and empty for unnamed types such as gin.H.
+	typ string
+}
+
+// writtenResponses type-checks the package and collects, for each Handler
+// method, the success responses it writes with c.JSON.
+func writtenResponses(t *testing.T) map[string][]writtenResponse {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, ".", func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var files []*ast.File
+	for _, file := range pkgs["api"].Files {
+		files = append(files, file)
+	}
+
+	info := &types.Info{Types: map[ast.Expr]types.TypeAndValue{}}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "gc", exportData(t))}
+	if _, err := conf.Check("dooreye-backend/internal/api", fset, files, info); err != nil {
+		t.Fatal(err)
+	}
+
+	written := map[string][]writtenResponse{}
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Recv == nil || fn.Body == nil {
+				continue
+			}
+
+			ast.Inspect(fn.Body, func(n ast.Node) bool {
+				call, ok := n.(*ast.CallExpr)
+				if !ok || len(call.Args) != 2 {
+					return true
+				}
+				sel, ok := call.Fun.(*ast.SelectorExpr)
+				if !ok || sel.Sel.Name != "JSON" {
+					return true
+				}
+
+				var res writtenResponse
+				if v := info.Types[call.Args[0]].Value; v != nil {
+					status, _ := constant.Int64Val(v)
+					if status >= 300 {
+						return true
+					}
+					res.status = int(status)
+				}
+
+				body := call.Args[1]
+				if data := envelopeData(body); data != nil {
+					res.enveloped = true
+					body = data
+				}
+				res.typ = goTypeName(info.TypeOf(body))
+
+				written[fn.Name.Name] = append(written[fn.Name.Name], res)
+				return true
+			})
+		}
+	}
+	return written
+}
+
+// exportData looks imports up in the compiled export data the go command
+// reports for the package's dependencies, which is much faster than
+// type-checking them from source.
+func exportData(t *testing.T) importer.Lookup {
+	out, err := exec.Command("go", "list", "-export", "-deps", "-f", "{{.ImportPath}} {{.Export}}", ".").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exports := map[string]string{}
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if path, file, ok := strings.Cut(line, " "); ok && file != "" {
+			exports[path] = file
+		}
+	}
+
+	return func(path string) (io.ReadCloser, error) {
+		file, ok := exports[path]
+		if !ok {
+			return nil, fmt.Errorf("no export data for %s", path)
+		}
+		return os.Open(file)
+	}
+}
+
+// envelopeData returns the value of a gin.H{"data": ...} literal with no
+// other keys, and nil for any other expression.
+func envelopeData(expr ast.Expr) ast.Expr {
+	lit, ok := expr.(*ast.CompositeLit)
+	if !ok || len(lit.Elts) != 1 {
+		return nil
+	}
+	kv, ok := lit.Elts[0].(*ast.KeyValueExpr)
+	if !ok {
+		return nil
+	}
+	if key, ok := kv.Key.(*ast.BasicLit); !ok || key.Value != `"data"` {
+		return nil
+	}
+	return kv.Value
+}
+
+// goTypeName and reflectTypeName spell a type the same way, as in
+// "[]model.Visit", ignoring pointers since they encode alike. Both return ""
+// for unnamed types.
+func goTypeName(t types.Type) string {
+	switch t := t.(type) {
+	case *types.Pointer:
+		return goTypeName(t.Elem())
+	case *types.Slice:
+		if name := goTypeName(t.Elem()); name != "" {
+			return "[]" + name
+		}
+	case *types.Named:
+		if t.Obj().Pkg() != nil && t.Obj().Name() != "H" {
+			return t.Obj().Pkg().Name() + "." + t.Obj().Name()
+		}
+	}
+	return ""
+}
+
+func reflectTypeName(t reflect.Type) string {
+	if t == nil {
+		return ""
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return reflectTypeName(t.Elem())
+	case reflect.Slice:
+		if name := reflectTypeName(t.Elem()); name != "" {
+			return "[]" + name
+		}
+		return ""
+	}
+	if t.Name() == "" || t.PkgPath() == "" {
+		return ""
+	}
+	return t.String()
+}
@@ -0,0 +1,138 @@
+package api
+
+import (
+	"dooreye-backend/internal/model"
+	"dooreye-backend/internal/store"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+var ErrSocietyRequired = errors.New("society_id is required")
+
+func (h *Handler) getOverstays(c *gin.Context) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	var filter store.OverstayFilter
+
+	switch user.Role {
+	case model.RoleOwner, model.RoleResident:
+		if user.ResidenceID == nil {
+			h.respondError(c, http.StatusForbidden, ErrUnauthorizedRole)
+			return
+		}
+		filter.ResidenceID = user.ResidenceID
+	case model.RoleAdmin:
+		societyID, err := societyIDParam(c, nil)
+		if err != nil && !errors.Is(err, ErrSocietyRequired) {
+			h.respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		filter.SocietyID = societyID
+	default:
+		societyID, err := societyIDParam(c, user)
+		if err != nil {
+			h.respondError(c, http.StatusForbidden, err)
+			return
+		}
+		filter.SocietyID = societyID
+	}
+
+	visits, err := h.db.GetOverstayingVisits(c.Request.Context(), filter)
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": visits})
+}
+
+func (h *Handler) getStayLimits(c *gin.Context) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	societyID, err := societyIDParam(c, user)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	limits, err := h.db.GetStayLimits(c.Request.Context(), *societyID)
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": limits})
+}
+
+type UpdateStayLimitRequest struct {
+	MaxStayMinutes int `json:"max_stay_minutes" binding:"required,min=1"`
+}
+
+func (h *Handler) updateStayLimit(c *gin.Context) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	var req UpdateStayLimitRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	societyID, err := societyIDParam(c, user)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	visitorType := model.VisitorType(c.Param("visitor_type"))
+	if !visitorType.Valid() {
+		h.respondError(c, http.StatusBadRequest, fmt.Errorf("invalid visitor_type %q", visitorType))
+		return
+	}
+
+	limit, err := h.db.UpsertStayLimit(c.Request.Context(), *societyID, visitorType, req.MaxStayMinutes)
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": limit})
+}
+
+// societyIDParam resolves the society a request operates on. Society level
+// users are pinned to their own society; admins pick one via ?society_id.
+func societyIDParam(c *gin.Context, user *AuthUser) (*int64, error) {
+	if user != nil && user.Role != model.RoleAdmin {
+		if user.SocietyID == nil {
+			return nil, ErrSocietyRequired
+		}
+		return user.SocietyID, nil
+	}
+
+	raw := c.Query("society_id")
+	if raw == "" {
+		return nil, ErrSocietyRequired
+	}
+
+	id, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid society_id: %w", err)
+	}
+
+	return &id, nil
+}
@@ -0,0 +1,485 @@
+package api
+
+import (
+	"context"
+	"dooreye-backend/internal/model"
+	"dooreye-backend/internal/store"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	ErrSlotNotAssigned    = errors.New("parking slot is not assigned to this residence")
+	ErrInvalidBookingTime = errors.New("ends_at must be after starts_at and in the future")
+	ErrVisitorNotYours    = errors.New("visitor was not pre-approved by you")
+)
+
+// defaultGuestStay is how long an unbooked guest holds a slot at check-in
+// when the society has no stay limit for the visitor type.
+const defaultGuestStay = 4 * time.Hour
+
+// maxGuestBooking caps a single guest parking booking.
+const maxGuestBooking = 7 * 24 * time.Hour
+
+func (h *Handler) getParkingSlots(c *gin.Context) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	var filter store.ParkingSlotFilter
+	switch user.Role {
+	case model.RoleOwner, model.RoleResident:
+		if user.ResidenceID == nil {
+			h.respondError(c, http.StatusForbidden, ErrUnauthorizedRole)
+			return
+		}
+		filter.ResidenceID = user.ResidenceID
+	default:
+		societyID, err := societyIDParam(c, user)
+		if err != nil {
+			h.respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		filter.SocietyID = societyID
+		filter.OnlyGuest = c.Query("guest") == "true"
+	}
+
+	slots, err := h.db.GetParkingSlots(c.Request.Context(), filter)
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": slots})
+}
+
+type CreateParkingSlotRequest struct {
+	BlockID     int64             `json:"block_id" binding:"required"`
+	Code        string            `json:"code" binding:"required,max=20"`
+	VehicleType model.VehicleType `json:"vehicle_type" binding:"required"`
+	ResidenceID *int64            `json:"residence_id"`
+	IsGuest     bool              `json:"is_guest"`
+}
+
+func (h *Handler) createParkingSlot(c *gin.Context) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	var req CreateParkingSlotRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if !req.VehicleType.Valid() {
+		h.respondError(c, http.StatusBadRequest, fmt.Errorf("invalid vehicle type %q", req.VehicleType))
+		return
+	}
+
+	if req.IsGuest && req.ResidenceID != nil {
+		h.respondError(c, http.StatusBadRequest, errors.New("a guest slot cannot be assigned to a residence"))
+		return
+	}
+
+	societyID, err := societyIDParam(c, user)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if req.ResidenceID != nil && !h.residenceInSociety(c, *req.ResidenceID, *societyID) {
+		return
+	}
+
+	slot, err := h.db.CreateParkingSlot(c.Request.Context(), store.CreateParkingSlotParams{
+		SocietyID:   *societyID,
+		BlockID:     req.BlockID,
+		Code:        req.Code,
+		VehicleType: req.VehicleType,
+		ResidenceID: req.ResidenceID,
+		IsGuest:     req.IsGuest,
+	})
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			status = http.StatusNotFound
+			err = errors.New("block not found in this society")
+		case errors.Is(err, store.ErrAlreadyExists):
+			status = http.StatusConflict
+		}
+		h.respondError(c, status, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": slot})
+}
+
+type AssignParkingSlotRequest struct {
+	ResidenceID *int64 `json:"residence_id"`
+	IsGuest     bool   `json:"is_guest"`
+}
+
+// assignParkingSlot hands a slot to a residence, moves it to the guest pool,
+// or leaves it unassigned when neither is given.
+func (h *Handler) assignParkingSlot(c *gin.Context) {
+	slot, ok := h.managedParkingSlot(c)
+	if !ok {
+		return
+	}
+
+	var req AssignParkingSlotRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if req.IsGuest && req.ResidenceID != nil {
+		h.respondError(c, http.StatusBadRequest, errors.New("a guest slot cannot be assigned to a residence"))
+		return
+	}
+
+	if req.ResidenceID != nil && !h.residenceInSociety(c, *req.ResidenceID, slot.SocietyID) {
+		return
+	}
+
+	slot, err := h.db.AssignParkingSlot(c.Request.Context(), slot.ID, req.ResidenceID, req.IsGuest)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, store.ErrSlotHasBookings):
+			status = http.StatusConflict
+		}
+		h.respondError(c, status, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": slot})
+}
+
+func (h *Handler) retireParkingSlot(c *gin.Context) {
+	slot, ok := h.managedParkingSlot(c)
+	if !ok {
+		return
+	}
+
+	if err := h.db.RetireParkingSlot(c.Request.Context(), slot.ID); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, store.ErrNotFound) {
+			status = http.StatusNotFound
+		}
+		h.respondError(c, status, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *Handler) getGuestParkingAvailability(c *gin.Context) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	societyID, err := h.userSocietyID(c, user)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	from, to, err := parseBookingWindow(c.Query("starts_at"), c.Query("ends_at"))
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	vehicleType := model.VehicleType(c.DefaultQuery("vehicle_type", string(model.VehicleCar)))
+	if !vehicleType.Valid() {
+		h.respondError(c, http.StatusBadRequest, fmt.Errorf("invalid vehicle type %q", vehicleType))
+		return
+	}
+
+	slots, err := h.db.GetAvailableGuestSlots(c.Request.Context(), societyID, vehicleType, from, to)
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": slots})
+}
+
+type CreateGuestBookingRequest struct {
+	VisitorID   string            `json:"visitor_id" binding:"required,uuid"`
+	SlotID      *int64            `json:"slot_id"`
+	VehicleType model.VehicleType `json:"vehicle_type"`
+	StartsAt    time.Time         `json:"starts_at" binding:"required"`
+	EndsAt      time.Time         `json:"ends_at" binding:"required"`
+}
+
+// createGuestBooking lets a resident hold a guest slot for a visitor they
+// pre-approved. The visitor's phone is matched at check-in.
+func (h *Handler) createGuestBooking(c *gin.Context) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	if !c.GetBool(string(CanCreatePassesKey)) {
+		h.respondError(c, http.StatusForbidden, ErrPermissionDenied)
+		return
+	}
+
+	var req CreateGuestBookingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if !req.EndsAt.After(req.StartsAt) || !req.EndsAt.After(time.Now()) || req.EndsAt.Sub(req.StartsAt) > maxGuestBooking {
+		h.respondError(c, http.StatusBadRequest, ErrInvalidBookingTime)
+		return
+	}
+
+	if req.VehicleType == "" {
+		req.VehicleType = model.VehicleCar
+	}
+	if !req.VehicleType.Valid() {
+		h.respondError(c, http.StatusBadRequest, fmt.Errorf("invalid vehicle type %q", req.VehicleType))
+		return
+	}
+
+	residenceID, ok := h.residenceInScope(c, user, nil)
+	if !ok {
+		return
+	}
+
+	visitor, err := h.db.GetVisitor(c.Request.Context(), req.VisitorID)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		h.respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	if err != nil || visitor.CreatedBy.String() != user.ID || visitor.PreApprovedTill == nil {
+		h.respondError(c, http.StatusForbidden, ErrVisitorNotYours)
+		return
+	}
+
+	societyID, err := h.db.GetResidenceSocietyID(c.Request.Context(), residenceID)
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	booking, err := h.db.CreateGuestBooking(c.Request.Context(), store.CreateGuestBookingParams{
+		SocietyID:   societyID,
+		SlotID:      req.SlotID,
+		VehicleType: req.VehicleType,
+		ResidenceID: &residenceID,
+		VisitorID:   &req.VisitorID,
+		From:        req.StartsAt,
+		To:          req.EndsAt,
+		BookedBy:    user.ID,
+	})
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, store.ErrSlotUnavailable),
+			errors.Is(err, store.ErrVisitorAlreadyBooked),
+			errors.Is(err, store.ErrNoGuestSlot):
+			status = http.StatusConflict
+		}
+		h.respondError(c, status, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": booking})
+}
+
+func (h *Handler) getGuestBookings(c *gin.Context) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	filter := store.GuestBookingFilter{
+		OnlyUpcoming: c.Query("upcoming") == "true",
+	}
+
+	switch user.Role {
+	case model.RoleOwner, model.RoleResident:
+		if user.ResidenceID == nil {
+			h.respondError(c, http.StatusForbidden, ErrUnauthorizedRole)
+			return
+		}
+		filter.ResidenceID = user.ResidenceID
+	default:
+		societyID, err := societyIDParam(c, user)
+		if err != nil {
+			h.respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		filter.SocietyID = societyID
+	}
+
+	bookings, err := h.db.GetGuestBookings(c.Request.Context(), filter)
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": bookings})
+}
+
+func (h *Handler) cancelGuestBooking(c *gin.Context) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, fmt.Errorf("invalid booking id: %w", err))
+		return
+	}
+
+	booking, err := h.db.GetGuestBooking(c.Request.Context(), id)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, store.ErrNotFound) {
+			status = http.StatusNotFound
+		}
+		h.respondError(c, status, err)
+		return
+	}
+
+	if booking.ResidenceID == nil {
+		h.respondError(c, http.StatusForbidden, ErrUnauthorizedRole)
+		return
+	}
+	if _, ok := h.residenceInScope(c, user, booking.ResidenceID); !ok {
+		return
+	}
+
+	if err := h.db.CancelGuestBooking(c.Request.Context(), id); err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, store.ErrBookingClosed):
+			status = http.StatusConflict
+		}
+		h.respondError(c, status, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// managedParkingSlot loads the slot named by :id and checks it belongs to
+// the caller's society. It writes the error response itself.
+func (h *Handler) managedParkingSlot(c *gin.Context) (*model.ParkingSlot, bool) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return nil, false
+	}
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, fmt.Errorf("invalid slot id: %w", err))
+		return nil, false
+	}
+
+	slot, err := h.db.GetParkingSlot(c.Request.Context(), id)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, store.ErrNotFound) {
+			status = http.StatusNotFound
+		}
+		h.respondError(c, status, err)
+		return nil, false
+	}
+
+	if user.Role != model.RoleAdmin && (user.SocietyID == nil || *user.SocietyID != slot.SocietyID) {
+		h.respondError(c, http.StatusForbidden, ErrUnauthorizedRole)
+		return nil, false
+	}
+
+	return slot, true
+}
+
+// residenceInSociety writes the error response itself.
+func (h *Handler) residenceInSociety(c *gin.Context, residenceID, societyID int64) bool {
+	got, err := h.db.GetResidenceSocietyID(c.Request.Context(), residenceID)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		h.respondError(c, http.StatusInternalServerError, err)
+		return false
+	}
+	if err != nil || got != societyID {
+		h.respondError(c, http.StatusBadRequest, errors.New("residence is not in this society"))
+		return false
+	}
+
+	return true
+}
+
+// guestStay is how long a walk-in guest vehicle is expected to stay: the
+// society's stay limit for the visitor type, or defaultGuestStay.
+func (h *Handler) guestStay(ctx context.Context, societyID int64, visitorType model.VisitorType) time.Duration {
+	limits, err := h.db.GetStayLimits(ctx, societyID)
+	if err != nil {
+		h.log.WarnContext(ctx, "loading stay limits for guest parking", "society_id", societyID, "error", err)
+		return defaultGuestStay
+	}
+
+	for _, l := range limits {
+		if l.VisitorType == visitorType && l.MaxStayMinutes > 0 {
+			return time.Duration(l.MaxStayMinutes) * time.Minute
+		}
+	}
+
+	return defaultGuestStay
+}
+
+func parseBookingWindow(rawFrom, rawTo string) (time.Time, time.Time, error) {
+	from := time.Now()
+	if rawFrom != "" {
+		t, err := time.Parse(time.RFC3339, rawFrom)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid starts_at: %w", err)
+		}
+		from = t
+	}
+
+	to := from.Add(defaultGuestStay)
+	if rawTo != "" {
+		t, err := time.Parse(time.RFC3339, rawTo)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid ends_at: %w", err)
+		}
+		to = t
+	}
+
+	if !to.After(from) {
+		return time.Time{}, time.Time{}, ErrInvalidBookingTime
+	}
+
+	return from, to, nil
+}
@@ -0,0 +1,415 @@
+package api
+
+import (
+	"context"
+	"dooreye-backend/internal/ballot"
+	"dooreye-backend/internal/model"
+	"dooreye-backend/internal/store"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	ErrInvalidPollWindow = errors.New("closes_at must be after opens_at and in the future")
+	ErrDuplicateOption   = errors.New("poll options must be distinct")
+	ErrResultsNotFinal   = errors.New("results are available once the poll has closed")
+)
+
+type CreatePollRequest struct {
+	Title       string                `json:"title" binding:"required,max=200"`
+	Description *string               `json:"description" binding:"omitempty,max=5000"`
+	Eligibility model.PollEligibility `json:"eligibility"`
+	IsAnonymous bool                  `json:"is_anonymous"`
+	OpensAt     *time.Time            `json:"opens_at"`
+	ClosesAt    time.Time             `json:"closes_at" binding:"required"`
+	Options     []string              `json:"options" binding:"required,min=2,max=20,dive,required,max=200"`
+}
+
+func (h *Handler) createPoll(c *gin.Context) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	var req CreatePollRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if req.Eligibility == "" {
+		req.Eligibility = model.PollOwners
+	}
+	if !req.Eligibility.Valid() {
+		h.respondError(c, http.StatusBadRequest, fmt.Errorf("invalid eligibility %q", req.Eligibility))
+		return
+	}
+
+	now := time.Now()
+	opensAt := now
+	if req.OpensAt != nil && req.OpensAt.After(now) {
+		opensAt = *req.OpensAt
+	}
+	if !req.ClosesAt.After(opensAt) {
+		h.respondError(c, http.StatusBadRequest, ErrInvalidPollWindow)
+		return
+	}
+
+	seen := make(map[string]bool, len(req.Options))
+	for i, label := range req.Options {
+		label = strings.TrimSpace(label)
+		key := strings.ToLower(label)
+		if label == "" || seen[key] {
+			h.respondError(c, http.StatusBadRequest, ErrDuplicateOption)
+			return
+		}
+		seen[key] = true
+		req.Options[i] = label
+	}
+
+	societyID, err := societyIDParam(c, user)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	poll, err := h.db.CreatePoll(c.Request.Context(), store.CreatePollParams{
+		SocietyID:   *societyID,
+		Title:       req.Title,
+		Description: req.Description,
+		Eligibility: req.Eligibility,
+		IsAnonymous: req.IsAnonymous,
+		OpensAt:     opensAt,
+		ClosesAt:    req.ClosesAt,
+		Options:     req.Options,
+		CreatedBy:   user.ID,
+	})
+	if err != nil {
+		h.respondError(c, errorStatus(err), err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": poll})
+}
+
+func (h *Handler) getPolls(c *gin.Context) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	var societyID int64
+	if isPollManager(user) {
+		id, err := societyIDParam(c, user)
+		if err != nil {
+			h.respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		societyID = *id
+	} else {
+		societyID, err = h.userSocietyID(c, user)
+		if err != nil {
+			h.respondError(c, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	filter := store.PollFilter{
+		SocietyID: societyID,
+		Status:    model.PollStatus(strings.ToUpper(c.Query("status"))),
+	}
+	filter.Limit, _ = strconv.Atoi(c.Query("limit"))
+	filter.Offset, _ = strconv.Atoi(c.Query("offset"))
+
+	polls, err := h.db.GetPolls(c.Request.Context(), filter)
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": polls})
+}
+
+// getPoll returns a poll with whether the caller's residence has voted, and
+// its results once they are visible to the caller.
+func (h *Handler) getPoll(c *gin.Context) {
+	poll, user, ok := h.pollInScope(c)
+	if !ok {
+		return
+	}
+
+	resp := gin.H{"poll": poll}
+
+	if user.ResidenceID != nil {
+		voted, err := h.db.HasVoted(c.Request.Context(), poll.ID, *user.ResidenceID)
+		if err != nil {
+			h.respondError(c, http.StatusInternalServerError, err)
+			return
+		}
+		resp["has_voted"] = voted
+	}
+
+	if resultsVisible(poll, user) {
+		results, err := h.db.GetPollResults(c.Request.Context(), poll)
+		if err != nil {
+			h.respondError(c, http.StatusInternalServerError, err)
+			return
+		}
+		resp["results"] = results
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": resp})
+}
+
+type CastVoteRequest struct {
+	OptionID int64 `json:"option_id" binding:"required"`
+}
+
+// castVote records the vote of the caller's residence. The response carries
+// the ballot hash as a receipt the voter can later find in the export.
+func (h *Handler) castVote(c *gin.Context) {
+	poll, user, ok := h.pollInScope(c)
+	if !ok {
+		return
+	}
+
+	var req CastVoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if user.ResidenceID == nil {
+		h.respondError(c, http.StatusForbidden, store.ErrNotEligible)
+		return
+	}
+
+	b, err := h.db.CastVote(c.Request.Context(), store.CastVoteParams{
+		PollID:      poll.ID,
+		OptionID:    req.OptionID,
+		ResidenceID: *user.ResidenceID,
+		VoterID:     user.ID,
+	})
+	if err != nil {
+		h.respondError(c, errorStatus(err), err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": gin.H{
+		"poll_id":   b.PollID,
+		"option_id": b.OptionID,
+		"seq":       b.Seq,
+		"receipt":   b.Hash,
+	}})
+}
+
+func (h *Handler) closePoll(c *gin.Context) {
+	h.endPoll(c, h.db.ClosePoll)
+}
+
+func (h *Handler) cancelPoll(c *gin.Context) {
+	h.endPoll(c, h.db.CancelPoll)
+}
+
+func (h *Handler) endPoll(c *gin.Context, end func(ctx context.Context, id int64) (*model.Poll, error)) {
+	poll, _, ok := h.pollInScope(c)
+	if !ok {
+		return
+	}
+
+	poll, err := end(c.Request.Context(), poll.ID)
+	if err != nil {
+		h.respondError(c, errorStatus(err), err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": poll})
+}
+
+func (h *Handler) getPollResults(c *gin.Context) {
+	poll, user, ok := h.pollInScope(c)
+	if !ok {
+		return
+	}
+
+	if !resultsVisible(poll, user) {
+		h.respondError(c, http.StatusForbidden, ErrResultsNotFinal)
+		return
+	}
+
+	results, err := h.db.GetPollResults(c.Request.Context(), poll)
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": results})
+}
+
+// exportPoll exports the results with the full ballot chain, so anyone
+// holding the export can recompute the hashes and compare the head against
+// the one the server reports. ?format=csv gives the ballots as a sheet.
+func (h *Handler) exportPoll(c *gin.Context) {
+	poll, user, ok := h.pollInScope(c)
+	if !ok {
+		return
+	}
+
+	if !resultsVisible(poll, user) {
+		h.respondError(c, http.StatusForbidden, ErrResultsNotFinal)
+		return
+	}
+
+	results, err := h.db.GetPollResults(c.Request.Context(), poll)
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	ballots, err := h.db.GetPollBallots(c.Request.Context(), poll.ID)
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	if c.Query("format") == "csv" {
+		name := fmt.Sprintf("poll-%d-%s.csv", poll.ID, time.Now().Format("20060102-1504"))
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+		c.Header("Content-Type", "text/csv")
+		c.Status(http.StatusOK)
+
+		if err := writePollCSV(c.Writer, poll, ballots); err != nil {
+			h.log.ErrorContext(c.Request.Context(), "writing poll csv", "error", err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"poll":    poll,
+		"results": results,
+		"ballots": ballots,
+	}})
+}
+
+// verifyPoll recomputes the ballot chain and reports whether it is intact.
+func (h *Handler) verifyPoll(c *gin.Context) {
+	poll, _, ok := h.pollInScope(c)
+	if !ok {
+		return
+	}
+
+	ballots, err := h.db.GetPollBallots(c.Request.Context(), poll.ID)
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	resp := gin.H{
+		"poll_id":      poll.ID,
+		"ballot_count": len(ballots),
+		"head_hash":    poll.HeadHash,
+		"valid":        true,
+	}
+
+	if err := ballot.Verify(ballots, poll.HeadHash); err != nil {
+		h.log.WarnContext(c.Request.Context(), "poll ballot chain failed verification", "poll_id", poll.ID, "error", err)
+		resp["valid"] = false
+		resp["error"] = err.Error()
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": resp})
+}
+
+func writePollCSV(w io.Writer, poll *model.Poll, ballots []model.PollBallot) error {
+	labels := make(map[int64]string, len(poll.Options))
+	for _, o := range poll.Options {
+		labels[o.ID] = o.Label
+	}
+
+	cw := csv.NewWriter(w)
+	cw.Write([]string{
+		"seq", "option_id", "option", "residence_id", "voter_id", "cast_at",
+		"prev_hash", "hash",
+	})
+
+	for _, b := range ballots {
+		var residence, voter, cast string
+		if b.ResidenceID != nil {
+			residence = strconv.FormatInt(*b.ResidenceID, 10)
+		}
+		if b.VoterID != nil {
+			voter = b.VoterID.String()
+		}
+		if b.CastAt != nil {
+			cast = b.CastAt.Format(time.RFC3339Nano)
+		}
+
+		cw.Write([]string{
+			strconv.Itoa(b.Seq),
+			strconv.FormatInt(b.OptionID, 10),
+			labels[b.OptionID],
+			residence,
+			voter,
+			cast,
+			b.PrevHash.String(),
+			b.Hash.String(),
+		})
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// pollInScope loads the poll named by :id if the caller belongs to or
+// manages its society. It writes the error response itself.
+func (h *Handler) pollInScope(c *gin.Context) (*model.Poll, *AuthUser, bool) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return nil, nil, false
+	}
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, fmt.Errorf("invalid poll id: %w", err))
+		return nil, nil, false
+	}
+
+	poll, err := h.db.GetPoll(c.Request.Context(), id)
+	if err != nil {
+		h.respondError(c, errorStatus(err), err)
+		return nil, nil, false
+	}
+
+	if user.Role == model.RoleAdmin {
+		return poll, user, true
+	}
+
+	societyID, err := h.userSocietyID(c, user)
+	if err != nil || societyID != poll.SocietyID {
+		h.respondError(c, http.StatusNotFound, store.ErrNotFound)
+		return nil, nil, false
+	}
+
+	return poll, user, true
+}
+
+func isPollManager(user *AuthUser) bool {
+	return user.Role == model.RoleAdmin || user.Role == model.RoleSocietyManager
+}
+
+// resultsVisible hides running tallies from voters so early results do not
+// sway the vote. Managers see them at any time.
+func resultsVisible(poll *model.Poll, user *AuthUser) bool {
+	return isPollManager(user) || poll.Status == model.PollClosed
+}
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"crypto/sha256"
+	"dooreye-backend/internal/ratelimit"
+	"encoding/hex"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var ErrLockedOut = errors.New("too many failed attempts, try again later")
+
+// Request rates. Public routes are limited by IP; /api is limited by IP
+// before authentication, so guessing device IDs is slow, and by user after.
+var (
+	activateRate = ratelimit.PerMinute(10, 5)
+	inviteRate   = ratelimit.PerMinute(30, 30)
+	apiIPRate    = ratelimit.PerSecond(20, 100)
+	apiUserRate  = ratelimit.PerSecond(10, 50)
+)
+
+// newActivationLockout locks out an IP, device or access code after
+// repeated failed activations: a minute after the fifth, doubling up to an
+// hour.
+func newActivationLockout(backend ratelimit.Backend) *ratelimit.Lockout {
+	return ratelimit.NewLockout("activate", backend, 5, time.Minute, time.Hour, time.Hour)
+}
+
+// newAuthLockout locks out an IP presenting unknown device IDs. It allows
+// more failures than activation, since signed-out devices retry on their
+// own, and never resets on success, so one valid device behind a shared
+// IP cannot clear an attacker's count.
+func newAuthLockout(backend ratelimit.Backend) *ratelimit.Lockout {
+	return ratelimit.NewLockout("auth", backend, 20, time.Minute, time.Hour, 15*time.Minute)
+}
+
+// rateKey picks what a request is counted against. An empty key skips the
+// limit.
+type rateKey func(c *gin.Context) string
+
+func byIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+func byUser(c *gin.Context) string {
+	if id := c.GetString(string(UserIDKey)); id != "" {
+		return "user:" + id
+	}
+	return ""
+}
+
+// byDeviceID keys on a device ID that is not yet a credential. It is
+// hashed so stored keys cannot be used to sign in.
+func byDeviceID(deviceID string) string {
+	sum := sha256.Sum256([]byte(deviceID))
+	return "device:" + hex.EncodeToString(sum[:16])
+}
+
+// byAccessCode keys on a submitted access code, hashed like device IDs so
+// stored keys do not reveal pending codes.
+func byAccessCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return "code:" + hex.EncodeToString(sum[:16])
+}
+
+// RateLimit rejects requests over the limiter's rate for their key. If the
+// backend fails, requests are let through rather than taking the API down.
+func (h *Handler) RateLimit(l *ratelimit.Limiter, key rateKey) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		k := key(c)
+		if k == "" {
+			c.Next()
+			return
+		}
+
+		ok, retry, err := l.Allow(c.Request.Context(), k)
+		if err != nil {
+			h.log.ErrorContext(c.Request.Context(), "checking rate limit", "error", err)
+			c.Next()
+			return
+		}
+		if !ok {
+			h.tooManyRequests(c, retry, ErrRateLimited)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// lockedOut answers 429 and returns true if key is locked out.
+func (h *Handler) lockedOut(c *gin.Context, l *ratelimit.Lockout, key string) bool {
+	retry, err := l.Check(c.Request.Context(), key)
+	if err != nil {
+		h.log.ErrorContext(c.Request.Context(), "checking lockout", "error", err)
+		return false
+	}
+	if retry > 0 {
+		h.tooManyRequests(c, retry, ErrLockedOut)
+		return true
+	}
+	return false
+}
+
+// recordFailure counts a failed attempt against key. The attempt itself is
+// answered normally; a resulting lock applies from the next one.
+func (h *Handler) recordFailure(c *gin.Context, l *ratelimit.Lockout, key string) {
+	locked, err := l.Fail(c.Request.Context(), key)
+	if err != nil {
+		h.log.ErrorContext(c.Request.Context(), "recording failed attempt", "error", err)
+		return
+	}
+	if locked > 0 {
+		h.log.WarnContext(c.Request.Context(), "locked out after failed attempts",
+			"key", key, "duration", locked)
+	}
+}
+
+// clearFailures forgets key's failed attempts after a success.
+func (h *Handler) clearFailures(c *gin.Context, l *ratelimit.Lockout, key string) {
+	if err := l.Succeed(c.Request.Context(), key); err != nil {
+		h.log.ErrorContext(c.Request.Context(), "clearing failed attempts", "error", err)
+	}
+}
+
+func (h *Handler) tooManyRequests(c *gin.Context, retry time.Duration, err error) {
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retry.Seconds()))))
+	h.respondError(c, http.StatusTooManyRequests, err)
+}
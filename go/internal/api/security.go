@@ -0,0 +1,58 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultMaxBodyBytes bounds request bodies when Options.MaxBodyBytes is
+// unset. The API takes JSON only, so this is generous.
+const defaultMaxBodyBytes = 1 << 20
+
+var ErrBodyTooLarge = errors.New("request body too large")
+
+// apiContentSecurityPolicy forbids loading anything: responses are JSON
+// and CSV, never pages. handleDocs relaxes it for its own page.
+const apiContentSecurityPolicy = "default-src 'none'; frame-ancestors 'none'"
+
+// SecurityHeaders sets headers that keep browsers from sniffing, framing or
+// leaking responses. HSTS is sent only when hstsMaxAge is positive, since a
+// deployment still on plain HTTP would lock its users out.
+func (h *Handler) SecurityHeaders(hstsMaxAge time.Duration) gin.HandlerFunc {
+	hsts := ""
+	if hstsMaxAge > 0 {
+		hsts = "max-age=" + strconv.Itoa(int(hstsMaxAge.Seconds())) + "; includeSubDomains"
+	}
+
+	return func(c *gin.Context) {
+		header := c.Writer.Header()
+		header.Set("X-Content-Type-Options", "nosniff")
+		header.Set("X-Frame-Options", "DENY")
+		header.Set("Referrer-Policy", "no-referrer")
+		header.Set("Content-Security-Policy", apiContentSecurityPolicy)
+		if hsts != "" {
+			header.Set("Strict-Transport-Security", hsts)
+		}
+
+		c.Next()
+	}
+}
+
+// LimitBody caps request bodies at limit bytes. Bodies declaring a larger
+// length are refused outright; others fail when a handler reads past the
+// limit, which respondError reports as 413.
+func (h *Handler) LimitBody(limit int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.ContentLength > limit {
+			h.respondError(c, http.StatusRequestEntityTooLarge, ErrBodyTooLarge)
+			return
+		}
+
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		c.Next()
+	}
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSecurityHeaders checks the headers are sent on every response,
+// including errors raised before or instead of a handler.
+func TestSecurityHeaders(t *testing.T) {
+	h := newTestHandler(Options{HSTSMaxAge: 365 * 24 * time.Hour, MaxBodyBytes: 16})
+
+	tooLarge := httptest.NewRequest(http.MethodPost, "/activate", strings.NewReader(`{"access_code": "0123456789abcdef"}`))
+	tooLarge.Header.Set("Content-Type", "application/json")
+
+	tests := []struct {
+		name   string
+		req    *http.Request
+		status int
+	}{
+		{"ok", httptest.NewRequest(http.MethodGet, "/livez", nil), http.StatusOK},
+		{"unknown route", httptest.NewRequest(http.MethodGet, "/nowhere", nil), http.StatusNotFound},
+		{"wrong method", httptest.NewRequest(http.MethodDelete, "/livez", nil), http.StatusMethodNotAllowed},
+		{"unauthenticated", httptest.NewRequest(http.MethodGet, "/api/visits", nil), http.StatusUnauthorized},
+		{"body too large", tooLarge, http.StatusRequestEntityTooLarge},
+	}
+
+	want := map[string]string{
+		"X-Content-Type-Options":    "nosniff",
+		"X-Frame-Options":           "DENY",
+		"Referrer-Policy":           "no-referrer",
+		"Content-Security-Policy":   apiContentSecurityPolicy,
+		"Strict-Transport-Security": "max-age=31536000; includeSubDomains",
+	}
+
+	for _, tt := range tests {
+		w := serve(h, tt.req)
+		if w.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.status)
+		}
+		for name, value := range want {
+			if got := w.Header().Get(name); got != value {
+				t.Errorf("%s: %s = %q, want %q", tt.name, name, got, value)
+			}
+		}
+	}
+}
+
+func TestSecurityHeadersWithoutHSTS(t *testing.T) {
+	w := serve(newTestHandler(Options{}), httptest.NewRequest(http.MethodGet, "/livez", nil))
+	if got := w.Header().Get("Strict-Transport-Security"); got != "" {
+		t.Errorf("Strict-Transport-Security = %q without HSTSMaxAge", got)
+	}
+}
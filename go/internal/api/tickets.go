@@ -0,0 +1,608 @@
+package api
+
+import (
+	"context"
+	"dooreye-backend/internal/model"
+	"dooreye-backend/internal/notify"
+	"dooreye-backend/internal/store"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	ErrTicketLocation    = errors.New("give either residence_id or common_area, not both")
+	ErrInvalidAssignee   = errors.New("assignee must be an active manager or security user of the society")
+	ErrTicketNotForVisit = errors.New("ticket is not open in this society")
+)
+
+type CreateTicketRequest struct {
+	ResidenceID *int64               `json:"residence_id"`
+	CommonArea  *string              `json:"common_area" binding:"omitempty,min=1,max=100"`
+	Category    model.TicketCategory `json:"category" binding:"required"`
+	Priority    model.TicketPriority `json:"priority"`
+	Title       string               `json:"title" binding:"required,max=200"`
+	Description *string              `json:"description" binding:"omitempty,max=5000"`
+	PhotoURLs   []string             `json:"photo_urls" binding:"max=10,dive,url"`
+}
+
+// createTicket lets a resident raise a ticket against their residence, or
+// against a common area of their society such as a lift or the lobby.
+func (h *Handler) createTicket(c *gin.Context) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	var req CreateTicketRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if !req.Category.Valid() {
+		h.respondError(c, http.StatusBadRequest, fmt.Errorf("invalid category %q", req.Category))
+		return
+	}
+
+	if req.Priority == "" {
+		req.Priority = model.TicketMedium
+	}
+	if !req.Priority.Valid() {
+		h.respondError(c, http.StatusBadRequest, fmt.Errorf("invalid priority %q", req.Priority))
+		return
+	}
+
+	if req.CommonArea != nil && req.ResidenceID != nil {
+		h.respondError(c, http.StatusBadRequest, ErrTicketLocation)
+		return
+	}
+
+	params := store.CreateTicketParams{
+		CommonArea:  req.CommonArea,
+		Category:    req.Category,
+		Priority:    req.Priority,
+		Title:       req.Title,
+		Description: req.Description,
+		PhotoURLs:   req.PhotoURLs,
+		RaisedBy:    user.ID,
+	}
+
+	if req.CommonArea == nil {
+		residenceID, ok := h.residenceInScope(c, user, req.ResidenceID)
+		if !ok {
+			return
+		}
+		params.ResidenceID = &residenceID
+	}
+
+	params.SocietyID, err = h.userSocietyID(c, user)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ticket, err := h.db.CreateTicket(c.Request.Context(), params)
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	h.notifyTicketManagers(c.Request.Context(), ticket)
+
+	c.JSON(http.StatusCreated, gin.H{"data": ticket})
+}
+
+func (h *Handler) getTickets(c *gin.Context) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	var filter store.TicketFilter
+
+	if isSocietyStaff(user) {
+		filter.SocietyID, err = societyIDParam(c, user)
+		if err != nil {
+			h.respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		if c.Query("assigned_to") == "me" {
+			filter.AssignedTo = &user.ID
+		}
+		filter.OnlyBreached = c.Query("breached") == "true"
+	} else {
+		filter.RaisedBy = &user.ID
+		filter.ResidenceID = user.ResidenceID
+	}
+
+	if status := c.Query("status"); status != "" {
+		s := model.TicketStatus(status)
+		if !s.Valid() {
+			h.respondError(c, http.StatusBadRequest, fmt.Errorf("invalid status %q", status))
+			return
+		}
+		filter.Status = &s
+	}
+
+	if category := c.Query("category"); category != "" {
+		cat := model.TicketCategory(category)
+		if !cat.Valid() {
+			h.respondError(c, http.StatusBadRequest, fmt.Errorf("invalid category %q", category))
+			return
+		}
+		filter.Category = &cat
+	}
+
+	filter.OnlyOpen = c.Query("open") == "true"
+	filter.Limit, _ = strconv.Atoi(c.Query("limit"))
+	filter.Offset, _ = strconv.Atoi(c.Query("offset"))
+
+	tickets, err := h.db.GetTickets(c.Request.Context(), filter)
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": tickets})
+}
+
+// getTicket returns a ticket with its conversation, status history and the
+// maintenance visits made for it. Internal notes are shown to staff only.
+func (h *Handler) getTicket(c *gin.Context) {
+	ticket, staff, ok := h.ticketInScope(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	comments, err := h.db.GetTicketComments(ctx, ticket.ID, staff)
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	events, err := h.db.GetTicketEvents(ctx, ticket.ID)
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	visits, err := h.db.GetTicketVisits(ctx, ticket.ID)
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"ticket":   ticket,
+		"comments": comments,
+		"events":   events,
+		"visits":   visits,
+	}})
+}
+
+type AddTicketCommentRequest struct {
+	Body       string `json:"body" binding:"required,max=5000"`
+	IsInternal bool   `json:"is_internal"`
+}
+
+func (h *Handler) addTicketComment(c *gin.Context) {
+	ticket, staff, ok := h.ticketInScope(c)
+	if !ok {
+		return
+	}
+
+	var req AddTicketCommentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if req.IsInternal && !staff {
+		h.respondError(c, http.StatusForbidden, ErrPermissionDenied)
+		return
+	}
+
+	if ticket.Status == model.TicketClosed {
+		h.respondError(c, http.StatusConflict, store.ErrTicketClosed)
+		return
+	}
+
+	comment, err := h.db.AddTicketComment(c.Request.Context(), ticket.ID, c.GetString("user_id"), req.Body, req.IsInternal, staff)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, store.ErrNotFound) {
+			status = http.StatusNotFound
+		}
+		h.respondError(c, status, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": comment})
+}
+
+type AddTicketPhotoRequest struct {
+	URL string `json:"url" binding:"required,url"`
+}
+
+func (h *Handler) addTicketPhoto(c *gin.Context) {
+	ticket, _, ok := h.ticketInScope(c)
+	if !ok {
+		return
+	}
+
+	var req AddTicketPhotoRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if ticket.Status == model.TicketClosed {
+		h.respondError(c, http.StatusConflict, store.ErrTicketClosed)
+		return
+	}
+
+	photo, err := h.db.AddTicketPhoto(c.Request.Context(), ticket.ID, req.URL, c.GetString("user_id"))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, store.ErrNotFound) {
+			status = http.StatusNotFound
+		}
+		h.respondError(c, status, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": photo})
+}
+
+type AssignTicketRequest struct {
+	AssigneeID string  `json:"assignee_id" binding:"required,uuid"`
+	Note       *string `json:"note" binding:"omitempty,max=1000"`
+}
+
+func (h *Handler) assignTicket(c *gin.Context) {
+	ticket, _, ok := h.ticketInScope(c)
+	if !ok {
+		return
+	}
+
+	var req AssignTicketRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	assignee, err := h.db.GetUser(c.Request.Context(), req.AssigneeID)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		h.respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	if err != nil || !assignee.IsActive || assignee.SocietyID == nil || *assignee.SocietyID != ticket.SocietyID ||
+		(assignee.Role != string(model.RoleSocietyManager) && assignee.Role != string(model.RoleSecurity)) {
+		h.respondError(c, http.StatusBadRequest, ErrInvalidAssignee)
+		return
+	}
+
+	updated, err := h.db.TransitionTicket(c.Request.Context(), store.TransitionTicketParams{
+		ID:         ticket.ID,
+		To:         model.TicketAssigned,
+		Actor:      c.GetString("user_id"),
+		ByStaff:    true,
+		AssignedTo: &req.AssigneeID,
+		Note:       req.Note,
+	})
+	if err != nil {
+		h.respondError(c, errorStatus(err), err)
+		return
+	}
+
+	h.notifyTicket(c.Request.Context(), updated, []string{req.AssigneeID},
+		"TICKET_ASSIGNED", fmt.Sprintf("Ticket #%d assigned to you", updated.ID), updated.Title)
+	h.notifyTicketStatus(c.Request.Context(), updated)
+
+	c.JSON(http.StatusOK, gin.H{"data": updated})
+}
+
+type UpdateTicketStatusRequest struct {
+	Status model.TicketStatus `json:"status" binding:"required"`
+	Note   *string            `json:"note" binding:"omitempty,max=1000"`
+}
+
+// updateTicketStatus moves a ticket along the workflow on behalf of staff.
+// Managers may act on any ticket in the society, security only on tickets
+// assigned to them.
+func (h *Handler) updateTicketStatus(c *gin.Context) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	ticket, _, ok := h.ticketInScope(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateTicketStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	// Assignment carries an assignee and has its own endpoint.
+	if !req.Status.Valid() || req.Status == model.TicketAssigned || req.Status == model.TicketOpen {
+		h.respondError(c, http.StatusBadRequest, fmt.Errorf("invalid status %q", req.Status))
+		return
+	}
+
+	if user.Role == model.RoleSecurity && (ticket.AssignedTo == nil || ticket.AssignedTo.String() != user.ID) {
+		h.respondError(c, http.StatusForbidden, ErrPermissionDenied)
+		return
+	}
+
+	updated, err := h.db.TransitionTicket(c.Request.Context(), store.TransitionTicketParams{
+		ID:      ticket.ID,
+		To:      req.Status,
+		Actor:   user.ID,
+		ByStaff: true,
+		Note:    req.Note,
+	})
+	if err != nil {
+		h.respondError(c, errorStatus(err), err)
+		return
+	}
+
+	h.notifyTicketStatus(c.Request.Context(), updated)
+
+	c.JSON(http.StatusOK, gin.H{"data": updated})
+}
+
+type CloseTicketRequest struct {
+	Rating        *int    `json:"rating" binding:"omitempty,min=1,max=5"`
+	RatingComment *string `json:"rating_comment" binding:"omitempty,max=1000"`
+}
+
+// closeTicket is the resident confirming a resolved ticket, with an
+// optional satisfaction rating, or withdrawing one nobody has picked up.
+func (h *Handler) closeTicket(c *gin.Context) {
+	ticket, _, ok := h.ticketInScope(c)
+	if !ok {
+		return
+	}
+
+	var req CloseTicketRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if req.Rating != nil && ticket.Status != model.TicketResolved {
+		h.respondError(c, http.StatusConflict, errors.New("only resolved tickets can be rated"))
+		return
+	}
+
+	updated, err := h.db.TransitionTicket(c.Request.Context(), store.TransitionTicketParams{
+		ID:            ticket.ID,
+		To:            model.TicketClosed,
+		Actor:         c.GetString("user_id"),
+		Rating:        req.Rating,
+		RatingComment: req.RatingComment,
+	})
+	if err != nil {
+		h.respondError(c, errorStatus(err), err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": updated})
+}
+
+type ReopenTicketRequest struct {
+	Note string `json:"note" binding:"required,max=1000"`
+}
+
+func (h *Handler) reopenTicket(c *gin.Context) {
+	ticket, _, ok := h.ticketInScope(c)
+	if !ok {
+		return
+	}
+
+	var req ReopenTicketRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if ticket.Status != model.TicketResolved {
+		h.respondError(c, http.StatusConflict, fmt.Errorf("%w: only resolved tickets can be reopened", store.ErrInvalidTransition))
+		return
+	}
+
+	updated, err := h.db.TransitionTicket(c.Request.Context(), store.TransitionTicketParams{
+		ID:    ticket.ID,
+		To:    model.TicketInProgress,
+		Actor: c.GetString("user_id"),
+		Note:  &req.Note,
+	})
+	if err != nil {
+		h.respondError(c, errorStatus(err), err)
+		return
+	}
+
+	h.notifyTicketManagers(c.Request.Context(), updated)
+
+	c.JSON(http.StatusOK, gin.H{"data": updated})
+}
+
+func (h *Handler) getTicketSLAPolicies(c *gin.Context) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	societyID, err := societyIDParam(c, user)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	policies, err := h.db.GetTicketSLAPolicies(c.Request.Context(), *societyID)
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": policies})
+}
+
+type UpdateTicketSLAPolicyRequest struct {
+	ResponseMinutes   int `json:"response_minutes" binding:"required,min=1"`
+	ResolutionMinutes int `json:"resolution_minutes" binding:"required,min=1"`
+}
+
+// updateTicketSLAPolicy overrides the SLA for one priority. It applies to
+// tickets raised from now on.
+func (h *Handler) updateTicketSLAPolicy(c *gin.Context) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	var req UpdateTicketSLAPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if req.ResolutionMinutes < req.ResponseMinutes {
+		h.respondError(c, http.StatusBadRequest, errors.New("resolution_minutes must not be less than response_minutes"))
+		return
+	}
+
+	societyID, err := societyIDParam(c, user)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	priority := model.TicketPriority(c.Param("priority"))
+	if !priority.Valid() {
+		h.respondError(c, http.StatusBadRequest, fmt.Errorf("invalid priority %q", priority))
+		return
+	}
+
+	policy, err := h.db.UpsertTicketSLAPolicy(c.Request.Context(), *societyID, priority, req.ResponseMinutes, req.ResolutionMinutes)
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": policy})
+}
+
+// ticketInScope loads the ticket named by :id. Staff see every ticket of
+// their society; residents their residence's tickets and the ones they
+// raised. It reports whether the caller is staff and writes the error
+// response itself.
+func (h *Handler) ticketInScope(c *gin.Context) (*model.Ticket, bool, bool) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return nil, false, false
+	}
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, fmt.Errorf("invalid ticket id: %w", err))
+		return nil, false, false
+	}
+
+	ticket, err := h.db.GetTicket(c.Request.Context(), id)
+	if err != nil {
+		h.respondError(c, errorStatus(err), err)
+		return nil, false, false
+	}
+
+	switch {
+	case user.Role == model.RoleAdmin:
+		return ticket, true, true
+	case isSocietyStaff(user):
+		if user.SocietyID != nil && *user.SocietyID == ticket.SocietyID {
+			return ticket, true, true
+		}
+	case ticket.RaisedBy.String() == user.ID,
+		ticket.ResidenceID != nil && user.ResidenceID != nil && *ticket.ResidenceID == *user.ResidenceID:
+		return ticket, false, true
+	}
+
+	h.respondError(c, http.StatusForbidden, ErrUnauthorizedRole)
+	return nil, false, false
+}
+
+// ticketForVisit checks a maintenance visit may be linked to a ticket: it
+// must be an unresolved ticket of the guard's society.
+func (h *Handler) ticketForVisit(ctx context.Context, ticketID, societyID int64) (*model.Ticket, error) {
+	ticket, err := h.db.GetTicket(ctx, ticketID)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, ErrTicketNotForVisit
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if ticket.SocietyID != societyID || ticket.Status == model.TicketResolved || ticket.Status == model.TicketClosed {
+		return nil, ErrTicketNotForVisit
+	}
+
+	return ticket, nil
+}
+
+func (h *Handler) notifyTicketManagers(ctx context.Context, ticket *model.Ticket) {
+	recipients, err := h.db.GetSocietyUserIDsByRole(ctx, ticket.SocietyID, model.RoleSocietyManager)
+	if err != nil {
+		h.log.ErrorContext(ctx, "loading ticket recipients", "ticket_id", ticket.ID, "error", err)
+		return
+	}
+
+	title := fmt.Sprintf("New %s ticket #%d", ticket.Category, ticket.ID)
+	if ticket.Status != model.TicketOpen {
+		title = fmt.Sprintf("Ticket #%d reopened", ticket.ID)
+	}
+
+	h.notifyTicket(ctx, ticket, recipients, "TICKET_RAISED", title, ticket.Title)
+}
+
+func (h *Handler) notifyTicketStatus(ctx context.Context, ticket *model.Ticket) {
+	h.notifyTicket(ctx, ticket, []string{ticket.RaisedBy.String()}, "TICKET_STATUS",
+		fmt.Sprintf("Ticket #%d is now %s", ticket.ID, ticket.Status), ticket.Title)
+}
+
+func (h *Handler) notifyTicket(ctx context.Context, ticket *model.Ticket, recipients []string, kind, title, body string) {
+	priority := notify.PriorityNormal
+	if ticket.Priority == model.TicketUrgent {
+		priority = notify.PriorityHigh
+	}
+
+	err := h.notifier.Send(ctx, notify.Message{
+		UserIDs:  recipients,
+		Kind:     kind,
+		Title:    title,
+		Body:     body,
+		Priority: priority,
+		Data: map[string]string{
+			"ticket_id": strconv.FormatInt(ticket.ID, 10),
+			"status":    string(ticket.Status),
+		},
+	})
+	if err != nil {
+		h.log.ErrorContext(ctx, "sending ticket notification", "ticket_id", ticket.ID, "kind", kind, "error", err)
+	}
+}
@@ -4,11 +4,16 @@ import (
 	"dooreye-backend/internal/model"
 	"dooreye-backend/internal/store"
 	"errors"
+	"fmt"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
 
+// ErrOutsideSociety is returned when a society manager names a society or
+// residence other than their own.
+var ErrOutsideSociety = errors.New("residence or society is outside your society")
+
 type CreateUserRequest struct {
 	AccessCode  string         `json:"access_code" binding:"required"`
 	DeviceID    string         `json:"device_id" binding:"required"`
@@ -18,13 +23,38 @@ type CreateUserRequest struct {
 	Role        model.UserRole `json:"role" binding:"required"`
 }
 
+// createUser is for admins and society managers. Managers are confined to
+// their own society, and only admins may create other admins.
 func (h *Handler) createUser(c *gin.Context) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return
+	}
+
 	var req CreateUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		h.respondError(c, http.StatusBadRequest, err)
 		return
 	}
 
+	if !req.Role.Valid() {
+		h.respondError(c, http.StatusBadRequest, fmt.Errorf("invalid role %q", req.Role))
+		return
+	}
+	if req.Role == model.RoleAdmin && user.Role != model.RoleAdmin {
+		h.respondError(c, http.StatusForbidden, ErrUnauthorizedRole)
+		return
+	}
+	if user.Role != model.RoleAdmin {
+		if err := h.scopeToSociety(c, user, &req); err != nil {
+			// The error table maps the scope errors; anything else is a
+			// failed lookup.
+			h.respondError(c, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
 	params := store.CreateUserParams{
 		AccessCode:  req.AccessCode,
 		DeviceID:    req.DeviceID,
@@ -35,18 +65,45 @@ func (h *Handler) createUser(c *gin.Context) {
 		ActivatedBy: c.GetString("user_id"),
 	}
 
-	user, err := h.db.CreateUser(c.Request.Context(), params)
+	created, err := h.db.CreateUser(c.Request.Context(), params)
 	if err != nil {
 		status := http.StatusInternalServerError
 		switch {
 		case errors.Is(err, store.ErrInvalidUserType):
 			status = http.StatusBadRequest
-		case errors.Is(err, store.ErrDuplicateAccessCode):
+		case errors.Is(err, store.ErrDuplicateAccessCode), errors.Is(err, store.ErrDeviceTaken):
 			status = http.StatusConflict
 		}
 		h.respondError(c, status, err)
 		return
 	}
 
-	c.JSON(http.StatusCreated, user)
+	c.JSON(http.StatusCreated, created)
+}
+
+// scopeToSociety checks that the society and residence of a new user are in
+// the caller's society, and defaults the society to it when neither is set.
+func (h *Handler) scopeToSociety(c *gin.Context, user *AuthUser, req *CreateUserRequest) error {
+	if user.SocietyID == nil {
+		return ErrSocietyRequired
+	}
+	own := *user.SocietyID
+
+	if req.SocietyID != nil && *req.SocietyID != own {
+		return ErrOutsideSociety
+	}
+
+	if req.ResidenceID != nil {
+		societyID, err := h.db.GetResidenceSocietyID(c.Request.Context(), *req.ResidenceID)
+		if errors.Is(err, store.ErrNotFound) || (err == nil && societyID != own) {
+			return ErrOutsideSociety
+		}
+		if err != nil {
+			return err
+		}
+	} else if req.SocietyID == nil {
+		req.SocietyID = &own
+	}
+
+	return nil
 }
@@ -0,0 +1,462 @@
+package api
+
+import (
+	"dooreye-backend/internal/model"
+	"dooreye-backend/internal/notify"
+	"dooreye-backend/internal/plate"
+	"dooreye-backend/internal/store"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+)
+
+type RegisterVehicleRequest struct {
+	Plate         string            `json:"plate" binding:"required"`
+	Type          model.VehicleType `json:"type" binding:"required"`
+	MakeModel     *string           `json:"make_model" binding:"omitempty,max=100"`
+	Color         *string           `json:"color" binding:"omitempty,max=30"`
+	ParkingSlotID *int64            `json:"parking_slot_id"`
+	// ResidenceID is only honoured for society staff; residents always
+	// register against their active residence.
+	ResidenceID *int64 `json:"residence_id"`
+}
+
+func (h *Handler) registerVehicle(c *gin.Context) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	var req RegisterVehicleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if !req.Type.Valid() {
+		h.respondError(c, http.StatusBadRequest, fmt.Errorf("invalid vehicle type %q", req.Type))
+		return
+	}
+
+	normalized, err := plate.Normalize(req.Plate)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	residenceID, ok := h.residenceInScope(c, user, req.ResidenceID)
+	if !ok {
+		return
+	}
+
+	if req.ParkingSlotID != nil {
+		slot, err := h.db.GetParkingSlot(c.Request.Context(), *req.ParkingSlotID)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			h.respondError(c, http.StatusInternalServerError, err)
+			return
+		}
+		if err != nil || slot.ResidenceID == nil || *slot.ResidenceID != residenceID {
+			h.respondError(c, http.StatusBadRequest, ErrSlotNotAssigned)
+			return
+		}
+	}
+
+	vehicle, err := h.db.CreateVehicle(c.Request.Context(), store.CreateVehicleParams{
+		ResidenceID:   residenceID,
+		Plate:         normalized,
+		Type:          req.Type,
+		MakeModel:     req.MakeModel,
+		Color:         req.Color,
+		ParkingSlotID: req.ParkingSlotID,
+		CreatedBy:     user.ID,
+	})
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, store.ErrPlateRegistered) {
+			status = http.StatusConflict
+		}
+		h.respondError(c, status, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": vehicle})
+}
+
+func (h *Handler) getVehicles(c *gin.Context) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	var filter store.VehicleFilter
+
+	switch user.Role {
+	case model.RoleOwner, model.RoleResident:
+		if user.ResidenceID == nil {
+			h.respondError(c, http.StatusForbidden, ErrUnauthorizedRole)
+			return
+		}
+		filter.ResidenceID = user.ResidenceID
+	default:
+		filter.SocietyID, err = societyIDParam(c, user)
+		if err != nil {
+			h.respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		if raw := c.Query("residence_id"); raw != "" {
+			id, err := strconv.ParseInt(raw, 10, 64)
+			if err != nil {
+				h.respondError(c, http.StatusBadRequest, fmt.Errorf("invalid residence_id: %w", err))
+				return
+			}
+			filter.ResidenceID = &id
+		}
+	}
+
+	vehicles, err := h.db.GetVehicles(c.Request.Context(), filter)
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": vehicles})
+}
+
+func (h *Handler) deleteVehicle(c *gin.Context) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, fmt.Errorf("invalid vehicle id: %w", err))
+		return
+	}
+
+	vehicle, err := h.db.GetVehicle(c.Request.Context(), id)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, store.ErrNotFound) {
+			status = http.StatusNotFound
+		}
+		h.respondError(c, status, err)
+		return
+	}
+
+	if _, ok := h.residenceInScope(c, user, &vehicle.ResidenceID); !ok {
+		return
+	}
+
+	if err := h.db.DeactivateVehicle(c.Request.Context(), id); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, store.ErrNotFound) {
+			status = http.StatusNotFound
+		}
+		h.respondError(c, status, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// lookupVehicle answers the guard's question at the barrier: whose vehicle
+// is this, and is it already inside?
+func (h *Handler) lookupVehicle(c *gin.Context) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	societyID, err := societyIDParam(c, user)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	normalized, err := plate.Normalize(c.Query("plate"))
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	vehicle, err := h.db.GetVehicleByPlate(c.Request.Context(), *societyID, normalized)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		h.respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	entries, err := h.db.GetVehicleEntries(c.Request.Context(), store.VehicleEntryFilter{
+		SocietyID: *societyID,
+		Plate:     normalized,
+		Limit:     10,
+	})
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"plate":          normalized,
+		"display":        plate.Format(normalized),
+		"registered":     vehicle != nil,
+		"vehicle":        vehicle,
+		"recent_entries": entries,
+	}})
+}
+
+type VehicleEntryRequest struct {
+	Plate   string            `json:"plate" binding:"required"`
+	Type    model.VehicleType `json:"type" binding:"required"`
+	GateID  *int64            `json:"gate_id"`
+	VisitID *uuid.UUID        `json:"visit_id"`
+}
+
+func (h *Handler) logVehicleEntry(c *gin.Context) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	var req VehicleEntryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if !req.Type.Valid() {
+		h.respondError(c, http.StatusBadRequest, fmt.Errorf("invalid vehicle type %q", req.Type))
+		return
+	}
+
+	normalized, err := plate.Normalize(req.Plate)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	societyID, err := societyIDParam(c, user)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	params := store.LogVehicleEntryParams{
+		SocietyID: *societyID,
+		Plate:     normalized,
+		Type:      req.Type,
+		GateID:    req.GateID,
+		LoggedBy:  user.ID,
+	}
+
+	vehicle, err := h.db.GetVehicleByPlate(c.Request.Context(), *societyID, normalized)
+	switch {
+	case err == nil:
+		params.Category = model.VehicleResident
+		params.VehicleID = &vehicle.ID
+	case !errors.Is(err, store.ErrNotFound):
+		h.respondError(c, http.StatusInternalServerError, err)
+		return
+	case req.VisitID != nil:
+		params.Category = model.VehicleGuest
+		visitID := req.VisitID.String()
+		params.VisitID = &visitID
+	default:
+		params.Category = model.VehicleUnknown
+	}
+
+	entry, err := h.db.LogVehicleEntry(c.Request.Context(), params)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, store.ErrVehicleInside) {
+			status = http.StatusConflict
+		}
+		h.respondError(c, status, err)
+		return
+	}
+
+	if entry.Category == model.VehicleUnknown {
+		h.alertUnknownVehicle(c, entry)
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": entry})
+}
+
+type VehicleExitRequest struct {
+	Plate  string `json:"plate" binding:"required"`
+	GateID *int64 `json:"gate_id"`
+}
+
+func (h *Handler) logVehicleExit(c *gin.Context) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	var req VehicleExitRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	normalized, err := plate.Normalize(req.Plate)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	societyID, err := societyIDParam(c, user)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	entry, err := h.db.LogVehicleExit(c.Request.Context(), *societyID, normalized, req.GateID, user.ID)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, store.ErrVehicleNotInside) {
+			status = http.StatusNotFound
+		}
+		h.respondError(c, status, err)
+		return
+	}
+
+	if entry.VisitID != nil {
+		if err := h.db.CompleteGuestParking(c.Request.Context(), entry.VisitID.String()); err != nil {
+			h.log.ErrorContext(c.Request.Context(), "freeing guest parking slot", "visit_id", entry.VisitID, "error", err)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": entry})
+}
+
+func (h *Handler) getVehicleEntries(c *gin.Context) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	societyID, err := societyIDParam(c, user)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	filter := store.VehicleEntryFilter{
+		SocietyID:  *societyID,
+		OnlyInside: c.Query("inside") == "true",
+	}
+
+	if raw := c.Query("plate"); raw != "" {
+		filter.Plate, err = plate.Normalize(raw)
+		if err != nil {
+			h.respondError(c, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	entries, err := h.db.GetVehicleEntries(c.Request.Context(), filter)
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": entries})
+}
+
+func (h *Handler) getParkingOccupancy(c *gin.Context) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	societyID, err := societyIDParam(c, user)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	occupancy, err := h.db.GetParkingOccupancy(c.Request.Context(), *societyID)
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": occupancy})
+}
+
+// alertUnknownVehicle tells guards and managers about a vehicle that is
+// neither registered to a resident nor tied to a visit. Failures are logged
+// only; the entry itself is already recorded.
+func (h *Handler) alertUnknownVehicle(c *gin.Context, entry *model.VehicleEntry) {
+	ctx := c.Request.Context()
+
+	recipients, err := h.db.GetSocietyUserIDsByRole(ctx, entry.SocietyID,
+		model.RoleSecurity, model.RoleSocietyManager)
+	if err != nil {
+		h.log.ErrorContext(ctx, "loading unknown vehicle recipients", "error", err)
+		return
+	}
+
+	err = h.notifier.Send(ctx, notify.Message{
+		UserIDs:  recipients,
+		Kind:     "UNKNOWN_VEHICLE",
+		Title:    "Unregistered vehicle entered",
+		Body:     fmt.Sprintf("%s %s is not registered to any residence", entry.Type, plate.Format(entry.Plate)),
+		Priority: notify.PriorityHigh,
+		Data: map[string]string{
+			"vehicle_entry_id": strconv.FormatInt(entry.ID, 10),
+			"plate":            entry.Plate,
+		},
+	})
+	if err != nil {
+		h.log.ErrorContext(ctx, "sending unknown vehicle alert", "error", err)
+	}
+}
+
+// residenceInScope checks that the caller may act on a residence. Residents
+// are confined to their active residence; society staff to residences of
+// their society. It writes the error response itself.
+func (h *Handler) residenceInScope(c *gin.Context, user *AuthUser, requested *int64) (int64, bool) {
+	switch user.Role {
+	case model.RoleOwner, model.RoleResident:
+		if user.ResidenceID == nil || (requested != nil && *requested != *user.ResidenceID) {
+			h.respondError(c, http.StatusForbidden, ErrUnauthorizedRole)
+			return 0, false
+		}
+		return *user.ResidenceID, true
+	}
+
+	if requested == nil {
+		h.respondError(c, http.StatusBadRequest, errors.New("residence_id is required"))
+		return 0, false
+	}
+
+	if user.Role == model.RoleAdmin {
+		return *requested, true
+	}
+
+	societyID, err := h.db.GetResidenceSocietyID(c.Request.Context(), *requested)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		h.respondError(c, http.StatusInternalServerError, err)
+		return 0, false
+	}
+	if err != nil || user.SocietyID == nil || societyID != *user.SocietyID {
+		h.respondError(c, http.StatusForbidden, ErrUnauthorizedRole)
+		return 0, false
+	}
+
+	return *requested, true
+}
@@ -1,8 +1,11 @@
 package api
 
 import (
+	"dooreye-backend/internal/metrics"
 	"dooreye-backend/internal/model"
+	"dooreye-backend/internal/plate"
 	"dooreye-backend/internal/store"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -11,6 +14,12 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+var (
+	ErrPhoneRequired     = errors.New("phone is required")
+	ErrGateNotFound      = errors.New("gate not found in this society")
+	ErrResidenceNotFound = errors.New("residence not found in this society")
+)
+
 type CreateVisitRequest struct {
 	Phone       string            `json:"phone" binding:"required"`
 	Name        string            `json:"name" binding:"required"`
@@ -18,6 +27,14 @@ type CreateVisitRequest struct {
 	Type        model.VisitorType `json:"type" binding:"required"`
 	Purpose     string            `json:"purpose"`
 	ResidenceID *int64            `json:"residence_id"`
+	GateID      *int64            `json:"gate_id"`
+
+	// Ticket a maintenance visitor is attending.
+	TicketID *int64 `json:"ticket_id"`
+
+	// Optional guest vehicle, logged as entering with the visitor.
+	VehiclePlate *string           `json:"vehicle_plate"`
+	VehicleType  model.VehicleType `json:"vehicle_type"`
 }
 
 func (h *Handler) createVisitAsSecurity(c *gin.Context) {
@@ -29,6 +46,66 @@ func (h *Handler) createVisitAsSecurity(c *gin.Context) {
 
 	userID := c.GetString("user_id")
 
+	var vehiclePlate string
+	if req.VehiclePlate != nil {
+		var err error
+		vehiclePlate, err = plate.Normalize(*req.VehiclePlate)
+		if err != nil {
+			h.respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		if req.VehicleType == "" {
+			req.VehicleType = model.VehicleCar
+		}
+		if !req.VehicleType.Valid() {
+			h.respondError(c, http.StatusBadRequest, fmt.Errorf("invalid vehicle type %q", req.VehicleType))
+			return
+		}
+	}
+
+	if req.GateID != nil || req.ResidenceID != nil {
+		societyID, ok := c.Get(string(SocietyIDKey))
+		if !ok {
+			h.respondError(c, http.StatusBadRequest, ErrSocietyRequired)
+			return
+		}
+		if !h.visitInSociety(c, req, societyID.(int64)) {
+			return
+		}
+	}
+
+	if req.TicketID != nil {
+		if req.Type != model.VisitorMaintenance {
+			h.respondError(c, http.StatusBadRequest, errors.New("only maintenance visits can be linked to a ticket"))
+			return
+		}
+
+		societyID, ok := c.Get(string(SocietyIDKey))
+		if !ok {
+			h.respondError(c, http.StatusBadRequest, ErrSocietyRequired)
+			return
+		}
+
+		ticket, err := h.ticketForVisit(c.Request.Context(), *req.TicketID, societyID.(int64))
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, ErrTicketNotForVisit) {
+				status = http.StatusBadRequest
+			}
+			h.respondError(c, status, err)
+			return
+		}
+
+		// The visit goes to the flat the ticket was raised for.
+		if ticket.ResidenceID != nil {
+			if req.ResidenceID != nil && *req.ResidenceID != *ticket.ResidenceID {
+				h.respondError(c, http.StatusBadRequest, errors.New("residence_id does not match the ticket"))
+				return
+			}
+			req.ResidenceID = ticket.ResidenceID
+		}
+	}
+
 	tx, err := h.db.BeginTx(c)
 	if err != nil {
 		h.respondError(c, http.StatusInternalServerError, err)
@@ -50,12 +127,12 @@ func (h *Handler) createVisitAsSecurity(c *gin.Context) {
 
 	var visit model.Visit
 	err = tx.QueryRow(c, `
-		INSERT INTO visits (residence_id, visitor_id, checked_in_by, check_in_time, purpose)
-		VALUES ($1, $2, $3, $4, $5)
-		RETURNING id, residence_id, visitor_id, checked_in_by, check_in_time, purpose
-	`, req.ResidenceID, visitorID, userID, time.Now(), req.Purpose).Scan(
+		INSERT INTO visits (residence_id, visitor_id, checked_in_by, check_in_time, purpose, gate_id, ticket_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, residence_id, visitor_id, checked_in_by, check_in_time, purpose, gate_id, ticket_id
+	`, req.ResidenceID, visitorID, userID, time.Now(), req.Purpose, req.GateID, req.TicketID).Scan(
 		&visit.ID, &visit.ResidenceID, &visit.VisitorID, &visit.CheckedInBy,
-		&visit.CheckInTime, &visit.Purpose,
+		&visit.CheckInTime, &visit.Purpose, &visit.GateID, &visit.TicketID,
 	)
 
 	if err != nil {
@@ -63,27 +140,74 @@ func (h *Handler) createVisitAsSecurity(c *gin.Context) {
 		return
 	}
 
+	if vehiclePlate != "" {
+		societyID, ok := c.Get(string(SocietyIDKey))
+		if !ok {
+			h.respondError(c, http.StatusBadRequest, ErrSocietyRequired)
+			return
+		}
+
+		visitID := visit.ID.String()
+		_, err = h.db.LogVehicleEntryTx(c, tx, store.LogVehicleEntryParams{
+			SocietyID: societyID.(int64),
+			Plate:     vehiclePlate,
+			Type:      req.VehicleType,
+			Category:  model.VehicleGuest,
+			VisitID:   &visitID,
+			GateID:    req.GateID,
+			LoggedBy:  userID,
+		})
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, store.ErrVehicleInside) {
+				status = http.StatusConflict
+			}
+			h.respondError(c, status, err)
+			return
+		}
+
+		visit.GuestParking, err = h.db.CheckInGuestParking(c, tx, store.GuestParkingCheckInParams{
+			SocietyID:   societyID.(int64),
+			Phone:       req.Phone,
+			VehicleType: req.VehicleType,
+			VisitID:     visitID,
+			Stay:        h.guestStay(c, societyID.(int64), req.Type),
+			LoggedBy:    userID,
+		})
+		if err != nil && !errors.Is(err, store.ErrNoGuestSlot) {
+			h.respondError(c, http.StatusInternalServerError, err)
+			return
+		}
+		// A full guest pool does not stop the visitor from coming in; the
+		// guard parks them wherever they can.
+		if err != nil {
+			h.log.WarnContext(c.Request.Context(), "no guest parking slot at check-in", "society_id", societyID, "visit_id", visitID)
+		}
+	}
+
 	if err := tx.Commit(c); err != nil {
 		h.respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
+	metrics.CheckIn(req.Type, 1)
+
 	c.JSON(http.StatusCreated, visit)
 }
 
 func (h *Handler) getVisitorByPhone(c *gin.Context) {
 	phone := c.Query("phone")
 	if phone == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "phone is required"})
+		h.respondError(c, http.StatusBadRequest, ErrPhoneRequired)
 		return
 	}
 
 	visitor, err := h.db.GetVisitorByPhone(c.Request.Context(), phone)
+	if errors.Is(err, store.ErrNotFound) {
+		h.respondError(c, http.StatusNotFound, withCode(http.StatusNotFound, "VISITOR_NOT_FOUND", err))
+		return
+	}
 	if err != nil {
-		if err == store.ErrNotFound {
-			h.respondError(c, http.StatusNotFound, err)
-			return
-		}
 		h.respondError(c, http.StatusInternalServerError, err)
 		return
 	}
@@ -100,6 +224,11 @@ type CreatePreApprovedVisitorRequest struct {
 }
 
 func (h *Handler) createPreApprovedVisitor(c *gin.Context) {
+	if !c.GetBool(string(CanCreatePassesKey)) {
+		h.respondError(c, http.StatusForbidden, ErrPermissionDenied)
+		return
+	}
+
 	var req CreatePreApprovedVisitorRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		h.respondError(c, http.StatusBadRequest, err)
@@ -126,6 +255,65 @@ func (h *Handler) createPreApprovedVisitor(c *gin.Context) {
 	c.JSON(http.StatusCreated, gin.H{"data": visitor})
 }
 
+// visitInSociety answers 404 and returns false unless the gate and
+// residence of a new visit, where given, belong to the guard's society.
+func (h *Handler) visitInSociety(c *gin.Context, req CreateVisitRequest, societyID int64) bool {
+	ctx := c.Request.Context()
+
+	if req.GateID != nil {
+		gate, err := h.db.GetGate(ctx, *req.GateID)
+		if err == nil && gate.SocietyID != societyID {
+			err = ErrGateNotFound
+		}
+		if err != nil {
+			h.respondError(c, errorStatus(err), err)
+			return false
+		}
+	}
+
+	if req.ResidenceID != nil {
+		residenceSociety, err := h.db.GetResidenceSocietyID(ctx, *req.ResidenceID)
+		if err == nil && residenceSociety != societyID {
+			err = ErrResidenceNotFound
+		}
+		if err != nil {
+			h.respondError(c, errorStatus(err), err)
+			return false
+		}
+	}
+
+	return true
+}
+
+// approveVisit lets a resident approve a visitor the gate logged for their
+// residence.
+func (h *Handler) approveVisit(c *gin.Context) {
+	user, err := GetAuthUser(c)
+	if err != nil {
+		h.respondError(c, http.StatusUnauthorized, err)
+		return
+	}
+	if !user.CanApproveVisitors {
+		h.respondError(c, http.StatusForbidden, ErrPermissionDenied)
+		return
+	}
+
+	residenceID, ok := h.residenceInScope(c, user, nil)
+	if !ok {
+		return
+	}
+
+	visit, err := h.db.ApproveVisit(c.Request.Context(), c.Param("id"), residenceID, user.ID)
+	if err != nil {
+		h.respondError(c, errorStatus(err), err)
+		return
+	}
+
+	metrics.Approved(visit.ApprovedAt.Sub(visit.CreatedAt))
+
+	c.JSON(http.StatusOK, gin.H{"data": visit})
+}
+
 func (h *Handler) getVisits(c *gin.Context) {
 	var filter store.VisitFilter
 
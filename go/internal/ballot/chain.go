@@ -0,0 +1,73 @@
+// Package ballot hash chains poll ballots so the recorded votes are tamper
+// evident: every ballot's hash covers its content and the previous hash,
+// and the poll keeps the head of the chain.
+package ballot
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"dooreye-backend/internal/model"
+	"fmt"
+	"strconv"
+)
+
+// Genesis is the previous hash of a poll's first ballot.
+var Genesis = model.Digest(make([]byte, sha256.Size))
+
+// Hash computes a ballot's hash from prev and the ballot's content. The
+// ballot's own PrevHash and Hash fields are ignored.
+func Hash(prev model.Digest, b model.PollBallot) model.Digest {
+	var residence, voter, cast string
+	if b.ResidenceID != nil {
+		residence = strconv.FormatInt(*b.ResidenceID, 10)
+	}
+	if b.VoterID != nil {
+		voter = b.VoterID.String()
+	}
+	if b.CastAt != nil {
+		cast = strconv.FormatInt(b.CastAt.UnixMicro(), 10)
+	}
+
+	h := sha256.New()
+	h.Write(prev)
+	fmt.Fprintf(h, "poll=%d|seq=%d|option=%d|residence=%s|voter=%s|cast=%s",
+		b.PollID, b.Seq, b.OptionID, residence, voter, cast)
+	return h.Sum(nil)
+}
+
+// VerifyError points at the first ballot that does not fit the chain.
+type VerifyError struct {
+	Seq    int
+	Reason string
+}
+
+func (e *VerifyError) Error() string {
+	if e.Seq == 0 {
+		return "ballot chain: " + e.Reason
+	}
+	return fmt.Sprintf("ballot chain broken at seq %d: %s", e.Seq, e.Reason)
+}
+
+// Verify recomputes the chain over ballots, ordered by seq, and checks it
+// ends at head.
+func Verify(ballots []model.PollBallot, head model.Digest) error {
+	prev := Genesis
+	for i, b := range ballots {
+		if b.Seq != i+1 {
+			return &VerifyError{Seq: b.Seq, Reason: fmt.Sprintf("expected seq %d", i+1)}
+		}
+		if !bytes.Equal(b.PrevHash, prev) {
+			return &VerifyError{Seq: b.Seq, Reason: "previous hash does not match"}
+		}
+		if !bytes.Equal(Hash(prev, b), b.Hash) {
+			return &VerifyError{Seq: b.Seq, Reason: "hash does not match contents"}
+		}
+		prev = b.Hash
+	}
+
+	if !bytes.Equal(prev, head) {
+		return &VerifyError{Reason: "chain does not end at the poll's head hash"}
+	}
+
+	return nil
+}
@@ -0,0 +1,161 @@
+package ballot
+
+import (
+	"dooreye-backend/internal/model"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+// chain builds a valid chain of n ballots and returns it with its head.
+func chain(n int) ([]model.PollBallot, model.Digest) {
+	castAt := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	var ballots []model.PollBallot
+	prev := Genesis
+	for i := 1; i <= n; i++ {
+		residence := int64(100 + i)
+		voter := uuid.Must(uuid.NewV4())
+		cast := castAt.Add(time.Duration(i) * time.Minute)
+
+		b := model.PollBallot{
+			PollID:      7,
+			Seq:         i,
+			OptionID:    int64(1 + i%2),
+			ResidenceID: &residence,
+			VoterID:     &voter,
+			CastAt:      &cast,
+			PrevHash:    prev,
+		}
+		b.Hash = Hash(prev, b)
+		ballots = append(ballots, b)
+		prev = b.Hash
+	}
+	return ballots, prev
+}
+
+// rehash recomputes each ballot's hash from its stored previous hash, as
+// someone rewriting ballots in place would.
+func rehash(ballots []model.PollBallot) {
+	for i := range ballots {
+		ballots[i].Hash = Hash(ballots[i].PrevHash, ballots[i])
+	}
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name   string
+		n      int
+		tamper func(ballots []model.PollBallot, head model.Digest) ([]model.PollBallot, model.Digest)
+		// seq and reason describe the expected VerifyError; reason is empty
+		// for a chain that verifies.
+		seq    int
+		reason string
+	}{
+		{name: "valid chain", n: 5},
+		{name: "empty chain", n: 0},
+		{
+			name: "tampered option",
+			n:    5,
+			tamper: func(b []model.PollBallot, head model.Digest) ([]model.PollBallot, model.Digest) {
+				b[2].OptionID = 99
+				return b, head
+			},
+			seq: 3, reason: "hash does not match contents",
+		},
+		{
+			name: "tampered cast time",
+			n:    5,
+			tamper: func(b []model.PollBallot, head model.Digest) ([]model.PollBallot, model.Digest) {
+				later := b[4].CastAt.Add(time.Hour)
+				b[4].CastAt = &later
+				return b, head
+			},
+			seq: 5, reason: "hash does not match contents",
+		},
+		{
+			name: "reordered ballots",
+			n:    5,
+			tamper: func(b []model.PollBallot, head model.Digest) ([]model.PollBallot, model.Digest) {
+				b[1], b[2] = b[2], b[1]
+				return b, head
+			},
+			seq: 3, reason: "expected seq 2",
+		},
+		{
+			name: "reordered and renumbered",
+			n:    5,
+			tamper: func(b []model.PollBallot, head model.Digest) ([]model.PollBallot, model.Digest) {
+				b[1], b[2] = b[2], b[1]
+				b[1].Seq, b[2].Seq = 2, 3
+				rehash(b)
+				return b, head
+			},
+			seq: 2, reason: "previous hash does not match",
+		},
+		{
+			name: "broken prev hash",
+			n:    5,
+			tamper: func(b []model.PollBallot, head model.Digest) ([]model.PollBallot, model.Digest) {
+				b[3].PrevHash = Genesis
+				rehash(b)
+				return b, head
+			},
+			seq: 4, reason: "previous hash does not match",
+		},
+		{
+			name: "dropped last ballot",
+			n:    5,
+			tamper: func(b []model.PollBallot, head model.Digest) ([]model.PollBallot, model.Digest) {
+				return b[:4], head
+			},
+			reason: "chain does not end at the poll's head hash",
+		},
+		{
+			name: "wrong head",
+			n:    3,
+			tamper: func(b []model.PollBallot, head model.Digest) ([]model.PollBallot, model.Digest) {
+				return b, Genesis
+			},
+			reason: "chain does not end at the poll's head hash",
+		},
+	}
+
+	for _, tt := range tests {
+		ballots, head := chain(tt.n)
+		if tt.tamper != nil {
+			ballots, head = tt.tamper(ballots, head)
+		}
+
+		err := Verify(ballots, head)
+		if tt.reason == "" {
+			if err != nil {
+				t.Errorf("%s: Verify = %v, want nil", tt.name, err)
+			}
+			continue
+		}
+
+		var verr *VerifyError
+		if !errors.As(err, &verr) {
+			t.Errorf("%s: Verify = %v, want a VerifyError", tt.name, err)
+			continue
+		}
+		if verr.Seq != tt.seq || verr.Reason != tt.reason {
+			t.Errorf("%s: Verify failed at seq %d (%s), want seq %d (%s)",
+				tt.name, verr.Seq, verr.Reason, tt.seq, tt.reason)
+		}
+	}
+}
+
+func TestHashIgnoresStoredHashes(t *testing.T) {
+	ballots, _ := chain(1)
+	b := ballots[0]
+
+	want := Hash(Genesis, b)
+	b.PrevHash, b.Hash = nil, nil
+	if got := Hash(Genesis, b); string(got) != string(want) {
+		t.Errorf("Hash changed with the ballot's PrevHash and Hash fields: %s, want %s", got, want)
+	}
+}
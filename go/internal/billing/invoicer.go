@@ -0,0 +1,182 @@
+package billing
+
+import (
+	"context"
+	"dooreye-backend/internal/model"
+	"dooreye-backend/internal/notify"
+	"dooreye-backend/internal/store"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+)
+
+type Invoicer struct {
+	db       *store.DB
+	notifier notify.Notifier
+	log      *slog.Logger
+}
+
+func NewInvoicer(db *store.DB, notifier notify.Notifier, log *slog.Logger) *Invoicer {
+	return &Invoicer{
+		db:       db,
+		notifier: notifier,
+		log:      log.With("component", "billing"),
+	}
+}
+
+// Generate is the periodic job that issues the current period's invoices in
+// every billed society. Residences already invoiced for the period are
+// skipped, so running it daily only bills each period once.
+func (inv *Invoicer) Generate(ctx context.Context, _ *model.Job) error {
+	societies, err := inv.db.GetBilledSocieties(ctx)
+	if err != nil {
+		return err
+	}
+
+	var failed int
+	for i := range societies {
+		settings := &societies[i]
+		today, err := Today(settings, time.Now())
+		if err == nil {
+			_, err = inv.GenerateForSociety(ctx, settings, today)
+		}
+		if err != nil {
+			failed++
+			inv.log.ErrorContext(ctx, "generating invoices", "society_id", settings.SocietyID, "error", err)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("invoice generation failed for %d of %d societies", failed, len(societies))
+	}
+	return nil
+}
+
+// GenerateForSociety issues invoices for the billing period containing day
+// to every residence the society's rules cover. It returns the invoices it
+// created. A residence whose charges cannot be worked out, such as one with
+// no area under a per-sqft rule, is logged and left out rather than billed
+// short.
+func (inv *Invoicer) GenerateForSociety(ctx context.Context, settings *model.BillingSettings, day time.Time) ([]model.Invoice, error) {
+	rules, err := inv.db.GetBillingRules(ctx, settings.SocietyID, false)
+	if err != nil {
+		return nil, err
+	}
+
+	residences, err := inv.db.GetBillableResidences(ctx, settings.SocietyID)
+	if err != nil {
+		return nil, err
+	}
+
+	start, end := Period(settings, day)
+	due := DueDate(settings, start)
+
+	created := []model.Invoice{}
+	for _, r := range residences {
+		lines, err := Lines(rules, r, settings.CycleMonths)
+		if err != nil {
+			inv.log.WarnContext(ctx, "skipping residence", "residence_id", r.ID, "error", err)
+			continue
+		}
+		if len(lines) == 0 {
+			continue
+		}
+
+		invoice, err := inv.db.CreateInvoice(ctx, store.CreateInvoiceParams{
+			SocietyID:   settings.SocietyID,
+			ResidenceID: r.ID,
+			PeriodStart: start,
+			PeriodEnd:   end,
+			DueDate:     due,
+			Lines:       lines,
+		})
+		if errors.Is(err, store.ErrAlreadyExists) {
+			continue
+		}
+		if err != nil {
+			return created, fmt.Errorf("invoicing residence %d: %w", r.ID, err)
+		}
+		created = append(created, *invoice)
+
+		if err := inv.notifyIssued(ctx, invoice); err != nil {
+			inv.log.ErrorContext(ctx, "notifying invoice", "invoice_id", invoice.ID, "error", err)
+		}
+	}
+
+	if len(created) > 0 {
+		inv.log.InfoContext(ctx, "invoices issued",
+			"society_id", settings.SocietyID,
+			"period_start", start.Format(time.DateOnly),
+			"count", len(created),
+		)
+	}
+
+	return created, nil
+}
+
+// ApplyLateFees is the periodic job that charges late fees on overdue
+// invoices and tells the residence. Residences charged before a failure
+// are still told, since their fees are committed.
+func (inv *Invoicer) ApplyLateFees(ctx context.Context, _ *model.Job) error {
+	charged, chargeErr := inv.db.ApplyLateFees(ctx)
+
+	for i := range charged {
+		invoice := &charged[i]
+		recipients, err := inv.db.GetResidenceUserIDs(ctx, invoice.ResidenceID)
+		if err == nil {
+			err = inv.notifier.Send(ctx, notify.Message{
+				UserIDs:  recipients,
+				Kind:     "INVOICE_LATE_FEE",
+				Title:    "Late fee charged",
+				Body:     fmt.Sprintf("A late fee was added to your maintenance for %s. Amount due: %s", invoice.PeriodStart.Format("Jan 2006"), Rupees(invoice.TotalPaise-invoice.PaidPaise)),
+				Priority: notify.PriorityNormal,
+				Data: map[string]string{
+					"invoice_id": strconv.FormatInt(invoice.ID, 10),
+				},
+			})
+		}
+		if err != nil {
+			inv.log.ErrorContext(ctx, "notifying late fee", "invoice_id", invoice.ID, "error", err)
+		}
+	}
+
+	if len(charged) > 0 {
+		inv.log.InfoContext(ctx, "late fees charged", "count", len(charged))
+	}
+	return chargeErr
+}
+
+func (inv *Invoicer) notifyIssued(ctx context.Context, invoice *model.Invoice) error {
+	recipients, err := inv.db.GetResidenceUserIDs(ctx, invoice.ResidenceID)
+	if err != nil {
+		return err
+	}
+
+	return inv.notifier.Send(ctx, notify.Message{
+		UserIDs:  recipients,
+		Kind:     "INVOICE_ISSUED",
+		Title:    "Maintenance invoice for " + invoice.PeriodStart.Format("Jan 2006"),
+		Body:     fmt.Sprintf("%s due by %s", Rupees(invoice.TotalPaise), invoice.DueDate.Format("2 Jan")),
+		Priority: notify.PriorityNormal,
+		Data: map[string]string{
+			"invoice_id": strconv.FormatInt(invoice.ID, 10),
+		},
+	})
+}
+
+// Rupees formats an amount in paise for display, e.g. "₹1,234.50".
+func Rupees(paise int64) string {
+	sign := ""
+	if paise < 0 {
+		sign, paise = "-", -paise
+	}
+
+	whole := strconv.FormatInt(paise/100, 10)
+	for i := len(whole) - 3; i > 0; i -= 3 {
+		whole = whole[:i] + "," + whole[i:]
+	}
+
+	return fmt.Sprintf("%s₹%s.%02d", sign, whole, paise%100)
+}
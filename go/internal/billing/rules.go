@@ -0,0 +1,122 @@
+// Package billing turns a society's maintenance rules into periodic invoices
+// and charges late fees on the ones left unpaid. Amounts are in paise and
+// billing periods are calendar months in the society's time zone.
+package billing
+
+import (
+	"dooreye-backend/internal/model"
+	"errors"
+	"fmt"
+	"time"
+
+	// Embedded so billing periods work on hosts without zoneinfo.
+	_ "time/tzdata"
+)
+
+var ErrAreaUnknown = errors.New("residence has no area for a per-sqft rule")
+
+// Applies reports whether a rule covers the residence.
+func Applies(rule model.BillingRule, r model.BillableResidence) bool {
+	return rule.IsActive && (rule.BlockID == nil || *rule.BlockID == r.BlockID)
+}
+
+// Charge is what one rule bills a residence for one month.
+func Charge(rule model.BillingRule, r model.BillableResidence) (int64, error) {
+	switch rule.Kind {
+	case model.RuleFlat:
+		return rule.RatePaise, nil
+	case model.RulePerSqft:
+		if r.AreaSqft == nil {
+			return 0, ErrAreaUnknown
+		}
+		return rule.RatePaise * int64(*r.AreaSqft), nil
+	case model.RulePerFloor:
+		return rule.RatePaise * int64(r.Floor), nil
+	}
+	return 0, fmt.Errorf("unknown billing rule kind %q", rule.Kind)
+}
+
+// Lines evaluates every rule that covers the residence over a period of the
+// given number of months. Rules that come to nothing, such as a per-floor
+// rule on the ground floor, produce no line.
+func Lines(rules []model.BillingRule, r model.BillableResidence, months int) ([]model.InvoiceLine, error) {
+	var lines []model.InvoiceLine
+	for _, rule := range rules {
+		if !Applies(rule, r) {
+			continue
+		}
+
+		monthly, err := Charge(rule, r)
+		if err != nil {
+			return nil, fmt.Errorf("rule %q: %w", rule.Name, err)
+		}
+		if monthly <= 0 {
+			continue
+		}
+
+		ruleID := rule.ID
+		lines = append(lines, model.InvoiceLine{
+			Kind:        model.EntryCharge,
+			RuleID:      &ruleID,
+			Description: describe(rule, r, months),
+			AmountPaise: monthly * int64(months),
+		})
+	}
+
+	return lines, nil
+}
+
+func describe(rule model.BillingRule, r model.BillableResidence, months int) string {
+	var basis string
+	switch rule.Kind {
+	case model.RulePerSqft:
+		basis = fmt.Sprintf(" (%d sqft)", *r.AreaSqft)
+	case model.RulePerFloor:
+		basis = fmt.Sprintf(" (floor %d)", r.Floor)
+	}
+
+	if months == 1 {
+		return rule.Name + basis
+	}
+	return fmt.Sprintf("%s%s × %d months", rule.Name, basis, months)
+}
+
+// Location resolves a society's time zone.
+func Location(settings *model.BillingSettings) (*time.Location, error) {
+	loc, err := time.LoadLocation(settings.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("loading time zone %q: %w", settings.Timezone, err)
+	}
+	return loc, nil
+}
+
+// Today returns the society's current calendar date as midnight UTC, the
+// form DATE columns are read in.
+func Today(settings *model.BillingSettings, now time.Time) (time.Time, error) {
+	loc, err := Location(settings)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	local := now.In(loc)
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC), nil
+}
+
+// Period returns the billing period [start, end) containing day. Periods
+// are aligned to January, so a quarterly cycle bills Jan-Mar, Apr-Jun and
+// so on.
+func Period(settings *model.BillingSettings, day time.Time) (time.Time, time.Time) {
+	months := settings.CycleMonths
+	if months <= 0 {
+		months = 1
+	}
+
+	first := (int(day.Month()) - 1) / months * months
+	start := time.Date(day.Year(), time.Month(first+1), 1, 0, 0, 0, 0, time.UTC)
+	return start, start.AddDate(0, months, 0)
+}
+
+// DueDate is when an invoice for the period starting at start falls due.
+func DueDate(settings *model.BillingSettings, start time.Time) time.Time {
+	return start.AddDate(0, 0, settings.DueDays)
+}
@@ -0,0 +1,118 @@
+package billing
+
+import (
+	"dooreye-backend/internal/model"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCharge(t *testing.T) {
+	area := 1200
+	residence := model.BillableResidence{ID: 1, BlockID: 10, Floor: 3, AreaSqft: &area}
+
+	tests := []struct {
+		name string
+		rule model.BillingRule
+		r    model.BillableResidence
+		want int64
+		err  error
+	}{
+		{"flat", model.BillingRule{Kind: model.RuleFlat, RatePaise: 250000}, residence, 250000, nil},
+		{"per sqft", model.BillingRule{Kind: model.RulePerSqft, RatePaise: 300}, residence, 360000, nil},
+		{"per sqft without area", model.BillingRule{Kind: model.RulePerSqft, RatePaise: 300},
+			model.BillableResidence{Floor: 3}, 0, ErrAreaUnknown},
+		{"per floor", model.BillingRule{Kind: model.RulePerFloor, RatePaise: 5000}, residence, 15000, nil},
+		{"per floor on ground floor", model.BillingRule{Kind: model.RulePerFloor, RatePaise: 5000},
+			model.BillableResidence{Floor: 0}, 0, nil},
+	}
+
+	for _, tt := range tests {
+		got, err := Charge(tt.rule, tt.r)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: Charge error = %v, want %v", tt.name, err, tt.err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: Charge = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+
+	if _, err := Charge(model.BillingRule{Kind: "HOURLY"}, residence); err == nil {
+		t.Error("Charge accepted an unknown rule kind")
+	}
+}
+
+func TestLines(t *testing.T) {
+	area := 1000
+	block, otherBlock := int64(10), int64(11)
+	residence := model.BillableResidence{ID: 1, BlockID: block, Floor: 2, AreaSqft: &area}
+
+	rules := []model.BillingRule{
+		{ID: 1, Name: "Maintenance", Kind: model.RulePerSqft, RatePaise: 250, IsActive: true},
+		{ID: 2, Name: "Sinking fund", Kind: model.RuleFlat, RatePaise: 50000, IsActive: true},
+		{ID: 3, Name: "Lift", Kind: model.RulePerFloor, RatePaise: 10000, BlockID: &block, IsActive: true},
+		{ID: 4, Name: "Other block", Kind: model.RuleFlat, RatePaise: 99900, BlockID: &otherBlock, IsActive: true},
+		{ID: 5, Name: "Retired", Kind: model.RuleFlat, RatePaise: 12300},
+	}
+	ids := []int64{1, 2, 3}
+
+	lines, err := Lines(rules, residence, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []model.InvoiceLine{
+		{Kind: model.EntryCharge, RuleID: &ids[0], Description: "Maintenance (1000 sqft) × 3 months", AmountPaise: 750000},
+		{Kind: model.EntryCharge, RuleID: &ids[1], Description: "Sinking fund × 3 months", AmountPaise: 150000},
+		{Kind: model.EntryCharge, RuleID: &ids[2], Description: "Lift (floor 2) × 3 months", AmountPaise: 60000},
+	}
+	if !reflect.DeepEqual(lines, want) {
+		t.Errorf("Lines =\n%+v\nwant\n%+v", lines, want)
+	}
+
+	ground := model.BillableResidence{ID: 2, BlockID: block, Floor: 0, AreaSqft: &area}
+	lines, err = Lines(rules[2:3], ground, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 0 {
+		t.Errorf("Lines on the ground floor = %+v, want none", lines)
+	}
+
+	if _, err := Lines(rules[:1], model.BillableResidence{BlockID: block}, 1); !errors.Is(err, ErrAreaUnknown) {
+		t.Errorf("Lines without area = %v, want ErrAreaUnknown", err)
+	}
+}
+
+func TestPeriod(t *testing.T) {
+	day := func(s string) time.Time {
+		t.Helper()
+		d, err := time.Parse(time.DateOnly, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+
+	tests := []struct {
+		cycle      int
+		day        string
+		start, end string
+	}{
+		{1, "2024-05-17", "2024-05-01", "2024-06-01"},
+		{0, "2024-12-31", "2024-12-01", "2025-01-01"},
+		{3, "2024-05-17", "2024-04-01", "2024-07-01"},
+		{3, "2024-01-01", "2024-01-01", "2024-04-01"},
+		{12, "2024-11-30", "2024-01-01", "2025-01-01"},
+	}
+
+	for _, tt := range tests {
+		start, end := Period(&model.BillingSettings{CycleMonths: tt.cycle}, day(tt.day))
+		if !start.Equal(day(tt.start)) || !end.Equal(day(tt.end)) {
+			t.Errorf("Period(%d months, %s) = [%s, %s), want [%s, %s)",
+				tt.cycle, tt.day, start.Format(time.DateOnly), end.Format(time.DateOnly), tt.start, tt.end)
+		}
+	}
+}
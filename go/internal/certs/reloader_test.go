@@ -0,0 +1,143 @@
+package certs
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"log/slog"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writePair writes a self-signed certificate with the given serial number
+// and its key, then dates both files at modTime.
+func writePair(t *testing.T, certFile, keyFile string, serial int64, modTime time.Time) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		DNSNames:     []string{"api.dooreye.example"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeFile(t, certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), modTime)
+	writeFile(t, keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), modTime)
+}
+
+func writeFile(t *testing.T, name string, data []byte, modTime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(name, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(name, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func serial(t *testing.T, r *Reloader) int64 {
+	t.Helper()
+	cert, err := r.GetCertificate(&tls.ClientHelloInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf.SerialNumber.Int64()
+}
+
+// waitForSerial polls until the reloader serves the certificate with the
+// given serial, failing after a second.
+func waitForSerial(t *testing.T, r *Reloader, want int64) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for serial(t, r) != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("serving serial %d, want %d", serial(t, r), want)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestReloaderWatch(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	issued := time.Now().Add(-time.Hour)
+	writePair(t, certFile, keyFile, 1, issued)
+
+	r, err := NewReloader(certFile, keyFile, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := serial(t, r); got != 1 {
+		t.Fatalf("serving serial %d, want 1", got)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Watch(ctx, 10*time.Millisecond)
+
+	// A renewal replaces both files.
+	writePair(t, certFile, keyFile, 2, issued.Add(time.Minute))
+	waitForSerial(t, r, 2)
+
+	// Halfway through the next renewal only the certificate is new, and
+	// it does not match the key, so the previous pair stays in use.
+	pending := filepath.Join(dir, "pending")
+	writePair(t, pending+".crt", pending+".key", 3, issued)
+	next, err := os.ReadFile(pending + ".crt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, certFile, next, issued.Add(2*time.Minute))
+	time.Sleep(50 * time.Millisecond)
+	if got := serial(t, r); got != 2 {
+		t.Fatalf("serving serial %d after a mismatched certificate, want 2", got)
+	}
+
+	// Once the key follows, the new pair loads.
+	key, err := os.ReadFile(pending + ".key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, keyFile, key, issued.Add(3*time.Minute))
+	waitForSerial(t, r, 3)
+}
+
+func TestNewReloaderRejectsBadPair(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	if _, err := NewReloader(certFile, keyFile, log); err == nil {
+		t.Error("NewReloader accepted missing files")
+	}
+
+	writePair(t, certFile, keyFile, 1, time.Now())
+	writeFile(t, keyFile, []byte("not a key"), time.Now())
+	if _, err := NewReloader(certFile, keyFile, log); err == nil {
+		t.Error("NewReloader accepted an unusable key")
+	}
+}
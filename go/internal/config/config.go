@@ -0,0 +1,417 @@
+// Package config loads the server's settings from the environment and an
+// optional dotenv file into typed fields, and checks them before startup.
+//
+// Every setting is declared once, on a struct field, with tags naming its
+// variable, its default and what it does:
+//
+//	env       variable name
+//	default   value used when the variable is unset
+//	dev       default used instead when GO_ENV is development
+//	required  startup fails if the value is empty
+//	secret    value is redacted when printed; "password" redacts only the
+//	          password of a URL
+//	desc      one line shown by Print
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/netip"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+const EnvDevelopment = "development"
+const EnvProduction = "production"
+
+// Log formats.
+const (
+	LogText = "text"
+	LogJSON = "json"
+)
+
+// Rate limit backends.
+const (
+	RateLimitMemory   = "memory"
+	RateLimitPostgres = "postgres"
+)
+
+type Config struct {
+	Env string `env:"GO_ENV" default:"development" desc:"deployment environment; picks the default config file and defaults"`
+
+	Server    Server
+	Database  Database
+	Log       Log
+	Tracing   Tracing
+	RateLimit RateLimit
+	CORS      CORS
+	TLS       TLS
+	Payments  Payments
+	Features  Features
+
+	// file is the config file that was read, if any.
+	file string
+	// sources records where each variable's value came from.
+	sources map[string]string
+	// errs holds values that could not be parsed, reported by Validate.
+	errs []error
+}
+
+type Server struct {
+	Port            int           `env:"PORT" default:"8080" desc:"port the API listens on"`
+	AdminAddr       string        `env:"ADMIN_ADDR" default:"localhost:9090" desc:"address of the metrics listener; keep it off the public network"`
+	ReadTimeout     time.Duration `env:"SERVER_READ_TIMEOUT" default:"10s" desc:"time allowed to read a request, body included"`
+	WriteTimeout    time.Duration `env:"SERVER_WRITE_TIMEOUT" default:"10s" desc:"time allowed to write a response"`
+	IdleTimeout     time.Duration `env:"SERVER_IDLE_TIMEOUT" default:"60s" desc:"how long keep-alive connections stay open between requests"`
+	DrainDelay      time.Duration `env:"DRAIN_DELAY" default:"0s" desc:"how long to keep serving, with /readyz failing, after a shutdown signal"`
+	ShutdownTimeout time.Duration `env:"SHUTDOWN_TIMEOUT" default:"15s" desc:"time allowed for in-flight requests and jobs after draining"`
+	MaxBodyBytes    int           `env:"MAX_BODY_BYTES" default:"1048576" desc:"largest request body accepted"`
+	HSTSMaxAge      time.Duration `env:"HSTS_MAX_AGE" default:"8760h" dev:"0s" desc:"how long browsers keep to HTTPS after a response; 0s sends no HSTS header"`
+	TrustedProxies  []string      `env:"TRUSTED_PROXIES" desc:"comma separated IPs or CIDRs of proxies whose X-Forwarded-For is believed; empty trusts none"`
+}
+
+// Addr is the listen address for the API.
+func (s Server) Addr() string {
+	return ":" + strconv.Itoa(s.Port)
+}
+
+type Database struct {
+	URL             string        `env:"DATABASE_URL" required:"true" secret:"password" desc:"Postgres connection URL"`
+	ConnectTimeout  time.Duration `env:"DATABASE_CONNECT_TIMEOUT" default:"10s" desc:"time allowed to connect at startup"`
+	MaxConns        int           `env:"DATABASE_MAX_CONNS" default:"25" desc:"largest number of pooled connections"`
+	MinConns        int           `env:"DATABASE_MIN_CONNS" default:"5" desc:"connections kept open when idle"`
+	MaxConnLifetime time.Duration `env:"DATABASE_MAX_CONN_LIFETIME" default:"5m" desc:"age at which a connection is replaced"`
+	MaxConnIdleTime time.Duration `env:"DATABASE_MAX_CONN_IDLE_TIME" default:"10m" desc:"idle time after which a connection is closed"`
+}
+
+type Log struct {
+	Level  string `env:"LOG_LEVEL" default:"info" dev:"debug" desc:"debug, info, warn or error"`
+	Format string `env:"LOG_FORMAT" default:"json" dev:"text" desc:"json or text"`
+}
+
+type Tracing struct {
+	Exporter string `env:"OTEL_TRACES_EXPORTER" default:"none" desc:"where spans go: none, stdout or otlp"`
+}
+
+type RateLimit struct {
+	Backend string `env:"RATE_LIMIT_BACKEND" default:"memory" desc:"memory limits each instance separately; postgres shares limits across instances"`
+}
+
+type CORS struct {
+	AllowedOrigins   []string      `env:"CORS_ALLOWED_ORIGINS" desc:"comma separated origins browsers may call the API from, or *"`
+	AllowedMethods   []string      `env:"CORS_ALLOWED_METHODS" default:"GET,POST,PUT,PATCH,DELETE" desc:"methods allowed from those origins"`
+	AllowCredentials bool          `env:"CORS_ALLOW_CREDENTIALS" default:"false" desc:"let browsers send cookies and HTTP auth; not allowed with *"`
+	MaxAge           time.Duration `env:"CORS_MAX_AGE" default:"10m" desc:"how long browsers may cache a preflight answer"`
+}
+
+// TLS is for deployments without a reverse proxy in front. Replaced
+// certificate files are picked up without a restart.
+type TLS struct {
+	CertFile       string        `env:"TLS_CERT_FILE" desc:"PEM certificate chain; serve HTTPS when set with TLS_KEY_FILE"`
+	KeyFile        string        `env:"TLS_KEY_FILE" desc:"PEM private key for TLS_CERT_FILE"`
+	ReloadInterval time.Duration `env:"TLS_RELOAD_INTERVAL" default:"1m" desc:"how often the certificate files are checked for changes"`
+}
+
+// Enabled reports whether the server should serve HTTPS itself.
+func (t TLS) Enabled() bool {
+	return t.CertFile != ""
+}
+
+type Payments struct {
+	FakeSecret string `env:"FAKE_PAYMENT_SECRET" secret:"true" desc:"enables the fake payment provider, whose webhooks are signed with this secret"`
+}
+
+// Features switch optional parts of the server on or off.
+type Features struct {
+	Docs bool `env:"FEATURE_DOCS" default:"false" dev:"true" desc:"serve the API reference at /docs"`
+	Jobs bool `env:"FEATURE_JOBS" default:"true" desc:"run scheduled and queued jobs in this process"`
+}
+
+// Where a value came from.
+const (
+	sourceEnv     = "env"
+	sourceFile    = "file"
+	sourceDefault = "default"
+)
+
+// Load reads the configuration. Values set in the environment win over
+// those in the config file. If path is empty, .env.<GO_ENV> is read if it
+// exists, or .env in production.
+//
+// Values from the file are also exported to the environment, so libraries
+// that read their own variables, such as OTEL_EXPORTER_OTLP_ENDPOINT, see
+// them. Load fails only if the file cannot be read; call Validate before
+// using the result.
+func Load(path string) (*Config, error) {
+	env := os.Getenv("GO_ENV")
+	if env == "" {
+		env = EnvDevelopment
+	}
+
+	explicit := path != ""
+	if !explicit {
+		path = DefaultFile(env)
+	}
+
+	fileValues, err := godotenv.Read(path)
+	switch {
+	case err == nil:
+	case !explicit && errors.Is(err, fs.ErrNotExist):
+		path = ""
+	default:
+		return nil, fmt.Errorf("reading config file: %w", err)
+	}
+
+	c := &Config{file: path, sources: make(map[string]string)}
+
+	for key, value := range fileValues {
+		if _, ok := os.LookupEnv(key); ok {
+			c.sources[key] = sourceEnv
+			continue
+		}
+		if err := os.Setenv(key, value); err != nil {
+			return nil, fmt.Errorf("exporting %s: %w", key, err)
+		}
+		c.sources[key] = sourceFile
+	}
+
+	c.load()
+	return c, nil
+}
+
+// DefaultFile is the config file read for env when none is given.
+func DefaultFile(env string) string {
+	if env == EnvProduction {
+		return ".env"
+	}
+	return ".env." + env
+}
+
+// File is the config file that was read, or "" if there was none.
+func (c *Config) File() string {
+	return c.file
+}
+
+// load fills every field from the environment. A variable set but empty
+// counts as unset. GO_ENV comes first, so the development defaults apply if
+// it was set by the config file.
+func (c *Config) load() {
+	for _, f := range fields(c) {
+		dev := c.Env == EnvDevelopment
+
+		raw := os.Getenv(f.env)
+		if raw != "" {
+			if _, seen := c.sources[f.env]; !seen {
+				c.sources[f.env] = sourceEnv
+			}
+		} else {
+			raw = f.defaultValue(dev)
+			c.sources[f.env] = sourceDefault
+		}
+
+		// A value that does not parse is reported once, by Validate; the
+		// default stands in so range checks do not report it again.
+		if err := set(f.value, raw); err != nil {
+			c.errs = append(c.errs, fmt.Errorf("%s: %w", f.env, err))
+			set(f.value, f.defaultValue(dev))
+		}
+	}
+}
+
+// Validate reports every missing or invalid value at once.
+func (c *Config) Validate() error {
+	errs := append([]error(nil), c.errs...)
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	for _, f := range fields(c) {
+		if f.required && f.value.IsZero() {
+			errs = append(errs, fmt.Errorf("%s is required", f.env))
+		}
+	}
+
+	s := c.Server
+	check(s.Port > 0 && s.Port <= 65535, "PORT must be between 1 and 65535, got %d", s.Port)
+	check(s.AdminAddr != "", "ADMIN_ADDR must not be empty")
+	check(s.ReadTimeout > 0, "SERVER_READ_TIMEOUT must be positive")
+	check(s.WriteTimeout > 0, "SERVER_WRITE_TIMEOUT must be positive")
+	check(s.IdleTimeout > 0, "SERVER_IDLE_TIMEOUT must be positive")
+	check(s.DrainDelay >= 0, "DRAIN_DELAY must not be negative")
+	check(s.ShutdownTimeout > 0, "SHUTDOWN_TIMEOUT must be positive")
+
+	d := c.Database
+	check(d.ConnectTimeout > 0, "DATABASE_CONNECT_TIMEOUT must be positive")
+	check(d.MaxConns > 0, "DATABASE_MAX_CONNS must be positive")
+	check(d.MinConns >= 0 && d.MinConns <= d.MaxConns,
+		"DATABASE_MIN_CONNS must be between 0 and DATABASE_MAX_CONNS (%d), got %d", d.MaxConns, d.MinConns)
+	check(d.MaxConnLifetime > 0, "DATABASE_MAX_CONN_LIFETIME must be positive")
+	check(d.MaxConnIdleTime > 0, "DATABASE_MAX_CONN_IDLE_TIME must be positive")
+
+	check(oneOf(c.Log.Level, "debug", "info", "warn", "error"),
+		"LOG_LEVEL must be debug, info, warn or error, got %q", c.Log.Level)
+	check(oneOf(c.Log.Format, LogText, LogJSON),
+		"LOG_FORMAT must be text or json, got %q", c.Log.Format)
+	check(oneOf(c.Tracing.Exporter, "none", "stdout", "otlp"),
+		"OTEL_TRACES_EXPORTER must be none, stdout or otlp, got %q", c.Tracing.Exporter)
+	check(oneOf(c.RateLimit.Backend, RateLimitMemory, RateLimitPostgres),
+		"RATE_LIMIT_BACKEND must be memory or postgres, got %q", c.RateLimit.Backend)
+
+	check(s.MaxBodyBytes > 0, "MAX_BODY_BYTES must be positive")
+	check(s.HSTSMaxAge >= 0, "HSTS_MAX_AGE must not be negative")
+	for _, proxy := range s.TrustedProxies {
+		_, addrErr := netip.ParseAddr(proxy)
+		_, prefixErr := netip.ParsePrefix(proxy)
+		check(addrErr == nil || prefixErr == nil, "TRUSTED_PROXIES: %q is not an IP or CIDR", proxy)
+	}
+
+	for _, origin := range c.CORS.AllowedOrigins {
+		check(origin == "*" || strings.HasPrefix(origin, "http://") || strings.HasPrefix(origin, "https://"),
+			"CORS_ALLOWED_ORIGINS: %q is not an http(s) origin", origin)
+		check(!(origin == "*" && c.CORS.AllowCredentials),
+			"CORS_ALLOW_CREDENTIALS cannot be used with CORS_ALLOWED_ORIGINS=*")
+	}
+	for _, method := range c.CORS.AllowedMethods {
+		check(method == strings.ToUpper(method), "CORS_ALLOWED_METHODS: %q must be upper case", method)
+	}
+	check(c.CORS.MaxAge >= 0, "CORS_MAX_AGE must not be negative")
+
+	t := c.TLS
+	check((t.CertFile == "") == (t.KeyFile == ""), "TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	check(t.ReloadInterval > 0, "TLS_RELOAD_INTERVAL must be positive")
+	for _, name := range []string{t.CertFile, t.KeyFile} {
+		if name == "" {
+			continue
+		}
+		_, err := os.Stat(name)
+		check(err == nil, "TLS certificate file: %v", err)
+	}
+
+	return errors.Join(errs...)
+}
+
+func oneOf(v string, allowed ...string) bool {
+	for _, a := range allowed {
+		if v == a {
+			return true
+		}
+	}
+	return false
+}
+
+// field is one tagged setting, found by walking Config.
+type field struct {
+	env      string
+	def      string
+	devDef   string
+	hasDev   bool
+	required bool
+	secret   string
+	desc     string
+	value    reflect.Value
+}
+
+func (f field) defaultValue(dev bool) string {
+	if dev && f.hasDev {
+		return f.devDef
+	}
+	return f.def
+}
+
+func fields(c *Config) []field {
+	var out []field
+	var walk func(v reflect.Value)
+	walk = func(v reflect.Value) {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			if !sf.IsExported() {
+				continue
+			}
+			fv := v.Field(i)
+			name, ok := sf.Tag.Lookup("env")
+			if !ok {
+				if fv.Kind() == reflect.Struct {
+					walk(fv)
+				}
+				continue
+			}
+			devDef, hasDev := sf.Tag.Lookup("dev")
+			out = append(out, field{
+				env:      name,
+				def:      sf.Tag.Get("default"),
+				devDef:   devDef,
+				hasDev:   hasDev,
+				required: sf.Tag.Get("required") == "true",
+				secret:   sf.Tag.Get("secret"),
+				desc:     sf.Tag.Get("desc"),
+				value:    fv,
+			})
+		}
+	}
+	walk(reflect.ValueOf(c).Elem())
+	return out
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func set(v reflect.Value, raw string) error {
+	raw = strings.TrimSpace(raw)
+
+	if v.Type() == durationType {
+		if raw == "" {
+			v.SetInt(0)
+			return nil
+		}
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", raw)
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Int:
+		if raw == "" {
+			v.SetInt(0)
+			return nil
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+		v.SetInt(int64(n))
+	case reflect.Bool:
+		if raw == "" {
+			v.SetBool(false)
+			return nil
+		}
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", raw)
+		}
+		v.SetBool(b)
+	case reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		panic(fmt.Sprintf("config: unsupported field type %s", v.Type()))
+	}
+	return nil
+}
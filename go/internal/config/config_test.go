@@ -0,0 +1,239 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// clearEnv unsets every config variable for the test, restoring them
+// after. Load exports file values, so this also undoes those.
+func clearEnv(t *testing.T) {
+	t.Helper()
+	for _, f := range fields(&Config{}) {
+		t.Setenv(f.env, "")
+		os.Unsetenv(f.env)
+	}
+}
+
+// writeFile writes a dotenv file into a temporary directory.
+func writeFile(t *testing.T, lines ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), ".env.test")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadEnvOverFile(t *testing.T) {
+	clearEnv(t)
+	path := writeFile(t,
+		"PORT=9000",
+		"LOG_LEVEL=warn",
+		"DATABASE_URL=postgres://file/dooreye",
+	)
+	t.Setenv("PORT", "7000")
+
+	c, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	if c.File() != path {
+		t.Errorf("File() = %q, want %q", c.File(), path)
+	}
+	if c.Server.Port != 7000 {
+		t.Errorf("Port = %d, want 7000 from the environment", c.Server.Port)
+	}
+	if c.Log.Level != "warn" {
+		t.Errorf("Log.Level = %q, want warn from the file", c.Log.Level)
+	}
+	if c.Database.URL != "postgres://file/dooreye" {
+		t.Errorf("Database.URL = %q, want the file's", c.Database.URL)
+	}
+	if got := os.Getenv("LOG_LEVEL"); got != "warn" {
+		t.Errorf("LOG_LEVEL exported as %q, want warn", got)
+	}
+
+	for env, want := range map[string]string{
+		"PORT":         sourceEnv,
+		"LOG_LEVEL":    sourceFile,
+		"DATABASE_URL": sourceFile,
+		"LOG_FORMAT":   sourceDefault,
+	} {
+		if got := c.sources[env]; got != want {
+			t.Errorf("source of %s = %q, want %q", env, got, want)
+		}
+	}
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	clearEnv(t)
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.env")); err == nil {
+		t.Error("Load of a missing explicit file succeeded, want error")
+	}
+}
+
+func TestLoadDefaults(t *testing.T) {
+	tests := []struct {
+		name       string
+		env        string
+		file       []string
+		level      string
+		format     string
+		docs       bool
+		hstsMaxAge time.Duration
+	}{
+		{"development", "", nil, "debug", "text", true, 0},
+		{"production", EnvProduction, nil, "info", "json", false, 8760 * time.Hour},
+		{"development from file", "", []string{"GO_ENV=development"}, "debug", "text", true, 0},
+		{"production from file", "", []string{"GO_ENV=production"}, "info", "json", false, 8760 * time.Hour},
+		{"file over dev default", "", []string{"LOG_FORMAT=json"}, "debug", "json", true, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			if tt.env != "" {
+				t.Setenv("GO_ENV", tt.env)
+			}
+
+			c, err := Load(writeFile(t, append(tt.file, "DATABASE_URL=postgres://db/dooreye")...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := c.Validate(); err != nil {
+				t.Fatalf("Validate: %v", err)
+			}
+
+			if c.Log.Level != tt.level || c.Log.Format != tt.format {
+				t.Errorf("Log = %s/%s, want %s/%s", c.Log.Level, c.Log.Format, tt.level, tt.format)
+			}
+			if c.Features.Docs != tt.docs {
+				t.Errorf("Features.Docs = %v, want %v", c.Features.Docs, tt.docs)
+			}
+			if c.Server.HSTSMaxAge != tt.hstsMaxAge {
+				t.Errorf("Server.HSTSMaxAge = %s, want %s", c.Server.HSTSMaxAge, tt.hstsMaxAge)
+			}
+			if c.Server.Port != 8080 {
+				t.Errorf("Server.Port = %d, want 8080", c.Server.Port)
+			}
+		})
+	}
+}
+
+func TestValidateReportsParseErrors(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("PORT", "eighty")
+	t.Setenv("SERVER_READ_TIMEOUT", "soon")
+	t.Setenv("FEATURE_DOCS", "maybe")
+	t.Setenv("LOG_LEVEL", "loud")
+	t.Setenv("TRUSTED_PROXIES", "10.0.0.0/8,proxy.internal")
+
+	c, err := Load(writeFile(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A value that does not parse leaves the default in place.
+	if c.Server.Port != 8080 {
+		t.Errorf("Port = %d, want the default 8080", c.Server.Port)
+	}
+
+	err = c.Validate()
+	if err == nil {
+		t.Fatal("Validate succeeded, want errors")
+	}
+	msg := err.Error()
+	for _, want := range []string{
+		`PORT: invalid integer "eighty"`,
+		`SERVER_READ_TIMEOUT: invalid duration "soon"`,
+		`FEATURE_DOCS: invalid boolean "maybe"`,
+		"DATABASE_URL is required",
+		`LOG_LEVEL must be debug, info, warn or error, got "loud"`,
+		`TRUSTED_PROXIES: "proxy.internal" is not an IP or CIDR`,
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("Validate error is missing %q; got:\n%s", want, msg)
+		}
+	}
+	if n := strings.Count(msg, "PORT"); n != 1 {
+		t.Errorf("PORT reported %d times, want once; got:\n%s", n, msg)
+	}
+	if strings.Contains(msg, "10.0.0.0/8") {
+		t.Errorf("valid proxy reported; got:\n%s", msg)
+	}
+}
+
+func TestPrintRedacts(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want []string
+	}{
+		{
+			name: "URL password",
+			env:  map[string]string{"DATABASE_URL": "postgres://app:hunter2@db:5432/dooreye?sslmode=disable"},
+			want: []string{"DATABASE_URL=postgres://app:xxxxx@db:5432/dooreye?sslmode=disable"},
+		},
+		{
+			name: "query password",
+			env:  map[string]string{"DATABASE_URL": "postgres://db/dooreye?password=hunter2&user=app"},
+			want: []string{"DATABASE_URL=postgres://db/dooreye?password=xxxxx&user=app"},
+		},
+		{
+			name: "not a URL",
+			env:  map[string]string{"DATABASE_URL": "host=db password=hunter2"},
+			want: []string{"DATABASE_URL=" + redacted},
+		},
+		{
+			name: "secret",
+			env: map[string]string{
+				"DATABASE_URL":        "postgres://db/dooreye",
+				"FAKE_PAYMENT_SECRET": "hunter2",
+			},
+			want: []string{"FAKE_PAYMENT_SECRET=" + redacted, "DATABASE_URL=postgres://db/dooreye"},
+		},
+		{
+			name: "unset secret",
+			env:  map[string]string{"DATABASE_URL": "postgres://db/dooreye"},
+			want: []string{"\nFAKE_PAYMENT_SECRET= "},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			c, err := Load(writeFile(t))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var buf bytes.Buffer
+			if err := c.Print(&buf); err != nil {
+				t.Fatal(err)
+			}
+			out := buf.String()
+
+			for _, want := range tt.want {
+				if !strings.Contains(out, want) {
+					t.Errorf("output is missing %q; got:\n%s", want, out)
+				}
+			}
+			if strings.Contains(out, "hunter2") {
+				t.Errorf("output shows a secret; got:\n%s", out)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const redacted = "[redacted]"
+
+// Print writes the effective configuration as dotenv lines, each followed
+// by where the value came from and what it does. Secrets are redacted.
+func (c *Config) Print(w io.Writer) error {
+	if c.file != "" {
+		fmt.Fprintf(w, "# config file: %s\n", c.file)
+	} else {
+		fmt.Fprintln(w, "# config file: none")
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, f := range fields(c) {
+		fmt.Fprintf(tw, "%s=%s\t# %s: %s\n", f.env, f.display(), c.sources[f.env], f.desc)
+	}
+	return tw.Flush()
+}
+
+func (f field) display() string {
+	var s string
+	switch v := f.value.Interface().(type) {
+	case time.Duration:
+		s = v.String()
+	case []string:
+		s = strings.Join(v, ",")
+	default:
+		s = fmt.Sprint(v)
+	}
+
+	switch {
+	case s == "" || f.secret == "":
+		return s
+	case f.secret == "password":
+		return redactPassword(s)
+	default:
+		return redacted
+	}
+}
+
+// redactPassword hides the password in a connection URL, keeping the rest
+// readable. Anything that does not parse as a URL is hidden entirely.
+func redactPassword(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || u.Scheme == "" {
+		return redacted
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), "xxxxx")
+	}
+
+	q := u.Query()
+	if q.Has("password") {
+		q.Set("password", "xxxxx")
+		u.RawQuery = q.Encode()
+	}
+	return u.String()
+}
@@ -0,0 +1,146 @@
+// Package helpdesk runs the background side of resident tickets: SLA breach
+// alerts and closing tickets the resident never confirmed.
+package helpdesk
+
+import (
+	"context"
+	"dooreye-backend/internal/model"
+	"dooreye-backend/internal/notify"
+	"dooreye-backend/internal/store"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AutoCloseAfter is how long a resolved ticket waits for the resident to
+// confirm or reopen it before it is closed without a rating.
+const AutoCloseAfter = 7 * 24 * time.Hour
+
+type Monitor struct {
+	db       *store.DB
+	notifier notify.Notifier
+	log      *slog.Logger
+}
+
+func NewMonitor(db *store.DB, notifier notify.Notifier, log *slog.Logger) *Monitor {
+	return &Monitor{
+		db:       db,
+		notifier: notifier,
+		log:      log.With("component", "helpdesk"),
+	}
+}
+
+// CheckSLA is the periodic job that reports tickets past a response or
+// resolution deadline to the society's managers and the assignee. Each
+// breach is claimed in the database, so it is reported once.
+func (m *Monitor) CheckSLA(ctx context.Context, _ *model.Job) error {
+	now := time.Now()
+	tickets, err := m.db.GetSLATickets(ctx, now)
+	if err != nil {
+		return err
+	}
+
+	for i := range tickets {
+		t := &tickets[i]
+		for _, kind := range Breaches(t, now) {
+			claimed, err := m.db.ClaimTicketBreach(ctx, t.ID, kind)
+			if err != nil {
+				return err
+			}
+			if !claimed {
+				continue
+			}
+
+			b := model.TicketBreach{
+				TicketID:   t.ID,
+				SocietyID:  t.SocietyID,
+				Title:      t.Title,
+				Priority:   t.Priority,
+				Kind:       kind,
+				AssignedTo: t.AssignedTo,
+			}
+			if err := m.alert(ctx, b); err != nil {
+				m.log.ErrorContext(ctx, "alerting ticket SLA breach", "ticket_id", b.TicketID, "kind", b.Kind, "error", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Due returns a ticket's response and resolution deadlines as of now. The
+// SLA clock is stopped while a ticket is on hold, so an ongoing hold pushes
+// both back by its length so far; finished holds are already included in
+// the stored deadlines.
+func Due(t *model.Ticket, now time.Time) (respondBy, resolveBy time.Time) {
+	respondBy, resolveBy = t.RespondBy, t.ResolveBy
+	if t.HeldSince != nil && now.After(*t.HeldSince) {
+		held := now.Sub(*t.HeldSince)
+		respondBy, resolveBy = respondBy.Add(held), resolveBy.Add(held)
+	}
+	return respondBy, resolveBy
+}
+
+// Breaches returns the deadlines an open ticket has missed by now and not
+// yet been reported for. A deadline is missed once now is past it.
+func Breaches(t *model.Ticket, now time.Time) []string {
+	if t.Status == model.TicketResolved || t.Status == model.TicketClosed {
+		return nil
+	}
+
+	respondBy, resolveBy := Due(t, now)
+
+	var kinds []string
+	if t.FirstResponseAt == nil && t.ResponseBreachedAt == nil && now.After(respondBy) {
+		kinds = append(kinds, model.BreachResponse)
+	}
+	if t.ResolutionBreachedAt == nil && now.After(resolveBy) {
+		kinds = append(kinds, model.BreachResolution)
+	}
+	return kinds
+}
+
+// AutoClose is the periodic job that closes stale resolved tickets.
+func (m *Monitor) AutoClose(ctx context.Context, _ *model.Job) error {
+	n, err := m.db.CloseResolvedTickets(ctx, time.Now().Add(-AutoCloseAfter))
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		m.log.InfoContext(ctx, "closed resolved tickets", "count", n)
+	}
+	return nil
+}
+
+func (m *Monitor) alert(ctx context.Context, b model.TicketBreach) error {
+	recipients, err := m.db.GetSocietyUserIDsByRole(ctx, b.SocietyID, model.RoleSocietyManager)
+	if err != nil {
+		return err
+	}
+	if b.AssignedTo != nil {
+		recipients = append(recipients, b.AssignedTo.String())
+	}
+
+	return m.notifier.Send(ctx, notify.Message{
+		UserIDs:  recipients,
+		Kind:     "TICKET_SLA_BREACH",
+		Title:    fmt.Sprintf("Ticket #%d missed its %s deadline", b.TicketID, strings.ToLower(b.Kind)),
+		Body:     fmt.Sprintf("%s ticket %q is past its %s SLA", b.Priority, b.Title, strings.ToLower(b.Kind)),
+		Priority: alertPriority(b.Priority),
+		Data: map[string]string{
+			"ticket_id": strconv.FormatInt(b.TicketID, 10),
+			"kind":      b.Kind,
+		},
+	})
+}
+
+// alertPriority maps a ticket's priority to the priority of its breach
+// alerts.
+func alertPriority(p model.TicketPriority) notify.Priority {
+	if p == model.TicketHigh || p == model.TicketUrgent {
+		return notify.PriorityHigh
+	}
+	return notify.PriorityNormal
+}
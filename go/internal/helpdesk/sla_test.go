@@ -0,0 +1,136 @@
+package helpdesk
+
+import (
+	"dooreye-backend/internal/model"
+	"dooreye-backend/internal/notify"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAlertPriority(t *testing.T) {
+	tests := []struct {
+		priority model.TicketPriority
+		want     notify.Priority
+	}{
+		{model.TicketLow, notify.PriorityNormal},
+		{model.TicketMedium, notify.PriorityNormal},
+		{model.TicketHigh, notify.PriorityHigh},
+		{model.TicketUrgent, notify.PriorityHigh},
+	}
+
+	for _, tt := range tests {
+		if got := alertPriority(tt.priority); got != tt.want {
+			t.Errorf("alertPriority(%s) = %s, want %s", tt.priority, got, tt.want)
+		}
+	}
+}
+
+func TestBreaches(t *testing.T) {
+	raised := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	respondBy := raised.Add(2 * time.Hour)
+	resolveBy := raised.Add(24 * time.Hour)
+
+	at := func(d time.Duration) *time.Time {
+		t := raised.Add(d)
+		return &t
+	}
+	ticket := func(edit func(*model.Ticket)) *model.Ticket {
+		t := &model.Ticket{
+			Priority:  model.TicketHigh,
+			Status:    model.TicketOpen,
+			RespondBy: respondBy,
+			ResolveBy: resolveBy,
+			CreatedAt: raised,
+		}
+		if edit != nil {
+			edit(t)
+		}
+		return t
+	}
+
+	tests := []struct {
+		name   string
+		ticket *model.Ticket
+		now    time.Time
+		want   []string
+	}{
+		{"just before the response deadline", ticket(nil), respondBy.Add(-time.Second), nil},
+		{"at the response deadline", ticket(nil), respondBy, nil},
+		{"just after the response deadline", ticket(nil), respondBy.Add(time.Second),
+			[]string{model.BreachResponse}},
+		{"responded in time", ticket(func(t *model.Ticket) {
+			t.Status = model.TicketAssigned
+			t.FirstResponseAt = at(time.Hour)
+		}), respondBy.Add(time.Hour), nil},
+		{"response already reported", ticket(func(t *model.Ticket) {
+			t.ResponseBreachedAt = at(3 * time.Hour)
+		}), respondBy.Add(time.Hour), nil},
+		{"just before the resolution deadline", ticket(func(t *model.Ticket) {
+			t.FirstResponseAt = at(time.Hour)
+		}), resolveBy.Add(-time.Second), nil},
+		{"just after the resolution deadline", ticket(func(t *model.Ticket) {
+			t.FirstResponseAt = at(time.Hour)
+		}), resolveBy.Add(time.Second), []string{model.BreachResolution}},
+		{"both missed", ticket(nil), resolveBy.Add(time.Second),
+			[]string{model.BreachResponse, model.BreachResolution}},
+		{"resolved", ticket(func(t *model.Ticket) {
+			t.Status = model.TicketResolved
+			t.ResolvedAt = at(30 * time.Hour)
+		}), resolveBy.Add(time.Hour), nil},
+
+		// Paused time: the clock stops for the length of a hold.
+		{"on hold since before the deadline", ticket(func(t *model.Ticket) {
+			t.Status = model.TicketOnHold
+			t.FirstResponseAt = at(time.Hour)
+			t.HeldSince = at(20 * time.Hour)
+		}), resolveBy.Add(6 * time.Hour), nil},
+		{"on hold for days", ticket(func(t *model.Ticket) {
+			t.Status = model.TicketOnHold
+			t.FirstResponseAt = at(time.Hour)
+			t.HeldSince = at(20 * time.Hour)
+		}), resolveBy.Add(72 * time.Hour), nil},
+		{"hold ended", ticket(func(t *model.Ticket) {
+			// Resuming after 6 hours on hold moved the deadline to 30h.
+			t.Status = model.TicketInProgress
+			t.FirstResponseAt = at(time.Hour)
+			t.ResolveBy = resolveBy.Add(6 * time.Hour)
+		}), resolveBy.Add(6*time.Hour + time.Second), []string{model.BreachResolution}},
+		{"put on hold after the deadline", ticket(func(t *model.Ticket) {
+			t.Status = model.TicketOnHold
+			t.FirstResponseAt = at(time.Hour)
+			t.HeldSince = at(25 * time.Hour)
+		}), resolveBy.Add(2 * time.Hour), []string{model.BreachResolution}},
+	}
+
+	for _, tt := range tests {
+		if got := Breaches(tt.ticket, tt.now); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Breaches = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDue(t *testing.T) {
+	raised := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	respondBy, resolveBy := raised.Add(2*time.Hour), raised.Add(24*time.Hour)
+	heldSince := raised.Add(3 * time.Hour)
+
+	tests := []struct {
+		name      string
+		heldSince *time.Time
+		now       time.Time
+		shift     time.Duration
+	}{
+		{"not on hold", nil, raised.Add(10 * time.Hour), 0},
+		{"on hold", &heldSince, heldSince.Add(90 * time.Minute), 90 * time.Minute},
+		{"hold not started", &heldSince, heldSince.Add(-time.Minute), 0},
+	}
+
+	for _, tt := range tests {
+		ticket := &model.Ticket{RespondBy: respondBy, ResolveBy: resolveBy, HeldSince: tt.heldSince}
+		gotRespond, gotResolve := Due(ticket, tt.now)
+		if !gotRespond.Equal(respondBy.Add(tt.shift)) || !gotResolve.Equal(resolveBy.Add(tt.shift)) {
+			t.Errorf("%s: Due = %s, %s, want both moved by %s", tt.name, gotRespond, gotResolve, tt.shift)
+		}
+	}
+}
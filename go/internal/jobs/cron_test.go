@@ -0,0 +1,87 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleNext(t *testing.T) {
+	at := func(s string) time.Time {
+		t.Helper()
+		v, err := time.Parse("2006-01-02 15:04:05", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+
+	tests := []struct {
+		spec  string
+		after string
+		want  string
+	}{
+		{"*/15 * * * *", "2024-05-01 10:07:30", "2024-05-01 10:15:00"},
+		{"*/15 * * * *", "2024-05-01 10:15:00", "2024-05-01 10:30:00"},
+		{"5/20 * * * *", "2024-05-01 10:06:00", "2024-05-01 10:25:00"},
+		{"0,30 8 * * *", "2024-05-01 08:00:00", "2024-05-01 08:30:00"},
+		{"30 9-17/4 * * 1-5", "2024-05-31 17:30:00", "2024-06-03 09:30:00"},
+		{"0 0 * * *", "2024-01-31 23:59:59", "2024-02-01 00:00:00"},
+		{"0 0 31 * *", "2024-04-01 00:00:00", "2024-05-31 00:00:00"},
+		{"0 0 1 1 *", "2024-06-01 00:00:00", "2025-01-01 00:00:00"},
+		{"0 0 29 2 *", "2024-03-01 00:00:00", "2028-02-29 00:00:00"},
+		// Both day fields restricted: either one matches.
+		{"0 9 1 * 1", "2024-05-01 10:00:00", "2024-05-06 09:00:00"},
+		{"@hourly", "2024-05-01 10:59:59", "2024-05-01 11:00:00"},
+		{"@daily", "2024-12-31 12:00:00", "2025-01-01 00:00:00"},
+		{"@midnight", "2024-05-01 00:00:00", "2024-05-02 00:00:00"},
+		{"@weekly", "2024-06-01 12:00:00", "2024-06-02 00:00:00"},
+		{"@monthly", "2024-02-15 00:00:00", "2024-03-01 00:00:00"},
+		{"@every 1h", "2024-05-01 10:20:30", "2024-05-01 11:00:00"},
+		{"@every 30s", "2024-05-01 10:20:30", "2024-05-01 10:21:00"},
+	}
+
+	for _, tt := range tests {
+		s, err := ParseSchedule(tt.spec)
+		if err != nil {
+			t.Errorf("ParseSchedule(%q): %v", tt.spec, err)
+			continue
+		}
+		if got, want := s.Next(at(tt.after)), at(tt.want); !got.Equal(want) {
+			t.Errorf("%q.Next(%s) = %s, want %s", tt.spec, tt.after, got, want)
+		}
+	}
+}
+
+func TestScheduleNextImpossible(t *testing.T) {
+	s, err := ParseSchedule("0 0 31 2 *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Next(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)); !got.IsZero() {
+		t.Errorf("Next = %s, want zero time", got)
+	}
+}
+
+func TestParseScheduleErrors(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 7",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"1-x * * * *",
+		"@every 500ms",
+		"@every soon",
+		"@yearly",
+	} {
+		if _, err := ParseSchedule(spec); err == nil {
+			t.Errorf("ParseSchedule(%q) succeeded, want error", spec)
+		}
+	}
+}
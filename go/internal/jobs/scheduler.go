@@ -0,0 +1,321 @@
+package jobs
+
+import (
+	"context"
+	"dooreye-backend/internal/model"
+	"dooreye-backend/internal/store"
+	"dooreye-backend/internal/telemetry"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// HandlerFunc processes one job. Returning an error schedules a retry with
+// exponential backoff until the job runs out of attempts.
+type HandlerFunc func(ctx context.Context, job *model.Job) error
+
+type Options struct {
+	// Workers is the number of goroutines pulling from the queue.
+	Workers int
+	// PollInterval is how long an idle worker waits before polling again.
+	PollInterval time.Duration
+	// StaleAfter is how long a job may stay RUNNING before another
+	// instance assumes its worker died and requeues it.
+	StaleAfter time.Duration
+}
+
+type periodic struct {
+	name     string
+	schedule Schedule
+}
+
+// Scheduler runs periodic jobs on cron schedules and works off the durable
+// job queue in Postgres. Periodic runs are enqueued with a unique key per
+// activation, so every instance can run a scheduler and each activation is
+// still executed once.
+type Scheduler struct {
+	db       *store.DB
+	log      *slog.Logger
+	opts     Options
+	workerID string
+
+	mu       sync.RWMutex
+	handlers map[string]HandlerFunc
+	periodic []periodic
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewScheduler(db *store.DB, log *slog.Logger, opts Options) *Scheduler {
+	if opts.Workers <= 0 {
+		opts.Workers = 4
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = 2 * time.Second
+	}
+	if opts.StaleAfter <= 0 {
+		opts.StaleAfter = 15 * time.Minute
+	}
+
+	host, _ := os.Hostname()
+
+	return &Scheduler{
+		db:       db,
+		log:      log.With("component", "jobs"),
+		opts:     opts,
+		workerID: fmt.Sprintf("%s-%d", host, os.Getpid()),
+		handlers: make(map[string]HandlerFunc),
+	}
+}
+
+// Handle registers the handler for a job kind. Only registered kinds are
+// claimed by this instance.
+func (s *Scheduler) Handle(kind string, fn HandlerFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.handlers[kind] = fn
+}
+
+// Every registers fn as a periodic job on a cron spec (see ParseSchedule).
+func (s *Scheduler) Every(name, spec string, fn HandlerFunc) error {
+	schedule, err := ParseSchedule(spec)
+	if err != nil {
+		return fmt.Errorf("job %s: %w", name, err)
+	}
+
+	s.Handle(name, fn)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.periodic = append(s.periodic, periodic{name: name, schedule: schedule})
+
+	return nil
+}
+
+// Enqueue adds a one-off job to the queue.
+func (s *Scheduler) Enqueue(ctx context.Context, kind string, payload any, runAt time.Time) (*model.Job, error) {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("encoding job payload: %w", err)
+	}
+
+	return s.db.EnqueueJob(ctx, store.EnqueueJobParams{
+		Kind:    kind,
+		Payload: raw,
+		RunAt:   runAt,
+	})
+}
+
+// Start launches the cron loop and the queue workers. They run until Stop is
+// called.
+func (s *Scheduler) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+
+	s.mu.RLock()
+	for _, p := range s.periodic {
+		s.wg.Add(1)
+		go s.runPeriodic(ctx, p)
+	}
+	s.mu.RUnlock()
+
+	s.wg.Add(1)
+	go s.runReaper(ctx)
+
+	for i := 0; i < s.opts.Workers; i++ {
+		s.wg.Add(1)
+		go s.runWorker(ctx, fmt.Sprintf("%s-w%d", s.workerID, i))
+	}
+
+	s.log.Info("job scheduler started", "workers", s.opts.Workers, "periodic", len(s.periodic))
+}
+
+// Stop stops claiming new jobs and waits for running ones to finish, or for
+// ctx to expire.
+func (s *Scheduler) Stop(ctx context.Context) error {
+	if s.cancel == nil {
+		return nil
+	}
+	s.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		s.log.InfoContext(ctx, "job scheduler stopped")
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("waiting for running jobs: %w", ctx.Err())
+	}
+}
+
+func (s *Scheduler) runPeriodic(ctx context.Context, p periodic) {
+	defer s.wg.Done()
+
+	for {
+		next := p.schedule.Next(time.Now())
+		if next.IsZero() {
+			s.log.ErrorContext(ctx, "periodic job will never run again", "job", p.name)
+			return
+		}
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		key := fmt.Sprintf("%s@%s", p.name, next.UTC().Format(time.RFC3339))
+		_, err := s.db.EnqueueJob(ctx, store.EnqueueJobParams{
+			Kind:        p.name,
+			RunAt:       next,
+			MaxAttempts: 1,
+			UniqueKey:   &key,
+		})
+		if err != nil && !errors.Is(err, store.ErrAlreadyExists) {
+			s.log.ErrorContext(ctx, "enqueueing periodic job", "job", p.name, "error", err)
+		}
+	}
+}
+
+func (s *Scheduler) runReaper(ctx context.Context) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.opts.StaleAfter / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		n, err := s.db.RequeueStaleJobs(ctx, time.Now().Add(-s.opts.StaleAfter))
+		if err != nil {
+			s.log.ErrorContext(ctx, "requeueing stale jobs", "error", err)
+			continue
+		}
+		if n > 0 {
+			s.log.WarnContext(ctx, "requeued or failed stale jobs", "count", n)
+		}
+	}
+}
+
+func (s *Scheduler) runWorker(ctx context.Context, workerID string) {
+	defer s.wg.Done()
+
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+
+		job, err := s.db.ClaimJob(ctx, workerID, s.kinds())
+		if err != nil {
+			if !errors.Is(err, store.ErrNotFound) && ctx.Err() == nil {
+				s.log.ErrorContext(ctx, "claiming job", "error", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(s.opts.PollInterval):
+			}
+			continue
+		}
+
+		s.execute(job)
+	}
+}
+
+// execute runs a claimed job to completion. It deliberately does not use the
+// worker context, so a shutdown lets in-flight jobs finish. The job's
+// context carries its ID into the handler's logs and a span of its own.
+func (s *Scheduler) execute(job *model.Job) {
+	ctx := telemetry.WithLogAttrs(context.Background(),
+		"job_id", job.ID, "kind", job.Kind, "attempt", job.Attempts)
+	ctx, span := otel.Tracer("dooreye-backend/internal/jobs").Start(ctx, "job "+job.Kind,
+		trace.WithAttributes(
+			attribute.Int64("job.id", job.ID),
+			attribute.String("job.kind", job.Kind),
+			attribute.Int("job.attempt", job.Attempts),
+		),
+	)
+	defer span.End()
+
+	s.mu.RLock()
+	fn := s.handlers[job.Kind]
+	s.mu.RUnlock()
+
+	start := time.Now()
+	err := s.safeRun(ctx, fn, job)
+	if err == nil {
+		if err := s.db.CompleteJob(ctx, job.ID); err != nil {
+			s.log.ErrorContext(ctx, "completing job", "error", err)
+		}
+		s.log.DebugContext(ctx, "job succeeded", "duration", time.Since(start))
+		return
+	}
+
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+
+	retryAt := time.Now().Add(Backoff(job.Attempts))
+	if err := s.db.FailJob(ctx, job.ID, err, retryAt); err != nil {
+		s.log.ErrorContext(ctx, "recording job failure", "error", err)
+	}
+	s.log.WarnContext(ctx, "job failed", "error", err, "duration", time.Since(start))
+}
+
+func (s *Scheduler) safeRun(ctx context.Context, fn HandlerFunc, job *model.Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+
+	return fn(ctx, job)
+}
+
+func (s *Scheduler) kinds() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	kinds := make([]string, 0, len(s.handlers))
+	for k := range s.handlers {
+		kinds = append(kinds, k)
+	}
+	return kinds
+}
+
+// Backoff returns the delay before retry number attempt: 10s doubling per
+// attempt, capped at one hour, with up to 20% jitter.
+func Backoff(attempt int) time.Duration {
+	d := 10 * time.Second
+	for i := 1; i < attempt && d < time.Hour; i++ {
+		d *= 2
+	}
+	if d > time.Hour {
+		d = time.Hour
+	}
+
+	return d + time.Duration(rand.Int63n(int64(d)/5+1))
+}
@@ -0,0 +1,77 @@
+// Package metrics holds the Prometheus metrics the server exports. They are
+// served on the admin listener only, never on the public port.
+package metrics
+
+import (
+	"dooreye-backend/internal/model"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "dooreye"
+
+// Registry holds every metric of the process, including Go runtime and
+// process metrics.
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Time taken to serve HTTP requests, by route and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	visitorCheckIns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "visits",
+		Name:      "check_ins_total",
+		Help:      "People checked in at the gate, by visitor type.",
+	}, []string{"visitor_type"})
+
+	visitApprovalLatency = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "visits",
+		Name:      "approval_latency_seconds",
+		Help:      "Time from a visit being logged at the gate to a resident approving it.",
+		Buckets:   []float64{5, 15, 30, 60, 120, 300, 600, 1800, 3600},
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequestDuration,
+		visitorCheckIns,
+		visitApprovalLatency,
+	)
+}
+
+// Handler serves the registry in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// ObserveRequest records a served request. route is the route pattern, not
+// the raw path, so the number of series stays bounded.
+func ObserveRequest(method, route string, status int, elapsed time.Duration) {
+	httpRequestDuration.WithLabelValues(method, route, strconv.Itoa(status)).Observe(elapsed.Seconds())
+}
+
+// CheckIn counts people let in at the gate: one for most visits, the whole
+// party for an event guest.
+func CheckIn(visitorType model.VisitorType, people int) {
+	visitorCheckIns.WithLabelValues(string(visitorType)).Add(float64(people))
+}
+
+// Approved records how long a visit waited for its resident's approval.
+func Approved(waited time.Duration) {
+	visitApprovalLatency.Observe(waited.Seconds())
+}
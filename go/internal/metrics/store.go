@@ -0,0 +1,90 @@
+package metrics
+
+import (
+	"context"
+	"dooreye-backend/internal/store"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// storeScrapeTimeout bounds the queries run while serving a scrape.
+const storeScrapeTimeout = 5 * time.Second
+
+// RegisterStore adds the connection pool and database backed gauges, which
+// are read from db on every scrape.
+func RegisterStore(db *store.DB) error {
+	if err := Registry.Register(poolCollector{db: db}); err != nil {
+		return err
+	}
+	return Registry.Register(activeVisitsCollector{db: db})
+}
+
+var (
+	poolAcquiredConns = poolDesc("acquired_conns", "Connections currently in use.")
+	poolIdleConns     = poolDesc("idle_conns", "Connections idle in the pool.")
+	poolTotalConns    = poolDesc("total_conns", "Connections open, in use or idle.")
+	poolMaxConns      = poolDesc("max_conns", "Largest number of connections the pool opens.")
+	poolAcquires      = poolDesc("acquires_total", "Connections handed out by the pool.")
+	poolEmptyAcquires = poolDesc("empty_acquires_total", "Acquires that had to wait because no connection was idle.")
+	poolCanceled      = poolDesc("canceled_acquires_total", "Acquires abandoned because their context ended.")
+	poolAcquireWait   = poolDesc("acquire_wait_seconds_total", "Total time spent acquiring connections.")
+)
+
+func poolDesc(name, help string) *prometheus.Desc {
+	return prometheus.NewDesc(prometheus.BuildFQName(namespace, "db_pool", name), help, nil, nil)
+}
+
+type poolCollector struct {
+	db *store.DB
+}
+
+func (c poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(c, ch)
+}
+
+func (c poolCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.db.PoolStats()
+
+	ch <- prometheus.MustNewConstMetric(poolAcquiredConns, prometheus.GaugeValue, float64(s.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(poolIdleConns, prometheus.GaugeValue, float64(s.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(poolTotalConns, prometheus.GaugeValue, float64(s.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(poolMaxConns, prometheus.GaugeValue, float64(s.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(poolAcquires, prometheus.CounterValue, float64(s.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(poolEmptyAcquires, prometheus.CounterValue, float64(s.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(poolCanceled, prometheus.CounterValue, float64(s.CanceledAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(poolAcquireWait, prometheus.CounterValue, s.AcquireDuration().Seconds())
+}
+
+var activeVisits = prometheus.NewDesc(
+	prometheus.BuildFQName(namespace, "visits", "active"),
+	"Visitors currently inside, by society.",
+	[]string{"society_id"}, nil,
+)
+
+// activeVisitsCollector counts open visits at scrape time, so the gauge
+// cannot drift from the visits table.
+type activeVisitsCollector struct {
+	db *store.DB
+}
+
+func (c activeVisitsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- activeVisits
+}
+
+func (c activeVisitsCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), storeScrapeTimeout)
+	defer cancel()
+
+	counts, err := c.db.CountOpenVisitsBySociety(ctx)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(activeVisits, err)
+		return
+	}
+
+	for societyID, n := range counts {
+		ch <- prometheus.MustNewConstMetric(activeVisits, prometheus.GaugeValue, float64(n),
+			strconv.FormatInt(societyID, 10))
+	}
+}
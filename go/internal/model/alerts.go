@@ -0,0 +1,58 @@
+package model
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+type AlertType string
+
+const (
+	AlertPanic     AlertType = "PANIC"
+	AlertFire      AlertType = "FIRE"
+	AlertIntrusion AlertType = "INTRUSION"
+	AlertMedical   AlertType = "MEDICAL"
+	AlertOther     AlertType = "OTHER"
+)
+
+func (t AlertType) Valid() bool {
+	switch t {
+	case AlertPanic, AlertFire, AlertIntrusion, AlertMedical, AlertOther:
+		return true
+	}
+	return false
+}
+
+type AlertStatus string
+
+const (
+	AlertRaised       AlertStatus = "RAISED"
+	AlertAcknowledged AlertStatus = "ACKNOWLEDGED"
+	AlertResolved     AlertStatus = "RESOLVED"
+)
+
+type Alert struct {
+	ID              uuid.UUID   `json:"id"`
+	SocietyID       int64       `json:"society_id"`
+	Type            AlertType   `json:"type"`
+	Status          AlertStatus `json:"status"`
+	ResidenceID     *int64      `json:"residence_id,omitempty"`
+	GateID          *int64      `json:"gate_id,omitempty"`
+	Message         *string     `json:"message,omitempty"`
+	RaisedBy        uuid.UUID   `json:"raised_by"`
+	EscalationLevel int         `json:"escalation_level"`
+	LastEscalatedAt *time.Time  `json:"last_escalated_at,omitempty"`
+	AcknowledgedBy  *uuid.UUID  `json:"acknowledged_by,omitempty"`
+	AcknowledgedAt  *time.Time  `json:"acknowledged_at,omitempty"`
+	ResolvedBy      *uuid.UUID  `json:"resolved_by,omitempty"`
+	ResolvedAt      *time.Time  `json:"resolved_at,omitempty"`
+	CreatedAt       time.Time   `json:"created_at"`
+	UpdatedAt       time.Time   `json:"updated_at"`
+}
+
+type AlertEscalationStep struct {
+	Level        int        `json:"level"`
+	AfterSeconds int        `json:"after_seconds"`
+	Roles        []UserRole `json:"roles"`
+}
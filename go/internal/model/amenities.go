@@ -0,0 +1,88 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+// ClockTime is a time of day in minutes after local midnight. It travels as
+// "HH:MM" in JSON.
+type ClockTime int
+
+func (t ClockTime) String() string {
+	return fmt.Sprintf("%02d:%02d", int(t)/60, int(t)%60)
+}
+
+func (t ClockTime) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.String())
+}
+
+func (t *ClockTime) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("time of day must be a HH:MM string: %w", err)
+	}
+
+	var hh, mm int
+	if _, err := fmt.Sscanf(s, "%d:%d", &hh, &mm); err != nil || hh < 0 || hh > 24 || mm < 0 || mm > 59 || (hh == 24 && mm != 0) {
+		return fmt.Errorf("invalid time of day %q", s)
+	}
+
+	*t = ClockTime(hh*60 + mm)
+	return nil
+}
+
+type Amenity struct {
+	ID                int64     `json:"id"`
+	SocietyID         int64     `json:"society_id"`
+	Name              string    `json:"name"`
+	Description       *string   `json:"description,omitempty"`
+	Capacity          int       `json:"capacity"`
+	SlotMinutes       int       `json:"slot_minutes"`
+	OpensAt           ClockTime `json:"opens_at"`
+	ClosesAt          ClockTime `json:"closes_at"`
+	MaxPerWeek        *int      `json:"max_per_week,omitempty"`
+	AdvanceDays       int       `json:"advance_days"`
+	FeePaise          int64     `json:"fee_paise"`
+	CancelCutoffHours *int      `json:"cancel_cutoff_hours,omitempty"`
+	Timezone          string    `json:"timezone"`
+	IsActive          bool      `json:"is_active"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+type AmenityBookingStatus string
+
+const (
+	AmenityBookingConfirmed AmenityBookingStatus = "CONFIRMED"
+	AmenityBookingCancelled AmenityBookingStatus = "CANCELLED"
+)
+
+type AmenityBooking struct {
+	ID           int64                `json:"id"`
+	AmenityID    int64                `json:"amenity_id"`
+	AmenityName  string               `json:"amenity_name"`
+	Unit         int                  `json:"unit"`
+	ResidenceID  int64                `json:"residence_id"`
+	BookedBy     uuid.UUID            `json:"booked_by"`
+	StartsAt     time.Time            `json:"starts_at"`
+	EndsAt       time.Time            `json:"ends_at"`
+	Status       AmenityBookingStatus `json:"status"`
+	FeePaise     int64                `json:"fee_paise"`
+	Notes        *string              `json:"notes,omitempty"`
+	CancelledAt  *time.Time           `json:"cancelled_at,omitempty"`
+	CancelledBy  *uuid.UUID           `json:"cancelled_by,omitempty"`
+	CancelReason *string              `json:"cancel_reason,omitempty"`
+	CreatedAt    time.Time            `json:"created_at"`
+	UpdatedAt    time.Time            `json:"updated_at"`
+}
+
+// AmenitySlot is one bookable interval of a day with the units left free.
+type AmenitySlot struct {
+	StartsAt time.Time `json:"starts_at"`
+	EndsAt   time.Time `json:"ends_at"`
+	Free     int       `json:"free"`
+}
@@ -0,0 +1,41 @@
+package model
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+type Announcement struct {
+	ID          int64      `json:"id"`
+	SocietyID   int64      `json:"society_id"`
+	Title       string     `json:"title"`
+	Body        string     `json:"body"`
+	BlockIDs    []int64    `json:"block_ids"`
+	Roles       []UserRole `json:"roles"`
+	IsUrgent    bool       `json:"is_urgent"`
+	IsPinned    bool       `json:"is_pinned"`
+	PublishedAt *time.Time `json:"published_at,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	NotifiedAt  *time.Time `json:"notified_at,omitempty"`
+	WithdrawnAt *time.Time `json:"withdrawn_at,omitempty"`
+	CreatedBy   uuid.UUID  `json:"created_by"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+
+	Attachments []AnnouncementAttachment `json:"attachments"`
+
+	// Set in a user's feed.
+	ReadAt *time.Time `json:"read_at,omitempty"`
+	// Set in the manager listing.
+	ReadCount *int `json:"read_count,omitempty"`
+}
+
+type AnnouncementAttachment struct {
+	ID             int64     `json:"id"`
+	AnnouncementID int64     `json:"announcement_id"`
+	URL            string    `json:"url"`
+	Name           string    `json:"name"`
+	ContentType    *string   `json:"content_type,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+}
@@ -0,0 +1,181 @@
+package model
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+type BillingRuleKind string
+
+const (
+	RuleFlat     BillingRuleKind = "FLAT"
+	RulePerSqft  BillingRuleKind = "PER_SQFT"
+	RulePerFloor BillingRuleKind = "PER_FLOOR"
+)
+
+func (k BillingRuleKind) Valid() bool {
+	return k == RuleFlat || k == RulePerSqft || k == RulePerFloor
+}
+
+type BillingSettings struct {
+	SocietyID    int64     `json:"society_id"`
+	CycleMonths  int       `json:"cycle_months"`
+	DueDays      int       `json:"due_days"`
+	LateFeePaise int64     `json:"late_fee_paise"`
+	GraceDays    int       `json:"grace_days"`
+	Timezone     string    `json:"timezone"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+type BillingRule struct {
+	ID        int64           `json:"id"`
+	SocietyID int64           `json:"society_id"`
+	BlockID   *int64          `json:"block_id,omitempty"`
+	Name      string          `json:"name"`
+	Kind      BillingRuleKind `json:"kind"`
+	RatePaise int64           `json:"rate_paise"`
+	IsActive  bool            `json:"is_active"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// BillableResidence is what the billing rules are evaluated against.
+type BillableResidence struct {
+	ID       int64  `json:"id"`
+	BlockID  int64  `json:"block_id"`
+	Number   string `json:"number"`
+	Floor    int    `json:"floor"`
+	AreaSqft *int   `json:"area_sqft,omitempty"`
+}
+
+type InvoiceStatus string
+
+const (
+	InvoiceDue     InvoiceStatus = "DUE"
+	InvoiceOverdue InvoiceStatus = "OVERDUE"
+	InvoicePaid    InvoiceStatus = "PAID"
+)
+
+// LedgerEntryKind classifies ledger transactions and invoice lines.
+type LedgerEntryKind string
+
+const (
+	EntryCharge  LedgerEntryKind = "CHARGE"
+	EntryPenalty LedgerEntryKind = "PENALTY"
+	EntryPayment LedgerEntryKind = "PAYMENT"
+)
+
+type Invoice struct {
+	ID               int64         `json:"id"`
+	SocietyID        int64         `json:"society_id"`
+	ResidenceID      int64         `json:"residence_id"`
+	PeriodStart      time.Time     `json:"period_start"`
+	PeriodEnd        time.Time     `json:"period_end"`
+	DueDate          time.Time     `json:"due_date"`
+	TotalPaise       int64         `json:"total_paise"`
+	PaidPaise        int64         `json:"paid_paise"`
+	Status           InvoiceStatus `json:"status"`
+	LateFeeAppliedAt *time.Time    `json:"late_fee_applied_at,omitempty"`
+	IssuedAt         time.Time     `json:"issued_at"`
+	UpdatedAt        time.Time     `json:"updated_at"`
+
+	Lines []InvoiceLine `json:"lines,omitempty"`
+}
+
+// StatusAt derives the invoice's status from what has been paid and its due
+// date. today is a calendar date, midnight UTC like the invoice's dates.
+func (i *Invoice) StatusAt(today time.Time) InvoiceStatus {
+	switch {
+	case i.PaidPaise >= i.TotalPaise:
+		return InvoicePaid
+	case today.After(i.DueDate):
+		return InvoiceOverdue
+	}
+	return InvoiceDue
+}
+
+type InvoiceLine struct {
+	ID          int64           `json:"id"`
+	InvoiceID   int64           `json:"invoice_id"`
+	Kind        LedgerEntryKind `json:"kind"`
+	RuleID      *int64          `json:"rule_id,omitempty"`
+	Description string          `json:"description"`
+	AmountPaise int64           `json:"amount_paise"`
+}
+
+type PaymentMethod string
+
+const (
+	PaymentCash         PaymentMethod = "CASH"
+	PaymentCheque       PaymentMethod = "CHEQUE"
+	PaymentBankTransfer PaymentMethod = "BANK_TRANSFER"
+	PaymentGateway      PaymentMethod = "GATEWAY"
+)
+
+// Offline reports whether the method is recorded by hand rather than
+// confirmed by a gateway.
+func (m PaymentMethod) Offline() bool {
+	return m == PaymentCash || m == PaymentCheque || m == PaymentBankTransfer
+}
+
+type PaymentStatus string
+
+const (
+	PaymentPending   PaymentStatus = "PENDING"
+	PaymentSucceeded PaymentStatus = "SUCCEEDED"
+	PaymentFailed    PaymentStatus = "FAILED"
+)
+
+type Payment struct {
+	ID            int64         `json:"id"`
+	SocietyID     int64         `json:"society_id"`
+	ResidenceID   int64         `json:"residence_id"`
+	AmountPaise   int64         `json:"amount_paise"`
+	Method        PaymentMethod `json:"method"`
+	Status        PaymentStatus `json:"status"`
+	Provider      *string       `json:"provider,omitempty"`
+	Reference     *string       `json:"reference,omitempty"`
+	FailureReason *string       `json:"failure_reason,omitempty"`
+	CreatedBy     uuid.UUID     `json:"created_by"`
+	CompletedAt   *time.Time    `json:"completed_at,omitempty"`
+	CreatedAt     time.Time     `json:"created_at"`
+	UpdatedAt     time.Time     `json:"updated_at"`
+}
+
+type LedgerAccount string
+
+const (
+	AccountReceivable        LedgerAccount = "RECEIVABLE"
+	AccountMaintenanceIncome LedgerAccount = "MAINTENANCE_INCOME"
+	AccountPenaltyIncome     LedgerAccount = "PENALTY_INCOME"
+	AccountCash              LedgerAccount = "CASH"
+	AccountBank              LedgerAccount = "BANK"
+	AccountGateway           LedgerAccount = "GATEWAY"
+)
+
+// StatementLine is one ledger transaction as it moved a residence's
+// receivable. Debits raise what is owed, credits lower it.
+type StatementLine struct {
+	TransactionID int64           `json:"transaction_id"`
+	Kind          LedgerEntryKind `json:"kind"`
+	Memo          string          `json:"memo"`
+	InvoiceID     *int64          `json:"invoice_id,omitempty"`
+	PaymentID     *int64          `json:"payment_id,omitempty"`
+	PostedAt      time.Time       `json:"posted_at"`
+	DebitPaise    int64           `json:"debit_paise"`
+	CreditPaise   int64           `json:"credit_paise"`
+	BalancePaise  int64           `json:"balance_paise"`
+}
+
+// Statement is a residence's account over [From, To). A positive balance is
+// owed by the residence, a negative one is credit in its favour.
+type Statement struct {
+	ResidenceID    int64           `json:"residence_id"`
+	From           time.Time       `json:"from"`
+	To             time.Time       `json:"to"`
+	OpeningPaise   int64           `json:"opening_balance_paise"`
+	ClosingPaise   int64           `json:"closing_balance_paise"`
+	Lines          []StatementLine `json:"lines"`
+	UnpaidInvoices []Invoice       `json:"unpaid_invoices"`
+}
@@ -0,0 +1,65 @@
+package model
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+type EventStatus string
+
+const (
+	EventScheduled EventStatus = "SCHEDULED"
+	EventOpen      EventStatus = "OPEN"
+	EventClosed    EventStatus = "CLOSED"
+)
+
+// EventEarlyEntry is how long before the start the gate starts checking
+// guests in against the list.
+const EventEarlyEntry = time.Hour
+
+type Event struct {
+	ID          int64       `json:"id"`
+	SocietyID   int64       `json:"society_id"`
+	ResidenceID *int64      `json:"residence_id,omitempty"`
+	AmenityID   *int64      `json:"amenity_id,omitempty"`
+	VenueName   *string     `json:"venue_name,omitempty"`
+	Title       string      `json:"title"`
+	StartsAt    time.Time   `json:"starts_at"`
+	EndsAt      time.Time   `json:"ends_at"`
+	Capacity    int         `json:"capacity"`
+	ClosedAt    *time.Time  `json:"closed_at,omitempty"`
+	Status      EventStatus `json:"status"`
+	CreatedBy   uuid.UUID   `json:"created_by"`
+	CreatedAt   time.Time   `json:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+
+	// Live counts, in people rather than guest list entries.
+	ExpectedCount int `json:"expected_count"`
+	ArrivedCount  int `json:"arrived_count"`
+}
+
+// StatusAt derives whether the gate checks guests in against the list.
+// Once an event closes, its guests go through normal visitor approval.
+func (e *Event) StatusAt(now time.Time) EventStatus {
+	switch {
+	case e.ClosedAt != nil, !now.Before(e.EndsAt):
+		return EventClosed
+	case now.Before(e.StartsAt.Add(-EventEarlyEntry)):
+		return EventScheduled
+	}
+	return EventOpen
+}
+
+type EventGuest struct {
+	ID          int64      `json:"id"`
+	EventID     int64      `json:"event_id"`
+	Name        string     `json:"name"`
+	Phone       *string    `json:"phone,omitempty"`
+	Code        string     `json:"code"`
+	PartySize   int        `json:"party_size"`
+	ArrivedAt   *time.Time `json:"arrived_at,omitempty"`
+	VisitID     *uuid.UUID `json:"visit_id,omitempty"`
+	CheckedInBy *uuid.UUID `json:"checked_in_by,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
@@ -0,0 +1,74 @@
+package model
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+type HeadcountSubject string
+
+const (
+	HeadcountVisit    HeadcountSubject = "VISIT"
+	HeadcountResident HeadcountSubject = "RESIDENT"
+	HeadcountStaff    HeadcountSubject = "STAFF"
+)
+
+type HeadcountCategory string
+
+const (
+	CategoryVisitor  HeadcountCategory = "VISITOR"
+	CategoryStaff    HeadcountCategory = "STAFF"
+	CategoryResident HeadcountCategory = "RESIDENT"
+)
+
+type HeadcountEvent struct {
+	ID               uuid.UUID  `json:"id"`
+	SocietyID        int64      `json:"society_id"`
+	Reason           *string    `json:"reason,omitempty"`
+	IncludeResidents bool       `json:"include_residents"`
+	StartedBy        uuid.UUID  `json:"started_by"`
+	StartedAt        time.Time  `json:"started_at"`
+	ClosedBy         *uuid.UUID `json:"closed_by,omitempty"`
+	ClosedAt         *time.Time `json:"closed_at,omitempty"`
+}
+
+// HeadcountEntry is one person believed to be on premises.
+type HeadcountEntry struct {
+	SubjectType     HeadcountSubject  `json:"subject_type"`
+	SubjectID       uuid.UUID         `json:"subject_id"`
+	Category        HeadcountCategory `json:"category"`
+	Name            string            `json:"name"`
+	Phone           *string           `json:"phone,omitempty"`
+	VisitorType     *VisitorType      `json:"visitor_type,omitempty"`
+	BlockID         *int64            `json:"block_id,omitempty"`
+	BlockName       *string           `json:"block_name,omitempty"`
+	ResidenceID     *int64            `json:"residence_id,omitempty"`
+	ResidenceNumber *string           `json:"residence_number,omitempty"`
+	GateID          *int64            `json:"gate_id,omitempty"`
+	GateName        *string           `json:"gate_name,omitempty"`
+	Since           time.Time         `json:"since"`
+	AccountedAt     *time.Time        `json:"accounted_at,omitempty"`
+	AccountedBy     *uuid.UUID        `json:"accounted_by,omitempty"`
+}
+
+// StaffShift is a society staff member's time on premises, from punching
+// in to punching out.
+type StaffShift struct {
+	ID        int64      `json:"id"`
+	SocietyID int64      `json:"society_id"`
+	UserID    uuid.UUID  `json:"user_id"`
+	GateID    *int64     `json:"gate_id,omitempty"`
+	StartedAt time.Time  `json:"started_at"`
+	EndedAt   *time.Time `json:"ended_at,omitempty"`
+}
+
+type HeadcountGroup struct {
+	BlockID   *int64           `json:"block_id,omitempty"`
+	BlockName *string          `json:"block_name,omitempty"`
+	GateID    *int64           `json:"gate_id,omitempty"`
+	GateName  *string          `json:"gate_name,omitempty"`
+	Total     int              `json:"total"`
+	Accounted int              `json:"accounted"`
+	Entries   []HeadcountEntry `json:"entries"`
+}
@@ -0,0 +1,85 @@
+package model
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+type GuestInviteStatus string
+
+const (
+	InviteScheduled GuestInviteStatus = "SCHEDULED"
+	InviteActive    GuestInviteStatus = "ACTIVE"
+	InviteFull      GuestInviteStatus = "FULL"
+	InviteExpired   GuestInviteStatus = "EXPIRED"
+	InviteRevoked   GuestInviteStatus = "REVOKED"
+)
+
+type GuestInvite struct {
+	ID          int64             `json:"id"`
+	Token       string            `json:"token"`
+	SocietyID   int64             `json:"society_id"`
+	ResidenceID int64             `json:"residence_id"`
+	Title       string            `json:"title"`
+	MaxUses     int               `json:"max_uses"`
+	UseCount    int               `json:"use_count"`
+	ValidFrom   time.Time         `json:"valid_from"`
+	ValidUntil  time.Time         `json:"valid_until"`
+	RevokedAt   *time.Time        `json:"revoked_at,omitempty"`
+	Status      GuestInviteStatus `json:"status"`
+	CreatedBy   uuid.UUID         `json:"created_by"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+
+	Registrations []GuestRegistration `json:"registrations,omitempty"`
+}
+
+// StatusAt derives whether guests can register through the invite.
+func (i *GuestInvite) StatusAt(now time.Time) GuestInviteStatus {
+	switch {
+	case i.RevokedAt != nil:
+		return InviteRevoked
+	case !now.Before(i.ValidUntil):
+		return InviteExpired
+	case i.UseCount >= i.MaxUses:
+		return InviteFull
+	case now.Before(i.ValidFrom):
+		return InviteScheduled
+	}
+	return InviteActive
+}
+
+// PublicGuestInvite is what a guest opening the link is shown.
+type PublicGuestInvite struct {
+	Title       string            `json:"title"`
+	SocietyName string            `json:"society_name"`
+	HostName    *string           `json:"host_name,omitempty"`
+	ValidFrom   time.Time         `json:"valid_from"`
+	ValidUntil  time.Time         `json:"valid_until"`
+	Status      GuestInviteStatus `json:"status"`
+}
+
+type GuestRegistration struct {
+	ID           int64      `json:"id"`
+	InviteID     int64      `json:"invite_id"`
+	VisitorID    uuid.UUID  `json:"visitor_id"`
+	Name         string     `json:"name"`
+	Phone        string     `json:"phone"`
+	PhotoURL     *string    `json:"photo_url,omitempty"`
+	PassCode     string     `json:"pass_code"`
+	RegisteredAt time.Time  `json:"registered_at"`
+	ArrivedAt    *time.Time `json:"arrived_at,omitempty"`
+	VisitID      *uuid.UUID `json:"visit_id,omitempty"`
+}
+
+// GuestPass is a registration as the gate sees it: who the guest is, whom
+// they are visiting and whether the pass is usable now.
+type GuestPass struct {
+	GuestRegistration
+	ResidenceID int64             `json:"residence_id"`
+	InviteTitle string            `json:"invite_title"`
+	ValidFrom   time.Time         `json:"valid_from"`
+	ValidUntil  time.Time         `json:"valid_until"`
+	Status      GuestInviteStatus `json:"invite_status"`
+}
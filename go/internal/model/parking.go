@@ -0,0 +1,44 @@
+package model
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+type ParkingSlot struct {
+	ID          int64       `json:"id"`
+	SocietyID   int64       `json:"society_id"`
+	BlockID     int64       `json:"block_id"`
+	Code        string      `json:"code"`
+	VehicleType VehicleType `json:"vehicle_type"`
+	ResidenceID *int64      `json:"residence_id,omitempty"`
+	IsGuest     bool        `json:"is_guest"`
+	IsActive    bool        `json:"is_active"`
+	CreatedAt   time.Time   `json:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+}
+
+type ParkingBookingStatus string
+
+const (
+	BookingBooked    ParkingBookingStatus = "BOOKED"
+	BookingCheckedIn ParkingBookingStatus = "CHECKED_IN"
+	BookingCompleted ParkingBookingStatus = "COMPLETED"
+	BookingCancelled ParkingBookingStatus = "CANCELLED"
+)
+
+type GuestParkingBooking struct {
+	ID          int64                `json:"id"`
+	SlotID      int64                `json:"slot_id"`
+	SlotCode    string               `json:"slot_code"`
+	ResidenceID *int64               `json:"residence_id,omitempty"`
+	VisitorID   *uuid.UUID           `json:"visitor_id,omitempty"`
+	VisitID     *uuid.UUID           `json:"visit_id,omitempty"`
+	StartsAt    time.Time            `json:"starts_at"`
+	EndsAt      time.Time            `json:"ends_at"`
+	Status      ParkingBookingStatus `json:"status"`
+	BookedBy    uuid.UUID            `json:"booked_by"`
+	CreatedAt   time.Time            `json:"created_at"`
+	UpdatedAt   time.Time            `json:"updated_at"`
+}
@@ -0,0 +1,118 @@
+package model
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+type PollEligibility string
+
+const (
+	PollOwners       PollEligibility = "OWNERS"
+	PollAllResidents PollEligibility = "ALL_RESIDENTS"
+)
+
+func (e PollEligibility) Valid() bool {
+	return e == PollOwners || e == PollAllResidents
+}
+
+// Allows reports whether a residence member with the given membership role
+// may vote.
+func (e PollEligibility) Allows(role UserRole) bool {
+	if e == PollOwners {
+		return role == RoleOwner
+	}
+	return role == RoleOwner || role == RoleResident
+}
+
+type PollStatus string
+
+const (
+	PollScheduled PollStatus = "SCHEDULED"
+	PollOpen      PollStatus = "OPEN"
+	PollClosed    PollStatus = "CLOSED"
+	PollCancelled PollStatus = "CANCELLED"
+)
+
+// Digest is a SHA-256 hash, hex encoded in JSON.
+type Digest []byte
+
+func (d Digest) String() string {
+	return hex.EncodeToString(d)
+}
+
+func (d Digest) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+type Poll struct {
+	ID          int64           `json:"id"`
+	SocietyID   int64           `json:"society_id"`
+	Title       string          `json:"title"`
+	Description *string         `json:"description,omitempty"`
+	Eligibility PollEligibility `json:"eligibility"`
+	IsAnonymous bool            `json:"is_anonymous"`
+	OpensAt     time.Time       `json:"opens_at"`
+	ClosesAt    time.Time       `json:"closes_at"`
+	CancelledAt *time.Time      `json:"cancelled_at,omitempty"`
+	Status      PollStatus      `json:"status"`
+	BallotCount int             `json:"ballot_count"`
+	HeadHash    Digest          `json:"head_hash"`
+	// SealedAt is when the final head was published to the society's
+	// voters, after the poll closed.
+	SealedAt  *time.Time `json:"sealed_at,omitempty"`
+	CreatedBy uuid.UUID  `json:"created_by"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+
+	Options []PollOption `json:"options"`
+}
+
+// StatusAt derives the poll's status from its window.
+func (p *Poll) StatusAt(now time.Time) PollStatus {
+	switch {
+	case p.CancelledAt != nil:
+		return PollCancelled
+	case now.Before(p.OpensAt):
+		return PollScheduled
+	case now.Before(p.ClosesAt):
+		return PollOpen
+	}
+	return PollClosed
+}
+
+type PollOption struct {
+	ID       int64  `json:"id"`
+	PollID   int64  `json:"poll_id"`
+	Label    string `json:"label"`
+	Position int    `json:"position"`
+}
+
+type PollBallot struct {
+	PollID      int64      `json:"poll_id"`
+	Seq         int        `json:"seq"`
+	OptionID    int64      `json:"option_id"`
+	ResidenceID *int64     `json:"residence_id,omitempty"`
+	VoterID     *uuid.UUID `json:"voter_id,omitempty"`
+	CastAt      *time.Time `json:"cast_at,omitempty"`
+	PrevHash    Digest     `json:"prev_hash"`
+	Hash        Digest     `json:"hash"`
+}
+
+type PollOptionResult struct {
+	OptionID int64  `json:"option_id"`
+	Label    string `json:"label"`
+	Votes    int    `json:"votes"`
+}
+
+type PollResults struct {
+	PollID             int64              `json:"poll_id"`
+	Status             PollStatus         `json:"status"`
+	Options            []PollOptionResult `json:"options"`
+	BallotCount        int                `json:"ballot_count"`
+	EligibleResidences int                `json:"eligible_residences"`
+	HeadHash           Digest             `json:"head_hash"`
+}
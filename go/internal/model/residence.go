@@ -12,6 +12,7 @@ type Society struct {
 	CityID    int64     `json:"city_id"`
 	Name      string    `json:"name"`
 	Address   string    `json:"address"`
+	Timezone  string    `json:"timezone"`
 	CreatedAt time.Time `json:"created_at"`
 }
 
@@ -27,3 +28,10 @@ type Residence struct {
 	Number  string `json:"number"`
 	Floor   int    `json:"floor"`
 }
+
+type Gate struct {
+	ID        int64     `json:"id"`
+	SocietyID int64     `json:"society_id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
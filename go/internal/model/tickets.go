@@ -0,0 +1,165 @@
+package model
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+type TicketCategory string
+
+const (
+	TicketPlumbing     TicketCategory = "PLUMBING"
+	TicketElectrical   TicketCategory = "ELECTRICAL"
+	TicketLift         TicketCategory = "LIFT"
+	TicketCarpentry    TicketCategory = "CARPENTRY"
+	TicketHousekeeping TicketCategory = "HOUSEKEEPING"
+	TicketSecurity     TicketCategory = "SECURITY"
+	TicketParking      TicketCategory = "PARKING"
+	TicketOther        TicketCategory = "OTHER"
+)
+
+func (c TicketCategory) Valid() bool {
+	switch c {
+	case TicketPlumbing, TicketElectrical, TicketLift, TicketCarpentry,
+		TicketHousekeeping, TicketSecurity, TicketParking, TicketOther:
+		return true
+	}
+	return false
+}
+
+type TicketPriority string
+
+const (
+	TicketLow    TicketPriority = "LOW"
+	TicketMedium TicketPriority = "MEDIUM"
+	TicketHigh   TicketPriority = "HIGH"
+	TicketUrgent TicketPriority = "URGENT"
+)
+
+func (p TicketPriority) Valid() bool {
+	switch p {
+	case TicketLow, TicketMedium, TicketHigh, TicketUrgent:
+		return true
+	}
+	return false
+}
+
+type TicketStatus string
+
+const (
+	TicketOpen       TicketStatus = "OPEN"
+	TicketAssigned   TicketStatus = "ASSIGNED"
+	TicketInProgress TicketStatus = "IN_PROGRESS"
+	TicketOnHold     TicketStatus = "ON_HOLD"
+	TicketResolved   TicketStatus = "RESOLVED"
+	TicketClosed     TicketStatus = "CLOSED"
+)
+
+// ticketTransitions is the helpdesk workflow. Assignment moves a ticket to
+// ASSIGNED and is allowed from any non-final state; closing is done by the
+// resident, or by staff for withdrawn or stale tickets.
+var ticketTransitions = map[TicketStatus][]TicketStatus{
+	TicketOpen:       {TicketAssigned, TicketClosed},
+	TicketAssigned:   {TicketAssigned, TicketInProgress, TicketOnHold, TicketResolved, TicketClosed},
+	TicketInProgress: {TicketAssigned, TicketOnHold, TicketResolved},
+	TicketOnHold:     {TicketAssigned, TicketInProgress, TicketResolved},
+	// Reopening sends a resolved ticket back to whoever was working it.
+	TicketResolved: {TicketInProgress, TicketClosed},
+}
+
+func (s TicketStatus) Valid() bool {
+	_, ok := ticketTransitions[s]
+	return ok || s == TicketClosed
+}
+
+func (s TicketStatus) CanMoveTo(next TicketStatus) bool {
+	for _, t := range ticketTransitions[s] {
+		if t == next {
+			return true
+		}
+	}
+	return false
+}
+
+type Ticket struct {
+	ID                   int64          `json:"id"`
+	SocietyID            int64          `json:"society_id"`
+	ResidenceID          *int64         `json:"residence_id,omitempty"`
+	CommonArea           *string        `json:"common_area,omitempty"`
+	Category             TicketCategory `json:"category"`
+	Priority             TicketPriority `json:"priority"`
+	Title                string         `json:"title"`
+	Description          *string        `json:"description,omitempty"`
+	Status               TicketStatus   `json:"status"`
+	RaisedBy             uuid.UUID      `json:"raised_by"`
+	AssignedTo           *uuid.UUID     `json:"assigned_to,omitempty"`
+	RespondBy            time.Time      `json:"respond_by"`
+	ResolveBy            time.Time      `json:"resolve_by"`
+	FirstResponseAt      *time.Time     `json:"first_response_at,omitempty"`
+	ResolvedAt           *time.Time     `json:"resolved_at,omitempty"`
+	ClosedAt             *time.Time     `json:"closed_at,omitempty"`
+	ResponseBreachedAt   *time.Time     `json:"response_breached_at,omitempty"`
+	ResolutionBreachedAt *time.Time     `json:"resolution_breached_at,omitempty"`
+	HeldSince            *time.Time     `json:"held_since,omitempty"`
+	Rating               *int           `json:"rating,omitempty"`
+	RatingComment        *string        `json:"rating_comment,omitempty"`
+	CreatedAt            time.Time      `json:"created_at"`
+	UpdatedAt            time.Time      `json:"updated_at"`
+
+	Photos []TicketPhoto `json:"photos,omitempty"`
+}
+
+type TicketPhoto struct {
+	ID         int64     `json:"id"`
+	TicketID   int64     `json:"ticket_id"`
+	URL        string    `json:"url"`
+	UploadedBy uuid.UUID `json:"uploaded_by"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type TicketComment struct {
+	ID         int64     `json:"id"`
+	TicketID   int64     `json:"ticket_id"`
+	AuthorID   uuid.UUID `json:"author_id"`
+	AuthorName string    `json:"author_name"`
+	Body       string    `json:"body"`
+	IsInternal bool      `json:"is_internal"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type TicketEvent struct {
+	ID         int64         `json:"id"`
+	TicketID   int64         `json:"ticket_id"`
+	FromStatus *TicketStatus `json:"from_status,omitempty"`
+	ToStatus   TicketStatus  `json:"to_status"`
+	ActorID    *uuid.UUID    `json:"actor_id,omitempty"`
+	Note       *string       `json:"note,omitempty"`
+	CreatedAt  time.Time     `json:"created_at"`
+}
+
+type TicketSLAPolicy struct {
+	ID                int64          `json:"id"`
+	SocietyID         *int64         `json:"society_id,omitempty"`
+	Priority          TicketPriority `json:"priority"`
+	ResponseMinutes   int            `json:"response_minutes"`
+	ResolutionMinutes int            `json:"resolution_minutes"`
+	UpdatedAt         time.Time      `json:"updated_at"`
+}
+
+// Kinds of SLA deadline.
+const (
+	BreachResponse   = "RESPONSE"
+	BreachResolution = "RESOLUTION"
+)
+
+// TicketBreach is an SLA deadline a ticket has just missed.
+type TicketBreach struct {
+	TicketID  int64          `json:"ticket_id"`
+	SocietyID int64          `json:"society_id"`
+	Title     string         `json:"title"`
+	Priority  TicketPriority `json:"priority"`
+	// RESPONSE or RESOLUTION.
+	Kind       string     `json:"kind"`
+	AssignedTo *uuid.UUID `json:"assigned_to,omitempty"`
+}
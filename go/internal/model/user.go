@@ -16,17 +16,46 @@ const (
 	RoleResident       UserRole = "RESIDENT"
 )
 
+func (r UserRole) Valid() bool {
+	switch r {
+	case RoleAdmin, RoleSocietyManager, RoleSecurity, RoleOwner, RoleResident:
+		return true
+	}
+	return false
+}
+
 type User struct {
-	ID          uuid.UUID  `json:"id"`
-	AccessCode  string     `json:"access_code,omitempty"`
-	Role        UserRole   `json:"role"`
-	Name        string     `json:"name"`
-	ResidenceID int64      `json:"residence_id,omitempty"`
-	SocietyID   int64      `json:"society_id,omitempty"`
-	IsActive    bool       `json:"is_active"`
-	DeviceID    string     `json:"device_id"`
-	ActivatedBy uuid.UUID  `json:"activated_by,omitempty"`
-	ActivatedAt *time.Time `json:"activated_at,omitempty"`
-	CreatedAt   time.Time  `json:"created_at"`
-	UpdatedAt   time.Time  `json:"updated_at"`
+	ID          uuid.UUID    `json:"id"`
+	AccessCode  string       `json:"access_code,omitempty"`
+	Role        UserRole     `json:"role"`
+	Name        string       `json:"name"`
+	ResidenceID int64        `json:"residence_id,omitempty"`
+	SocietyID   int64        `json:"society_id,omitempty"`
+	IsActive    bool         `json:"is_active"`
+	Memberships []Membership `json:"memberships,omitempty"`
+	ActivatedBy uuid.UUID    `json:"activated_by,omitempty"`
+	ActivatedAt *time.Time   `json:"activated_at,omitempty"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+}
+
+// Membership ties a user to one residence with a role for that residence.
+type Membership struct {
+	ResidenceID        int64      `json:"residence_id"`
+	SocietyID          int64      `json:"society_id"`
+	Role               UserRole   `json:"role"`
+	CanApproveVisitors bool       `json:"can_approve_visitors"`
+	CanCreatePasses    bool       `json:"can_create_passes"`
+	IsTenant           bool       `json:"is_tenant"`
+	LeaseEnd           *time.Time `json:"lease_end,omitempty"`
+}
+
+type Device struct {
+	ID         int64      `json:"id"`
+	UserID     uuid.UUID  `json:"user_id"`
+	DeviceID   string     `json:"device_id"`
+	Name       *string    `json:"name,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt *time.Time `json:"last_seen_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
 }
@@ -0,0 +1,70 @@
+package model
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+type VehicleType string
+
+const (
+	VehicleCar   VehicleType = "CAR"
+	VehicleBike  VehicleType = "BIKE"
+	VehicleOther VehicleType = "OTHER"
+)
+
+func (t VehicleType) Valid() bool {
+	switch t {
+	case VehicleCar, VehicleBike, VehicleOther:
+		return true
+	}
+	return false
+}
+
+type VehicleCategory string
+
+const (
+	VehicleResident VehicleCategory = "RESIDENT"
+	VehicleGuest    VehicleCategory = "GUEST"
+	VehicleUnknown  VehicleCategory = "UNKNOWN"
+)
+
+type Vehicle struct {
+	ID            int64       `json:"id"`
+	ResidenceID   int64       `json:"residence_id"`
+	Plate         string      `json:"plate"`
+	Type          VehicleType `json:"type"`
+	MakeModel     *string     `json:"make_model,omitempty"`
+	Color         *string     `json:"color,omitempty"`
+	ParkingSlotID *int64      `json:"parking_slot_id,omitempty"`
+	IsActive      bool        `json:"is_active"`
+	CreatedBy     uuid.UUID   `json:"created_by"`
+	CreatedAt     time.Time   `json:"created_at"`
+	UpdatedAt     time.Time   `json:"updated_at"`
+}
+
+type VehicleEntry struct {
+	ID            int64           `json:"id"`
+	SocietyID     int64           `json:"society_id"`
+	Plate         string          `json:"plate"`
+	Type          VehicleType     `json:"type"`
+	Category      VehicleCategory `json:"category"`
+	VehicleID     *int64          `json:"vehicle_id,omitempty"`
+	VisitID       *uuid.UUID      `json:"visit_id,omitempty"`
+	GateID        *int64          `json:"gate_id,omitempty"`
+	EnteredAt     time.Time       `json:"entered_at"`
+	EntryLoggedBy uuid.UUID       `json:"entry_logged_by"`
+	ExitedAt      *time.Time      `json:"exited_at,omitempty"`
+	ExitGateID    *int64          `json:"exit_gate_id,omitempty"`
+	ExitLoggedBy  *uuid.UUID      `json:"exit_logged_by,omitempty"`
+}
+
+type ParkingOccupancy struct {
+	SocietyID  int64                                   `json:"society_id"`
+	Inside     int                                     `json:"inside"`
+	ByCategory map[VehicleCategory]int                 `json:"by_category"`
+	ByType     map[VehicleType]int                     `json:"by_type"`
+	Breakdown  map[VehicleCategory]map[VehicleType]int `json:"breakdown"`
+	Registered int                                     `json:"registered"`
+}
@@ -9,14 +9,20 @@ import (
 type Visit struct {
 	ID           uuid.UUID  `json:"id"`
 	ResidenceID  *int64     `json:"residence_id,omitempty"`
-	VisitorID    int64      `json:"visitor_id"`
+	GateID       *int64     `json:"gate_id,omitempty"`
+	TicketID     *int64     `json:"ticket_id,omitempty"`
+	VisitorID    uuid.UUID  `json:"visitor_id"`
 	CheckedInBy  uuid.UUID  `json:"checked_in_by"`
 	ApprovedBy   uuid.UUID  `json:"approved_by,omitempty"`
+	ApprovedAt   *time.Time `json:"approved_at,omitempty"`
 	CheckInTime  time.Time  `json:"check_in_time"`
 	CheckOutTime *time.Time `json:"check_out_time,omitempty"`
 	Purpose      string     `json:"purpose,omitempty"`
 	CreatedAt    time.Time  `json:"created_at"`
 	UpdatedAt    time.Time  `json:"updated_at"`
+
+	// Guest slot given to the visitor's vehicle at check-in, if any.
+	GuestParking *GuestParkingBooking `json:"guest_parking,omitempty"`
 }
 
 type VisitorType string
@@ -50,6 +56,7 @@ type VisitWithVisitor struct {
 	CheckInTime  time.Time  `json:"check_in_time"`
 	CheckOutTime *time.Time `json:"check_out_time,omitempty"`
 	Purpose      *string    `json:"purpose,omitempty"`
+	TicketID     *int64     `json:"ticket_id,omitempty"`
 	CreatedAt    time.Time  `json:"created_at"`
 	UpdatedAt    time.Time  `json:"updated_at"`
 
@@ -58,3 +65,33 @@ type VisitWithVisitor struct {
 	PhotoURL *string     `json:"photo_url,omitempty"`
 	Type     VisitorType `json:"visitor_type"`
 }
+
+type StayLimit struct {
+	ID             int64       `json:"id"`
+	SocietyID      *int64      `json:"society_id,omitempty"`
+	VisitorType    VisitorType `json:"visitor_type"`
+	MaxStayMinutes int         `json:"max_stay_minutes"`
+	UpdatedAt      time.Time   `json:"updated_at"`
+}
+
+type OverstayVisit struct {
+	VisitID            uuid.UUID   `json:"visit_id"`
+	ResidenceID        *int64      `json:"residence_id,omitempty"`
+	SocietyID          *int64      `json:"society_id,omitempty"`
+	CheckedInBy        uuid.UUID   `json:"checked_in_by"`
+	CheckInTime        time.Time   `json:"check_in_time"`
+	MaxStayMinutes     int         `json:"max_stay_minutes"`
+	OverstayMinutes    int         `json:"overstay_minutes"`
+	OverstayNotifiedAt *time.Time  `json:"overstay_notified_at,omitempty"`
+	Name               string      `json:"name"`
+	Phone              string      `json:"phone"`
+	Type               VisitorType `json:"visitor_type"`
+}
+
+func (t VisitorType) Valid() bool {
+	switch t {
+	case VisitorDelivery, VisitorMaintenance, VisitorGuest, VisitorCab, VisitorStaff:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,53 @@
+package notify
+
+import (
+	"context"
+	"log/slog"
+)
+
+type Priority string
+
+const (
+	PriorityNormal   Priority = "NORMAL"
+	PriorityHigh     Priority = "HIGH"
+	PriorityCritical Priority = "CRITICAL"
+)
+
+type Message struct {
+	UserIDs  []string          `json:"user_ids"`
+	Kind     string            `json:"kind"`
+	Title    string            `json:"title"`
+	Body     string            `json:"body"`
+	Priority Priority          `json:"priority"`
+	Data     map[string]string `json:"data,omitempty"`
+}
+
+// Notifier delivers a message to a set of users. Implementations decide the
+// channel (push, SMS, ...); callers only pick the recipients.
+type Notifier interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// LogNotifier writes messages to the log instead of delivering them. It is the
+// default until a real push provider is configured.
+type LogNotifier struct {
+	log *slog.Logger
+}
+
+func NewLogNotifier(log *slog.Logger) *LogNotifier {
+	return &LogNotifier{log: log}
+}
+
+func (n *LogNotifier) Send(ctx context.Context, msg Message) error {
+	if len(msg.UserIDs) == 0 {
+		return nil
+	}
+
+	n.log.InfoContext(ctx, "notification",
+		"kind", msg.Kind,
+		"priority", msg.Priority,
+		"title", msg.Title,
+		"recipients", len(msg.UserIDs),
+	)
+	return nil
+}
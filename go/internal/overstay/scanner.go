@@ -0,0 +1,100 @@
+package overstay
+
+import (
+	"context"
+	"dooreye-backend/internal/model"
+	"dooreye-backend/internal/notify"
+	"dooreye-backend/internal/store"
+	"fmt"
+	"log/slog"
+)
+
+// Scanner looks for visits that have outstayed the limit for their visitor
+// type and alerts the host residence and the society's guards. Only the
+// instance holding the advisory lock scans, so running several API instances
+// does not produce duplicate alerts.
+type Scanner struct {
+	db       *store.DB
+	notifier notify.Notifier
+	log      *slog.Logger
+}
+
+func NewScanner(db *store.DB, notifier notify.Notifier, log *slog.Logger) *Scanner {
+	return &Scanner{
+		db:       db,
+		notifier: notifier,
+		log:      log.With("component", "overstay_scanner"),
+	}
+}
+
+// Job is the periodic job entry point. It scans only if it can take the
+// scanner lock and skips the run otherwise.
+func (s *Scanner) Job(ctx context.Context, _ *model.Job) error {
+	lock, err := s.db.TryAdvisoryLock(ctx, store.LockOverstayScanner)
+	if err != nil {
+		return err
+	}
+	if lock == nil {
+		s.log.Debug("overstay scan already running elsewhere")
+		return nil
+	}
+	defer func() {
+		if err := lock.Release(context.Background()); err != nil {
+			s.log.WarnContext(ctx, "releasing overstay scanner lock", "error", err)
+		}
+	}()
+
+	return s.Scan(ctx)
+}
+
+// Scan alerts on every overstaying visit that has not been alerted yet.
+func (s *Scanner) Scan(ctx context.Context) error {
+	visits, err := s.db.GetOverstayingVisits(ctx, store.OverstayFilter{OnlyUnnotified: true})
+	if err != nil {
+		return err
+	}
+
+	for _, v := range visits {
+		if err := s.alert(ctx, v); err != nil {
+			s.log.ErrorContext(ctx, "alerting overstay", "visit_id", v.VisitID, "error", err)
+			continue
+		}
+
+		if err := s.db.MarkOverstayNotified(ctx, v.VisitID.String()); err != nil {
+			s.log.ErrorContext(ctx, "marking overstay notified", "visit_id", v.VisitID, "error", err)
+		}
+	}
+
+	return nil
+}
+
+func (s *Scanner) alert(ctx context.Context, v model.OverstayVisit) error {
+	var recipients []string
+
+	if v.ResidenceID != nil {
+		ids, err := s.db.GetResidenceUserIDs(ctx, *v.ResidenceID)
+		if err != nil {
+			return err
+		}
+		recipients = append(recipients, ids...)
+	}
+
+	if v.SocietyID != nil {
+		ids, err := s.db.GetSocietyUserIDsByRole(ctx, *v.SocietyID, model.RoleSecurity)
+		if err != nil {
+			return err
+		}
+		recipients = append(recipients, ids...)
+	}
+
+	return s.notifier.Send(ctx, notify.Message{
+		UserIDs:  recipients,
+		Kind:     "VISIT_OVERSTAY",
+		Title:    "Visitor overstaying",
+		Body:     fmt.Sprintf("%s (%s) has been inside %d minutes past the allowed stay", v.Name, v.Type, v.OverstayMinutes),
+		Priority: notify.PriorityHigh,
+		Data: map[string]string{
+			"visit_id": v.VisitID.String(),
+		},
+	})
+}
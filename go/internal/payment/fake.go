@@ -0,0 +1,129 @@
+package payment
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"dooreye-backend/internal/model"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FakeSignatureHeader carries the fake provider's webhook signature as
+// "t=<unix seconds>,v1=<hex HMAC-SHA256 of "<t>.<body>">".
+const FakeSignatureHeader = "X-Fake-Signature"
+
+// webhookTolerance bounds how old a signed webhook may be, so a captured
+// request cannot be replayed later.
+const webhookTolerance = 5 * time.Minute
+
+// checkoutTTL is how long a fake checkout stays payable.
+const checkoutTTL = 30 * time.Minute
+
+// Fake is a provider for development and tests. Checkouts succeed without
+// any payment; the outcome is reported by posting a webhook signed with
+// the shared secret, which SignWebhook produces.
+type Fake struct {
+	secret []byte
+	now    func() time.Time
+}
+
+func NewFake(secret string) *Fake {
+	return &Fake{secret: []byte(secret), now: time.Now}
+}
+
+func (f *Fake) Name() string {
+	return "fake"
+}
+
+func (f *Fake) CreateCheckout(ctx context.Context, checkout Checkout) (*Session, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("generating payment reference: %w", err)
+	}
+
+	return &Session{
+		Provider:  f.Name(),
+		Reference: "fake_" + hex.EncodeToString(b),
+		ExpiresAt: f.now().Add(checkoutTTL),
+	}, nil
+}
+
+// FakeWebhook is the fake provider's webhook body.
+type FakeWebhook struct {
+	EventID       string              `json:"event_id"`
+	Reference     string              `json:"reference"`
+	Status        model.PaymentStatus `json:"status"`
+	AmountPaise   int64               `json:"amount_paise"`
+	FailureReason string              `json:"failure_reason,omitempty"`
+}
+
+func (f *Fake) ParseWebhook(header http.Header, body []byte) (*Event, error) {
+	if err := f.verify(header.Get(FakeSignatureHeader), body); err != nil {
+		return nil, err
+	}
+
+	var w FakeWebhook
+	if err := json.Unmarshal(body, &w); err != nil {
+		return nil, fmt.Errorf("decoding webhook: %w", err)
+	}
+	if w.Status != model.PaymentSucceeded && w.Status != model.PaymentFailed {
+		return nil, fmt.Errorf("unexpected payment status %q", w.Status)
+	}
+
+	return &Event{
+		ID:            w.EventID,
+		Reference:     w.Reference,
+		Status:        w.Status,
+		AmountPaise:   w.AmountPaise,
+		FailureReason: w.FailureReason,
+	}, nil
+}
+
+// SignWebhook returns the signature header value for body, signed at t.
+func (f *Fake) SignWebhook(body []byte, t time.Time) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return "t=" + ts + ",v1=" + hex.EncodeToString(f.mac(ts, body))
+}
+
+func (f *Fake) verify(signature string, body []byte) error {
+	var ts, sig string
+	for _, part := range strings.Split(signature, ",") {
+		k, v, _ := strings.Cut(part, "=")
+		switch k {
+		case "t":
+			ts = v
+		case "v1":
+			sig = v
+		}
+	}
+
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if age := f.now().Sub(time.Unix(unix, 0)); age > webhookTolerance || age < -webhookTolerance {
+		return ErrInvalidSignature
+	}
+
+	got, err := hex.DecodeString(sig)
+	if err != nil || !hmac.Equal(got, f.mac(ts, body)) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+func (f *Fake) mac(ts string, body []byte) []byte {
+	m := hmac.New(sha256.New, f.secret)
+	m.Write([]byte(ts))
+	m.Write([]byte("."))
+	m.Write(body)
+	return m.Sum(nil)
+}
@@ -0,0 +1,77 @@
+package payment
+
+import (
+	"dooreye-backend/internal/model"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFakeVerify(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	f := NewFake("secret")
+	f.now = func() time.Time { return now }
+
+	body := []byte(`{"event_id":"evt_1","reference":"fake_1","status":"SUCCEEDED","amount_paise":150000}`)
+	valid := f.SignWebhook(body, now)
+	ts, _, _ := strings.Cut(valid, ",")
+
+	tests := []struct {
+		name      string
+		signature string
+		body      []byte
+		ok        bool
+	}{
+		{"valid", valid, body, true},
+		{"within tolerance", f.SignWebhook(body, now.Add(-webhookTolerance)), body, true},
+		{"stale", f.SignWebhook(body, now.Add(-webhookTolerance-time.Second)), body, false},
+		{"future", f.SignWebhook(body, now.Add(webhookTolerance+time.Second)), body, false},
+		{"tampered body", valid, []byte(strings.Replace(string(body), "150000", "1", 1)), false},
+		{"other secret", NewFake("other").SignWebhook(body, now), body, false},
+		{"bad hex", ts + ",v1=zz", body, false},
+		{"no signature", ts, body, false},
+		{"no timestamp", strings.TrimPrefix(valid, ts+","), body, false},
+		{"bad timestamp", "t=soon," + strings.SplitN(valid, ",", 2)[1], body, false},
+		{"empty", "", body, false},
+	}
+
+	for _, tt := range tests {
+		err := f.verify(tt.signature, tt.body)
+		if tt.ok && err != nil {
+			t.Errorf("%s: verify = %v, want nil", tt.name, err)
+		}
+		if !tt.ok && !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("%s: verify = %v, want ErrInvalidSignature", tt.name, err)
+		}
+	}
+}
+
+func TestFakeParseWebhook(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	f := NewFake("secret")
+	f.now = func() time.Time { return now }
+
+	parse := func(body string) (*Event, error) {
+		header := http.Header{}
+		header.Set(FakeSignatureHeader, f.SignWebhook([]byte(body), now))
+		return f.ParseWebhook(header, []byte(body))
+	}
+
+	ev, err := parse(`{"event_id":"evt_1","reference":"fake_1","status":"FAILED","amount_paise":100,"failure_reason":"declined"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Event{ID: "evt_1", Reference: "fake_1", Status: model.PaymentFailed, AmountPaise: 100, FailureReason: "declined"}
+	if *ev != want {
+		t.Errorf("ParseWebhook = %+v, want %+v", *ev, want)
+	}
+
+	if _, err := parse(`{"event_id":"evt_2","reference":"fake_1","status":"PENDING"}`); err == nil {
+		t.Error("ParseWebhook accepted a PENDING status")
+	}
+	if _, err := parse(`not json`); err == nil {
+		t.Error("ParseWebhook accepted a malformed body")
+	}
+}
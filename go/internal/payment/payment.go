@@ -0,0 +1,86 @@
+// Package payment abstracts the payment gateways residents pay dues
+// through. A provider starts a checkout for a payment and later reports its
+// outcome through a signed webhook.
+package payment
+
+import (
+	"context"
+	"dooreye-backend/internal/model"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+)
+
+var (
+	ErrUnknownProvider  = errors.New("unknown payment provider")
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+)
+
+// Checkout describes the payment a provider is asked to collect.
+type Checkout struct {
+	SocietyID   int64
+	ResidenceID int64
+	AmountPaise int64
+	Description string
+}
+
+// Session is the provider's side of a started checkout. Reference is the
+// provider's id for the payment; its webhooks refer back to it.
+type Session struct {
+	Provider    string    `json:"provider"`
+	Reference   string    `json:"reference"`
+	RedirectURL string    `json:"redirect_url,omitempty"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// Event is a verified webhook: the final outcome of a payment.
+type Event struct {
+	ID            string
+	Reference     string
+	Status        model.PaymentStatus
+	AmountPaise   int64
+	FailureReason string
+}
+
+// Provider is a payment gateway.
+type Provider interface {
+	Name() string
+	CreateCheckout(ctx context.Context, checkout Checkout) (*Session, error)
+	// ParseWebhook verifies a webhook request and decodes its event. It
+	// returns ErrInvalidSignature when the request was not signed by the
+	// provider.
+	ParseWebhook(header http.Header, body []byte) (*Event, error)
+}
+
+// Registry holds the configured providers by name.
+type Registry struct {
+	providers map[string]Provider
+}
+
+func NewRegistry(providers ...Provider) *Registry {
+	r := &Registry{providers: make(map[string]Provider, len(providers))}
+	for _, p := range providers {
+		r.providers[p.Name()] = p
+	}
+	return r
+}
+
+func (r *Registry) Get(name string) (Provider, error) {
+	p, ok := r.providers[name]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownProvider, name)
+	}
+	return p, nil
+}
+
+// Names lists the configured providers, sorted.
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.providers))
+	for name := range r.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,71 @@
+// Package plate normalizes Indian vehicle registration numbers so the same
+// plate typed by different guards compares equal.
+package plate
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+var ErrInvalidPlate = errors.New("invalid registration number")
+
+var (
+	// State series, e.g. MH12AB1234, DL3CAB1234, KA05M1. The district code
+	// is captured separately so it can be zero padded.
+	stateSeries = regexp.MustCompile(`^([A-Z]{2})(\d{1,2})([A-Z]{0,3})(\d{1,4})$`)
+	// Bharat series, e.g. 22BH1234AA.
+	bharatSeries = regexp.MustCompile(`^(\d{2})(BH)(\d{4})([A-Z]{1,2})$`)
+)
+
+// Normalize upper-cases a plate, drops separators and zero pads the district
+// code and serial number: "mh-1-ab-123" becomes "MH01AB0123".
+func Normalize(raw string) (string, error) {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(raw) {
+		switch {
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == ' ', r == '-', r == '.', r == '/':
+		default:
+			return "", ErrInvalidPlate
+		}
+	}
+	s := b.String()
+
+	if m := bharatSeries.FindStringSubmatch(s); m != nil {
+		return s, nil
+	}
+
+	m := stateSeries.FindStringSubmatch(s)
+	if m == nil {
+		return "", ErrInvalidPlate
+	}
+
+	return m[1] + pad(m[2], 2) + m[3] + pad(m[4], 4), nil
+}
+
+// Format renders a normalized plate with spaces for display: "MH 01 AB 0123".
+func Format(normalized string) string {
+	if m := bharatSeries.FindStringSubmatch(normalized); m != nil {
+		return strings.Join(m[1:], " ")
+	}
+
+	m := stateSeries.FindStringSubmatch(normalized)
+	if m == nil {
+		return normalized
+	}
+
+	parts := []string{m[1], m[2]}
+	if m[3] != "" {
+		parts = append(parts, m[3])
+	}
+	return strings.Join(append(parts, m[4]), " ")
+}
+
+func pad(s string, n int) string {
+	if len(s) >= n {
+		return s
+	}
+	return strings.Repeat("0", n-len(s)) + s
+}
@@ -0,0 +1,77 @@
+package plate
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{"MH12AB1234", "MH12AB1234"},
+		{"mh12ab1234", "MH12AB1234"},
+		{"MH 12 AB 1234", "MH12AB1234"},
+		{"mh-1-ab-123", "MH01AB0123"},
+		{"DL 3C AB 1234", "DL03CAB1234"},
+		{"KA05M1", "KA05M0001"},
+		{"ka.5.m.1", "KA05M0001"},
+		{"TN 09 1234", "TN091234"},
+		{"GJ/1/ABC/7", "GJ01ABC0007"},
+		{"22BH1234AA", "22BH1234AA"},
+		{"22 bh 1234 a", "22BH1234A"},
+	}
+
+	for _, tt := range tests {
+		got, err := Normalize(tt.raw)
+		if err != nil {
+			t.Errorf("Normalize(%q): %v", tt.raw, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeRejects(t *testing.T) {
+	for _, raw := range []string{
+		"",
+		"   ",
+		"MH12AB",
+		"MH12AB12345",
+		"M12AB1234",
+		"MH123AB1234",
+		"MH12ABCD1234",
+		"MH12_AB1234",
+		"MH12AB1234!",
+		"MH१२AB1234",
+		"22BH123AA",
+		"22BH1234ABC",
+		"2BH1234AA",
+	} {
+		if got, err := Normalize(raw); !errors.Is(err, ErrInvalidPlate) {
+			t.Errorf("Normalize(%q) = %q, %v; want ErrInvalidPlate", raw, got, err)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		normalized string
+		want       string
+	}{
+		{"MH01AB0123", "MH 01 AB 0123"},
+		{"DL03CAB1234", "DL 03 CAB 1234"},
+		{"TN091234", "TN 09 1234"},
+		{"22BH1234AA", "22 BH 1234 AA"},
+		{"not a plate", "not a plate"},
+	}
+
+	for _, tt := range tests {
+		if got := Format(tt.normalized); got != tt.want {
+			t.Errorf("Format(%q) = %q, want %q", tt.normalized, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+// Package polls runs the background side of society polls: publishing each
+// poll's final ballot chain head once it closes.
+package polls
+
+import (
+	"context"
+	"dooreye-backend/internal/model"
+	"dooreye-backend/internal/notify"
+	"dooreye-backend/internal/store"
+	"fmt"
+	"log/slog"
+	"strconv"
+)
+
+type Sealer struct {
+	db       *store.DB
+	notifier notify.Notifier
+	log      *slog.Logger
+}
+
+func NewSealer(db *store.DB, notifier notify.Notifier, log *slog.Logger) *Sealer {
+	return &Sealer{
+		db:       db,
+		notifier: notifier,
+		log:      log.With("component", "polls"),
+	}
+}
+
+// Seal is the periodic job that sends every voter the head hash and ballot
+// count of polls that have just closed. The head in the database proves
+// nothing against someone who can rewrite the database; the copies the
+// voters hold, and the one in the logs, are what the chain is checked
+// against.
+func (s *Sealer) Seal(ctx context.Context, _ *model.Job) error {
+	polls, err := s.db.ClaimClosedPolls(ctx)
+	if err != nil {
+		return err
+	}
+
+	for i := range polls {
+		p := &polls[i]
+		s.log.InfoContext(ctx, "poll sealed",
+			"poll_id", p.ID,
+			"society_id", p.SocietyID,
+			"ballot_count", p.BallotCount,
+			"head_hash", p.HeadHash.String(),
+		)
+		if err := s.publish(ctx, p); err != nil {
+			s.log.ErrorContext(ctx, "publishing poll head", "poll_id", p.ID, "error", err)
+		}
+	}
+
+	return nil
+}
+
+func (s *Sealer) publish(ctx context.Context, p *model.Poll) error {
+	recipients, err := s.db.GetPollVoterIDs(ctx, p)
+	if err != nil {
+		return err
+	}
+
+	return s.notifier.Send(ctx, notify.Message{
+		UserIDs:  recipients,
+		Kind:     "POLL_CLOSED",
+		Title:    fmt.Sprintf("Poll closed: %s", p.Title),
+		Body:     fmt.Sprintf("%d ballots were cast. Keep this receipt to check the result later: %s", p.BallotCount, p.HeadHash),
+		Priority: notify.PriorityNormal,
+		Data: map[string]string{
+			"poll_id":      strconv.FormatInt(p.ID, 10),
+			"ballot_count": strconv.Itoa(p.BallotCount),
+			"head_hash":    p.HeadHash.String(),
+		},
+	})
+}
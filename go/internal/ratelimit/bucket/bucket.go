@@ -0,0 +1,38 @@
+// Package bucket holds the token bucket arithmetic shared by the rate limit
+// backends. It imports nothing from the project, so the store can use it
+// without depending on package ratelimit.
+package bucket
+
+import (
+	"math"
+	"time"
+)
+
+// Bucket is the state of one rate limit key. Tokens refill continuously at
+// the limit's rate up to its burst; each request takes one.
+type Bucket struct {
+	Tokens    float64
+	UpdatedAt time.Time
+}
+
+// Full returns a full bucket.
+func Full(burst int, now time.Time) Bucket {
+	return Bucket{Tokens: float64(burst), UpdatedAt: now}
+}
+
+// Take refills the bucket up to now and takes a token if one is available.
+// When none is, it reports how long until one will be.
+func (b *Bucket) Take(perSecond float64, burst int, now time.Time) (bool, time.Duration) {
+	if elapsed := now.Sub(b.UpdatedAt).Seconds(); elapsed > 0 {
+		b.Tokens = math.Min(float64(burst), b.Tokens+elapsed*perSecond)
+	}
+	b.UpdatedAt = now
+
+	if b.Tokens >= 1 {
+		b.Tokens--
+		return true, 0
+	}
+
+	wait := (1 - b.Tokens) / perSecond
+	return false, time.Duration(wait * float64(time.Second))
+}
@@ -0,0 +1,45 @@
+package bucket
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBucketTake(t *testing.T) {
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		tokens    float64
+		elapsed   time.Duration
+		perSecond float64
+		burst     int
+		allowed   bool
+		retry     time.Duration
+		left      float64
+	}{
+		{"full", 3, 0, 1, 3, true, 0, 2},
+		{"last token", 1, 0, 1, 3, true, 0, 0},
+		{"empty", 0, 0, 1, 3, false, time.Second, 0},
+		{"part refilled", 0.5, 0, 1, 3, false, 500 * time.Millisecond, 0.5},
+		{"refills over time", 0, 2 * time.Second, 1, 3, true, 0, 1},
+		{"refill stops at burst", 0, time.Hour, 1, 3, true, 0, 2},
+		{"slow rate", 0, 0, 0.25, 3, false, 4 * time.Second, 0},
+		{"slow rate part refilled", 0, 2 * time.Second, 0.25, 3, false, 2 * time.Second, 0.5},
+		{"clock went back", 0, -time.Minute, 1, 3, false, time.Second, 0},
+	}
+
+	for _, tt := range tests {
+		b := Bucket{Tokens: tt.tokens, UpdatedAt: now.Add(-tt.elapsed)}
+		allowed, retry := b.Take(tt.perSecond, tt.burst, now)
+		if allowed != tt.allowed || retry != tt.retry {
+			t.Errorf("%s: Take = %v, %s; want %v, %s", tt.name, allowed, retry, tt.allowed, tt.retry)
+		}
+		if b.Tokens != tt.left {
+			t.Errorf("%s: %v tokens left, want %v", tt.name, b.Tokens, tt.left)
+		}
+		if !b.UpdatedAt.Equal(now) {
+			t.Errorf("%s: UpdatedAt = %s, want %s", tt.name, b.UpdatedAt, now)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// Lockout locks a key out after Threshold failures in a row, for Base at
+// first and twice as long with each further failure, up to Max. Failures
+// more than Window apart do not add up.
+type Lockout struct {
+	name    string
+	backend Backend
+
+	Threshold int
+	Base      time.Duration
+	Max       time.Duration
+	Window    time.Duration
+}
+
+func NewLockout(name string, backend Backend, threshold int, base, max, window time.Duration) *Lockout {
+	return &Lockout{
+		name:      name,
+		backend:   backend,
+		Threshold: threshold,
+		Base:      base,
+		Max:       max,
+		Window:    window,
+	}
+}
+
+// Check returns how long key is still locked out, zero if it is not.
+func (l *Lockout) Check(ctx context.Context, key string) (time.Duration, error) {
+	now := time.Now()
+	until, err := l.backend.LockedUntil(ctx, l.name+":"+key, now)
+	if err != nil || until.IsZero() {
+		return 0, err
+	}
+	return until.Sub(now), nil
+}
+
+// Fail records a failure for key and returns how long it is now locked
+// out, zero if it is not.
+func (l *Lockout) Fail(ctx context.Context, key string) (time.Duration, error) {
+	now := time.Now()
+	key = l.name + ":" + key
+
+	failures, err := l.backend.RecordFailure(ctx, key, now, l.Window)
+	if err != nil {
+		return 0, err
+	}
+
+	d := l.duration(failures)
+	if d == 0 {
+		return 0, nil
+	}
+	return d, l.backend.Lock(ctx, key, now.Add(d))
+}
+
+// Succeed clears key's failures.
+func (l *Lockout) Succeed(ctx context.Context, key string) error {
+	return l.backend.Reset(ctx, l.name+":"+key)
+}
+
+// duration is the lock earned by the given number of failures in a row.
+func (l *Lockout) duration(failures int) time.Duration {
+	if failures < l.Threshold {
+		return 0
+	}
+
+	d := l.Base
+	for i := l.Threshold; i < failures && d < l.Max; i++ {
+		d *= 2
+	}
+	return min(d, l.Max)
+}
@@ -0,0 +1,66 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLockoutDuration(t *testing.T) {
+	l := NewLockout("test", NewMemory(), 5, time.Minute, time.Hour, time.Hour)
+
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{4, 0},
+		{5, time.Minute},
+		{6, 2 * time.Minute},
+		{7, 4 * time.Minute},
+		{10, 32 * time.Minute},
+		{11, time.Hour},
+		{12, time.Hour},
+		{1000, time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := l.duration(tt.failures); got != tt.want {
+			t.Errorf("duration(%d) = %s, want %s", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestLockoutDurationBaseOverMax(t *testing.T) {
+	l := NewLockout("test", NewMemory(), 1, 2*time.Hour, time.Hour, time.Hour)
+	if got := l.duration(1); got != time.Hour {
+		t.Errorf("duration(1) = %s, want %s", got, time.Hour)
+	}
+}
+
+func TestLockoutSucceedClearsFailures(t *testing.T) {
+	ctx := context.Background()
+	l := NewLockout("test", NewMemory(), 2, time.Minute, time.Hour, time.Hour)
+
+	for i, want := range []time.Duration{0, time.Minute} {
+		if got, err := l.Fail(ctx, "k"); err != nil || got != want {
+			t.Fatalf("Fail #%d = %s, %v; want %s", i+1, got, err, want)
+		}
+	}
+	if got, err := l.Check(ctx, "k"); err != nil || got <= 0 {
+		t.Fatalf("Check after lock = %s, %v; want locked", got, err)
+	}
+	if got, err := l.Check(ctx, "other"); err != nil || got != 0 {
+		t.Fatalf("Check other key = %s, %v; want 0", got, err)
+	}
+
+	if err := l.Succeed(ctx, "k"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := l.Check(ctx, "k"); err != nil || got != 0 {
+		t.Fatalf("Check after Succeed = %s, %v; want 0", got, err)
+	}
+	if got, err := l.Fail(ctx, "k"); err != nil || got != 0 {
+		t.Fatalf("Fail after Succeed = %s, %v; want 0", got, err)
+	}
+}
@@ -0,0 +1,115 @@
+package ratelimit
+
+import (
+	"context"
+	"dooreye-backend/internal/ratelimit/bucket"
+	"sync"
+	"time"
+)
+
+// memoryIdle is how long an untouched key is kept. Buckets idle this long
+// have refilled for any realistic rate, and failure windows have passed.
+const memoryIdle = time.Hour
+
+// Memory keeps state in process. With several instances, each enforces
+// its limits separately.
+type Memory struct {
+	mu       sync.Mutex
+	buckets  map[string]*bucket.Bucket
+	failures map[string]*memoryFailures
+	swept    time.Time
+}
+
+type memoryFailures struct {
+	count       int
+	lastFailed  time.Time
+	lockedUntil time.Time
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		buckets:  make(map[string]*bucket.Bucket),
+		failures: make(map[string]*memoryFailures),
+	}
+}
+
+func (m *Memory) Take(_ context.Context, key string, rate Rate, now time.Time) (bool, time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sweep(now)
+
+	b, ok := m.buckets[key]
+	if !ok {
+		full := bucket.Full(rate.Burst, now)
+		b = &full
+		m.buckets[key] = b
+	}
+
+	allowed, retry := b.Take(rate.PerSecond, rate.Burst, now)
+	return allowed, retry, nil
+}
+
+func (m *Memory) RecordFailure(_ context.Context, key string, now time.Time, window time.Duration) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sweep(now)
+
+	f, ok := m.failures[key]
+	if !ok {
+		f = &memoryFailures{}
+		m.failures[key] = f
+	}
+	if now.Sub(f.lastFailed) > window {
+		f.count = 0
+	}
+	f.count++
+	f.lastFailed = now
+
+	return f.count, nil
+}
+
+func (m *Memory) Lock(_ context.Context, key string, until time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if f, ok := m.failures[key]; ok {
+		f.lockedUntil = until
+	}
+	return nil
+}
+
+func (m *Memory) LockedUntil(_ context.Context, key string, now time.Time) (time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if f, ok := m.failures[key]; ok && f.lockedUntil.After(now) {
+		return f.lockedUntil, nil
+	}
+	return time.Time{}, nil
+}
+
+func (m *Memory) Reset(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.failures, key)
+	return nil
+}
+
+// sweep drops idle keys, at most once per idle period. Callers hold mu.
+func (m *Memory) sweep(now time.Time) {
+	if now.Sub(m.swept) < memoryIdle {
+		return
+	}
+	for k, b := range m.buckets {
+		if now.Sub(b.UpdatedAt) > memoryIdle {
+			delete(m.buckets, k)
+		}
+	}
+	for k, f := range m.failures {
+		if now.Sub(f.lastFailed) > memoryIdle && now.After(f.lockedUntil) {
+			delete(m.failures, k)
+		}
+	}
+	m.swept = now
+}
@@ -0,0 +1,37 @@
+package ratelimit
+
+import (
+	"context"
+	"dooreye-backend/internal/store"
+	"time"
+)
+
+// Postgres keeps state in the database, so every instance enforces the
+// same limits. Each check costs a round trip.
+type Postgres struct {
+	db *store.DB
+}
+
+func NewPostgres(db *store.DB) *Postgres {
+	return &Postgres{db: db}
+}
+
+func (p *Postgres) Take(ctx context.Context, key string, rate Rate, now time.Time) (bool, time.Duration, error) {
+	return p.db.TakeRateToken(ctx, key, rate.PerSecond, rate.Burst, now)
+}
+
+func (p *Postgres) RecordFailure(ctx context.Context, key string, now time.Time, window time.Duration) (int, error) {
+	return p.db.RecordAuthFailure(ctx, key, now, window)
+}
+
+func (p *Postgres) Lock(ctx context.Context, key string, until time.Time) error {
+	return p.db.LockAuthKey(ctx, key, until)
+}
+
+func (p *Postgres) LockedUntil(ctx context.Context, key string, now time.Time) (time.Time, error) {
+	return p.db.AuthLockedUntil(ctx, key, now)
+}
+
+func (p *Postgres) Reset(ctx context.Context, key string) error {
+	return p.db.ClearAuthFailures(ctx, key)
+}
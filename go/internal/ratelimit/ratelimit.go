@@ -0,0 +1,62 @@
+// Package ratelimit throttles clients with token buckets and locks out
+// keys that keep failing authentication. State lives in a Backend: memory
+// for a single instance, Postgres when several instances share limits.
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// Rate is a token bucket refilling PerSecond tokens a second up to Burst.
+type Rate struct {
+	PerSecond float64
+	Burst     int
+}
+
+// PerMinute allows n requests a minute on average, in bursts of up to
+// burst.
+func PerMinute(n, burst int) Rate {
+	return Rate{PerSecond: float64(n) / 60, Burst: burst}
+}
+
+// PerSecond allows n requests a second on average, in bursts of up to
+// burst.
+func PerSecond(n, burst int) Rate {
+	return Rate{PerSecond: float64(n), Burst: burst}
+}
+
+// Backend stores buckets and failure counts.
+type Backend interface {
+	// Take takes a token from key's bucket, or reports how long until one
+	// is available.
+	Take(ctx context.Context, key string, rate Rate, now time.Time) (bool, time.Duration, error)
+
+	// RecordFailure counts a failure for key and returns how many there
+	// have been in a row, each within window of the last.
+	RecordFailure(ctx context.Context, key string, now time.Time, window time.Duration) (int, error)
+	// Lock refuses key until the given time.
+	Lock(ctx context.Context, key string, until time.Time) error
+	// LockedUntil returns when key's lock ends, or the zero time if it is
+	// not locked.
+	LockedUntil(ctx context.Context, key string, now time.Time) (time.Time, error)
+	// Reset forgets key's failures.
+	Reset(ctx context.Context, key string) error
+}
+
+// Limiter applies one rate to many keys. Its name prefixes keys, so
+// limiters can share a backend.
+type Limiter struct {
+	name    string
+	rate    Rate
+	backend Backend
+}
+
+func NewLimiter(name string, rate Rate, backend Backend) *Limiter {
+	return &Limiter{name: name, rate: rate, backend: backend}
+}
+
+// Allow takes a token for key, or reports how long the caller should wait.
+func (l *Limiter) Allow(ctx context.Context, key string) (bool, time.Duration, error) {
+	return l.backend.Take(ctx, l.name+":"+key, l.rate, time.Now())
+}
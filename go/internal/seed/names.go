@@ -0,0 +1,77 @@
+package seed
+
+import (
+	"dooreye-backend/internal/model"
+	"fmt"
+	"math/rand/v2"
+)
+
+var cityNames = []string{
+	"Bengaluru", "Pune", "Hyderabad", "Chennai", "Mumbai", "Gurugram", "Noida", "Kolkata",
+	"Ahmedabad", "Kochi", "Jaipur", "Indore", "Chandigarh", "Coimbatore", "Nagpur", "Lucknow",
+}
+
+var societyPrefixes = []string{
+	"Green", "Royal", "Silver", "Lake", "Palm", "Sunrise", "Maple", "Orchid",
+	"Prestige", "Emerald", "Golden", "River", "Cedar", "Lotus", "Harmony", "Skyline",
+}
+
+var societySuffixes = []string{
+	"Residency", "Heights", "Enclave", "Meadows", "Gardens", "Towers", "Park", "Vista",
+}
+
+var localities = []string{
+	"Whitefield", "Baner", "Gachibowli", "Velachery", "Powai", "Sector 54", "Sector 137",
+	"Salt Lake", "Bopal", "Kakkanad", "Malviya Nagar", "Vijay Nagar", "Sector 22", "Saravanampatti",
+}
+
+var firstNames = []string{
+	"Aarav", "Vivaan", "Aditya", "Vihaan", "Arjun", "Sai", "Reyansh", "Krishna", "Ishaan", "Rohan",
+	"Ananya", "Diya", "Aadhya", "Saanvi", "Pari", "Myra", "Kavya", "Meera", "Priya", "Neha",
+	"Rahul", "Amit", "Suresh", "Ramesh", "Vikram", "Sanjay", "Deepak", "Manoj", "Anil", "Ravi",
+	"Lakshmi", "Sunita", "Pooja", "Anjali", "Rekha", "Geeta", "Shalini", "Divya", "Nisha", "Farah",
+	"Imran", "Joseph", "Thomas", "Gurpreet", "Harpreet", "Mohammed", "Ayesha", "Zoya", "Kabir", "Tara",
+}
+
+var lastNames = []string{
+	"Sharma", "Verma", "Iyer", "Reddy", "Nair", "Patel", "Shah", "Gupta", "Singh", "Kumar",
+	"Rao", "Menon", "Das", "Bose", "Mukherjee", "Joshi", "Kulkarni", "Deshpande", "Pillai", "Khan",
+	"Fernandes", "D'Souza", "Chopra", "Malhotra", "Banerjee", "Ghosh", "Naidu", "Hegde", "Bhat", "Mehta",
+}
+
+var purposes = map[model.VisitorType][]string{
+	model.VisitorDelivery: {
+		"Food delivery", "Grocery delivery", "Parcel", "Courier", "Milk delivery", "Pharmacy order",
+	},
+	model.VisitorCab: {"Pickup", "Drop"},
+	model.VisitorGuest: {
+		"Family visit", "Friends", "Birthday party", "Dinner", "Tuition", "Weekend stay",
+	},
+	model.VisitorMaintenance: {
+		"Plumbing repair", "Electrical work", "AC service", "Pest control", "Carpentry",
+		"Water purifier service", "Internet installation", "Appliance repair",
+	},
+	model.VisitorStaff: {"Housekeeping", "Cook", "Driver", "Nanny", "Elderly care"},
+}
+
+func personName(r *rand.Rand) string {
+	return firstNames[r.IntN(len(firstNames))] + " " + lastNames[r.IntN(len(lastNames))]
+}
+
+// phone returns a ten digit Indian mobile number.
+func phone(r *rand.Rand) string {
+	return fmt.Sprintf("%d%09d", 6+r.IntN(4), r.IntN(1_000_000_000))
+}
+
+// blockName names blocks A, B, ... Z, then AA, AB, ...
+func blockName(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return "Block " + name
+}
+
+func pick(r *rand.Rand, options []string) string {
+	return options[r.IntN(len(options))]
+}
@@ -0,0 +1,438 @@
+// Package seed generates a realistic, reproducible data set: cities,
+// societies with blocks and residences, users in every role, a pool of
+// visitors of every type, and months of visit history whose check-in times
+// follow each visitor type's daily rhythm. Visits are streamed into the
+// database as they are generated, so the history can be made large enough
+// to load test visit queries.
+package seed
+
+import (
+	"context"
+	"dooreye-backend/internal/model"
+	"dooreye-backend/internal/store"
+	"encoding/binary"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	// Societies keep local time; the seed must not depend on the host's
+	// time zone database.
+	_ "time/tzdata"
+
+	"github.com/gofrs/uuid"
+	"github.com/jackc/pgx/v4"
+)
+
+// timezone is the time zone of every generated society.
+const timezone = "Asia/Kolkata"
+
+// Options sizes the data set. Zero fields take the defaults noted.
+type Options struct {
+	// Seed picks the data: the same seed, sizes and Until generate the
+	// same rows on an empty database.
+	Seed uint64
+
+	Cities           int // 1
+	SocietiesPerCity int // 2
+	BlocksPerSociety int // 4
+	FloorsPerBlock   int // 8
+	UnitsPerFloor    int // 4
+	GuardsPerSociety int // 4
+
+	// Months of visit history, ending at Until. 3 by default.
+	Months int
+	// VisitsPerDay is the average number of visits an occupied residence
+	// gets a day, not counting its domestic staff. 2.5 by default.
+	VisitsPerDay float64
+	// Until ends the history. Visits that would end after it are left
+	// open, as if the visitor were still inside. It defaults to now.
+	Until time.Time
+}
+
+func (o *Options) setDefaults() {
+	def := func(v *int, d int) {
+		if *v <= 0 {
+			*v = d
+		}
+	}
+	def(&o.Cities, 1)
+	def(&o.SocietiesPerCity, 2)
+	def(&o.BlocksPerSociety, 4)
+	def(&o.FloorsPerBlock, 8)
+	def(&o.UnitsPerFloor, 4)
+	def(&o.GuardsPerSociety, 4)
+	def(&o.Months, 3)
+	if o.VisitsPerDay <= 0 {
+		o.VisitsPerDay = 2.5
+	}
+	if o.Until.IsZero() {
+		o.Until = time.Now()
+	}
+	o.Until = o.Until.Truncate(time.Second)
+}
+
+// Summary reports what Run loaded.
+type Summary struct {
+	Counts map[string]int64 `json:"counts"`
+	// Logins are device IDs that sign in as a sample user of each role.
+	Logins []Login `json:"logins"`
+}
+
+type Login struct {
+	Role      model.UserRole `json:"role"`
+	Name      string         `json:"name"`
+	SocietyID *int64         `json:"society_id,omitempty"`
+	DeviceID  string         `json:"device_id"`
+}
+
+// Run generates the data set and loads it in one transaction.
+func Run(ctx context.Context, db *store.DB, opts Options) (*Summary, error) {
+	opts.setDefaults()
+
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("loading time zone: %w", err)
+	}
+
+	g := &generator{opts: opts, loc: loc}
+	if err := g.reserveIDs(ctx, db); err != nil {
+		return nil, err
+	}
+	g.build()
+
+	counts, err := db.BulkLoad(ctx, g.tables())
+	if err != nil {
+		return nil, fmt.Errorf("loading seed data: %w", err)
+	}
+
+	return &Summary{Counts: counts, Logins: g.logins}, nil
+}
+
+// generator builds everything but visits in memory; visits are generated
+// as they are copied.
+type generator struct {
+	opts Options
+	loc  *time.Location
+	// start is local midnight on the first day of history.
+	start time.Time
+
+	// First reserved ID of each serial table.
+	firstCity, firstSociety, firstBlock, firstResidence, firstGate int64
+
+	cities      [][]any
+	societyRows [][]any
+	blocks      [][]any
+	residences  [][]any
+	gates       [][]any
+	users       [][]any
+	memberships [][]any
+	devices     [][]any
+	visitors    [][]any
+
+	societies []*society
+	logins    []Login
+}
+
+type society struct {
+	id        int64
+	index     int
+	guards    []uuid.UUID
+	mainGate  int64
+	service   int64
+	homes     []*residence
+	occupied  int
+	visitors  map[model.VisitorType][]uuid.UUID
+	createdAt time.Time
+}
+
+type residence struct {
+	id int64
+	// members are the people living there, who approve its visitors. A
+	// vacant residence has none and gets no visitors.
+	members []uuid.UUID
+	staff   []staffMember
+}
+
+// staffMember is domestic help who comes on every day but their day off.
+type staffMember struct {
+	visitor  uuid.UUID
+	purpose  string
+	fullTime bool
+	dayOff   time.Weekday
+}
+
+func (g *generator) societyCount() int  { return g.opts.Cities * g.opts.SocietiesPerCity }
+func (g *generator) blockCount() int    { return g.societyCount() * g.opts.BlocksPerSociety }
+func (g *generator) homesPerBlock() int { return g.opts.FloorsPerBlock * g.opts.UnitsPerFloor }
+
+func (g *generator) reserveIDs(ctx context.Context, db *store.DB) error {
+	reserve := []struct {
+		table string
+		n     int
+		first *int64
+	}{
+		{"cities", g.opts.Cities, &g.firstCity},
+		{"societies", g.societyCount(), &g.firstSociety},
+		{"blocks", g.blockCount(), &g.firstBlock},
+		{"residences", g.blockCount() * g.homesPerBlock(), &g.firstResidence},
+		{"gates", 2 * g.societyCount(), &g.firstGate},
+	}
+	for _, r := range reserve {
+		first, err := db.ReserveIDs(ctx, r.table, int64(r.n))
+		if err != nil {
+			return err
+		}
+		*r.first = first
+	}
+	return nil
+}
+
+// rng returns the random source for one part of the data set. Each part
+// has its own stream, so changing the size of one leaves the rest alone.
+func (g *generator) rng(part, index int) *rand.Rand {
+	return rand.New(rand.NewPCG(g.opts.Seed, uint64(part)<<32|uint64(index)))
+}
+
+// Random stream parts.
+const (
+	partCities = iota
+	partSociety
+	partVisits
+)
+
+func newUUID(r *rand.Rand) uuid.UUID {
+	var u uuid.UUID
+	binary.BigEndian.PutUint64(u[:8], r.Uint64())
+	binary.BigEndian.PutUint64(u[8:], r.Uint64())
+	u.SetVersion(uuid.V4)
+	u.SetVariant(uuid.VariantRFC4122)
+	return u
+}
+
+func (g *generator) build() {
+	until := g.opts.Until.In(g.loc)
+	first := until.AddDate(0, -g.opts.Months, 0)
+	g.start = time.Date(first.Year(), first.Month(), first.Day(), 0, 0, 0, 0, g.loc)
+
+	r := g.rng(partCities, 0)
+	offset := r.IntN(len(cityNames))
+	for c := 0; c < g.opts.Cities; c++ {
+		name := cityNames[(offset+c)%len(cityNames)]
+		if c >= len(cityNames) {
+			name = fmt.Sprintf("%s %d", name, c/len(cityNames)+1)
+		}
+		g.cities = append(g.cities, []any{g.firstCity + int64(c), name})
+	}
+
+	// The admin is platform wide and belongs to no society.
+	admin := newUUID(r)
+	adminSince := g.start.AddDate(-1, 0, 0)
+	g.addUser(admin, "Platform Admin", model.RoleAdmin, nil, nil, adminSince)
+	g.logins = append(g.logins, Login{Role: model.RoleAdmin, Name: "Platform Admin", DeviceID: deviceID(admin)})
+
+	for i := 0; i < g.societyCount(); i++ {
+		g.buildSociety(i)
+	}
+}
+
+func (g *generator) buildSociety(index int) {
+	r := g.rng(partSociety, index)
+	cityIndex := index / g.opts.SocietiesPerCity
+	s := &society{
+		id:       g.firstSociety + int64(index),
+		index:    index,
+		mainGate: g.firstGate + int64(2*index),
+		service:  g.firstGate + int64(2*index+1),
+		visitors: make(map[model.VisitorType][]uuid.UUID),
+		// Societies joined up to two years before the history starts.
+		createdAt: g.start.Add(-time.Duration(365+r.IntN(365)) * 24 * time.Hour),
+	}
+	g.societies = append(g.societies, s)
+
+	name := societyPrefixes[index%len(societyPrefixes)] + " " +
+		societySuffixes[(index/len(societyPrefixes)+cityIndex)%len(societySuffixes)]
+	if n := len(societyPrefixes) * len(societySuffixes); index >= n {
+		name = fmt.Sprintf("%s %d", name, index/n+1)
+	}
+	address := fmt.Sprintf("%d, %s", 1+r.IntN(300), pick(r, localities))
+	g.societyRows = append(g.societyRows, []any{
+		s.id, g.cities[cityIndex][0], name, address, timezone, s.createdAt,
+	})
+	g.gates = append(g.gates,
+		[]any{s.mainGate, s.id, "Main Gate", s.createdAt},
+		[]any{s.service, s.id, "Service Gate", s.createdAt},
+	)
+
+	// Staff: one manager and the guards, split over day and night shifts.
+	manager := newUUID(r)
+	managerName := personName(r)
+	g.addUser(manager, managerName, model.RoleSocietyManager, nil, &s.id, s.createdAt)
+	var guardName string
+	for i := 0; i < g.opts.GuardsPerSociety; i++ {
+		guard := newUUID(r)
+		if guardName = personName(r); i == 0 && index == 0 {
+			g.logins = append(g.logins,
+				Login{Role: model.RoleSocietyManager, Name: managerName, SocietyID: &s.id, DeviceID: deviceID(manager)},
+				Login{Role: model.RoleSecurity, Name: guardName, SocietyID: &s.id, DeviceID: deviceID(guard)},
+			)
+		}
+		g.addUser(guard, guardName, model.RoleSecurity, nil, &s.id, s.createdAt)
+		s.guards = append(s.guards, guard)
+	}
+
+	for b := 0; b < g.opts.BlocksPerSociety; b++ {
+		blockID := g.firstBlock + int64(index*g.opts.BlocksPerSociety+b)
+		g.blocks = append(g.blocks, []any{blockID, s.id, blockName(b)})
+
+		for f := 1; f <= g.opts.FloorsPerBlock; f++ {
+			for u := 1; u <= g.opts.UnitsPerFloor; u++ {
+				seq := (index*g.opts.BlocksPerSociety+b)*g.homesPerBlock() + (f-1)*g.opts.UnitsPerFloor + (u - 1)
+				home := &residence{id: g.firstResidence + int64(seq)}
+				// Corner units are the larger ones.
+				area := 750 + 250*((u-1)%3) + r.IntN(150)
+				g.residences = append(g.residences, []any{
+					home.id, fmt.Sprintf("%d%02d", f, u), blockID, int32(f), int32(area),
+				})
+				g.fillResidence(r, s, home)
+				s.homes = append(s.homes, home)
+			}
+		}
+	}
+
+	g.buildVisitorPool(r, s)
+}
+
+// fillResidence moves people into a home: about one in ten stays vacant
+// and one in five is let to tenants.
+func (g *generator) fillResidence(r *rand.Rand, s *society, home *residence) {
+	if r.Float64() < 0.1 {
+		return
+	}
+	s.occupied++
+
+	moveIn := s.createdAt.Add(time.Duration(r.Int64N(int64(g.start.Sub(s.createdAt)))))
+	tenanted := r.Float64() < 0.2
+
+	var leaseEnd *time.Time
+	if tenanted {
+		end := g.opts.Until.In(g.loc).AddDate(0, 1+r.IntN(11), 0)
+		end = time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, time.UTC)
+		leaseEnd = &end
+	}
+
+	// A let home's owner lives elsewhere; the tenants are its residents.
+	owner := newUUID(r)
+	ownerName := personName(r)
+	household := 1 + r.IntN(4)
+	if tenanted {
+		household++
+	}
+	for i := 0; i < household; i++ {
+		id, name, role := owner, ownerName, model.RoleOwner
+		if i > 0 {
+			id, name, role = newUUID(r), personName(r), model.RoleResident
+		}
+		tenant := tenanted && i > 0
+		var end *time.Time
+		if tenant {
+			end = leaseEnd
+		}
+		g.addUser(id, name, role, &home.id, nil, moveIn)
+		g.memberships = append(g.memberships, []any{id, home.id, string(role), tenant, end, moveIn})
+		if !tenanted || i > 0 {
+			home.members = append(home.members, id)
+		}
+	}
+	if s.index == 0 && s.occupied == 1 {
+		g.logins = append(g.logins, Login{Role: model.RoleOwner, Name: ownerName, SocietyID: &s.id, DeviceID: deviceID(owner)})
+	}
+
+	// Most homes have a help or two; some have a live-out full-timer.
+	for n := r.IntN(3); n > 0; n-- {
+		m := staffMember{
+			visitor:  g.addVisitor(r, s, model.VisitorStaff),
+			purpose:  pick(r, purposes[model.VisitorStaff]),
+			fullTime: r.Float64() < 0.15,
+			dayOff:   time.Weekday(r.IntN(7)),
+		}
+		home.staff = append(home.staff, m)
+	}
+}
+
+// buildVisitorPool creates the society's regular delivery agents, cab
+// drivers and technicians, and the guests its residents will receive.
+func (g *generator) buildVisitorPool(r *rand.Rand, s *society) {
+	homes := len(s.homes)
+	pools := []struct {
+		t model.VisitorType
+		n int
+	}{
+		{model.VisitorDelivery, max(5, homes/5)},
+		{model.VisitorCab, max(5, homes/5)},
+		{model.VisitorMaintenance, max(5, homes/20)},
+		{model.VisitorGuest, max(10, 2*homes)},
+	}
+	for _, p := range pools {
+		for i := 0; i < p.n; i++ {
+			g.addVisitor(r, s, p.t)
+		}
+	}
+}
+
+func (g *generator) addUser(id uuid.UUID, name string, role model.UserRole, residenceID, societyID *int64, since time.Time) {
+	g.users = append(g.users, []any{
+		id, name, string(role), residenceID, societyID, true, since, since,
+	})
+	g.devices = append(g.devices, []any{id, deviceID(id), since, since})
+}
+
+// deviceID is the device each generated user is signed in on.
+func deviceID(user uuid.UUID) string {
+	return "seed-" + user.String()
+}
+
+func (g *generator) addVisitor(r *rand.Rand, s *society, t model.VisitorType) uuid.UUID {
+	id := newUUID(r)
+	createdBy := s.guards[r.IntN(len(s.guards))]
+	firstSeen := g.start.Add(-time.Duration(r.IntN(30*24)) * time.Hour)
+
+	var preApprovedTill *time.Time
+	if t == model.VisitorStaff {
+		till := g.opts.Until.AddDate(0, 6, 0).UTC().Truncate(24 * time.Hour)
+		preApprovedTill = &till
+	}
+
+	g.visitors = append(g.visitors, []any{
+		id, personName(r), phone(r), string(t), preApprovedTill, createdBy, firstSeen, firstSeen,
+	})
+	if t != model.VisitorStaff {
+		s.visitors[t] = append(s.visitors[t], id)
+	}
+	return id
+}
+
+// tables lists the data in load order, parents before children.
+func (g *generator) tables() []store.BulkTable {
+	rows := func(name string, columns []string, data [][]any) store.BulkTable {
+		return store.BulkTable{Name: name, Columns: columns, Rows: pgx.CopyFromRows(data)}
+	}
+
+	return []store.BulkTable{
+		rows("cities", []string{"id", "name"}, g.cities),
+		rows("societies", []string{"id", "city_id", "name", "address", "timezone", "created_at"}, g.societyRows),
+		rows("blocks", []string{"id", "society_id", "name"}, g.blocks),
+		rows("residences", []string{"id", "number", "block_id", "floor", "area_sqft"}, g.residences),
+		rows("gates", []string{"id", "society_id", "name", "created_at"}, g.gates),
+		rows("users", []string{
+			"id", "name", "role", "residence_id", "society_id", "is_active", "activated_at", "created_at",
+		}, g.users),
+		rows("user_residences", []string{
+			"user_id", "residence_id", "role", "is_tenant", "lease_end", "created_at",
+		}, g.memberships),
+		rows("user_devices", []string{"user_id", "device_id", "created_at", "last_seen_at"}, g.devices),
+		rows("visitors", []string{
+			"id", "name", "phone", "type", "pre_approved_till", "created_by", "created_at", "updated_at",
+		}, g.visitors),
+		{Name: "visits", Columns: visitColumns, Rows: newVisitSource(g)},
+	}
+}
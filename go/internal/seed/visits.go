@@ -0,0 +1,291 @@
+package seed
+
+import (
+	"dooreye-backend/internal/model"
+	"math"
+	"math/rand/v2"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+var visitColumns = []string{
+	"id", "visitor_id", "residence_id", "gate_id", "checked_in_by", "approved_by",
+	"approved_at", "check_in_time", "check_out_time", "purpose", "created_at", "updated_at",
+}
+
+// profile is when visitors of one type arrive and how long they stay.
+type profile struct {
+	t model.VisitorType
+	// share is the part of a residence's daily visits of this type.
+	share float64
+	// weekend scales the rate on Saturdays and Sundays.
+	weekend float64
+	// hours weights check-ins by local hour; weekendHours, if set, is used
+	// on weekends instead.
+	hours, weekendHours [24]float64
+	minStay, maxStay    time.Duration
+	// serviceGate is the chance the visitor uses the service gate.
+	serviceGate float64
+	// approved is whether a resident approves the visit at the gate.
+	approved bool
+}
+
+var profiles = []profile{
+	{
+		t: model.VisitorDelivery, share: 0.55, weekend: 1.15,
+		// Lunch and dinner peaks.
+		hours: [24]float64{
+			0, 0, 0, 0, 0, 0, 0.5, 1, 2, 3, 4, 5,
+			7, 8, 6, 4, 4, 5, 6, 8, 9, 7, 3, 1,
+		},
+		minStay: 2 * time.Minute, maxStay: 12 * time.Minute,
+		serviceGate: 0.7, approved: true,
+	},
+	{
+		t: model.VisitorCab, share: 0.15, weekend: 0.8,
+		// Office commutes, with a trickle through the night.
+		hours: [24]float64{
+			1, 0.5, 0.3, 0.3, 0.5, 1, 3, 6, 9, 8, 4, 2,
+			2, 2, 2, 2, 3, 5, 7, 8, 6, 4, 3, 2,
+		},
+		minStay: 1 * time.Minute, maxStay: 6 * time.Minute,
+	},
+	{
+		t: model.VisitorGuest, share: 0.2, weekend: 2,
+		hours: [24]float64{
+			0, 0, 0, 0, 0, 0, 0, 0, 0, 0.5, 1, 2,
+			2, 2, 2, 2, 3, 5, 8, 9, 7, 4, 1, 0.2,
+		},
+		weekendHours: [24]float64{
+			0, 0, 0, 0, 0, 0, 0, 0, 0.5, 1, 3, 5,
+			6, 5, 4, 4, 5, 7, 8, 8, 6, 3, 1, 0.2,
+		},
+		minStay: 30 * time.Minute, maxStay: 4 * time.Hour,
+		approved: true,
+	},
+	{
+		t: model.VisitorMaintenance, share: 0.1, weekend: 0.4,
+		// Working hours, with a lunch dip.
+		hours: [24]float64{
+			0, 0, 0, 0, 0, 0, 0, 0, 0, 3, 8, 9,
+			7, 4, 7, 8, 6, 3, 1, 0, 0, 0, 0, 0,
+		},
+		minStay: 20 * time.Minute, maxStay: 150 * time.Minute,
+		serviceGate: 0.8, approved: true,
+	},
+}
+
+// Domestic staff arrive in the morning; part-timers may come back in the
+// evening, e.g. to cook dinner.
+var (
+	staffMorning = [24]float64{
+		0, 0, 0, 0, 0, 0, 2, 6, 9, 7, 4, 2,
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	}
+	staffFullTime = [24]float64{
+		0, 0, 0, 0, 0, 0, 1, 5, 8, 4, 0, 0,
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	}
+	staffEvening = [24]float64{
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		0, 0, 0, 0, 2, 5, 6, 3, 0, 0, 0, 0,
+	}
+)
+
+// visitSource generates visits a society and day at a time, as COPY reads
+// them, so the history never has to fit in memory.
+type visitSource struct {
+	g *generator
+	r *rand.Rand
+
+	society int
+	day     time.Time
+	until   time.Time
+
+	batch  [][]any
+	next   int
+	values []any
+}
+
+func newVisitSource(g *generator) *visitSource {
+	return &visitSource{
+		g:       g,
+		society: -1,
+		until:   g.opts.Until,
+	}
+}
+
+func (v *visitSource) Next() bool {
+	for v.next >= len(v.batch) {
+		if !v.advance() {
+			return false
+		}
+	}
+	v.values = v.batch[v.next]
+	v.next++
+	return true
+}
+
+func (v *visitSource) Values() ([]any, error) { return v.values, nil }
+func (v *visitSource) Err() error             { return nil }
+
+// advance generates the next day's visits, moving on to the next society
+// after the last day. Each society has its own random stream, so its
+// history does not depend on how many societies come before it.
+func (v *visitSource) advance() bool {
+	if v.society < 0 || !v.day.AddDate(0, 0, 1).Before(v.until) {
+		v.society++
+		if v.society >= len(v.g.societies) {
+			return false
+		}
+		v.r = v.g.rng(partVisits, v.society)
+		v.day = v.g.start
+	} else {
+		v.day = v.day.AddDate(0, 0, 1)
+	}
+
+	v.batch = v.batch[:0]
+	v.next = 0
+	s := v.g.societies[v.society]
+	for _, home := range s.homes {
+		if len(home.members) > 0 {
+			v.generateDay(s, home)
+		}
+	}
+	return true
+}
+
+func (v *visitSource) generateDay(s *society, home *residence) {
+	r := v.r
+	weekday := v.day.Weekday()
+	weekend := weekday == time.Saturday || weekday == time.Sunday
+
+	for _, m := range home.staff {
+		if weekday == m.dayOff || r.Float64() < 0.08 {
+			continue
+		}
+
+		if m.fullTime {
+			in := v.at(staffFullTime)
+			v.add(s, home, m.visitor, in, in.Add(randDuration(r, 8*time.Hour, 10*time.Hour)), m.purpose, true, false)
+			continue
+		}
+		in := v.at(staffMorning)
+		v.add(s, home, m.visitor, in, in.Add(randDuration(r, 45*time.Minute, 3*time.Hour)), m.purpose, true, false)
+		if m.purpose == "Cook" {
+			in := v.at(staffEvening)
+			v.add(s, home, m.visitor, in, in.Add(randDuration(r, 45*time.Minute, 2*time.Hour)), m.purpose, true, false)
+		}
+	}
+
+	for _, p := range profiles {
+		rate := v.g.opts.VisitsPerDay * p.share
+		hours := p.hours
+		if weekend {
+			rate *= p.weekend
+			if p.weekendHours != ([24]float64{}) {
+				hours = p.weekendHours
+			}
+		}
+
+		pool := s.visitors[p.t]
+		for n := poisson(r, rate); n > 0; n-- {
+			in := v.at(hours)
+			out := in.Add(randDuration(r, p.minStay, p.maxStay))
+			visitor := pool[r.IntN(len(pool))]
+			v.add(s, home, visitor, in, out, pick(r, purposes[p.t]), r.Float64() < p.serviceGate, p.approved)
+		}
+	}
+}
+
+// at picks a check-in time on the current day, with the hour drawn from
+// weights.
+func (v *visitSource) at(weights [24]float64) time.Time {
+	var total float64
+	for _, w := range weights {
+		total += w
+	}
+
+	x := v.r.Float64() * total
+	hour := 0
+	for ; hour < 23; hour++ {
+		if x < weights[hour] {
+			break
+		}
+		x -= weights[hour]
+	}
+
+	return v.day.Add(time.Duration(hour)*time.Hour + time.Duration(v.r.IntN(3600))*time.Second)
+}
+
+// add appends a visit unless it starts after the history ends. A visit
+// still going on at the end has no check-out.
+func (v *visitSource) add(s *society, home *residence, visitor uuid.UUID, in, out time.Time, purpose string, serviceGate, approved bool) {
+	r := v.r
+	id := newUUID(r)
+	guard := v.guardOnDuty(s, in)
+	gate := s.mainGate
+	if serviceGate {
+		gate = s.service
+	}
+
+	var approvedBy *uuid.UUID
+	var approvedAt *time.Time
+	if approved {
+		approvedBy = &home.members[r.IntN(len(home.members))]
+		at := in.Add(time.Duration(15+r.IntN(90)) * time.Second)
+		approvedAt = &at
+	}
+
+	if !in.Before(v.until) {
+		return
+	}
+
+	var checkOut *time.Time
+	updated := in
+	if out.Before(v.until) {
+		checkOut = &out
+		updated = out
+	}
+
+	v.batch = append(v.batch, []any{
+		id, visitor, home.id, gate, guard, approvedBy, approvedAt, in, checkOut, purpose, in, updated,
+	})
+}
+
+// guardOnDuty splits the guards into a day shift, 06:00 to 18:00, and a
+// night shift, and picks one on duty at t.
+func (v *visitSource) guardOnDuty(s *society, t time.Time) uuid.UUID {
+	guards := s.guards
+	if half := len(guards) / 2; half > 0 {
+		if h := t.Hour(); h >= 6 && h < 18 {
+			guards = guards[:half]
+		} else {
+			guards = guards[half:]
+		}
+	}
+	return guards[v.r.IntN(len(guards))]
+}
+
+func randDuration(r *rand.Rand, lo, hi time.Duration) time.Duration {
+	return lo + time.Duration(r.Int64N(int64(hi-lo)+1))
+}
+
+// poisson draws how many of a rate-lambda event happen in a day.
+func poisson(r *rand.Rand, lambda float64) int {
+	if lambda <= 0 {
+		return 0
+	}
+	if lambda > 30 {
+		n := int(math.Round(r.NormFloat64()*math.Sqrt(lambda) + lambda))
+		return max(n, 0)
+	}
+
+	limit := math.Exp(-lambda)
+	n := 0
+	for p := r.Float64(); p > limit; p *= r.Float64() {
+		n++
+	}
+	return n
+}
@@ -0,0 +1,248 @@
+package store
+
+import (
+	"context"
+	"dooreye-backend/internal/model"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v4"
+)
+
+var ErrAlertClosed = errors.New("alert is already resolved")
+
+const alertColumns = `
+    id, society_id, type, status, residence_id, gate_id, message, raised_by,
+    escalation_level, last_escalated_at, acknowledged_by, acknowledged_at,
+    resolved_by, resolved_at, created_at, updated_at
+`
+
+type CreateAlertParams struct {
+	SocietyID   int64
+	Type        model.AlertType
+	ResidenceID *int64
+	GateID      *int64
+	Message     *string
+	RaisedBy    string
+}
+
+func (db *DB) CreateAlert(ctx context.Context, params CreateAlertParams) (*model.Alert, error) {
+	alert, err := scanAlert(db.pool.QueryRow(ctx, `
+        INSERT INTO alerts (society_id, type, residence_id, gate_id, message, raised_by)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        RETURNING `+alertColumns,
+		params.SocietyID,
+		params.Type,
+		params.ResidenceID,
+		params.GateID,
+		params.Message,
+		params.RaisedBy,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("creating alert: %w", err)
+	}
+
+	return alert, nil
+}
+
+func (db *DB) GetAlert(ctx context.Context, id string) (*model.Alert, error) {
+	return scanAlert(db.pool.QueryRow(ctx, `SELECT `+alertColumns+` FROM alerts WHERE id = $1`, id))
+}
+
+type AlertFilter struct {
+	SocietyID   *int64
+	ResidenceID *int64
+	Status      model.AlertStatus
+	OnlyOpen    bool
+}
+
+func (db *DB) GetAlerts(ctx context.Context, filter AlertFilter) ([]model.Alert, error) {
+	query := `SELECT ` + alertColumns + ` FROM alerts WHERE 1=1`
+	args := []interface{}{}
+	argCount := 1
+
+	if filter.SocietyID != nil {
+		query += fmt.Sprintf(" AND society_id = $%d", argCount)
+		args = append(args, *filter.SocietyID)
+		argCount++
+	}
+
+	if filter.ResidenceID != nil {
+		query += fmt.Sprintf(" AND residence_id = $%d", argCount)
+		args = append(args, *filter.ResidenceID)
+		argCount++
+	}
+
+	if filter.Status != "" {
+		query += fmt.Sprintf(" AND status = $%d", argCount)
+		args = append(args, filter.Status)
+		argCount++
+	}
+
+	if filter.OnlyOpen {
+		query += " AND status <> 'RESOLVED'"
+	}
+
+	query += " ORDER BY created_at DESC LIMIT 200"
+
+	return db.queryAlerts(ctx, query, args...)
+}
+
+// AcknowledgeAlert moves a raised alert to acknowledged. Acknowledging an
+// already acknowledged alert is a no-op that returns the current state.
+func (db *DB) AcknowledgeAlert(ctx context.Context, id, userID string) (*model.Alert, error) {
+	alert, err := scanAlert(db.pool.QueryRow(ctx, `
+        UPDATE alerts
+        SET status = 'ACKNOWLEDGED', acknowledged_by = $2, acknowledged_at = NOW()
+        WHERE id = $1 AND status = 'RAISED'
+        RETURNING `+alertColumns, id, userID))
+	if errors.Is(err, ErrNotFound) {
+		return db.alertInState(ctx, id, model.AlertAcknowledged)
+	}
+
+	return alert, err
+}
+
+func (db *DB) ResolveAlert(ctx context.Context, id, userID string) (*model.Alert, error) {
+	alert, err := scanAlert(db.pool.QueryRow(ctx, `
+        UPDATE alerts
+        SET status = 'RESOLVED', resolved_by = $2, resolved_at = NOW(),
+            acknowledged_by = COALESCE(acknowledged_by, $2),
+            acknowledged_at = COALESCE(acknowledged_at, NOW())
+        WHERE id = $1 AND status <> 'RESOLVED'
+        RETURNING `+alertColumns, id, userID))
+	if errors.Is(err, ErrNotFound) {
+		return db.alertInState(ctx, id, "")
+	}
+
+	return alert, err
+}
+
+// alertInState explains why a state transition matched no row: the alert
+// either does not exist, is already in the wanted state, or is resolved.
+func (db *DB) alertInState(ctx context.Context, id string, want model.AlertStatus) (*model.Alert, error) {
+	alert, err := db.GetAlert(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if alert.Status == want {
+		return alert, nil
+	}
+
+	return nil, ErrAlertClosed
+}
+
+// GetAlertsDueForEscalation returns unacknowledged alerts that have passed
+// the delay of their next escalation step, together with that step.
+func (db *DB) GetAlertsDueForEscalation(ctx context.Context) ([]model.Alert, []model.AlertEscalationStep, error) {
+	rows, err := db.pool.Query(ctx, `
+        SELECT `+prefixColumns("a", alertColumns)+`,
+               s.level, s.after_seconds, s.roles::text[]
+        FROM alerts a
+        JOIN LATERAL (
+            SELECT level, after_seconds, roles
+            FROM alert_escalation_steps
+            WHERE level = a.escalation_level + 1
+              AND (society_id = a.society_id OR society_id IS NULL)
+            ORDER BY society_id NULLS LAST
+            LIMIT 1
+        ) s ON true
+        WHERE a.status = 'RAISED'
+          AND a.created_at + make_interval(secs => s.after_seconds) < NOW()
+        ORDER BY a.created_at
+    `)
+	if err != nil {
+		return nil, nil, fmt.Errorf("querying alerts to escalate: %w", err)
+	}
+	defer rows.Close()
+
+	var alerts []model.Alert
+	var steps []model.AlertEscalationStep
+	for rows.Next() {
+		var a model.Alert
+		var s model.AlertEscalationStep
+		var roles []string
+		if err := rows.Scan(
+			&a.ID, &a.SocietyID, &a.Type, &a.Status, &a.ResidenceID, &a.GateID,
+			&a.Message, &a.RaisedBy, &a.EscalationLevel, &a.LastEscalatedAt,
+			&a.AcknowledgedBy, &a.AcknowledgedAt, &a.ResolvedBy, &a.ResolvedAt,
+			&a.CreatedAt, &a.UpdatedAt,
+			&s.Level, &s.AfterSeconds, &roles,
+		); err != nil {
+			return nil, nil, fmt.Errorf("scanning alert row: %w", err)
+		}
+		for _, r := range roles {
+			s.Roles = append(s.Roles, model.UserRole(r))
+		}
+		alerts = append(alerts, a)
+		steps = append(steps, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("iterating alerts to escalate: %w", err)
+	}
+
+	return alerts, steps, nil
+}
+
+// MarkAlertEscalated records that an alert reached the given level. It
+// returns ErrNotFound if the alert was acknowledged or escalated by someone
+// else in the meantime.
+func (db *DB) MarkAlertEscalated(ctx context.Context, id string, level int) error {
+	result, err := db.pool.Exec(ctx, `
+        UPDATE alerts
+        SET escalation_level = $2, last_escalated_at = NOW()
+        WHERE id = $1 AND status = 'RAISED' AND escalation_level = $2 - 1
+    `, id, level)
+	if err != nil {
+		return fmt.Errorf("marking alert escalated: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (db *DB) queryAlerts(ctx context.Context, query string, args ...interface{}) ([]model.Alert, error) {
+	rows, err := db.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying alerts: %w", err)
+	}
+	defer rows.Close()
+
+	var alerts []model.Alert
+	for rows.Next() {
+		alert, err := scanAlert(rows)
+		if err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, *alert)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating alerts: %w", err)
+	}
+
+	return alerts, nil
+}
+
+func scanAlert(row pgx.Row) (*model.Alert, error) {
+	var a model.Alert
+	err := row.Scan(
+		&a.ID, &a.SocietyID, &a.Type, &a.Status, &a.ResidenceID, &a.GateID,
+		&a.Message, &a.RaisedBy, &a.EscalationLevel, &a.LastEscalatedAt,
+		&a.AcknowledgedBy, &a.AcknowledgedAt, &a.ResolvedBy, &a.ResolvedAt,
+		&a.CreatedAt, &a.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scanning alert: %w", err)
+	}
+
+	return &a, nil
+}
@@ -0,0 +1,470 @@
+package store
+
+import (
+	"context"
+	"dooreye-backend/internal/model"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+var (
+	ErrAmenityFull          = errors.New("amenity is fully booked for that time")
+	ErrAmenityWeeklyLimit   = errors.New("residence has reached this amenity's weekly booking limit")
+	ErrAmenityAlreadyBooked = errors.New("residence already has this amenity booked for an overlapping time")
+	ErrAmenityInactive      = errors.New("amenity is not accepting bookings")
+	// ErrInvalidAmenity reports settings rejected by the table's checks,
+	// such as closing before opening or a slot longer than the day.
+	ErrInvalidAmenity = errors.New("invalid amenity settings")
+)
+
+const amenityColumns = `
+    a.id, a.society_id, a.name, a.description, a.capacity, a.slot_minutes,
+    a.opens_at, a.closes_at, a.max_per_week, a.advance_days, a.fee_paise,
+    a.cancel_cutoff_hours, s.timezone, a.is_active, a.created_at, a.updated_at
+`
+
+type AmenityParams struct {
+	Name              string
+	Description       *string
+	Capacity          int
+	SlotMinutes       int
+	OpensAt           model.ClockTime
+	ClosesAt          model.ClockTime
+	MaxPerWeek        *int
+	AdvanceDays       int
+	FeePaise          int64
+	CancelCutoffHours *int
+}
+
+func (db *DB) CreateAmenity(ctx context.Context, societyID int64, params AmenityParams) (*model.Amenity, error) {
+	amenity, err := scanAmenity(db.pool.QueryRow(ctx, `
+        WITH a AS (
+            INSERT INTO amenities (
+                society_id, name, description, capacity, slot_minutes, opens_at,
+                closes_at, max_per_week, advance_days, fee_paise, cancel_cutoff_hours
+            )
+            VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+            RETURNING *
+        )
+        SELECT `+amenityColumns+`
+        FROM a
+        JOIN societies s ON s.id = a.society_id
+    `,
+		societyID,
+		params.Name,
+		params.Description,
+		params.Capacity,
+		params.SlotMinutes,
+		params.OpensAt,
+		params.ClosesAt,
+		params.MaxPerWeek,
+		params.AdvanceDays,
+		params.FeePaise,
+		params.CancelCutoffHours,
+	))
+	if err != nil {
+		switch {
+		case isPgError(err, pgUniqueViolation):
+			return nil, ErrAlreadyExists
+		case isPgError(err, pgCheckViolation):
+			return nil, fmt.Errorf("%w: %s", ErrInvalidAmenity, pgConstraint(err))
+		}
+		return nil, fmt.Errorf("creating amenity: %w", err)
+	}
+
+	return amenity, nil
+}
+
+// UpdateAmenity replaces an amenity's settings. Existing bookings are kept
+// as they are; the new rules apply to bookings made from now on.
+func (db *DB) UpdateAmenity(ctx context.Context, id int64, params AmenityParams) (*model.Amenity, error) {
+	amenity, err := scanAmenity(db.pool.QueryRow(ctx, `
+        WITH a AS (
+            UPDATE amenities
+            SET name = $2, description = $3, capacity = $4, slot_minutes = $5,
+                opens_at = $6, closes_at = $7, max_per_week = $8,
+                advance_days = $9, fee_paise = $10, cancel_cutoff_hours = $11
+            WHERE id = $1
+            RETURNING *
+        )
+        SELECT `+amenityColumns+`
+        FROM a
+        JOIN societies s ON s.id = a.society_id
+    `,
+		id,
+		params.Name,
+		params.Description,
+		params.Capacity,
+		params.SlotMinutes,
+		params.OpensAt,
+		params.ClosesAt,
+		params.MaxPerWeek,
+		params.AdvanceDays,
+		params.FeePaise,
+		params.CancelCutoffHours,
+	))
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		switch {
+		case isPgError(err, pgUniqueViolation):
+			return nil, ErrAlreadyExists
+		case isPgError(err, pgCheckViolation):
+			return nil, fmt.Errorf("%w: %s", ErrInvalidAmenity, pgConstraint(err))
+		}
+		return nil, fmt.Errorf("updating amenity: %w", err)
+	}
+
+	return amenity, err
+}
+
+func (db *DB) DeactivateAmenity(ctx context.Context, id int64) error {
+	result, err := db.pool.Exec(ctx, `
+        UPDATE amenities SET is_active = false
+        WHERE id = $1 AND is_active
+    `, id)
+	if err != nil {
+		return fmt.Errorf("deactivating amenity: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (db *DB) GetAmenity(ctx context.Context, id int64) (*model.Amenity, error) {
+	return scanAmenity(db.pool.QueryRow(ctx, `
+        SELECT `+amenityColumns+`
+        FROM amenities a
+        JOIN societies s ON s.id = a.society_id
+        WHERE a.id = $1
+    `, id))
+}
+
+func (db *DB) GetAmenities(ctx context.Context, societyID int64, includeInactive bool) ([]model.Amenity, error) {
+	query := `
+        SELECT ` + amenityColumns + `
+        FROM amenities a
+        JOIN societies s ON s.id = a.society_id
+        WHERE a.society_id = $1
+    `
+	if !includeInactive {
+		query += " AND a.is_active"
+	}
+	query += " ORDER BY a.name"
+
+	rows, err := db.pool.Query(ctx, query, societyID)
+	if err != nil {
+		return nil, fmt.Errorf("querying amenities: %w", err)
+	}
+	defer rows.Close()
+
+	var amenities []model.Amenity
+	for rows.Next() {
+		a, err := scanAmenity(rows)
+		if err != nil {
+			return nil, err
+		}
+		amenities = append(amenities, *a)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating amenities: %w", err)
+	}
+
+	return amenities, nil
+}
+
+const amenityBookingColumns = `
+    b.id, b.amenity_id, a.name, b.unit, b.residence_id, b.booked_by,
+    lower(b.period), upper(b.period), b.status, b.fee_paise, b.notes,
+    b.cancelled_at, b.cancelled_by, b.cancel_reason, b.created_at, b.updated_at
+`
+
+type CreateAmenityBookingParams struct {
+	Amenity     *model.Amenity
+	ResidenceID int64
+	BookedBy    string
+	From        time.Time
+	To          time.Time
+	Notes       *string
+	// Bounds of the week counted against Amenity.MaxPerWeek.
+	WeekFrom time.Time
+	WeekTo   time.Time
+}
+
+// CreateAmenityBooking reserves a free unit of the amenity. The residence
+// row is locked for the duration so concurrent requests from one household
+// cannot slip past the weekly limit; the exclusion constraints on the table
+// make double booking impossible regardless.
+func (db *DB) CreateAmenityBooking(ctx context.Context, params CreateAmenityBookingParams) (*model.AmenityBooking, error) {
+	var booking *model.AmenityBooking
+
+	err := db.RunInTx(ctx, func(tx pgx.Tx) error {
+		var active bool
+		err := tx.QueryRow(ctx, `SELECT is_active FROM amenities WHERE id = $1 FOR SHARE`, params.Amenity.ID).Scan(&active)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("locking amenity: %w", err)
+		}
+		if !active {
+			return ErrAmenityInactive
+		}
+
+		if _, err := tx.Exec(ctx, `SELECT 1 FROM residences WHERE id = $1 FOR UPDATE`, params.ResidenceID); err != nil {
+			return fmt.Errorf("locking residence: %w", err)
+		}
+
+		if params.Amenity.MaxPerWeek != nil {
+			var count int
+			err := tx.QueryRow(ctx, `
+                SELECT COUNT(*)
+                FROM amenity_bookings
+                WHERE amenity_id = $1 AND residence_id = $2
+                  AND status = 'CONFIRMED'
+                  AND lower(period) >= $3 AND lower(period) < $4
+            `, params.Amenity.ID, params.ResidenceID, params.WeekFrom, params.WeekTo).Scan(&count)
+			if err != nil {
+				return fmt.Errorf("counting weekly bookings: %w", err)
+			}
+			if count >= *params.Amenity.MaxPerWeek {
+				return ErrAmenityWeeklyLimit
+			}
+		}
+
+		for i := 0; i < slotAllocationAttempts; i++ {
+			booking, err = tryAmenityBooking(ctx, tx, params)
+			if errors.Is(err, errAmenityNoUnit) {
+				return ErrAmenityFull
+			}
+			if !errors.Is(err, ErrAmenityFull) {
+				return err
+			}
+		}
+		return ErrAmenityFull
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return booking, nil
+}
+
+// tryAmenityBooking runs in a savepoint so losing a unit to a concurrent
+// booking can be retried with the next free one.
+func tryAmenityBooking(ctx context.Context, q beginner, params CreateAmenityBookingParams) (*model.AmenityBooking, error) {
+	tx, err := q.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("beginning amenity booking: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var unit int
+	err = tx.QueryRow(ctx, `
+        SELECT u
+        FROM generate_series(1, $2::int) u
+        WHERE NOT EXISTS (
+            SELECT 1 FROM amenity_bookings b
+            WHERE b.amenity_id = $1 AND b.unit = u
+              AND b.status = 'CONFIRMED'
+              AND b.period && tstzrange($3, $4)
+        )
+        ORDER BY u
+        LIMIT 1
+    `, params.Amenity.ID, params.Amenity.Capacity, params.From, params.To).Scan(&unit)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, errAmenityNoUnit
+	}
+	if err != nil {
+		return nil, fmt.Errorf("selecting amenity unit: %w", err)
+	}
+
+	booking, err := scanAmenityBooking(tx.QueryRow(ctx, `
+        WITH b AS (
+            INSERT INTO amenity_bookings (amenity_id, unit, residence_id, booked_by, period, fee_paise, notes)
+            VALUES ($1, $2, $3, $4, tstzrange($5, $6), $7, $8)
+            RETURNING *
+        )
+        SELECT `+amenityBookingColumns+`
+        FROM b
+        JOIN amenities a ON a.id = b.amenity_id
+    `,
+		params.Amenity.ID,
+		unit,
+		params.ResidenceID,
+		params.BookedBy,
+		params.From,
+		params.To,
+		params.Amenity.FeePaise,
+		params.Notes,
+	))
+	if err != nil {
+		if isPgError(err, pgExclusionViolation) {
+			if pgConstraint(err) == "amenity_bookings_residence_overlap" {
+				return nil, ErrAmenityAlreadyBooked
+			}
+			return nil, ErrAmenityFull
+		}
+		return nil, fmt.Errorf("creating amenity booking: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("committing amenity booking: %w", err)
+	}
+
+	return booking, nil
+}
+
+// errAmenityNoUnit stops the retry loop: every unit is taken, so trying
+// again cannot help.
+var errAmenityNoUnit = fmt.Errorf("%w: no unit free", ErrAmenityFull)
+
+func (db *DB) GetAmenityBooking(ctx context.Context, id int64) (*model.AmenityBooking, error) {
+	return scanAmenityBooking(db.pool.QueryRow(ctx, `
+        SELECT `+amenityBookingColumns+`
+        FROM amenity_bookings b
+        JOIN amenities a ON a.id = b.amenity_id
+        WHERE b.id = $1
+    `, id))
+}
+
+type AmenityBookingFilter struct {
+	SocietyID     *int64
+	AmenityID     *int64
+	ResidenceID   *int64
+	From          *time.Time
+	To            *time.Time
+	OnlyConfirmed bool
+}
+
+func (db *DB) GetAmenityBookings(ctx context.Context, filter AmenityBookingFilter) ([]model.AmenityBooking, error) {
+	query := `
+        SELECT ` + amenityBookingColumns + `
+        FROM amenity_bookings b
+        JOIN amenities a ON a.id = b.amenity_id
+        WHERE 1=1
+    `
+	args := []interface{}{}
+	argCount := 1
+
+	if filter.SocietyID != nil {
+		query += fmt.Sprintf(" AND a.society_id = $%d", argCount)
+		args = append(args, *filter.SocietyID)
+		argCount++
+	}
+
+	if filter.AmenityID != nil {
+		query += fmt.Sprintf(" AND b.amenity_id = $%d", argCount)
+		args = append(args, *filter.AmenityID)
+		argCount++
+	}
+
+	if filter.ResidenceID != nil {
+		query += fmt.Sprintf(" AND b.residence_id = $%d", argCount)
+		args = append(args, *filter.ResidenceID)
+		argCount++
+	}
+
+	if filter.From != nil {
+		query += fmt.Sprintf(" AND upper(b.period) > $%d", argCount)
+		args = append(args, *filter.From)
+		argCount++
+	}
+
+	if filter.To != nil {
+		query += fmt.Sprintf(" AND lower(b.period) < $%d", argCount)
+		args = append(args, *filter.To)
+		argCount++
+	}
+
+	if filter.OnlyConfirmed {
+		query += " AND b.status = 'CONFIRMED'"
+	}
+
+	query += " ORDER BY lower(b.period), b.unit LIMIT 1000"
+
+	rows, err := db.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying amenity bookings: %w", err)
+	}
+	defer rows.Close()
+
+	var bookings []model.AmenityBooking
+	for rows.Next() {
+		b, err := scanAmenityBooking(rows)
+		if err != nil {
+			return nil, err
+		}
+		bookings = append(bookings, *b)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating amenity bookings: %w", err)
+	}
+
+	return bookings, nil
+}
+
+func (db *DB) CancelAmenityBooking(ctx context.Context, id int64, cancelledBy string, reason *string) (*model.AmenityBooking, error) {
+	booking, err := scanAmenityBooking(db.pool.QueryRow(ctx, `
+        WITH b AS (
+            UPDATE amenity_bookings
+            SET status = 'CANCELLED', cancelled_at = NOW(),
+                cancelled_by = $2, cancel_reason = $3
+            WHERE id = $1 AND status = 'CONFIRMED'
+            RETURNING *
+        )
+        SELECT `+amenityBookingColumns+`
+        FROM b
+        JOIN amenities a ON a.id = b.amenity_id
+    `, id, cancelledBy, reason))
+	if errors.Is(err, ErrNotFound) {
+		if _, err := db.GetAmenityBooking(ctx, id); err != nil {
+			return nil, err
+		}
+		return nil, ErrBookingClosed
+	}
+
+	return booking, err
+}
+
+func scanAmenity(row pgx.Row) (*model.Amenity, error) {
+	var a model.Amenity
+	err := row.Scan(
+		&a.ID, &a.SocietyID, &a.Name, &a.Description, &a.Capacity,
+		&a.SlotMinutes, &a.OpensAt, &a.ClosesAt, &a.MaxPerWeek, &a.AdvanceDays,
+		&a.FeePaise, &a.CancelCutoffHours, &a.Timezone, &a.IsActive,
+		&a.CreatedAt, &a.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scanning amenity: %w", err)
+	}
+
+	return &a, nil
+}
+
+func scanAmenityBooking(row pgx.Row) (*model.AmenityBooking, error) {
+	var b model.AmenityBooking
+	err := row.Scan(
+		&b.ID, &b.AmenityID, &b.AmenityName, &b.Unit, &b.ResidenceID,
+		&b.BookedBy, &b.StartsAt, &b.EndsAt, &b.Status, &b.FeePaise, &b.Notes,
+		&b.CancelledAt, &b.CancelledBy, &b.CancelReason, &b.CreatedAt,
+		&b.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scanning amenity booking: %w", err)
+	}
+
+	return &b, nil
+}
@@ -0,0 +1,555 @@
+package store
+
+import (
+	"context"
+	"dooreye-backend/internal/model"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// JobAnnouncementFanout is the job kind that notifies an announcement's
+// audience once it is published.
+const JobAnnouncementFanout = "announcement-fanout"
+
+var (
+	ErrAnnouncementPublished = errors.New("announcement is already published")
+	ErrInvalidBlocks         = errors.New("block_ids must be blocks of the society")
+	ErrInvalidExpiry         = errors.New("expires_at must be after published_at")
+)
+
+const announcementColumns = `
+    a.id, a.society_id, a.title, a.body, a.block_ids, a.roles::text[],
+    a.is_urgent, a.is_pinned, a.published_at, a.expires_at, a.notified_at,
+    a.withdrawn_at, a.created_by, a.created_at, a.updated_at
+`
+
+// announcementAudience describes the user $1 within society $2: one row per
+// residence membership with its block, plus one row for society staff.
+const announcementAudience = `
+    WITH me AS (
+        SELECT r.block_id, ur.role::text AS role
+        FROM user_residences ur
+        JOIN residences r ON r.id = ur.residence_id
+        JOIN blocks b ON b.id = r.block_id
+        WHERE ur.user_id = $1 AND b.society_id = $2
+        UNION ALL
+        SELECT NULL, u.role::text
+        FROM users u
+        WHERE u.id = $1 AND u.society_id = $2
+          AND u.role IN ('SOCIETY_MANAGER', 'SECURITY')
+    )
+`
+
+// announcementVisible matches live announcements of society $2 targeted at
+// any row of the announcementAudience.
+const announcementVisible = `
+    a.society_id = $2
+    AND a.published_at <= NOW()
+    AND a.withdrawn_at IS NULL
+    AND (a.expires_at IS NULL OR a.expires_at > NOW())
+    AND EXISTS (
+        SELECT 1 FROM me
+        WHERE (cardinality(a.block_ids) = 0 OR me.block_id IS NULL OR me.block_id = ANY(a.block_ids))
+          AND (cardinality(a.roles) = 0 OR me.role = ANY(a.roles::text[]))
+    )
+`
+
+type AnnouncementAttachmentParams struct {
+	URL         string
+	Name        string
+	ContentType *string
+}
+
+type CreateAnnouncementParams struct {
+	SocietyID   int64
+	Title       string
+	Body        string
+	BlockIDs    []int64
+	Roles       []model.UserRole
+	IsUrgent    bool
+	IsPinned    bool
+	ExpiresAt   *time.Time
+	Attachments []AnnouncementAttachmentParams
+	CreatedBy   string
+	// Publishes immediately, or at a later time, when set. Left nil the
+	// announcement stays a draft.
+	PublishAt *time.Time
+}
+
+func (db *DB) CreateAnnouncement(ctx context.Context, params CreateAnnouncementParams) (*model.Announcement, error) {
+	if params.BlockIDs == nil {
+		params.BlockIDs = []int64{}
+	}
+	roles := make([]string, len(params.Roles))
+	for i, r := range params.Roles {
+		roles[i] = string(r)
+	}
+
+	var id int64
+	err := db.RunInTx(ctx, func(tx pgx.Tx) error {
+		if len(params.BlockIDs) > 0 {
+			var matched int
+			err := tx.QueryRow(ctx, `
+                SELECT COUNT(DISTINCT id) FROM blocks
+                WHERE society_id = $1 AND id = ANY($2)
+            `, params.SocietyID, params.BlockIDs).Scan(&matched)
+			if err != nil {
+				return fmt.Errorf("checking announcement blocks: %w", err)
+			}
+			if matched != len(uniqueInt64s(params.BlockIDs)) {
+				return ErrInvalidBlocks
+			}
+		}
+
+		err := tx.QueryRow(ctx, `
+            INSERT INTO announcements (
+                society_id, title, body, block_ids, roles, is_urgent, is_pinned,
+                expires_at, created_by
+            )
+            VALUES ($1, $2, $3, $4, $5::user_role[], $6, $7, $8, $9)
+            RETURNING id
+        `,
+			params.SocietyID,
+			params.Title,
+			params.Body,
+			params.BlockIDs,
+			roles,
+			params.IsUrgent,
+			params.IsPinned,
+			params.ExpiresAt,
+			params.CreatedBy,
+		).Scan(&id)
+		if err != nil {
+			if isPgError(err, pgCheckViolation) {
+				return ErrInvalidExpiry
+			}
+			return fmt.Errorf("creating announcement: %w", err)
+		}
+
+		for _, att := range params.Attachments {
+			if _, err := tx.Exec(ctx, `
+                INSERT INTO announcement_attachments (announcement_id, url, name, content_type)
+                VALUES ($1, $2, $3, $4)
+            `, id, att.URL, att.Name, att.ContentType); err != nil {
+				return fmt.Errorf("adding announcement attachment: %w", err)
+			}
+		}
+
+		if params.PublishAt != nil {
+			return publishAnnouncement(ctx, tx, id, *params.PublishAt)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return db.GetAnnouncement(ctx, id)
+}
+
+// PublishAnnouncement makes a draft visible at the given time and queues
+// the notification fan-out to run then. Both happen in one transaction, so
+// a published announcement is always announced.
+func (db *DB) PublishAnnouncement(ctx context.Context, id int64, at time.Time) (*model.Announcement, error) {
+	err := db.RunInTx(ctx, func(tx pgx.Tx) error {
+		return publishAnnouncement(ctx, tx, id, at)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return db.GetAnnouncement(ctx, id)
+}
+
+func publishAnnouncement(ctx context.Context, tx pgx.Tx, id int64, at time.Time) error {
+	result, err := tx.Exec(ctx, `
+        UPDATE announcements SET published_at = $2
+        WHERE id = $1 AND published_at IS NULL AND withdrawn_at IS NULL
+    `, id, at)
+	if err != nil {
+		if isPgError(err, pgCheckViolation) {
+			return ErrInvalidExpiry
+		}
+		return fmt.Errorf("publishing announcement: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrAnnouncementPublished
+	}
+
+	payload, err := json.Marshal(map[string]int64{"announcement_id": id})
+	if err != nil {
+		return fmt.Errorf("encoding fan-out payload: %w", err)
+	}
+
+	key := JobAnnouncementFanout + ":" + strconv.FormatInt(id, 10)
+	_, err = enqueueJob(ctx, tx, EnqueueJobParams{
+		Kind:      JobAnnouncementFanout,
+		Payload:   payload,
+		RunAt:     at,
+		UniqueKey: &key,
+	})
+	if err != nil && !errors.Is(err, ErrAlreadyExists) {
+		return err
+	}
+
+	return nil
+}
+
+type UpdateAnnouncementParams struct {
+	// Content can only change while the announcement is a draft.
+	Title *string
+	Body  *string
+
+	IsPinned    *bool
+	ExpiresAt   *time.Time
+	ClearExpiry bool
+}
+
+func (db *DB) UpdateAnnouncement(ctx context.Context, id int64, params UpdateAnnouncementParams) (*model.Announcement, error) {
+	result, err := db.pool.Exec(ctx, `
+        UPDATE announcements
+        SET title = COALESCE($2, title),
+            body = COALESCE($3, body),
+            is_pinned = COALESCE($4, is_pinned),
+            expires_at = CASE WHEN $6 THEN NULL ELSE COALESCE($5, expires_at) END
+        WHERE id = $1 AND withdrawn_at IS NULL
+          AND (published_at IS NULL OR ($2::text IS NULL AND $3::text IS NULL))
+    `, id, params.Title, params.Body, params.IsPinned, params.ExpiresAt, params.ClearExpiry)
+	if err != nil {
+		if isPgError(err, pgCheckViolation) {
+			return nil, ErrInvalidExpiry
+		}
+		return nil, fmt.Errorf("updating announcement: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		a, err := db.GetAnnouncement(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if a.WithdrawnAt != nil {
+			return nil, ErrNotFound
+		}
+		return nil, ErrAnnouncementPublished
+	}
+
+	return db.GetAnnouncement(ctx, id)
+}
+
+// WithdrawAnnouncement takes an announcement off every feed. Read receipts
+// are kept.
+func (db *DB) WithdrawAnnouncement(ctx context.Context, id int64) error {
+	result, err := db.pool.Exec(ctx, `
+        UPDATE announcements SET withdrawn_at = NOW()
+        WHERE id = $1 AND withdrawn_at IS NULL
+    `, id)
+	if err != nil {
+		return fmt.Errorf("withdrawing announcement: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (db *DB) GetAnnouncement(ctx context.Context, id int64) (*model.Announcement, error) {
+	var readCount int
+	a, err := scanAnnouncement(db.pool.QueryRow(ctx, `
+        SELECT `+announcementColumns+`,
+               (SELECT COUNT(*) FROM announcement_reads r WHERE r.announcement_id = a.id)
+        FROM announcements a
+        WHERE a.id = $1
+    `, id), &readCount)
+	if err != nil {
+		return nil, err
+	}
+	a.ReadCount = &readCount
+
+	announcements := []model.Announcement{*a}
+	if err := db.loadAnnouncementAttachments(ctx, announcements); err != nil {
+		return nil, err
+	}
+
+	return &announcements[0], nil
+}
+
+// GetAnnouncements is the manager listing: drafts, scheduled, live and
+// withdrawn announcements with their read counts.
+func (db *DB) GetAnnouncements(ctx context.Context, societyID int64, limit, offset int) ([]model.Announcement, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	rows, err := db.pool.Query(ctx, `
+        SELECT `+announcementColumns+`,
+               (SELECT COUNT(*) FROM announcement_reads r WHERE r.announcement_id = a.id)
+        FROM announcements a
+        WHERE a.society_id = $1
+        ORDER BY COALESCE(a.published_at, a.created_at) DESC
+        LIMIT $2 OFFSET $3
+    `, societyID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("querying announcements: %w", err)
+	}
+	defer rows.Close()
+
+	var announcements []model.Announcement
+	for rows.Next() {
+		var readCount int
+		a, err := scanAnnouncement(rows, &readCount)
+		if err != nil {
+			return nil, err
+		}
+		a.ReadCount = &readCount
+		announcements = append(announcements, *a)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating announcements: %w", err)
+	}
+
+	if err := db.loadAnnouncementAttachments(ctx, announcements); err != nil {
+		return nil, err
+	}
+
+	return announcements, nil
+}
+
+type AnnouncementFeedParams struct {
+	UserID     string
+	SocietyID  int64
+	OnlyUnread bool
+	Limit      int
+	Offset     int
+}
+
+// GetAnnouncementFeed lists the live announcements addressed to a user,
+// pinned ones first, each with the user's read time.
+func (db *DB) GetAnnouncementFeed(ctx context.Context, params AnnouncementFeedParams) ([]model.Announcement, error) {
+	if params.Limit <= 0 || params.Limit > 100 {
+		params.Limit = 20
+	}
+
+	query := announcementAudience + `
+        SELECT ` + announcementColumns + `, r.read_at
+        FROM announcements a
+        LEFT JOIN announcement_reads r ON r.announcement_id = a.id AND r.user_id = $1
+        WHERE ` + announcementVisible
+	if params.OnlyUnread {
+		query += " AND r.read_at IS NULL"
+	}
+	query += " ORDER BY a.is_pinned DESC, a.published_at DESC LIMIT $3 OFFSET $4"
+
+	rows, err := db.pool.Query(ctx, query, params.UserID, params.SocietyID, params.Limit, params.Offset)
+	if err != nil {
+		return nil, fmt.Errorf("querying announcement feed: %w", err)
+	}
+	defer rows.Close()
+
+	var announcements []model.Announcement
+	for rows.Next() {
+		var readAt *time.Time
+		a, err := scanAnnouncement(rows, &readAt)
+		if err != nil {
+			return nil, err
+		}
+		a.ReadAt = readAt
+		announcements = append(announcements, *a)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating announcement feed: %w", err)
+	}
+
+	if err := db.loadAnnouncementAttachments(ctx, announcements); err != nil {
+		return nil, err
+	}
+
+	return announcements, nil
+}
+
+func (db *DB) CountUnreadAnnouncements(ctx context.Context, userID string, societyID int64) (int, error) {
+	var count int
+	err := db.pool.QueryRow(ctx, announcementAudience+`
+        SELECT COUNT(*)
+        FROM announcements a
+        WHERE `+announcementVisible+`
+          AND NOT EXISTS (
+              SELECT 1 FROM announcement_reads r
+              WHERE r.announcement_id = a.id AND r.user_id = $1
+          )
+    `, userID, societyID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("counting unread announcements: %w", err)
+	}
+
+	return count, nil
+}
+
+// MarkAnnouncementRead records a read receipt. Announcements the user cannot
+// see are reported as not found.
+func (db *DB) MarkAnnouncementRead(ctx context.Context, id int64, userID string, societyID int64) error {
+	var visible bool
+	err := db.pool.QueryRow(ctx, announcementAudience+`
+        SELECT EXISTS (
+            SELECT 1 FROM announcements a
+            WHERE a.id = $3 AND `+announcementVisible+`
+        )
+    `, userID, societyID, id).Scan(&visible)
+	if err != nil {
+		return fmt.Errorf("checking announcement visibility: %w", err)
+	}
+	if !visible {
+		return ErrNotFound
+	}
+
+	_, err = db.pool.Exec(ctx, `
+        INSERT INTO announcement_reads (announcement_id, user_id)
+        VALUES ($1, $2)
+        ON CONFLICT DO NOTHING
+    `, id, userID)
+	if err != nil {
+		return fmt.Errorf("marking announcement read: %w", err)
+	}
+
+	return nil
+}
+
+func (db *DB) MarkAllAnnouncementsRead(ctx context.Context, userID string, societyID int64) (int64, error) {
+	result, err := db.pool.Exec(ctx, announcementAudience+`
+        INSERT INTO announcement_reads (announcement_id, user_id)
+        SELECT a.id, $1
+        FROM announcements a
+        WHERE `+announcementVisible+`
+        ON CONFLICT DO NOTHING
+    `, userID, societyID)
+	if err != nil {
+		return 0, fmt.Errorf("marking announcements read: %w", err)
+	}
+
+	return result.RowsAffected(), nil
+}
+
+// GetAnnouncementRecipients resolves the active users an announcement is
+// addressed to, using the same targeting as the feed.
+func (db *DB) GetAnnouncementRecipients(ctx context.Context, id int64) ([]string, error) {
+	return db.queryUserIDs(ctx, `
+        SELECT DISTINCT aud.user_id
+        FROM announcements a
+        CROSS JOIN LATERAL (
+            SELECT ur.user_id, r.block_id, ur.role::text AS role
+            FROM user_residences ur
+            JOIN residences r ON r.id = ur.residence_id
+            JOIN blocks b ON b.id = r.block_id
+            JOIN users u ON u.id = ur.user_id
+            WHERE b.society_id = a.society_id AND u.is_active
+            UNION ALL
+            SELECT u.id, NULL, u.role::text
+            FROM users u
+            WHERE u.society_id = a.society_id AND u.is_active
+              AND u.role IN ('SOCIETY_MANAGER', 'SECURITY')
+        ) aud
+        WHERE a.id = $1
+          AND (cardinality(a.block_ids) = 0 OR aud.block_id IS NULL OR aud.block_id = ANY(a.block_ids))
+          AND (cardinality(a.roles) = 0 OR aud.role = ANY(a.roles::text[]))
+    `, id)
+}
+
+func (db *DB) MarkAnnouncementNotified(ctx context.Context, id int64) error {
+	_, err := db.pool.Exec(ctx, `UPDATE announcements SET notified_at = NOW() WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("marking announcement notified: %w", err)
+	}
+
+	return nil
+}
+
+func (db *DB) loadAnnouncementAttachments(ctx context.Context, announcements []model.Announcement) error {
+	if len(announcements) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(announcements))
+	index := make(map[int64]int, len(announcements))
+	for i, a := range announcements {
+		ids[i] = a.ID
+		index[a.ID] = i
+		announcements[i].Attachments = []model.AnnouncementAttachment{}
+	}
+
+	rows, err := db.pool.Query(ctx, `
+        SELECT id, announcement_id, url, name, content_type, created_at
+        FROM announcement_attachments
+        WHERE announcement_id = ANY($1)
+        ORDER BY id
+    `, ids)
+	if err != nil {
+		return fmt.Errorf("querying announcement attachments: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var att model.AnnouncementAttachment
+		if err := rows.Scan(
+			&att.ID, &att.AnnouncementID, &att.URL, &att.Name, &att.ContentType,
+			&att.CreatedAt,
+		); err != nil {
+			return fmt.Errorf("scanning announcement attachment: %w", err)
+		}
+		i := index[att.AnnouncementID]
+		announcements[i].Attachments = append(announcements[i].Attachments, att)
+	}
+
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("iterating announcement attachments: %w", err)
+	}
+
+	return nil
+}
+
+// scanAnnouncement scans announcementColumns followed by any extra columns
+// the query selected.
+func scanAnnouncement(row pgx.Row, extra ...interface{}) (*model.Announcement, error) {
+	var a model.Announcement
+	var roles []string
+	dest := append([]interface{}{
+		&a.ID, &a.SocietyID, &a.Title, &a.Body, &a.BlockIDs, &roles,
+		&a.IsUrgent, &a.IsPinned, &a.PublishedAt, &a.ExpiresAt, &a.NotifiedAt,
+		&a.WithdrawnAt, &a.CreatedBy, &a.CreatedAt, &a.UpdatedAt,
+	}, extra...)
+
+	err := row.Scan(dest...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scanning announcement: %w", err)
+	}
+
+	a.Roles = make([]model.UserRole, len(roles))
+	for i, r := range roles {
+		a.Roles[i] = model.UserRole(r)
+	}
+
+	return &a, nil
+}
+
+func uniqueInt64s(ids []int64) []int64 {
+	seen := make(map[int64]bool, len(ids))
+	var out []int64
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	return out
+}
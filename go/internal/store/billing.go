@@ -0,0 +1,1044 @@
+package store
+
+import (
+	"context"
+	"dooreye-backend/internal/model"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+var (
+	ErrBlockNotInSociety     = errors.New("block does not belong to the society")
+	ErrPaymentAmountMismatch = errors.New("payment amount does not match the gateway's")
+	ErrPaymentNotPending     = errors.New("payment has already completed")
+)
+
+const billingRuleColumns = `
+    id, society_id, block_id, name, kind, rate_paise, is_active, created_at,
+    updated_at
+`
+
+// invoiceColumns ends with the society's current date, which an invoice's
+// status is judged against.
+const invoiceColumns = `
+    id, society_id, residence_id, period_start, period_end, due_date,
+    total_paise, paid_paise, late_fee_applied_at, issued_at, updated_at,
+    (NOW() AT TIME ZONE (SELECT s.timezone FROM societies s WHERE s.id = society_id))::date
+`
+
+const paymentColumns = `
+    id, society_id, residence_id, amount_paise, method, status, provider,
+    reference, failure_reason, created_by, completed_at, created_at, updated_at
+`
+
+// GetBillingSettings returns a society's billing settings, or ErrNotFound
+// if billing has not been set up for it.
+func (db *DB) GetBillingSettings(ctx context.Context, societyID int64) (*model.BillingSettings, error) {
+	return scanBillingSettings(db.pool.QueryRow(ctx, `
+        SELECT bs.society_id, bs.cycle_months, bs.due_days, bs.late_fee_paise,
+               bs.grace_days, s.timezone, bs.updated_at
+        FROM billing_settings bs
+        JOIN societies s ON s.id = bs.society_id
+        WHERE bs.society_id = $1
+    `, societyID))
+}
+
+type BillingSettingsParams struct {
+	CycleMonths  int
+	DueDays      int
+	LateFeePaise int64
+	GraceDays    int
+}
+
+func (db *DB) UpsertBillingSettings(ctx context.Context, societyID int64, params BillingSettingsParams) (*model.BillingSettings, error) {
+	_, err := db.pool.Exec(ctx, `
+        INSERT INTO billing_settings (society_id, cycle_months, due_days, late_fee_paise, grace_days)
+        VALUES ($1, $2, $3, $4, $5)
+        ON CONFLICT (society_id)
+        DO UPDATE SET cycle_months = EXCLUDED.cycle_months,
+                      due_days = EXCLUDED.due_days,
+                      late_fee_paise = EXCLUDED.late_fee_paise,
+                      grace_days = EXCLUDED.grace_days
+    `, societyID, params.CycleMonths, params.DueDays, params.LateFeePaise, params.GraceDays)
+	if err != nil {
+		if isPgError(err, pgForeignKeyViolation) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("upserting billing settings: %w", err)
+	}
+
+	return db.GetBillingSettings(ctx, societyID)
+}
+
+// GetBilledSocieties returns the settings of every society that has billing
+// set up and at least one active rule.
+func (db *DB) GetBilledSocieties(ctx context.Context) ([]model.BillingSettings, error) {
+	rows, err := db.pool.Query(ctx, `
+        SELECT bs.society_id, bs.cycle_months, bs.due_days, bs.late_fee_paise,
+               bs.grace_days, s.timezone, bs.updated_at
+        FROM billing_settings bs
+        JOIN societies s ON s.id = bs.society_id
+        WHERE EXISTS (
+            SELECT 1 FROM billing_rules r WHERE r.society_id = bs.society_id AND r.is_active
+        )
+        ORDER BY bs.society_id
+    `)
+	if err != nil {
+		return nil, fmt.Errorf("querying billed societies: %w", err)
+	}
+	defer rows.Close()
+
+	var all []model.BillingSettings
+	for rows.Next() {
+		s, err := scanBillingSettings(rows)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, *s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating billed societies: %w", err)
+	}
+
+	return all, nil
+}
+
+type BillingRuleParams struct {
+	BlockID   *int64
+	Name      string
+	Kind      model.BillingRuleKind
+	RatePaise int64
+}
+
+func (db *DB) CreateBillingRule(ctx context.Context, societyID int64, params BillingRuleParams) (*model.BillingRule, error) {
+	rule, err := scanBillingRule(db.pool.QueryRow(ctx, `
+        INSERT INTO billing_rules (society_id, block_id, name, kind, rate_paise)
+        SELECT $1, $2, $3, $4, $5
+        WHERE $2::bigint IS NULL OR EXISTS (
+            SELECT 1 FROM blocks WHERE id = $2 AND society_id = $1
+        )
+        RETURNING `+billingRuleColumns,
+		societyID, params.BlockID, params.Name, params.Kind, params.RatePaise,
+	))
+	if errors.Is(err, ErrNotFound) {
+		return nil, ErrBlockNotInSociety
+	}
+	if err != nil {
+		return nil, fmt.Errorf("creating billing rule: %w", err)
+	}
+
+	return rule, nil
+}
+
+func (db *DB) UpdateBillingRule(ctx context.Context, id int64, params BillingRuleParams) (*model.BillingRule, error) {
+	rule, err := scanBillingRule(db.pool.QueryRow(ctx, `
+        UPDATE billing_rules r
+        SET block_id = $2, name = $3, kind = $4, rate_paise = $5
+        WHERE r.id = $1 AND ($2::bigint IS NULL OR EXISTS (
+            SELECT 1 FROM blocks b WHERE b.id = $2 AND b.society_id = r.society_id
+        ))
+        RETURNING `+prefixColumns("r", billingRuleColumns),
+		id, params.BlockID, params.Name, params.Kind, params.RatePaise,
+	))
+	if errors.Is(err, ErrNotFound) {
+		if _, err := db.GetBillingRule(ctx, id); err != nil {
+			return nil, err
+		}
+		return nil, ErrBlockNotInSociety
+	}
+	if err != nil {
+		return nil, fmt.Errorf("updating billing rule: %w", err)
+	}
+
+	return rule, nil
+}
+
+// DeactivateBillingRule stops a rule from billing future periods. Invoices
+// already issued keep their lines.
+func (db *DB) DeactivateBillingRule(ctx context.Context, id int64) error {
+	tag, err := db.pool.Exec(ctx, `UPDATE billing_rules SET is_active = false WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("deactivating billing rule: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (db *DB) GetBillingRule(ctx context.Context, id int64) (*model.BillingRule, error) {
+	return scanBillingRule(db.pool.QueryRow(ctx, `SELECT `+billingRuleColumns+` FROM billing_rules WHERE id = $1`, id))
+}
+
+func (db *DB) GetBillingRules(ctx context.Context, societyID int64, includeInactive bool) ([]model.BillingRule, error) {
+	rows, err := db.pool.Query(ctx, `
+        SELECT `+billingRuleColumns+`
+        FROM billing_rules
+        WHERE society_id = $1 AND (is_active OR $2)
+        ORDER BY id
+    `, societyID, includeInactive)
+	if err != nil {
+		return nil, fmt.Errorf("querying billing rules: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []model.BillingRule
+	for rows.Next() {
+		r, err := scanBillingRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, *r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating billing rules: %w", err)
+	}
+
+	return rules, nil
+}
+
+// GetBillableResidences returns every residence of a society with what the
+// billing rules need to know about it.
+func (db *DB) GetBillableResidences(ctx context.Context, societyID int64) ([]model.BillableResidence, error) {
+	rows, err := db.pool.Query(ctx, `
+        SELECT r.id, r.block_id, r.number, r.floor, r.area_sqft
+        FROM residences r
+        JOIN blocks b ON b.id = r.block_id
+        WHERE b.society_id = $1
+        ORDER BY r.id
+    `, societyID)
+	if err != nil {
+		return nil, fmt.Errorf("querying billable residences: %w", err)
+	}
+	defer rows.Close()
+
+	var residences []model.BillableResidence
+	for rows.Next() {
+		var r model.BillableResidence
+		if err := rows.Scan(&r.ID, &r.BlockID, &r.Number, &r.Floor, &r.AreaSqft); err != nil {
+			return nil, fmt.Errorf("scanning billable residence: %w", err)
+		}
+		residences = append(residences, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating billable residences: %w", err)
+	}
+
+	return residences, nil
+}
+
+// SetResidenceArea records a residence's built-up area. nil clears it.
+func (db *DB) SetResidenceArea(ctx context.Context, residenceID int64, areaSqft *int) (*model.BillableResidence, error) {
+	var r model.BillableResidence
+	err := db.pool.QueryRow(ctx, `
+        UPDATE residences SET area_sqft = $2
+        WHERE id = $1
+        RETURNING id, block_id, number, floor, area_sqft
+    `, residenceID, areaSqft).Scan(&r.ID, &r.BlockID, &r.Number, &r.Floor, &r.AreaSqft)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("setting residence area: %w", err)
+	}
+
+	return &r, nil
+}
+
+type CreateInvoiceParams struct {
+	SocietyID   int64
+	ResidenceID int64
+	PeriodStart time.Time
+	PeriodEnd   time.Time
+	DueDate     time.Time
+	Lines       []model.InvoiceLine
+}
+
+// CreateInvoice issues an invoice and charges its total to the residence's
+// receivable. It returns ErrAlreadyExists if the residence has already been
+// invoiced for the period.
+func (db *DB) CreateInvoice(ctx context.Context, params CreateInvoiceParams) (*model.Invoice, error) {
+	var total int64
+	for _, l := range params.Lines {
+		total += l.AmountPaise
+	}
+
+	var inv *model.Invoice
+
+	err := db.RunInTx(ctx, func(tx pgx.Tx) error {
+		if err := lockResidence(ctx, tx, params.ResidenceID); err != nil {
+			return err
+		}
+
+		var err error
+		inv, err = scanInvoice(tx.QueryRow(ctx, `
+            INSERT INTO invoices (
+                society_id, residence_id, period_start, period_end, due_date, total_paise
+            )
+            VALUES ($1, $2, $3, $4, $5, $6)
+            ON CONFLICT (residence_id, period_start) DO NOTHING
+            RETURNING `+invoiceColumns,
+			params.SocietyID,
+			params.ResidenceID,
+			params.PeriodStart,
+			params.PeriodEnd,
+			params.DueDate,
+			total,
+		))
+		if errors.Is(err, ErrNotFound) {
+			return ErrAlreadyExists
+		}
+		if err != nil {
+			return fmt.Errorf("creating invoice: %w", err)
+		}
+
+		for _, l := range params.Lines {
+			line, err := addInvoiceLine(ctx, tx, inv.ID, l)
+			if err != nil {
+				return err
+			}
+			inv.Lines = append(inv.Lines, *line)
+		}
+
+		if total == 0 {
+			return nil
+		}
+
+		err = postLedger(ctx, tx, ledgerPosting{
+			SocietyID:   params.SocietyID,
+			ResidenceID: params.ResidenceID,
+			Kind:        model.EntryCharge,
+			Memo:        "Maintenance " + params.PeriodStart.Format("Jan 2006"),
+			InvoiceID:   &inv.ID,
+			Debit:       model.AccountReceivable,
+			Credit:      model.AccountMaintenanceIncome,
+			AmountPaise: total,
+		})
+		if err != nil {
+			return err
+		}
+
+		return allocatePayments(ctx, tx, params.ResidenceID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return db.GetInvoice(ctx, inv.ID)
+}
+
+func (db *DB) GetInvoice(ctx context.Context, id int64) (*model.Invoice, error) {
+	inv, err := scanInvoice(db.pool.QueryRow(ctx, `SELECT `+invoiceColumns+` FROM invoices WHERE id = $1`, id))
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.pool.Query(ctx, `
+        SELECT id, invoice_id, kind, rule_id, description, amount_paise
+        FROM invoice_lines
+        WHERE invoice_id = $1
+        ORDER BY id
+    `, id)
+	if err != nil {
+		return nil, fmt.Errorf("querying invoice lines: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		line, err := scanInvoiceLine(rows)
+		if err != nil {
+			return nil, err
+		}
+		inv.Lines = append(inv.Lines, *line)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating invoice lines: %w", err)
+	}
+
+	return inv, nil
+}
+
+type InvoiceFilter struct {
+	SocietyID   int64
+	ResidenceID *int64
+	// Only invoices with an outstanding amount.
+	UnpaidOnly bool
+	Limit      int
+	Offset     int
+}
+
+func (db *DB) GetInvoices(ctx context.Context, filter InvoiceFilter) ([]model.Invoice, error) {
+	query := `SELECT ` + invoiceColumns + ` FROM invoices WHERE society_id = $1`
+	args := []interface{}{filter.SocietyID}
+
+	if filter.ResidenceID != nil {
+		args = append(args, *filter.ResidenceID)
+		query += fmt.Sprintf(" AND residence_id = $%d", len(args))
+	}
+	if filter.UnpaidOnly {
+		query += " AND paid_paise < total_paise"
+	}
+
+	if filter.Limit <= 0 || filter.Limit > 100 {
+		filter.Limit = 50
+	}
+	args = append(args, filter.Limit, filter.Offset)
+	query += fmt.Sprintf(" ORDER BY period_start DESC, residence_id LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+
+	return db.queryInvoices(ctx, query, args...)
+}
+
+// ApplyLateFees adds the society's late fee to every invoice still unpaid
+// grace_days after it fell due, judged in the society's time zone. Each
+// invoice is charged at most once. Residences are charged one transaction
+// at a time, so a run never holds more than one residence's lock and
+// payments elsewhere go ahead. It returns the invoices charged, including
+// those committed before any error.
+func (db *DB) ApplyLateFees(ctx context.Context) ([]model.Invoice, error) {
+	// Candidates are read without locks; each is rechecked after its
+	// residence is locked, so residences are always locked before their
+	// invoices, as payments do.
+	rows, err := db.pool.Query(ctx, `
+        SELECT i.id, i.society_id, i.residence_id, i.period_start, bs.late_fee_paise
+        FROM invoices i
+        JOIN billing_settings bs ON bs.society_id = i.society_id
+        JOIN societies s ON s.id = i.society_id
+        WHERE i.paid_paise < i.total_paise
+          AND i.late_fee_applied_at IS NULL
+          AND bs.late_fee_paise > 0
+          AND (NOW() AT TIME ZONE s.timezone)::date > i.due_date + bs.grace_days
+        ORDER BY i.residence_id, i.id
+    `)
+	if err != nil {
+		return nil, fmt.Errorf("querying overdue invoices: %w", err)
+	}
+
+	var due []overdueInvoice
+	for rows.Next() {
+		var o overdueInvoice
+		if err := rows.Scan(&o.id, &o.societyID, &o.residenceID, &o.periodStart, &o.fee); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scanning overdue invoice: %w", err)
+		}
+		due = append(due, o)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating overdue invoices: %w", err)
+	}
+
+	var charged []model.Invoice
+	for len(due) > 0 {
+		n := 1
+		for n < len(due) && due[n].residenceID == due[0].residenceID {
+			n++
+		}
+
+		var batch []model.Invoice
+		err := db.RunInTx(ctx, func(tx pgx.Tx) error {
+			var err error
+			batch, err = applyLateFees(ctx, tx, due[:n])
+			return err
+		})
+		if err != nil {
+			return charged, err
+		}
+		charged = append(charged, batch...)
+		due = due[n:]
+	}
+
+	return charged, nil
+}
+
+type overdueInvoice struct {
+	id, societyID, residenceID int64
+	periodStart                time.Time
+	fee                        int64
+}
+
+// applyLateFees charges one residence's overdue invoices under its lock.
+func applyLateFees(ctx context.Context, tx pgx.Tx, due []overdueInvoice) ([]model.Invoice, error) {
+	if err := lockResidence(ctx, tx, due[0].residenceID); err != nil {
+		return nil, err
+	}
+
+	var charged []model.Invoice
+	for _, o := range due {
+		var stillDue bool
+		err := tx.QueryRow(ctx, `
+            SELECT paid_paise < total_paise AND late_fee_applied_at IS NULL
+            FROM invoices
+            WHERE id = $1
+        `, o.id).Scan(&stillDue)
+		if err != nil {
+			return nil, fmt.Errorf("rechecking overdue invoice: %w", err)
+		}
+		if !stillDue {
+			continue
+		}
+
+		_, err = addInvoiceLine(ctx, tx, o.id, model.InvoiceLine{
+			Kind:        model.EntryPenalty,
+			Description: "Late payment fee",
+			AmountPaise: o.fee,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		inv, err := scanInvoice(tx.QueryRow(ctx, `
+            UPDATE invoices
+            SET total_paise = total_paise + $2, late_fee_applied_at = NOW()
+            WHERE id = $1
+            RETURNING `+invoiceColumns, o.id, o.fee))
+		if err != nil {
+			return nil, fmt.Errorf("charging late fee: %w", err)
+		}
+
+		err = postLedger(ctx, tx, ledgerPosting{
+			SocietyID:   o.societyID,
+			ResidenceID: o.residenceID,
+			Kind:        model.EntryPenalty,
+			Memo:        "Late fee on maintenance " + o.periodStart.Format("Jan 2006"),
+			InvoiceID:   &o.id,
+			Debit:       model.AccountReceivable,
+			Credit:      model.AccountPenaltyIncome,
+			AmountPaise: o.fee,
+		})
+		if err != nil {
+			return nil, err
+		}
+		charged = append(charged, *inv)
+	}
+
+	if len(charged) > 0 {
+		if err := allocatePayments(ctx, tx, due[0].residenceID); err != nil {
+			return nil, err
+		}
+	}
+	return charged, nil
+}
+
+type RecordPaymentParams struct {
+	SocietyID   int64
+	ResidenceID int64
+	AmountPaise int64
+	Method      model.PaymentMethod
+	Reference   *string
+	RecordedBy  string
+}
+
+// RecordPayment books an offline payment, such as cash collected at the
+// society office, and credits it to the residence straight away.
+func (db *DB) RecordPayment(ctx context.Context, params RecordPaymentParams) (*model.Payment, error) {
+	var p *model.Payment
+
+	err := db.RunInTx(ctx, func(tx pgx.Tx) error {
+		if err := lockResidence(ctx, tx, params.ResidenceID); err != nil {
+			return err
+		}
+
+		var err error
+		p, err = scanPayment(tx.QueryRow(ctx, `
+            INSERT INTO payments (
+                society_id, residence_id, amount_paise, method, status, reference,
+                created_by, completed_at
+            )
+            VALUES ($1, $2, $3, $4, 'SUCCEEDED', $5, $6, NOW())
+            RETURNING `+paymentColumns,
+			params.SocietyID,
+			params.ResidenceID,
+			params.AmountPaise,
+			params.Method,
+			params.Reference,
+			params.RecordedBy,
+		))
+		if err != nil {
+			return fmt.Errorf("recording payment: %w", err)
+		}
+
+		return creditPayment(ctx, tx, p)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+type CreateGatewayPaymentParams struct {
+	SocietyID   int64
+	ResidenceID int64
+	AmountPaise int64
+	Provider    string
+	Reference   string
+	CreatedBy   string
+}
+
+// CreateGatewayPayment records a checkout started with a payment provider.
+// Nothing is credited until the provider confirms it.
+func (db *DB) CreateGatewayPayment(ctx context.Context, params CreateGatewayPaymentParams) (*model.Payment, error) {
+	p, err := scanPayment(db.pool.QueryRow(ctx, `
+        INSERT INTO payments (
+            society_id, residence_id, amount_paise, method, provider, reference, created_by
+        )
+        VALUES ($1, $2, $3, 'GATEWAY', $4, $5, $6)
+        RETURNING `+paymentColumns,
+		params.SocietyID,
+		params.ResidenceID,
+		params.AmountPaise,
+		params.Provider,
+		params.Reference,
+		params.CreatedBy,
+	))
+	if err != nil {
+		if isPgError(err, pgUniqueViolation) {
+			return nil, ErrAlreadyExists
+		}
+		return nil, fmt.Errorf("creating gateway payment: %w", err)
+	}
+
+	return p, nil
+}
+
+type CompletePaymentParams struct {
+	Provider      string
+	Reference     string
+	Status        model.PaymentStatus
+	AmountPaise   int64
+	FailureReason string
+}
+
+// CompletePayment applies a provider's verdict on a pending gateway payment
+// and credits the residence if it succeeded. Providers redeliver webhooks,
+// so a repeat of the verdict already recorded returns the payment with
+// ErrPaymentNotPending and changes nothing.
+func (db *DB) CompletePayment(ctx context.Context, params CompletePaymentParams) (*model.Payment, error) {
+	var p *model.Payment
+
+	err := db.RunInTx(ctx, func(tx pgx.Tx) error {
+		var err error
+		p, err = scanPayment(tx.QueryRow(ctx, `
+            SELECT `+paymentColumns+` FROM payments
+            WHERE provider = $1 AND reference = $2
+            FOR UPDATE
+        `, params.Provider, params.Reference))
+		if err != nil {
+			return err
+		}
+		if p.Status != model.PaymentPending {
+			return ErrPaymentNotPending
+		}
+		if err := lockResidence(ctx, tx, p.ResidenceID); err != nil {
+			return err
+		}
+
+		status := params.Status
+		var reason *string
+		if status == model.PaymentSucceeded && params.AmountPaise != p.AmountPaise {
+			status = model.PaymentFailed
+			msg := ErrPaymentAmountMismatch.Error()
+			reason = &msg
+		} else if params.FailureReason != "" {
+			reason = &params.FailureReason
+		}
+
+		p, err = scanPayment(tx.QueryRow(ctx, `
+            UPDATE payments
+            SET status = $2, failure_reason = $3, completed_at = NOW()
+            WHERE id = $1
+            RETURNING `+paymentColumns, p.ID, status, reason))
+		if err != nil {
+			return fmt.Errorf("completing payment: %w", err)
+		}
+
+		if p.Status != model.PaymentSucceeded {
+			return nil
+		}
+		return creditPayment(ctx, tx, p)
+	})
+	if errors.Is(err, ErrPaymentNotPending) {
+		return p, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+type PaymentFilter struct {
+	SocietyID   int64
+	ResidenceID *int64
+	Limit       int
+	Offset      int
+}
+
+func (db *DB) GetPayments(ctx context.Context, filter PaymentFilter) ([]model.Payment, error) {
+	query := `SELECT ` + paymentColumns + ` FROM payments WHERE society_id = $1`
+	args := []interface{}{filter.SocietyID}
+
+	if filter.ResidenceID != nil {
+		args = append(args, *filter.ResidenceID)
+		query += fmt.Sprintf(" AND residence_id = $%d", len(args))
+	}
+
+	if filter.Limit <= 0 || filter.Limit > 100 {
+		filter.Limit = 50
+	}
+	args = append(args, filter.Limit, filter.Offset)
+	query += fmt.Sprintf(" ORDER BY created_at DESC LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+
+	rows, err := db.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying payments: %w", err)
+	}
+	defer rows.Close()
+
+	var payments []model.Payment
+	for rows.Next() {
+		p, err := scanPayment(rows)
+		if err != nil {
+			return nil, err
+		}
+		payments = append(payments, *p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating payments: %w", err)
+	}
+
+	return payments, nil
+}
+
+// GetResidenceBalance returns what a residence owes; negative is credit.
+func (db *DB) GetResidenceBalance(ctx context.Context, residenceID int64) (int64, error) {
+	var balance int64
+	err := db.pool.QueryRow(ctx, `
+        SELECT COALESCE(SUM(e.amount_paise), 0)
+        FROM ledger_entries e
+        JOIN ledger_transactions t ON t.id = e.transaction_id
+        WHERE t.residence_id = $1 AND e.account = 'RECEIVABLE'
+    `, residenceID).Scan(&balance)
+	if err != nil {
+		return 0, fmt.Errorf("getting residence balance: %w", err)
+	}
+
+	return balance, nil
+}
+
+// GetStatement returns a residence's receivable over [from, to) with a
+// running balance, and the invoices it has yet to pay in full.
+func (db *DB) GetStatement(ctx context.Context, residenceID int64, from, to time.Time) (*model.Statement, error) {
+	st := &model.Statement{
+		ResidenceID: residenceID,
+		From:        from,
+		To:          to,
+		Lines:       []model.StatementLine{},
+	}
+
+	err := db.pool.QueryRow(ctx, `
+        SELECT COALESCE(SUM(e.amount_paise), 0)
+        FROM ledger_entries e
+        JOIN ledger_transactions t ON t.id = e.transaction_id
+        WHERE t.residence_id = $1 AND e.account = 'RECEIVABLE' AND t.posted_at < $2
+    `, residenceID, from).Scan(&st.OpeningPaise)
+	if err != nil {
+		return nil, fmt.Errorf("getting opening balance: %w", err)
+	}
+
+	rows, err := db.pool.Query(ctx, `
+        SELECT t.id, t.kind, t.memo, t.invoice_id, t.payment_id, t.posted_at, e.amount_paise
+        FROM ledger_entries e
+        JOIN ledger_transactions t ON t.id = e.transaction_id
+        WHERE t.residence_id = $1 AND e.account = 'RECEIVABLE'
+          AND t.posted_at >= $2 AND t.posted_at < $3
+        ORDER BY t.posted_at, t.id
+    `, residenceID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("querying statement: %w", err)
+	}
+	defer rows.Close()
+
+	balance := st.OpeningPaise
+	for rows.Next() {
+		var l model.StatementLine
+		var amount int64
+		if err := rows.Scan(
+			&l.TransactionID, &l.Kind, &l.Memo, &l.InvoiceID, &l.PaymentID,
+			&l.PostedAt, &amount,
+		); err != nil {
+			return nil, fmt.Errorf("scanning statement line: %w", err)
+		}
+		if amount > 0 {
+			l.DebitPaise = amount
+		} else {
+			l.CreditPaise = -amount
+		}
+		balance += amount
+		l.BalancePaise = balance
+		st.Lines = append(st.Lines, l)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating statement: %w", err)
+	}
+	st.ClosingPaise = balance
+
+	st.UnpaidInvoices, err = db.queryInvoices(ctx, `
+        SELECT `+invoiceColumns+` FROM invoices
+        WHERE residence_id = $1 AND paid_paise < total_paise
+        ORDER BY period_start
+    `, residenceID)
+	if err != nil {
+		return nil, err
+	}
+
+	return st, nil
+}
+
+func (db *DB) queryInvoices(ctx context.Context, query string, args ...interface{}) ([]model.Invoice, error) {
+	rows, err := db.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying invoices: %w", err)
+	}
+	defer rows.Close()
+
+	invoices := []model.Invoice{}
+	for rows.Next() {
+		inv, err := scanInvoice(rows)
+		if err != nil {
+			return nil, err
+		}
+		invoices = append(invoices, *inv)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating invoices: %w", err)
+	}
+
+	return invoices, nil
+}
+
+// ledgerPosting is a balanced two-legged ledger transaction: AmountPaise is
+// debited to Debit and credited to Credit.
+type ledgerPosting struct {
+	SocietyID   int64
+	ResidenceID int64
+	Kind        model.LedgerEntryKind
+	Memo        string
+	InvoiceID   *int64
+	PaymentID   *int64
+	Debit       model.LedgerAccount
+	Credit      model.LedgerAccount
+	AmountPaise int64
+}
+
+func postLedger(ctx context.Context, tx pgx.Tx, p ledgerPosting) error {
+	var txnID int64
+	err := tx.QueryRow(ctx, `
+        INSERT INTO ledger_transactions (society_id, residence_id, kind, memo, invoice_id, payment_id)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        RETURNING id
+    `, p.SocietyID, p.ResidenceID, p.Kind, p.Memo, p.InvoiceID, p.PaymentID).Scan(&txnID)
+	if err != nil {
+		return fmt.Errorf("posting ledger transaction: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+        INSERT INTO ledger_entries (transaction_id, account, amount_paise)
+        VALUES ($1, $2, $4), ($1, $3, -$4::bigint)
+    `, txnID, p.Debit, p.Credit, p.AmountPaise)
+	if err != nil {
+		return fmt.Errorf("posting ledger entries: %w", err)
+	}
+
+	return nil
+}
+
+// lockResidence serializes billing for one residence. Every transaction
+// that posts to a residence's ledger or reallocates its payments takes this
+// lock first: allocatePayments recomputes from the ledger, and without it
+// two concurrent postings each miss the other's entry.
+func lockResidence(ctx context.Context, tx pgx.Tx, residenceID int64) error {
+	var id int64
+	err := tx.QueryRow(ctx, `SELECT id FROM residences WHERE id = $1 FOR UPDATE`, residenceID).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("locking residence: %w", err)
+	}
+
+	return nil
+}
+
+// creditPayment posts a succeeded payment against the residence's
+// receivable and allocates it to its invoices. The caller must hold the
+// residence lock.
+func creditPayment(ctx context.Context, tx pgx.Tx, p *model.Payment) error {
+	account := model.AccountGateway
+	memo := "Online payment"
+	switch p.Method {
+	case model.PaymentCash:
+		account, memo = model.AccountCash, "Cash payment"
+	case model.PaymentCheque:
+		account, memo = model.AccountBank, "Cheque payment"
+	case model.PaymentBankTransfer:
+		account, memo = model.AccountBank, "Bank transfer"
+	}
+	if p.Reference != nil {
+		memo += " " + *p.Reference
+	}
+
+	err := postLedger(ctx, tx, ledgerPosting{
+		SocietyID:   p.SocietyID,
+		ResidenceID: p.ResidenceID,
+		Kind:        model.EntryPayment,
+		Memo:        memo,
+		PaymentID:   &p.ID,
+		Debit:       account,
+		Credit:      model.AccountReceivable,
+		AmountPaise: p.AmountPaise,
+	})
+	if err != nil {
+		return err
+	}
+
+	return allocatePayments(ctx, tx, p.ResidenceID)
+}
+
+// allocatePayments spreads everything a residence has paid over its invoices,
+// oldest first, and records each invoice's paid amount. It is recomputed
+// from the ledger after every posting, so late fees and credit carried
+// forward from overpayment are handled the same way. The caller must hold
+// the residence lock.
+func allocatePayments(ctx context.Context, tx pgx.Tx, residenceID int64) error {
+	_, err := tx.Exec(ctx, `
+        WITH paid AS (
+            SELECT COALESCE(-SUM(e.amount_paise), 0) AS total
+            FROM ledger_entries e
+            JOIN ledger_transactions t ON t.id = e.transaction_id
+            WHERE t.residence_id = $1 AND t.kind = 'PAYMENT' AND e.account = 'RECEIVABLE'
+        ),
+        ordered AS (
+            SELECT id, total_paise,
+                   SUM(total_paise) OVER (ORDER BY period_start, id) - total_paise AS before
+            FROM invoices
+            WHERE residence_id = $1
+        )
+        UPDATE invoices i
+        SET paid_paise = LEAST(o.total_paise, GREATEST(0, p.total - o.before))
+        FROM ordered o, paid p
+        WHERE i.id = o.id
+          AND i.paid_paise <> LEAST(o.total_paise, GREATEST(0, p.total - o.before))
+    `, residenceID)
+	if err != nil {
+		return fmt.Errorf("allocating payments: %w", err)
+	}
+
+	return nil
+}
+
+func addInvoiceLine(ctx context.Context, q querier, invoiceID int64, l model.InvoiceLine) (*model.InvoiceLine, error) {
+	line, err := scanInvoiceLine(q.QueryRow(ctx, `
+        INSERT INTO invoice_lines (invoice_id, kind, rule_id, description, amount_paise)
+        VALUES ($1, $2, $3, $4, $5)
+        RETURNING id, invoice_id, kind, rule_id, description, amount_paise
+    `, invoiceID, l.Kind, l.RuleID, l.Description, l.AmountPaise))
+	if err != nil {
+		return nil, fmt.Errorf("adding invoice line: %w", err)
+	}
+
+	return line, nil
+}
+
+func scanBillingSettings(row pgx.Row) (*model.BillingSettings, error) {
+	var s model.BillingSettings
+	err := row.Scan(
+		&s.SocietyID, &s.CycleMonths, &s.DueDays, &s.LateFeePaise, &s.GraceDays,
+		&s.Timezone, &s.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scanning billing settings: %w", err)
+	}
+
+	return &s, nil
+}
+
+func scanBillingRule(row pgx.Row) (*model.BillingRule, error) {
+	var r model.BillingRule
+	err := row.Scan(
+		&r.ID, &r.SocietyID, &r.BlockID, &r.Name, &r.Kind, &r.RatePaise,
+		&r.IsActive, &r.CreatedAt, &r.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scanning billing rule: %w", err)
+	}
+
+	return &r, nil
+}
+
+func scanInvoice(row pgx.Row) (*model.Invoice, error) {
+	var i model.Invoice
+	var today time.Time
+	err := row.Scan(
+		&i.ID, &i.SocietyID, &i.ResidenceID, &i.PeriodStart, &i.PeriodEnd,
+		&i.DueDate, &i.TotalPaise, &i.PaidPaise, &i.LateFeeAppliedAt,
+		&i.IssuedAt, &i.UpdatedAt, &today,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scanning invoice: %w", err)
+	}
+
+	i.Status = i.StatusAt(today)
+
+	return &i, nil
+}
+
+func scanInvoiceLine(row pgx.Row) (*model.InvoiceLine, error) {
+	var l model.InvoiceLine
+	err := row.Scan(&l.ID, &l.InvoiceID, &l.Kind, &l.RuleID, &l.Description, &l.AmountPaise)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scanning invoice line: %w", err)
+	}
+
+	return &l, nil
+}
+
+func scanPayment(row pgx.Row) (*model.Payment, error) {
+	var p model.Payment
+	err := row.Scan(
+		&p.ID, &p.SocietyID, &p.ResidenceID, &p.AmountPaise, &p.Method, &p.Status,
+		&p.Provider, &p.Reference, &p.FailureReason, &p.CreatedBy, &p.CompletedAt,
+		&p.CreatedAt, &p.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scanning payment: %w", err)
+	}
+
+	return &p, nil
+}
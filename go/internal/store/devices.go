@@ -0,0 +1,156 @@
+package store
+
+import (
+	"context"
+	"dooreye-backend/internal/model"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v4"
+)
+
+var ErrDeviceTaken = errors.New("device is already registered")
+
+const deviceColumns = `id, user_id, device_id, name, created_at, last_seen_at, revoked_at`
+
+func (db *DB) AddUserDevice(ctx context.Context, userID, deviceID string, name *string) (*model.Device, error) {
+	device, err := scanDevice(db.pool.QueryRow(ctx, `
+        INSERT INTO user_devices (user_id, device_id, name)
+        VALUES ($1, $2, $3)
+        RETURNING `+deviceColumns, userID, deviceID, name))
+	if err != nil {
+		if isPgError(err, pgUniqueViolation) {
+			return nil, ErrDeviceTaken
+		}
+		return nil, fmt.Errorf("adding device: %w", err)
+	}
+
+	return device, nil
+}
+
+func addDevice(ctx context.Context, tx pgx.Tx, userID, deviceID string, name *string) error {
+	_, err := tx.Exec(ctx, `
+        INSERT INTO user_devices (user_id, device_id, name)
+        VALUES ($1, $2, $3)
+    `, userID, deviceID, name)
+	if err != nil {
+		if isPgError(err, pgUniqueViolation) {
+			return ErrDeviceTaken
+		}
+		return fmt.Errorf("adding device: %w", err)
+	}
+
+	return nil
+}
+
+// GetUserDevices lists a user's devices, newest first. Revoked devices are
+// included so users can see what was signed out.
+func (db *DB) GetUserDevices(ctx context.Context, userID string) ([]model.Device, error) {
+	rows, err := db.pool.Query(ctx, `
+        SELECT `+deviceColumns+`
+        FROM user_devices
+        WHERE user_id = $1
+        ORDER BY revoked_at NULLS FIRST, created_at DESC
+    `, userID)
+	if err != nil {
+		return nil, fmt.Errorf("querying devices: %w", err)
+	}
+	defer rows.Close()
+
+	var devices []model.Device
+	for rows.Next() {
+		d, err := scanDevice(rows)
+		if err != nil {
+			return nil, err
+		}
+		devices = append(devices, *d)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating devices: %w", err)
+	}
+
+	return devices, nil
+}
+
+// RevokeUserDevice signs a device out. The device must belong to userID.
+func (db *DB) RevokeUserDevice(ctx context.Context, userID string, id int64) error {
+	result, err := db.pool.Exec(ctx, `
+        UPDATE user_devices
+        SET revoked_at = NOW()
+        WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+    `, id, userID)
+	if err != nil {
+		return fmt.Errorf("revoking device: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// TouchDevice records that a device was just used.
+func (db *DB) TouchDevice(ctx context.Context, id int64) error {
+	if _, err := db.pool.Exec(ctx, `UPDATE user_devices SET last_seen_at = NOW() WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("touching device: %w", err)
+	}
+
+	return nil
+}
+
+func revokeAllDevices(ctx context.Context, tx pgx.Tx, userID string) error {
+	_, err := tx.Exec(ctx, `
+        UPDATE user_devices
+        SET revoked_at = NOW()
+        WHERE user_id = $1 AND revoked_at IS NULL
+    `, userID)
+	if err != nil {
+		return fmt.Errorf("revoking devices: %w", err)
+	}
+
+	return nil
+}
+
+func scanDevice(row pgx.Row) (*model.Device, error) {
+	var d model.Device
+	err := row.Scan(&d.ID, &d.UserID, &d.DeviceID, &d.Name, &d.CreatedAt, &d.LastSeenAt, &d.RevokedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scanning device: %w", err)
+	}
+
+	return &d, nil
+}
+
+// RebindUserDevice signs a user out of every device and registers deviceID
+// in their place, for a user who lost or replaced their phone.
+func (db *DB) RebindUserDevice(ctx context.Context, userID, deviceID string) (*model.Device, error) {
+	var device *model.Device
+	err := db.RunInTx(ctx, func(tx pgx.Tx) error {
+		if err := revokeAllDevices(ctx, tx, userID); err != nil {
+			return err
+		}
+
+		var err error
+		device, err = scanDevice(tx.QueryRow(ctx, `
+            INSERT INTO user_devices (user_id, device_id)
+            VALUES ($1, $2)
+            RETURNING `+deviceColumns, userID, deviceID))
+		return err
+	})
+	if err != nil {
+		if isPgError(err, pgUniqueViolation) {
+			return nil, ErrDeviceTaken
+		}
+		if isPgError(err, pgForeignKeyViolation) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("rebinding device: %w", err)
+	}
+
+	return device, nil
+}
@@ -0,0 +1,442 @@
+package store
+
+import (
+	"context"
+	"dooreye-backend/internal/model"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+var (
+	ErrEventClosed         = errors.New("event is closed; guests need normal visitor approval")
+	ErrEventNotStarted     = errors.New("event check-in has not opened yet")
+	ErrEventFull           = errors.New("event is at capacity")
+	ErrGuestArrived        = errors.New("guest has already checked in")
+	ErrDuplicateEventGuest = errors.New("a guest with this phone is already on the list")
+	ErrInvalidVenue        = errors.New("venue is not in this society")
+)
+
+const eventColumns = `
+    e.id, e.society_id, e.residence_id, e.amenity_id, a.name, e.title,
+    e.starts_at, e.ends_at, e.capacity, e.closed_at, e.created_by,
+    e.created_at, e.updated_at, e.arrived_count,
+    (SELECT COALESCE(SUM(g.party_size), 0) FROM event_guests g WHERE g.event_id = e.id)
+`
+
+const eventFrom = `
+    FROM events e
+    LEFT JOIN amenities a ON a.id = e.amenity_id
+`
+
+const eventGuestColumns = `
+    id, event_id, name, phone, code, party_size, arrived_at, visit_id,
+    checked_in_by, created_at
+`
+
+type CreateEventParams struct {
+	SocietyID   int64
+	ResidenceID *int64
+	AmenityID   *int64
+	Title       string
+	StartsAt    time.Time
+	EndsAt      time.Time
+	Capacity    int
+	CreatedBy   string
+}
+
+// CreateEvent creates an event at a residence or a society amenity such as
+// the clubhouse. The amenity must belong to the event's society.
+func (db *DB) CreateEvent(ctx context.Context, params CreateEventParams) (*model.Event, error) {
+	if params.AmenityID != nil {
+		var societyID int64
+		err := db.pool.QueryRow(ctx, `SELECT society_id FROM amenities WHERE id = $1`, *params.AmenityID).Scan(&societyID)
+		if errors.Is(err, pgx.ErrNoRows) || (err == nil && societyID != params.SocietyID) {
+			return nil, ErrInvalidVenue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("checking event venue: %w", err)
+		}
+	}
+
+	var id int64
+	err := db.pool.QueryRow(ctx, `
+        INSERT INTO events (
+            society_id, residence_id, amenity_id, title, starts_at, ends_at,
+            capacity, created_by
+        )
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+        RETURNING id
+    `,
+		params.SocietyID,
+		params.ResidenceID,
+		params.AmenityID,
+		params.Title,
+		params.StartsAt,
+		params.EndsAt,
+		params.Capacity,
+		params.CreatedBy,
+	).Scan(&id)
+	if err != nil {
+		return nil, fmt.Errorf("creating event: %w", err)
+	}
+
+	return db.GetEvent(ctx, id)
+}
+
+func (db *DB) GetEvent(ctx context.Context, id int64) (*model.Event, error) {
+	return scanEvent(db.pool.QueryRow(ctx, `SELECT `+eventColumns+eventFrom+` WHERE e.id = $1`, id))
+}
+
+type EventFilter struct {
+	SocietyID   int64
+	ResidenceID *int64
+	// OnlyOpen keeps events the gate is checking guests in for now.
+	OnlyOpen bool
+	Limit    int
+	Offset   int
+}
+
+func (db *DB) GetEvents(ctx context.Context, filter EventFilter) ([]model.Event, error) {
+	if filter.Limit <= 0 || filter.Limit > 100 {
+		filter.Limit = 20
+	}
+
+	query := `SELECT ` + eventColumns + eventFrom + ` WHERE e.society_id = $1`
+	args := []interface{}{filter.SocietyID}
+
+	if filter.ResidenceID != nil {
+		args = append(args, *filter.ResidenceID)
+		query += fmt.Sprintf(" AND e.residence_id = $%d", len(args))
+	}
+
+	if filter.OnlyOpen {
+		args = append(args, model.EventEarlyEntry.Seconds())
+		query += fmt.Sprintf(` AND e.closed_at IS NULL AND e.ends_at > NOW()
+            AND e.starts_at - make_interval(secs => $%d) <= NOW()`, len(args))
+	}
+
+	args = append(args, filter.Limit, filter.Offset)
+	query += fmt.Sprintf(" ORDER BY e.starts_at DESC LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+
+	rows, err := db.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []model.Event
+	for rows.Next() {
+		e, err := scanEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, *e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating events: %w", err)
+	}
+
+	return events, nil
+}
+
+// CloseEvent ends guest list check-in early.
+func (db *DB) CloseEvent(ctx context.Context, id int64) (*model.Event, error) {
+	_, err := db.pool.Exec(ctx, `
+        UPDATE events SET closed_at = NOW()
+        WHERE id = $1 AND closed_at IS NULL
+    `, id)
+	if err != nil {
+		return nil, fmt.Errorf("closing event: %w", err)
+	}
+
+	return db.GetEvent(ctx, id)
+}
+
+type NewEventGuest struct {
+	Name      string
+	Phone     *string
+	PartySize int
+	Code      string
+}
+
+// AddEventGuests adds guests to the list in one go; if any of them cannot
+// be added, none are.
+func (db *DB) AddEventGuests(ctx context.Context, eventID int64, guests []NewEventGuest) ([]model.EventGuest, error) {
+	added := make([]model.EventGuest, 0, len(guests))
+
+	err := db.RunInTx(ctx, func(tx pgx.Tx) error {
+		event, err := scanEvent(tx.QueryRow(ctx, `SELECT `+eventColumns+eventFrom+` WHERE e.id = $1 FOR UPDATE OF e`, eventID))
+		if err != nil {
+			return err
+		}
+		if event.Status == model.EventClosed {
+			return ErrEventClosed
+		}
+
+		for _, g := range guests {
+			guest, err := scanEventGuest(tx.QueryRow(ctx, `
+                INSERT INTO event_guests (event_id, name, phone, party_size, code)
+                VALUES ($1, $2, $3, $4, $5)
+                RETURNING `+eventGuestColumns,
+				eventID, g.Name, g.Phone, g.PartySize, g.Code,
+			))
+			if err != nil {
+				if isPgError(err, pgUniqueViolation) {
+					if pgConstraint(err) == "event_guests_code_key" {
+						return ErrDuplicatePassCode
+					}
+					return fmt.Errorf("%w: %s", ErrDuplicateEventGuest, *g.Phone)
+				}
+				return fmt.Errorf("adding event guest: %w", err)
+			}
+			added = append(added, *guest)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return added, nil
+}
+
+type EventGuestFilter struct {
+	// Search matches a name fragment, the start of a phone number or an
+	// exact code, the ways a guard looks a guest up.
+	Search  string
+	Arrived *bool
+	Limit   int
+	Offset  int
+}
+
+func (db *DB) GetEventGuests(ctx context.Context, eventID int64, filter EventGuestFilter) ([]model.EventGuest, error) {
+	if filter.Limit <= 0 || filter.Limit > 500 {
+		filter.Limit = 50
+	}
+
+	query := `SELECT ` + eventGuestColumns + ` FROM event_guests WHERE event_id = $1`
+	args := []interface{}{eventID}
+
+	if search := strings.TrimSpace(filter.Search); search != "" {
+		args = append(args, "%"+strings.ToLower(search)+"%", search+"%", strings.ToUpper(search))
+		n := len(args)
+		query += fmt.Sprintf(" AND (lower(name) LIKE $%d OR phone LIKE $%d OR code = $%d)", n-2, n-1, n)
+	}
+
+	if filter.Arrived != nil {
+		if *filter.Arrived {
+			query += " AND arrived_at IS NOT NULL"
+		} else {
+			query += " AND arrived_at IS NULL"
+		}
+	}
+
+	args = append(args, filter.Limit, filter.Offset)
+	query += fmt.Sprintf(" ORDER BY lower(name) LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+
+	rows, err := db.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying event guests: %w", err)
+	}
+	defer rows.Close()
+
+	guests := []model.EventGuest{}
+	for rows.Next() {
+		g, err := scanEventGuest(rows)
+		if err != nil {
+			return nil, err
+		}
+		guests = append(guests, *g)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating event guests: %w", err)
+	}
+
+	return guests, nil
+}
+
+// RemoveEventGuest takes a guest who has not arrived off the list.
+func (db *DB) RemoveEventGuest(ctx context.Context, eventID, guestID int64) error {
+	var arrived bool
+	err := db.pool.QueryRow(ctx, `
+        WITH target AS (
+            SELECT id, arrived_at IS NOT NULL AS arrived
+            FROM event_guests WHERE id = $1 AND event_id = $2
+        ), removed AS (
+            DELETE FROM event_guests
+            WHERE id IN (SELECT id FROM target WHERE NOT arrived)
+        )
+        SELECT arrived FROM target
+    `, guestID, eventID).Scan(&arrived)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("removing event guest: %w", err)
+	}
+	if arrived {
+		return ErrGuestArrived
+	}
+
+	return nil
+}
+
+type CheckInEventGuestParams struct {
+	SocietyID int64
+	// Either the guest picked from a search of the event's list, or the
+	// code the guest shows.
+	EventID *int64
+	GuestID *int64
+	Code    string
+	GateID  *int64
+	GuardID string
+}
+
+// CheckInEventGuest admits a guest on an open event's list without asking
+// the host: the listing is the approval. It opens a visit to the host's
+// residence, or to no residence for society events, and counts the guest's
+// party against the event capacity. The event row is locked so concurrent
+// check-ins at several gates cannot overshoot the cap.
+func (db *DB) CheckInEventGuest(ctx context.Context, params CheckInEventGuestParams) (*model.EventGuest, *model.Event, error) {
+	var guest *model.EventGuest
+	var event *model.Event
+
+	err := db.RunInTx(ctx, func(tx pgx.Tx) error {
+		var err error
+		if params.GuestID != nil {
+			guest, err = scanEventGuest(tx.QueryRow(ctx, `
+                SELECT `+eventGuestColumns+` FROM event_guests
+                WHERE id = $1 AND event_id = $2
+            `, *params.GuestID, params.EventID))
+		} else {
+			guest, err = scanEventGuest(tx.QueryRow(ctx, `
+                SELECT `+eventGuestColumns+` FROM event_guests WHERE code = $1
+            `, params.Code))
+		}
+		if err != nil {
+			return err
+		}
+
+		event, err = scanEvent(tx.QueryRow(ctx, `
+            SELECT `+eventColumns+eventFrom+`
+            WHERE e.id = $1 AND e.society_id = $2
+            FOR UPDATE OF e
+        `, guest.EventID, params.SocietyID))
+		if err != nil {
+			return err
+		}
+
+		// Re-read under the event lock; another gate may have just admitted
+		// the same guest.
+		err = tx.QueryRow(ctx, `SELECT arrived_at FROM event_guests WHERE id = $1`, guest.ID).Scan(&guest.ArrivedAt)
+		if err != nil {
+			return fmt.Errorf("checking guest arrival: %w", err)
+		}
+
+		switch {
+		case guest.ArrivedAt != nil:
+			return ErrGuestArrived
+		case event.Status == model.EventClosed:
+			return ErrEventClosed
+		case event.Status == model.EventScheduled:
+			return ErrEventNotStarted
+		case event.ArrivedCount+guest.PartySize > event.Capacity:
+			return ErrEventFull
+		}
+
+		phone := ""
+		if guest.Phone != nil {
+			phone = *guest.Phone
+		}
+
+		var visitorID string
+		err = tx.QueryRow(ctx, `
+            INSERT INTO visitors (name, phone, type, created_by)
+            VALUES ($1, $2, $3, $4)
+            RETURNING id
+        `, guest.Name, phone, model.VisitorGuest, params.GuardID).Scan(&visitorID)
+		if err != nil {
+			return fmt.Errorf("creating visitor: %w", err)
+		}
+
+		var visitID string
+		err = tx.QueryRow(ctx, `
+            INSERT INTO visits (residence_id, visitor_id, checked_in_by, approved_by, approved_at, check_in_time, purpose, gate_id)
+            VALUES ($1, $2, $3, $4, NOW(), NOW(), $5, $6)
+            RETURNING id
+        `, event.ResidenceID, visitorID, params.GuardID, event.CreatedBy, event.Title, params.GateID).Scan(&visitID)
+		if err != nil {
+			return fmt.Errorf("creating visit: %w", err)
+		}
+
+		guest, err = scanEventGuest(tx.QueryRow(ctx, `
+            UPDATE event_guests
+            SET arrived_at = NOW(), visit_id = $2, checked_in_by = $3
+            WHERE id = $1
+            RETURNING `+eventGuestColumns,
+			guest.ID, visitID, params.GuardID,
+		))
+		if err != nil {
+			return fmt.Errorf("marking guest arrived: %w", err)
+		}
+
+		err = tx.QueryRow(ctx, `
+            UPDATE events SET arrived_count = arrived_count + $2
+            WHERE id = $1
+            RETURNING arrived_count
+        `, event.ID, guest.PartySize).Scan(&event.ArrivedCount)
+		if err != nil {
+			return fmt.Errorf("counting event arrival: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return guest, event, nil
+}
+
+func scanEvent(row pgx.Row) (*model.Event, error) {
+	var e model.Event
+	err := row.Scan(
+		&e.ID, &e.SocietyID, &e.ResidenceID, &e.AmenityID, &e.VenueName,
+		&e.Title, &e.StartsAt, &e.EndsAt, &e.Capacity, &e.ClosedAt,
+		&e.CreatedBy, &e.CreatedAt, &e.UpdatedAt, &e.ArrivedCount,
+		&e.ExpectedCount,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scanning event: %w", err)
+	}
+
+	e.Status = e.StatusAt(time.Now())
+
+	return &e, nil
+}
+
+func scanEventGuest(row pgx.Row) (*model.EventGuest, error) {
+	var g model.EventGuest
+	err := row.Scan(
+		&g.ID, &g.EventID, &g.Name, &g.Phone, &g.Code, &g.PartySize,
+		&g.ArrivedAt, &g.VisitID, &g.CheckedInBy, &g.CreatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scanning event guest: %w", err)
+	}
+
+	return &g, nil
+}
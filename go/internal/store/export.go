@@ -0,0 +1,130 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// societyUsers matches the users of society $1: its staff and the members
+// of its residences.
+const societyUsers = `
+    SELECT u.id FROM users u
+    WHERE u.society_id = $1 OR EXISTS (
+        SELECT 1
+        FROM user_residences ur
+        JOIN residences r ON r.id = ur.residence_id
+        JOIN blocks b ON b.id = r.block_id
+        WHERE ur.user_id = u.id AND b.society_id = $1
+    )
+`
+
+// societyVisits matches visits to society $1, placed by residence or, for
+// visits to no residence, by the guard who checked the visitor in.
+const societyVisits = `
+    SELECT v.id FROM visits v
+    JOIN users u ON u.id = v.checked_in_by
+    LEFT JOIN residences r ON r.id = v.residence_id
+    LEFT JOIN blocks b ON b.id = r.block_id
+    WHERE COALESCE(b.society_id, u.society_id) = $1
+`
+
+// societyExports lists what ExportSociety writes, in order. Each query
+// takes the society ID as $1. Credentials are left out: users' access
+// codes, device IDs and guest invite tokens.
+var societyExports = []struct {
+	name  string
+	query string
+}{
+	{"society", `SELECT * FROM societies WHERE id = $1`},
+	{"blocks", `SELECT * FROM blocks WHERE society_id = $1 ORDER BY id`},
+	{"residences", `
+        SELECT r.* FROM residences r
+        JOIN blocks b ON b.id = r.block_id
+        WHERE b.society_id = $1
+        ORDER BY r.id
+    `},
+	{"gates", `SELECT * FROM gates WHERE society_id = $1 ORDER BY id`},
+	{"users", `
+        SELECT id, name, role, residence_id, society_id, is_active, invited_by, activated_by, activated_at, deactivated_at, created_at
+        FROM users
+        WHERE id IN (` + societyUsers + `)
+        ORDER BY created_at
+    `},
+	{"user_residences", `
+        SELECT ur.* FROM user_residences ur
+        JOIN residences r ON r.id = ur.residence_id
+        JOIN blocks b ON b.id = r.block_id
+        WHERE b.society_id = $1
+        ORDER BY ur.created_at
+    `},
+	{"vehicles", `
+        SELECT v.* FROM vehicles v
+        JOIN residences r ON r.id = v.residence_id
+        JOIN blocks b ON b.id = r.block_id
+        WHERE b.society_id = $1
+        ORDER BY v.id
+    `},
+	{"visitors", `
+        SELECT * FROM visitors
+        WHERE id IN (SELECT visitor_id FROM visits WHERE id IN (` + societyVisits + `))
+    `},
+	{"visits", `
+        SELECT * FROM visits
+        WHERE id IN (` + societyVisits + `)
+        ORDER BY check_in_time
+    `},
+	{"amenities", `SELECT * FROM amenities WHERE society_id = $1 ORDER BY id`},
+	{"amenity_bookings", `
+        SELECT ab.* FROM amenity_bookings ab
+        JOIN amenities a ON a.id = ab.amenity_id
+        WHERE a.society_id = $1
+        ORDER BY ab.id
+    `},
+	{"tickets", `SELECT * FROM tickets WHERE society_id = $1 ORDER BY id`},
+	{"announcements", `SELECT * FROM announcements WHERE society_id = $1 ORDER BY id`},
+	{"polls", `SELECT * FROM polls WHERE society_id = $1 ORDER BY id`},
+	{"invoices", `SELECT * FROM invoices WHERE society_id = $1 ORDER BY id`},
+	{"payments", `SELECT * FROM payments WHERE society_id = $1 ORDER BY id`},
+}
+
+// ExportedTable holds one table's rows for a society, as a JSON array.
+type ExportedTable struct {
+	Name string
+	Rows json.RawMessage
+}
+
+// ExportSociety reads a society's data from a single snapshot, so rows
+// written during the export do not leave it inconsistent.
+func (db *DB) ExportSociety(ctx context.Context, societyID int64) ([]ExportedTable, error) {
+	tx, err := db.pool.BeginTx(ctx, pgx.TxOptions{
+		IsoLevel:   pgx.RepeatableRead,
+		AccessMode: pgx.ReadOnly,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var exists bool
+	if err := tx.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM societies WHERE id = $1)`, societyID).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("checking society: %w", err)
+	}
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	tables := make([]ExportedTable, 0, len(societyExports))
+	for _, e := range societyExports {
+		var rows []byte
+		err := tx.QueryRow(ctx, `SELECT COALESCE(json_agg(t), '[]'::json) FROM (`+e.query+`) t`, societyID).Scan(&rows)
+		if err != nil {
+			return nil, fmt.Errorf("exporting %s: %w", e.name, err)
+		}
+		tables = append(tables, ExportedTable{Name: e.name, Rows: rows})
+	}
+
+	return tables, nil
+}
@@ -0,0 +1,324 @@
+package store
+
+import (
+	"context"
+	"dooreye-backend/internal/model"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v4"
+)
+
+var (
+	ErrHeadcountOpen   = errors.New("a headcount is already in progress")
+	ErrHeadcountClosed = errors.New("headcount is closed")
+	ErrOnShift         = errors.New("already punched in")
+)
+
+func (db *DB) SetResidentPresence(ctx context.Context, userID string, isHome bool) error {
+	_, err := db.pool.Exec(ctx, `
+        INSERT INTO resident_presence (user_id, is_home, updated_at)
+        VALUES ($1, $2, NOW())
+        ON CONFLICT (user_id) DO UPDATE
+        SET is_home = EXCLUDED.is_home, updated_at = EXCLUDED.updated_at
+    `, userID, isHome)
+	if err != nil {
+		return fmt.Errorf("setting resident presence: %w", err)
+	}
+
+	return nil
+}
+
+const staffShiftColumns = `id, society_id, user_id, gate_id, started_at, ended_at`
+
+type PunchInParams struct {
+	SocietyID int64
+	UserID    string
+	GateID    *int64
+}
+
+// PunchIn starts a staff member's shift. A member is on one shift at a
+// time.
+func (db *DB) PunchIn(ctx context.Context, params PunchInParams) (*model.StaffShift, error) {
+	shift, err := scanStaffShift(db.pool.QueryRow(ctx, `
+        INSERT INTO staff_shifts (society_id, user_id, gate_id)
+        VALUES ($1, $2, $3)
+        RETURNING `+staffShiftColumns,
+		params.SocietyID, params.UserID, params.GateID,
+	))
+	if err != nil {
+		if isPgError(err, pgUniqueViolation) {
+			return nil, ErrOnShift
+		}
+		return nil, fmt.Errorf("punching in: %w", err)
+	}
+
+	return shift, nil
+}
+
+// PunchOut ends a staff member's open shift, or returns ErrNotFound if they
+// are not on one.
+func (db *DB) PunchOut(ctx context.Context, userID string) (*model.StaffShift, error) {
+	return scanStaffShift(db.pool.QueryRow(ctx, `
+        UPDATE staff_shifts
+        SET ended_at = NOW()
+        WHERE user_id = $1 AND ended_at IS NULL
+        RETURNING `+staffShiftColumns, userID))
+}
+
+type HeadcountFilter struct {
+	SocietyID        int64
+	IncludeResidents bool
+	// EventID attaches the accounted-for marks of a headcount event.
+	EventID *string
+}
+
+// GetHeadcount lists everyone believed to be inside a society: visitors and
+// domestic staff with an open visit, society staff punched in and,
+// optionally, residents who marked themselves home. A resident is listed
+// once, under their default residence if it is in the society, else their
+// earliest membership there.
+func (db *DB) GetHeadcount(ctx context.Context, filter HeadcountFilter) ([]model.HeadcountEntry, error) {
+	rows, err := db.pool.Query(ctx, `
+        SELECT 'VISIT', v.id,
+               CASE WHEN vis.type = 'STAFF' THEN 'STAFF' ELSE 'VISITOR' END,
+               vis.name, vis.phone, vis.type,
+               b.id, b.name, r.id, r.number, g.id, g.name,
+               v.check_in_time, m.marked_at, m.marked_by
+        FROM visits v
+        JOIN visitors vis ON vis.id = v.visitor_id
+        JOIN users u ON u.id = v.checked_in_by
+        LEFT JOIN residences r ON r.id = v.residence_id
+        LEFT JOIN blocks b ON b.id = r.block_id
+        LEFT JOIN gates g ON g.id = v.gate_id
+        LEFT JOIN headcount_marks m
+               ON m.event_id = $2 AND m.subject_type = 'VISIT' AND m.subject_id = v.id
+        WHERE v.check_out_time IS NULL
+          AND COALESCE(b.society_id, g.society_id, u.society_id) = $1
+
+        UNION ALL
+
+        SELECT 'STAFF', u.id, 'STAFF',
+               COALESCE(u.name, ''), NULL, NULL,
+               NULL, NULL, NULL, NULL, g.id, g.name,
+               sh.started_at, m.marked_at, m.marked_by
+        FROM staff_shifts sh
+        JOIN users u ON u.id = sh.user_id
+        LEFT JOIN gates g ON g.id = sh.gate_id
+        LEFT JOIN headcount_marks m
+               ON m.event_id = $2 AND m.subject_type = 'STAFF' AND m.subject_id = u.id
+        WHERE sh.ended_at IS NULL AND sh.society_id = $1
+
+        UNION ALL
+
+        SELECT * FROM (
+            SELECT DISTINCT ON (u.id) 'RESIDENT', u.id, 'RESIDENT',
+                   COALESCE(u.name, ''), NULL, NULL,
+                   b.id, b.name, r.id, r.number, NULL::bigint, NULL,
+                   p.updated_at, m.marked_at, m.marked_by
+            FROM resident_presence p
+            JOIN users u ON u.id = p.user_id
+            JOIN user_residences ur ON ur.user_id = u.id
+            JOIN residences r ON r.id = ur.residence_id
+            JOIN blocks b ON b.id = r.block_id
+            LEFT JOIN headcount_marks m
+                   ON m.event_id = $2 AND m.subject_type = 'RESIDENT' AND m.subject_id = u.id
+            WHERE $3 AND p.is_home AND u.is_active AND b.society_id = $1
+              AND (ur.lease_end IS NULL OR ur.lease_end >= CURRENT_DATE)
+            ORDER BY u.id, ur.residence_id IS NOT DISTINCT FROM u.residence_id DESC, ur.created_at
+        ) residents
+
+        ORDER BY 8 NULLS LAST, 12 NULLS LAST, 4
+    `, filter.SocietyID, filter.EventID, filter.IncludeResidents)
+	if err != nil {
+		return nil, fmt.Errorf("querying headcount: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []model.HeadcountEntry
+	for rows.Next() {
+		var e model.HeadcountEntry
+		if err := rows.Scan(
+			&e.SubjectType, &e.SubjectID, &e.Category,
+			&e.Name, &e.Phone, &e.VisitorType,
+			&e.BlockID, &e.BlockName, &e.ResidenceID, &e.ResidenceNumber,
+			&e.GateID, &e.GateName,
+			&e.Since, &e.AccountedAt, &e.AccountedBy,
+		); err != nil {
+			return nil, fmt.Errorf("scanning headcount row: %w", err)
+		}
+		entries = append(entries, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating headcount: %w", err)
+	}
+
+	return entries, nil
+}
+
+const headcountEventColumns = `
+    id, society_id, reason, include_residents, started_by, started_at,
+    closed_by, closed_at
+`
+
+type StartHeadcountParams struct {
+	SocietyID        int64
+	Reason           *string
+	IncludeResidents bool
+	StartedBy        string
+}
+
+func (db *DB) StartHeadcountEvent(ctx context.Context, params StartHeadcountParams) (*model.HeadcountEvent, error) {
+	event, err := scanHeadcountEvent(db.pool.QueryRow(ctx, `
+        INSERT INTO headcount_events (society_id, reason, include_residents, started_by)
+        VALUES ($1, $2, $3, $4)
+        RETURNING `+headcountEventColumns,
+		params.SocietyID, params.Reason, params.IncludeResidents, params.StartedBy,
+	))
+	if err != nil {
+		if isPgError(err, pgUniqueViolation) {
+			return nil, ErrHeadcountOpen
+		}
+		return nil, fmt.Errorf("starting headcount: %w", err)
+	}
+
+	return event, nil
+}
+
+func (db *DB) GetHeadcountEvent(ctx context.Context, id string) (*model.HeadcountEvent, error) {
+	return scanHeadcountEvent(db.pool.QueryRow(ctx,
+		`SELECT `+headcountEventColumns+` FROM headcount_events WHERE id = $1`, id))
+}
+
+func (db *DB) CloseHeadcountEvent(ctx context.Context, id, userID string) (*model.HeadcountEvent, error) {
+	event, err := scanHeadcountEvent(db.pool.QueryRow(ctx, `
+        UPDATE headcount_events
+        SET closed_by = $2, closed_at = NOW()
+        WHERE id = $1 AND closed_at IS NULL
+        RETURNING `+headcountEventColumns, id, userID))
+	if errors.Is(err, ErrNotFound) {
+		if _, err := db.GetHeadcountEvent(ctx, id); err != nil {
+			return nil, err
+		}
+		return nil, ErrHeadcountClosed
+	}
+
+	return event, err
+}
+
+// MarkAccounted records that a person was seen safe during an open event.
+// Marking twice keeps the first mark. The person must be a visit or a
+// resident of the event's society, judged as in GetHeadcount; ErrNotFound
+// means they are not.
+func (db *DB) MarkAccounted(ctx context.Context, eventID string, subject model.HeadcountSubject, subjectID, userID string) error {
+	result, err := db.pool.Exec(ctx, `
+        INSERT INTO headcount_marks (event_id, subject_type, subject_id, marked_by)
+        SELECT e.id, $2, $3, $4
+        FROM headcount_events e
+        WHERE e.id = $1 AND e.closed_at IS NULL
+          AND CASE $2::headcount_subject
+              WHEN 'VISIT' THEN EXISTS (
+                  SELECT 1
+                  FROM visits v
+                  JOIN users u ON u.id = v.checked_in_by
+                  LEFT JOIN residences r ON r.id = v.residence_id
+                  LEFT JOIN blocks b ON b.id = r.block_id
+                  LEFT JOIN gates g ON g.id = v.gate_id
+                  WHERE v.id = $3
+                    AND COALESCE(b.society_id, g.society_id, u.society_id) = e.society_id
+              )
+              WHEN 'RESIDENT' THEN EXISTS (
+                  SELECT 1
+                  FROM user_residences ur
+                  JOIN residences r ON r.id = ur.residence_id
+                  JOIN blocks b ON b.id = r.block_id
+                  WHERE ur.user_id = $3 AND b.society_id = e.society_id
+              )
+              WHEN 'STAFF' THEN EXISTS (
+                  SELECT 1 FROM staff_shifts
+                  WHERE user_id = $3 AND society_id = e.society_id
+              )
+              ELSE false
+          END
+        ON CONFLICT DO NOTHING
+    `, eventID, subject, subjectID, userID)
+	if err != nil {
+		return fmt.Errorf("marking accounted: %w", err)
+	}
+
+	if result.RowsAffected() > 0 {
+		return nil
+	}
+
+	event, err := db.GetHeadcountEvent(ctx, eventID)
+	if err != nil {
+		return err
+	}
+	if event.ClosedAt != nil {
+		return ErrHeadcountClosed
+	}
+
+	var marked bool
+	err = db.pool.QueryRow(ctx, `
+        SELECT EXISTS (
+            SELECT 1 FROM headcount_marks
+            WHERE event_id = $1 AND subject_type = $2 AND subject_id = $3
+        )
+    `, eventID, subject, subjectID).Scan(&marked)
+	if err != nil {
+		return fmt.Errorf("checking mark: %w", err)
+	}
+	if !marked {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (db *DB) UnmarkAccounted(ctx context.Context, eventID string, subject model.HeadcountSubject, subjectID string) error {
+	result, err := db.pool.Exec(ctx, `
+        DELETE FROM headcount_marks m
+        USING headcount_events e
+        WHERE e.id = m.event_id AND e.closed_at IS NULL
+          AND m.event_id = $1 AND m.subject_type = $2 AND m.subject_id = $3
+    `, eventID, subject, subjectID)
+	if err != nil {
+		return fmt.Errorf("unmarking accounted: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func scanStaffShift(row pgx.Row) (*model.StaffShift, error) {
+	var s model.StaffShift
+	err := row.Scan(&s.ID, &s.SocietyID, &s.UserID, &s.GateID, &s.StartedAt, &s.EndedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scanning staff shift: %w", err)
+	}
+
+	return &s, nil
+}
+
+func scanHeadcountEvent(row pgx.Row) (*model.HeadcountEvent, error) {
+	var e model.HeadcountEvent
+	err := row.Scan(
+		&e.ID, &e.SocietyID, &e.Reason, &e.IncludeResidents,
+		&e.StartedBy, &e.StartedAt, &e.ClosedBy, &e.ClosedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scanning headcount event: %w", err)
+	}
+
+	return &e, nil
+}
@@ -0,0 +1,308 @@
+package store
+
+import (
+	"context"
+	"dooreye-backend/internal/model"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+var ErrInvalidAccessCode = errors.New("invalid or already used access code")
+
+type InviteHouseholdMemberParams struct {
+	AccessCode         string
+	Name               string
+	ResidenceID        int64
+	IsTenant           bool
+	LeaseEnd           *time.Time
+	CanApproveVisitors bool
+	CanCreatePasses    bool
+	InvitedBy          string
+}
+
+// HouseholdMember is a user as a member of one residence. Permissions and
+// tenancy are per residence: a member of two flats holds separate ones in
+// each, set by each flat's owner.
+type HouseholdMember struct {
+	User
+	CanApproveVisitors bool       `json:"can_approve_visitors"`
+	CanCreatePasses    bool       `json:"can_create_passes"`
+	IsTenant           bool       `json:"is_tenant"`
+	LeaseEnd           *time.Time `json:"lease_end,omitempty"`
+}
+
+const memberColumns = `can_approve_visitors, can_create_passes, is_tenant, lease_end`
+
+func scanHouseholdMember(row pgx.Row) (*HouseholdMember, error) {
+	var m HouseholdMember
+	err := row.Scan(append(userFields(&m.User),
+		&m.CanApproveVisitors, &m.CanCreatePasses, &m.IsTenant, &m.LeaseEnd,
+	)...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scanning household member: %w", err)
+	}
+
+	return &m, nil
+}
+
+// InviteHouseholdMember creates an inactive RESIDENT for a residence. The
+// member becomes active once they redeem the access code on their device.
+func (db *DB) InviteHouseholdMember(ctx context.Context, params InviteHouseholdMemberParams) (*HouseholdMember, error) {
+	var member *HouseholdMember
+	err := db.RunInTx(ctx, func(tx pgx.Tx) error {
+		user, err := scanUser(tx.QueryRow(ctx, `
+            INSERT INTO users (access_code, name, residence_id, role, is_active, invited_by)
+            VALUES ($1, $2, $3, $4, false, $5)
+            RETURNING `+userColumns,
+			params.AccessCode,
+			params.Name,
+			params.ResidenceID,
+			model.RoleResident,
+			params.InvitedBy,
+		))
+		if err != nil {
+			return err
+		}
+
+		member = &HouseholdMember{User: *user}
+		err = tx.QueryRow(ctx, `
+            INSERT INTO user_residences (user_id, residence_id, role, `+memberColumns+`)
+            VALUES ($1, $2, $3, $4, $5, $6, $7)
+            RETURNING `+memberColumns,
+			user.ID,
+			params.ResidenceID,
+			model.RoleResident,
+			params.CanApproveVisitors,
+			params.CanCreatePasses,
+			params.IsTenant,
+			params.LeaseEnd,
+		).Scan(&member.CanApproveVisitors, &member.CanCreatePasses, &member.IsTenant, &member.LeaseEnd)
+		if err != nil {
+			return fmt.Errorf("adding membership: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		if isPgError(err, pgUniqueViolation) {
+			return nil, ErrDuplicateAccessCode
+		}
+		return nil, fmt.Errorf("inviting household member: %w", err)
+	}
+
+	return member, nil
+}
+
+func (db *DB) GetUser(ctx context.Context, id string) (*User, error) {
+	return scanUser(db.pool.QueryRow(ctx, `SELECT `+userColumns+` FROM users WHERE id = $1`, id))
+}
+
+// GetHouseholdMembers lists every user of a residence, including pending
+// invitations and deactivated members.
+func (db *DB) GetHouseholdMembers(ctx context.Context, residenceID int64) ([]HouseholdMember, error) {
+	rows, err := db.pool.Query(ctx, `
+        SELECT `+prefixColumns("u", userColumns)+`, `+prefixColumns("ur", memberColumns)+`
+        FROM user_residences ur
+        JOIN users u ON u.id = ur.user_id
+        WHERE ur.residence_id = $1
+        ORDER BY ur.role, ur.created_at
+    `, residenceID)
+	if err != nil {
+		return nil, fmt.Errorf("querying household members: %w", err)
+	}
+	defer rows.Close()
+
+	var members []HouseholdMember
+	for rows.Next() {
+		m, err := scanHouseholdMember(rows)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, *m)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating household members: %w", err)
+	}
+
+	return members, nil
+}
+
+type UpdateHouseholdMemberParams struct {
+	CanApproveVisitors *bool
+	CanCreatePasses    *bool
+	IsTenant           *bool
+	LeaseEnd           *time.Time
+	ClearLeaseEnd      bool
+}
+
+// UpdateHouseholdMember changes a RESIDENT's permissions or tenancy in
+// residenceID only; their memberships elsewhere are untouched, so an owner
+// cannot reach into another flat.
+func (db *DB) UpdateHouseholdMember(ctx context.Context, residenceID int64, userID string, params UpdateHouseholdMemberParams) (*HouseholdMember, error) {
+	member, err := scanHouseholdMember(db.pool.QueryRow(ctx, `
+        WITH m AS (
+            UPDATE user_residences
+            SET can_approve_visitors = COALESCE($3, can_approve_visitors),
+                can_create_passes = COALESCE($4, can_create_passes),
+                is_tenant = COALESCE($5, is_tenant),
+                lease_end = CASE WHEN $7 THEN NULL ELSE COALESCE($6, lease_end) END
+            WHERE user_id = $1 AND residence_id = $2 AND role = 'RESIDENT'
+            RETURNING user_id, `+memberColumns+`
+        )
+        SELECT `+prefixColumns("u", userColumns)+`, `+prefixColumns("m", memberColumns)+`
+        FROM m
+        JOIN users u ON u.id = m.user_id`,
+		userID,
+		residenceID,
+		params.CanApproveVisitors,
+		params.CanCreatePasses,
+		params.IsTenant,
+		params.LeaseEnd,
+		params.ClearLeaseEnd,
+	))
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, fmt.Errorf("updating household member: %w", err)
+	}
+
+	return member, err
+}
+
+// RemoveHouseholdMember takes a RESIDENT out of a residence. A user left
+// without any residence is deactivated and signed out of every device; the
+// row is kept because visits and passes reference it.
+func (db *DB) RemoveHouseholdMember(ctx context.Context, residenceID int64, userID string) error {
+	return db.RunInTx(ctx, func(tx pgx.Tx) error {
+		result, err := tx.Exec(ctx, `
+            DELETE FROM user_residences
+            WHERE user_id = $1 AND residence_id = $2 AND role = 'RESIDENT'
+        `, userID, residenceID)
+		if err != nil {
+			return fmt.Errorf("removing household member: %w", err)
+		}
+
+		if result.RowsAffected() == 0 {
+			return ErrNotFound
+		}
+
+		return afterLeaving(ctx, tx, userID)
+	})
+}
+
+// afterLeaving moves a user's default residence to one they still belong
+// to, or deactivates them and revokes their devices if none is left.
+func afterLeaving(ctx context.Context, tx pgx.Tx, userID string) error {
+	var remaining *int64
+	err := tx.QueryRow(ctx, `
+        UPDATE users
+        SET residence_id = (
+                SELECT residence_id FROM user_residences
+                WHERE user_id = $1
+                ORDER BY created_at
+                LIMIT 1
+            )
+        WHERE id = $1
+        RETURNING residence_id
+    `, userID).Scan(&remaining)
+	if err != nil {
+		return fmt.Errorf("updating default residence: %w", err)
+	}
+
+	if remaining != nil {
+		return nil
+	}
+
+	if _, err := tx.Exec(ctx, `
+        UPDATE users
+        SET is_active = false, deactivated_at = NOW()
+        WHERE id = $1
+    `, userID); err != nil {
+		return fmt.Errorf("deactivating user: %w", err)
+	}
+
+	return revokeAllDevices(ctx, tx, userID)
+}
+
+// RedeemAccessCode registers a device for a pending user and activates them.
+func (db *DB) RedeemAccessCode(ctx context.Context, accessCode, deviceID string) (*User, error) {
+	var user *User
+	err := db.RunInTx(ctx, func(tx pgx.Tx) error {
+		var err error
+		user, err = scanUser(tx.QueryRow(ctx, `
+            UPDATE users
+            SET is_active = true, activated_by = invited_by, activated_at = NOW()
+            WHERE access_code = $1
+              AND activated_at IS NULL AND deactivated_at IS NULL
+              AND NOT EXISTS (
+                  SELECT 1 FROM user_residences ur
+                  WHERE ur.user_id = users.id AND ur.lease_end < CURRENT_DATE
+              )
+            RETURNING `+userColumns, accessCode))
+		if errors.Is(err, ErrNotFound) {
+			return ErrInvalidAccessCode
+		}
+		if err != nil {
+			return err
+		}
+
+		return addDevice(ctx, tx, user.ID, deviceID, nil)
+	})
+	if err != nil {
+		if errors.Is(err, ErrInvalidAccessCode) || errors.Is(err, ErrDeviceTaken) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("redeeming access code: %w", err)
+	}
+
+	return user, nil
+}
+
+// EndExpiredTenancies removes tenancies whose lease ended before today. Only
+// the expired membership goes: a tenant who still belongs to another
+// residence keeps it, and one left with none is deactivated and signed out
+// of their devices.
+func (db *DB) EndExpiredTenancies(ctx context.Context) (int64, error) {
+	var n int64
+	err := db.RunInTx(ctx, func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, `
+            DELETE FROM user_residences
+            WHERE is_tenant AND lease_end < CURRENT_DATE
+            RETURNING user_id
+        `)
+		if err != nil {
+			return fmt.Errorf("removing expired tenancies: %w", err)
+		}
+
+		users := map[string]bool{}
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return fmt.Errorf("scanning expired tenancy: %w", err)
+			}
+			users[id] = true
+			n++
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("iterating expired tenancies: %w", err)
+		}
+
+		for id := range users {
+			if err := afterLeaving(ctx, tx, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
@@ -0,0 +1,389 @@
+package store
+
+import (
+	"context"
+	"dooreye-backend/internal/model"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+var (
+	ErrInviteClosed      = errors.New("this invite link is no longer accepting guests")
+	ErrInviteNotStarted  = errors.New("this invite link is not open yet")
+	ErrGuestRegistered   = errors.New("this phone is already registered for the invite")
+	ErrDuplicatePassCode = errors.New("pass code already exists")
+	ErrPassNotValid      = errors.New("pass is not valid at this time")
+	ErrPassUsed          = errors.New("pass has already been used")
+)
+
+const guestInviteColumns = `
+    id, token, society_id, residence_id, title, max_uses, use_count,
+    valid_from, valid_until, revoked_at, created_by, created_at, updated_at
+`
+
+const guestRegistrationColumns = `
+    id, invite_id, visitor_id, name, phone, photo_url, pass_code,
+    registered_at, arrived_at, visit_id
+`
+
+type CreateGuestInviteParams struct {
+	Token       string
+	SocietyID   int64
+	ResidenceID int64
+	Title       string
+	MaxUses     int
+	ValidFrom   time.Time
+	ValidUntil  time.Time
+	CreatedBy   string
+}
+
+func (db *DB) CreateGuestInvite(ctx context.Context, params CreateGuestInviteParams) (*model.GuestInvite, error) {
+	invite, err := scanGuestInvite(db.pool.QueryRow(ctx, `
+        INSERT INTO guest_invites (
+            token, society_id, residence_id, title, max_uses, valid_from,
+            valid_until, created_by
+        )
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+        RETURNING `+guestInviteColumns,
+		params.Token,
+		params.SocietyID,
+		params.ResidenceID,
+		params.Title,
+		params.MaxUses,
+		params.ValidFrom,
+		params.ValidUntil,
+		params.CreatedBy,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("creating guest invite: %w", err)
+	}
+
+	return invite, nil
+}
+
+// GetGuestInvite returns an invite with everyone who registered through it.
+func (db *DB) GetGuestInvite(ctx context.Context, id int64) (*model.GuestInvite, error) {
+	invite, err := scanGuestInvite(db.pool.QueryRow(ctx, `SELECT `+guestInviteColumns+` FROM guest_invites WHERE id = $1`, id))
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.pool.Query(ctx, `
+        SELECT `+guestRegistrationColumns+`
+        FROM guest_registrations
+        WHERE invite_id = $1
+        ORDER BY registered_at
+    `, id)
+	if err != nil {
+		return nil, fmt.Errorf("querying guest registrations: %w", err)
+	}
+	defer rows.Close()
+
+	invite.Registrations = []model.GuestRegistration{}
+	for rows.Next() {
+		r, err := scanGuestRegistration(rows)
+		if err != nil {
+			return nil, err
+		}
+		invite.Registrations = append(invite.Registrations, *r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating guest registrations: %w", err)
+	}
+
+	return invite, nil
+}
+
+func (db *DB) GetGuestInvites(ctx context.Context, residenceID int64, limit, offset int) ([]model.GuestInvite, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	rows, err := db.pool.Query(ctx, `
+        SELECT `+guestInviteColumns+`
+        FROM guest_invites
+        WHERE residence_id = $1
+        ORDER BY valid_until DESC
+        LIMIT $2 OFFSET $3
+    `, residenceID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("querying guest invites: %w", err)
+	}
+	defer rows.Close()
+
+	var invites []model.GuestInvite
+	for rows.Next() {
+		i, err := scanGuestInvite(rows)
+		if err != nil {
+			return nil, err
+		}
+		invites = append(invites, *i)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating guest invites: %w", err)
+	}
+
+	return invites, nil
+}
+
+// GetPublicGuestInvite looks an invite up by its link token and returns only
+// what the guest needs to see.
+func (db *DB) GetPublicGuestInvite(ctx context.Context, token string) (*model.PublicGuestInvite, error) {
+	var invite model.GuestInvite
+	var pub model.PublicGuestInvite
+	err := db.pool.QueryRow(ctx, `
+        SELECT gi.title, gi.max_uses, gi.use_count, gi.valid_from, gi.valid_until,
+               gi.revoked_at, s.name, u.name
+        FROM guest_invites gi
+        JOIN societies s ON s.id = gi.society_id
+        JOIN users u ON u.id = gi.created_by
+        WHERE gi.token = $1
+    `, token).Scan(
+		&invite.Title, &invite.MaxUses, &invite.UseCount, &invite.ValidFrom,
+		&invite.ValidUntil, &invite.RevokedAt, &pub.SocietyName, &pub.HostName,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting guest invite: %w", err)
+	}
+
+	pub.Title = invite.Title
+	pub.ValidFrom = invite.ValidFrom
+	pub.ValidUntil = invite.ValidUntil
+	pub.Status = invite.StatusAt(time.Now())
+
+	return &pub, nil
+}
+
+// RevokeGuestInvite closes the link. Passes already issued through it stop
+// working at the gate too.
+func (db *DB) RevokeGuestInvite(ctx context.Context, id int64) (*model.GuestInvite, error) {
+	_, err := db.pool.Exec(ctx, `
+        UPDATE guest_invites SET revoked_at = NOW()
+        WHERE id = $1 AND revoked_at IS NULL
+    `, id)
+	if err != nil {
+		return nil, fmt.Errorf("revoking guest invite: %w", err)
+	}
+
+	return db.GetGuestInvite(ctx, id)
+}
+
+type RegisterGuestParams struct {
+	Token    string
+	Name     string
+	Phone    string
+	PhotoURL *string
+	PassCode string
+}
+
+// RegisterGuest signs a guest up through an invite link and issues their
+// pass. The invite row is locked so concurrent sign-ups cannot exceed its
+// cap. A phone can register once per invite; its pass went to that phone,
+// and is not handed to whoever enters the number again.
+func (db *DB) RegisterGuest(ctx context.Context, params RegisterGuestParams) (*model.GuestRegistration, *model.GuestInvite, error) {
+	var reg *model.GuestRegistration
+	var invite *model.GuestInvite
+
+	err := db.RunInTx(ctx, func(tx pgx.Tx) error {
+		var err error
+		invite, err = scanGuestInvite(tx.QueryRow(ctx, `
+            SELECT `+guestInviteColumns+` FROM guest_invites WHERE token = $1 FOR UPDATE
+        `, params.Token))
+		if err != nil {
+			return err
+		}
+
+		var registered bool
+		err = tx.QueryRow(ctx, `
+            SELECT EXISTS(SELECT 1 FROM guest_registrations WHERE invite_id = $1 AND phone = $2)
+        `, invite.ID, params.Phone).Scan(&registered)
+		if err != nil {
+			return fmt.Errorf("checking guest registration: %w", err)
+		}
+		if registered && invite.Status != model.InviteRevoked && invite.Status != model.InviteExpired {
+			return ErrGuestRegistered
+		}
+
+		switch invite.Status {
+		case model.InviteActive:
+		case model.InviteScheduled:
+			return ErrInviteNotStarted
+		default:
+			return ErrInviteClosed
+		}
+
+		var visitorID string
+		err = tx.QueryRow(ctx, `
+            INSERT INTO visitors (name, phone, photo_url, type, pre_approved_till, created_by)
+            VALUES ($1, $2, $3, $4, $5, $6)
+            RETURNING id
+        `, params.Name, params.Phone, params.PhotoURL, model.VisitorGuest, invite.ValidUntil, invite.CreatedBy).Scan(&visitorID)
+		if err != nil {
+			return fmt.Errorf("creating visitor: %w", err)
+		}
+
+		reg, err = scanGuestRegistration(tx.QueryRow(ctx, `
+            INSERT INTO guest_registrations (invite_id, visitor_id, name, phone, photo_url, pass_code)
+            VALUES ($1, $2, $3, $4, $5, $6)
+            RETURNING `+guestRegistrationColumns,
+			invite.ID, visitorID, params.Name, params.Phone, params.PhotoURL, params.PassCode,
+		))
+		if err != nil {
+			if isPgError(err, pgUniqueViolation) && pgConstraint(err) == "guest_registrations_pass_code_key" {
+				return ErrDuplicatePassCode
+			}
+			return fmt.Errorf("registering guest: %w", err)
+		}
+
+		_, err = tx.Exec(ctx, `UPDATE guest_invites SET use_count = use_count + 1 WHERE id = $1`, invite.ID)
+		if err != nil {
+			return fmt.Errorf("counting invite use: %w", err)
+		}
+		invite.UseCount++
+
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return reg, invite, nil
+}
+
+// GetGuestPass looks up a pass by the code on the guest's QR.
+func (db *DB) GetGuestPass(ctx context.Context, passCode string) (*model.GuestPass, error) {
+	return scanGuestPass(db.pool.QueryRow(ctx, guestPassQuery+` WHERE gr.pass_code = $1`, passCode))
+}
+
+type CheckInGuestPassParams struct {
+	PassCode  string
+	SocietyID int64
+	GateID    *int64
+	GuardID   string
+}
+
+// CheckInGuestPass admits an invited guest: it opens a visit to the host's
+// residence and marks the guest as arrived. A pass admits once, within the
+// invite's window, and only at the host's society.
+func (db *DB) CheckInGuestPass(ctx context.Context, params CheckInGuestPassParams) (*model.GuestPass, error) {
+	var pass *model.GuestPass
+
+	err := db.RunInTx(ctx, func(tx pgx.Tx) error {
+		var err error
+		pass, err = scanGuestPass(tx.QueryRow(ctx, guestPassQuery+`
+            WHERE gr.pass_code = $1 AND gi.society_id = $2
+            FOR UPDATE OF gr
+        `, params.PassCode, params.SocietyID))
+		if err != nil {
+			return err
+		}
+
+		if pass.ArrivedAt != nil {
+			return ErrPassUsed
+		}
+		if pass.Status != model.InviteActive && pass.Status != model.InviteFull {
+			return ErrPassNotValid
+		}
+
+		var visitID string
+		err = tx.QueryRow(ctx, `
+            INSERT INTO visits (residence_id, visitor_id, checked_in_by, check_in_time, purpose, gate_id)
+            VALUES ($1, $2, $3, NOW(), $4, $5)
+            RETURNING id
+        `, pass.ResidenceID, pass.VisitorID, params.GuardID, pass.InviteTitle, params.GateID).Scan(&visitID)
+		if err != nil {
+			return fmt.Errorf("creating visit: %w", err)
+		}
+
+		err = tx.QueryRow(ctx, `
+            UPDATE guest_registrations SET arrived_at = NOW(), visit_id = $2
+            WHERE id = $1
+            RETURNING arrived_at, visit_id
+        `, pass.ID, visitID).Scan(&pass.ArrivedAt, &pass.VisitID)
+		if err != nil {
+			return fmt.Errorf("marking guest arrived: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pass, nil
+}
+
+const guestPassQuery = `
+    SELECT gr.id, gr.invite_id, gr.visitor_id, gr.name, gr.phone, gr.photo_url,
+           gr.pass_code, gr.registered_at, gr.arrived_at, gr.visit_id,
+           gi.residence_id, gi.title, gi.max_uses, gi.use_count, gi.valid_from,
+           gi.valid_until, gi.revoked_at
+    FROM guest_registrations gr
+    JOIN guest_invites gi ON gi.id = gr.invite_id
+`
+
+func scanGuestPass(row pgx.Row) (*model.GuestPass, error) {
+	var p model.GuestPass
+	var invite model.GuestInvite
+	err := row.Scan(
+		&p.ID, &p.InviteID, &p.VisitorID, &p.Name, &p.Phone, &p.PhotoURL,
+		&p.PassCode, &p.RegisteredAt, &p.ArrivedAt, &p.VisitID,
+		&p.ResidenceID, &p.InviteTitle, &invite.MaxUses, &invite.UseCount,
+		&invite.ValidFrom, &invite.ValidUntil, &invite.RevokedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scanning guest pass: %w", err)
+	}
+
+	p.ValidFrom = invite.ValidFrom
+	p.ValidUntil = invite.ValidUntil
+	p.Status = invite.StatusAt(time.Now())
+
+	return &p, nil
+}
+
+func scanGuestInvite(row pgx.Row) (*model.GuestInvite, error) {
+	var i model.GuestInvite
+	err := row.Scan(
+		&i.ID, &i.Token, &i.SocietyID, &i.ResidenceID, &i.Title, &i.MaxUses,
+		&i.UseCount, &i.ValidFrom, &i.ValidUntil, &i.RevokedAt, &i.CreatedBy,
+		&i.CreatedAt, &i.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scanning guest invite: %w", err)
+	}
+
+	i.Status = i.StatusAt(time.Now())
+
+	return &i, nil
+}
+
+func scanGuestRegistration(row pgx.Row) (*model.GuestRegistration, error) {
+	var r model.GuestRegistration
+	err := row.Scan(
+		&r.ID, &r.InviteID, &r.VisitorID, &r.Name, &r.Phone, &r.PhotoURL,
+		&r.PassCode, &r.RegisteredAt, &r.ArrivedAt, &r.VisitID,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scanning guest registration: %w", err)
+	}
+
+	return &r, nil
+}
@@ -0,0 +1,268 @@
+package store
+
+import (
+	"context"
+	"dooreye-backend/internal/model"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+const jobColumns = `
+    id, kind, payload, status, attempts, max_attempts, run_at, unique_key,
+    locked_by, locked_at, last_error, finished_at, created_at, updated_at
+`
+
+type EnqueueJobParams struct {
+	Kind        string
+	Payload     []byte
+	RunAt       time.Time
+	MaxAttempts int
+	// UniqueKey deduplicates enqueues. A second enqueue with the same key is
+	// a no-op and EnqueueJob returns ErrAlreadyExists.
+	UniqueKey *string
+}
+
+func (db *DB) EnqueueJob(ctx context.Context, params EnqueueJobParams) (*model.Job, error) {
+	return enqueueJob(ctx, db.pool, params)
+}
+
+// enqueueJob lets store operations queue follow-up work in their own
+// transaction, so the job exists only if the change commits.
+func enqueueJob(ctx context.Context, q querier, params EnqueueJobParams) (*model.Job, error) {
+	if params.Payload == nil {
+		params.Payload = []byte("{}")
+	}
+	if params.RunAt.IsZero() {
+		params.RunAt = time.Now()
+	}
+	if params.MaxAttempts <= 0 {
+		params.MaxAttempts = 5
+	}
+
+	job, err := scanJob(q.QueryRow(ctx, `
+        INSERT INTO jobs (kind, payload, run_at, max_attempts, unique_key)
+        VALUES ($1, $2, $3, $4, $5)
+        ON CONFLICT (unique_key) DO NOTHING
+        RETURNING `+jobColumns,
+		params.Kind,
+		params.Payload,
+		params.RunAt,
+		params.MaxAttempts,
+		params.UniqueKey,
+	))
+	if errors.Is(err, ErrNotFound) {
+		return nil, ErrAlreadyExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("enqueueing job: %w", err)
+	}
+
+	return job, nil
+}
+
+// ClaimJob locks the oldest runnable job of one of the given kinds and marks
+// it as running. Concurrent workers skip rows locked by each other, so every
+// job is claimed exactly once. It returns ErrNotFound when the queue is empty.
+func (db *DB) ClaimJob(ctx context.Context, workerID string, kinds []string) (*model.Job, error) {
+	var job *model.Job
+
+	err := db.RunInTx(ctx, func(tx pgx.Tx) error {
+		var id int64
+		err := tx.QueryRow(ctx, `
+            SELECT id FROM jobs
+            WHERE status = 'PENDING' AND run_at <= NOW() AND kind = ANY($1)
+            ORDER BY run_at
+            LIMIT 1
+            FOR UPDATE SKIP LOCKED
+        `, kinds).Scan(&id)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("selecting job: %w", err)
+		}
+
+		job, err = scanJob(tx.QueryRow(ctx, `
+            UPDATE jobs
+            SET status = 'RUNNING', attempts = attempts + 1,
+                locked_by = $2, locked_at = NOW()
+            WHERE id = $1
+            RETURNING `+jobColumns, id, workerID))
+		if err != nil {
+			return fmt.Errorf("locking job: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+func (db *DB) CompleteJob(ctx context.Context, id int64) error {
+	result, err := db.pool.Exec(ctx, `
+        UPDATE jobs
+        SET status = 'SUCCEEDED', last_error = NULL, finished_at = NOW(),
+            locked_by = NULL, locked_at = NULL
+        WHERE id = $1 AND status = 'RUNNING'
+    `, id)
+	if err != nil {
+		return fmt.Errorf("completing job: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// FailJob records a failed attempt. The job goes back to the queue at retryAt
+// unless it has used up its attempts, in which case it is marked failed.
+func (db *DB) FailJob(ctx context.Context, id int64, jobErr error, retryAt time.Time) error {
+	result, err := db.pool.Exec(ctx, `
+        UPDATE jobs
+        SET status = CASE WHEN attempts >= max_attempts
+                          THEN 'FAILED'::job_status
+                          ELSE 'PENDING'::job_status END,
+            finished_at = CASE WHEN attempts >= max_attempts THEN NOW() END,
+            run_at = $3, last_error = $2,
+            locked_by = NULL, locked_at = NULL
+        WHERE id = $1 AND status = 'RUNNING'
+    `, id, jobErr.Error(), retryAt)
+	if err != nil {
+		return fmt.Errorf("failing job: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// RequeueStaleJobs returns jobs stuck in RUNNING, e.g. after a crashed
+// instance, to the queue. ClaimJob already counted the lost run as an
+// attempt, so a job that keeps killing its worker ends up failed instead of
+// being retried forever.
+func (db *DB) RequeueStaleJobs(ctx context.Context, lockedBefore time.Time) (int64, error) {
+	result, err := db.pool.Exec(ctx, `
+        UPDATE jobs
+        SET status = CASE WHEN attempts >= max_attempts
+                          THEN 'FAILED'::job_status
+                          ELSE 'PENDING'::job_status END,
+            finished_at = CASE WHEN attempts >= max_attempts THEN NOW() END,
+            locked_by = NULL, locked_at = NULL,
+            last_error = 'worker lost'
+        WHERE status = 'RUNNING' AND locked_at < $1
+    `, lockedBefore)
+	if err != nil {
+		return 0, fmt.Errorf("requeueing stale jobs: %w", err)
+	}
+
+	return result.RowsAffected(), nil
+}
+
+// RetryJob puts a failed job back in the queue with a fresh set of attempts
+// and no error left over from the last run.
+func (db *DB) RetryJob(ctx context.Context, id int64) (*model.Job, error) {
+	job, err := scanJob(db.pool.QueryRow(ctx, `
+        UPDATE jobs
+        SET status = 'PENDING', attempts = 0, run_at = NOW(),
+            finished_at = NULL, last_error = NULL
+        WHERE id = $1 AND status = 'FAILED'
+        RETURNING `+jobColumns, id))
+	if err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+func (db *DB) PurgeFinishedJobs(ctx context.Context, finishedBefore time.Time) (int64, error) {
+	result, err := db.pool.Exec(ctx, `
+        DELETE FROM jobs
+        WHERE status IN ('SUCCEEDED', 'FAILED') AND finished_at < $1
+    `, finishedBefore)
+	if err != nil {
+		return 0, fmt.Errorf("purging jobs: %w", err)
+	}
+
+	return result.RowsAffected(), nil
+}
+
+func (db *DB) GetJob(ctx context.Context, id int64) (*model.Job, error) {
+	return scanJob(db.pool.QueryRow(ctx, `SELECT `+jobColumns+` FROM jobs WHERE id = $1`, id))
+}
+
+type JobFilter struct {
+	Kind   string
+	Status model.JobStatus
+	Limit  int
+}
+
+func (db *DB) GetJobs(ctx context.Context, filter JobFilter) ([]model.Job, error) {
+	query := `SELECT ` + jobColumns + ` FROM jobs WHERE 1=1`
+	args := []interface{}{}
+	argCount := 1
+
+	if filter.Kind != "" {
+		query += fmt.Sprintf(" AND kind = $%d", argCount)
+		args = append(args, filter.Kind)
+		argCount++
+	}
+
+	if filter.Status != "" {
+		query += fmt.Sprintf(" AND status = $%d", argCount)
+		args = append(args, filter.Status)
+		argCount++
+	}
+
+	if filter.Limit <= 0 || filter.Limit > 500 {
+		filter.Limit = 100
+	}
+	query += fmt.Sprintf(" ORDER BY created_at DESC LIMIT $%d", argCount)
+	args = append(args, filter.Limit)
+
+	rows, err := db.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []model.Job
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, *job)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating jobs: %w", err)
+	}
+
+	return jobs, nil
+}
+
+func scanJob(row pgx.Row) (*model.Job, error) {
+	var j model.Job
+	err := row.Scan(
+		&j.ID, &j.Kind, &j.Payload, &j.Status, &j.Attempts, &j.MaxAttempts,
+		&j.RunAt, &j.UniqueKey, &j.LockedBy, &j.LockedAt, &j.LastError,
+		&j.FinishedAt, &j.CreatedAt, &j.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scanning job: %w", err)
+	}
+
+	return &j, nil
+}
@@ -0,0 +1,59 @@
+package store
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// Advisory lock keys. Each background worker that must run on a single
+// instance gets its own key.
+const (
+	LockOverstayScanner int64 = 1001
+)
+
+// AdvisoryLock is a session level Postgres advisory lock. It pins a pool
+// connection for as long as it is held.
+type AdvisoryLock struct {
+	conn *pgxpool.Conn
+	key  int64
+}
+
+// TryAdvisoryLock attempts to take the lock without blocking. It returns nil
+// if another session already holds it.
+func (db *DB) TryAdvisoryLock(ctx context.Context, key int64) (*AdvisoryLock, error) {
+	conn, err := db.pool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("acquiring connection: %w", err)
+	}
+
+	var acquired bool
+	if err := conn.QueryRow(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&acquired); err != nil {
+		conn.Release()
+		return nil, fmt.Errorf("trying advisory lock: %w", err)
+	}
+
+	if !acquired {
+		conn.Release()
+		return nil, nil
+	}
+
+	return &AdvisoryLock{conn: conn, key: key}, nil
+}
+
+// Alive reports whether the session holding the lock is still usable. A
+// dropped connection means the lock has been released server side.
+func (l *AdvisoryLock) Alive(ctx context.Context) bool {
+	return l.conn.Ping(ctx) == nil
+}
+
+func (l *AdvisoryLock) Release(ctx context.Context) error {
+	defer l.conn.Release()
+
+	if _, err := l.conn.Exec(ctx, "SELECT pg_advisory_unlock($1)", l.key); err != nil {
+		return fmt.Errorf("releasing advisory lock: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,161 @@
+package store
+
+import (
+	"context"
+	"dooreye-backend/internal/model"
+	"fmt"
+)
+
+type OverstayFilter struct {
+	SocietyID      *int64
+	ResidenceID    *int64
+	OnlyUnnotified bool
+}
+
+// GetOverstayingVisits returns open visits that have been inside longer than
+// the stay limit for their visitor type. A society specific limit takes
+// precedence over the global default.
+func (db *DB) GetOverstayingVisits(ctx context.Context, filter OverstayFilter) ([]model.OverstayVisit, error) {
+	query := `
+        WITH open_visits AS (
+            SELECT v.id, v.residence_id,
+                   COALESCE(b.society_id, u.society_id) AS society_id,
+                   v.checked_in_by, v.check_in_time, v.overstay_notified_at,
+                   vis.name, vis.phone, vis.type
+            FROM visits v
+            JOIN visitors vis ON vis.id = v.visitor_id
+            JOIN users u ON u.id = v.checked_in_by
+            LEFT JOIN residences r ON r.id = v.residence_id
+            LEFT JOIN blocks b ON b.id = r.block_id
+            WHERE v.check_out_time IS NULL
+        )
+        SELECT ov.id, ov.residence_id, ov.society_id, ov.checked_in_by,
+               ov.check_in_time, ov.overstay_notified_at,
+               ov.name, ov.phone, ov.type, sl.max_stay_minutes,
+               (EXTRACT(EPOCH FROM (NOW() - ov.check_in_time)) / 60)::int - sl.max_stay_minutes
+        FROM open_visits ov
+        JOIN LATERAL (
+            SELECT max_stay_minutes
+            FROM stay_limits
+            WHERE visitor_type = ov.type
+              AND (society_id = ov.society_id OR society_id IS NULL)
+            ORDER BY society_id NULLS LAST
+            LIMIT 1
+        ) sl ON true
+        WHERE ov.check_in_time + make_interval(mins => sl.max_stay_minutes) < NOW()
+    `
+	args := []interface{}{}
+	argCount := 1
+
+	if filter.SocietyID != nil {
+		query += fmt.Sprintf(" AND ov.society_id = $%d", argCount)
+		args = append(args, *filter.SocietyID)
+		argCount++
+	}
+
+	if filter.ResidenceID != nil {
+		query += fmt.Sprintf(" AND ov.residence_id = $%d", argCount)
+		args = append(args, *filter.ResidenceID)
+		argCount++
+	}
+
+	if filter.OnlyUnnotified {
+		query += " AND ov.overstay_notified_at IS NULL"
+	}
+
+	query += " ORDER BY ov.check_in_time"
+
+	rows, err := db.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying overstaying visits: %w", err)
+	}
+	defer rows.Close()
+
+	var visits []model.OverstayVisit
+	for rows.Next() {
+		var v model.OverstayVisit
+		if err := rows.Scan(
+			&v.VisitID, &v.ResidenceID, &v.SocietyID, &v.CheckedInBy,
+			&v.CheckInTime, &v.OverstayNotifiedAt,
+			&v.Name, &v.Phone, &v.Type, &v.MaxStayMinutes,
+			&v.OverstayMinutes,
+		); err != nil {
+			return nil, fmt.Errorf("scanning overstay row: %w", err)
+		}
+		visits = append(visits, v)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating overstaying visits: %w", err)
+	}
+
+	return visits, nil
+}
+
+func (db *DB) MarkOverstayNotified(ctx context.Context, visitID string) error {
+	result, err := db.pool.Exec(ctx, `
+        UPDATE visits
+        SET overstay_notified_at = NOW()
+        WHERE id = $1 AND overstay_notified_at IS NULL
+    `, visitID)
+	if err != nil {
+		return fmt.Errorf("marking overstay notified: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// GetStayLimits returns the effective stay limit for every visitor type in a
+// society, falling back to the global default where no override exists.
+func (db *DB) GetStayLimits(ctx context.Context, societyID int64) ([]model.StayLimit, error) {
+	rows, err := db.pool.Query(ctx, `
+        SELECT DISTINCT ON (visitor_type)
+               id, society_id, visitor_type, max_stay_minutes, updated_at
+        FROM stay_limits
+        WHERE society_id = $1 OR society_id IS NULL
+        ORDER BY visitor_type, society_id NULLS LAST
+    `, societyID)
+	if err != nil {
+		return nil, fmt.Errorf("querying stay limits: %w", err)
+	}
+	defer rows.Close()
+
+	var limits []model.StayLimit
+	for rows.Next() {
+		var l model.StayLimit
+		if err := rows.Scan(
+			&l.ID, &l.SocietyID, &l.VisitorType, &l.MaxStayMinutes, &l.UpdatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("scanning stay limit row: %w", err)
+		}
+		limits = append(limits, l)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating stay limits: %w", err)
+	}
+
+	return limits, nil
+}
+
+func (db *DB) UpsertStayLimit(ctx context.Context, societyID int64, visitorType model.VisitorType, maxStayMinutes int) (*model.StayLimit, error) {
+	var l model.StayLimit
+	err := db.pool.QueryRow(ctx, `
+        INSERT INTO stay_limits (society_id, visitor_type, max_stay_minutes)
+        VALUES ($1, $2, $3)
+        ON CONFLICT (COALESCE(society_id, 0), visitor_type)
+        DO UPDATE SET max_stay_minutes = EXCLUDED.max_stay_minutes
+        RETURNING id, society_id, visitor_type, max_stay_minutes, updated_at
+    `, societyID, visitorType, maxStayMinutes).Scan(
+		&l.ID, &l.SocietyID, &l.VisitorType, &l.MaxStayMinutes, &l.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("upserting stay limit: %w", err)
+	}
+
+	return &l, nil
+}
@@ -0,0 +1,490 @@
+package store
+
+import (
+	"context"
+	"dooreye-backend/internal/model"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+var (
+	ErrSlotUnavailable      = errors.New("parking slot is not available for that time")
+	ErrVisitorAlreadyBooked = errors.New("visitor already has a parking booking for that time")
+	ErrNoGuestSlot          = errors.New("no guest parking slot is free")
+	ErrSlotHasBookings      = errors.New("slot has guest bookings; retire it and create a new slot instead")
+	ErrBookingClosed        = errors.New("booking can no longer be changed")
+)
+
+// slotAllocationAttempts bounds retries when two bookings race for the same
+// free slot and one loses to the exclusion constraint.
+const slotAllocationAttempts = 3
+
+const parkingSlotColumns = `
+    id, society_id, block_id, code, vehicle_type, residence_id, is_guest,
+    is_active, created_at, updated_at
+`
+
+type CreateParkingSlotParams struct {
+	SocietyID   int64
+	BlockID     int64
+	Code        string
+	VehicleType model.VehicleType
+	ResidenceID *int64
+	IsGuest     bool
+}
+
+// CreateParkingSlot adds a slot under a block. The block must belong to the
+// given society, otherwise ErrNotFound is returned.
+func (db *DB) CreateParkingSlot(ctx context.Context, params CreateParkingSlotParams) (*model.ParkingSlot, error) {
+	slot, err := scanParkingSlot(db.pool.QueryRow(ctx, `
+        INSERT INTO parking_slots (society_id, block_id, code, vehicle_type, residence_id, is_guest)
+        SELECT b.society_id, b.id, $3, $4, $5, $6
+        FROM blocks b
+        WHERE b.id = $2 AND b.society_id = $1
+        RETURNING `+parkingSlotColumns,
+		params.SocietyID,
+		params.BlockID,
+		params.Code,
+		params.VehicleType,
+		params.ResidenceID,
+		params.IsGuest,
+	))
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		if isPgError(err, pgUniqueViolation) {
+			return nil, ErrAlreadyExists
+		}
+		return nil, fmt.Errorf("creating parking slot: %w", err)
+	}
+
+	return slot, err
+}
+
+func (db *DB) GetParkingSlot(ctx context.Context, id int64) (*model.ParkingSlot, error) {
+	return scanParkingSlot(db.pool.QueryRow(ctx, `SELECT `+parkingSlotColumns+` FROM parking_slots WHERE id = $1`, id))
+}
+
+type ParkingSlotFilter struct {
+	SocietyID   *int64
+	ResidenceID *int64
+	OnlyGuest   bool
+}
+
+func (db *DB) GetParkingSlots(ctx context.Context, filter ParkingSlotFilter) ([]model.ParkingSlot, error) {
+	query := `SELECT ` + parkingSlotColumns + ` FROM parking_slots WHERE is_active`
+	args := []interface{}{}
+	argCount := 1
+
+	if filter.SocietyID != nil {
+		query += fmt.Sprintf(" AND society_id = $%d", argCount)
+		args = append(args, *filter.SocietyID)
+		argCount++
+	}
+
+	if filter.ResidenceID != nil {
+		query += fmt.Sprintf(" AND residence_id = $%d", argCount)
+		args = append(args, *filter.ResidenceID)
+		argCount++
+	}
+
+	if filter.OnlyGuest {
+		query += " AND is_guest"
+	}
+
+	query += " ORDER BY block_id, code"
+
+	return db.queryParkingSlots(ctx, query, args...)
+}
+
+// AssignParkingSlot gives a slot to a residence, or returns it to the guest
+// pool when residenceID is nil and toGuestPool is set.
+func (db *DB) AssignParkingSlot(ctx context.Context, id int64, residenceID *int64, toGuestPool bool) (*model.ParkingSlot, error) {
+	slot, err := scanParkingSlot(db.pool.QueryRow(ctx, `
+        UPDATE parking_slots
+        SET residence_id = $2, is_guest = $3
+        WHERE id = $1 AND is_active
+        RETURNING `+parkingSlotColumns, id, residenceID, toGuestPool))
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		if isPgError(err, pgForeignKeyViolation) {
+			return nil, ErrSlotHasBookings
+		}
+		return nil, fmt.Errorf("assigning parking slot: %w", err)
+	}
+
+	return slot, err
+}
+
+func (db *DB) RetireParkingSlot(ctx context.Context, id int64) error {
+	result, err := db.pool.Exec(ctx, `
+        UPDATE parking_slots SET is_active = false
+        WHERE id = $1 AND is_active
+    `, id)
+	if err != nil {
+		return fmt.Errorf("retiring parking slot: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// GetAvailableGuestSlots lists guest slots with no live booking overlapping
+// [from, to).
+func (db *DB) GetAvailableGuestSlots(ctx context.Context, societyID int64, vehicleType model.VehicleType, from, to time.Time) ([]model.ParkingSlot, error) {
+	return db.queryParkingSlots(ctx, `
+        SELECT `+prefixColumns("s", parkingSlotColumns)+`
+        FROM parking_slots s
+        WHERE s.society_id = $1 AND s.vehicle_type = $2
+          AND s.is_guest AND s.is_active
+          AND NOT EXISTS (
+              SELECT 1 FROM guest_parking_bookings g
+              WHERE g.slot_id = s.id
+                AND g.status IN ('BOOKED', 'CHECKED_IN')
+                AND g.period && tstzrange($3, $4)
+          )
+        ORDER BY s.block_id, s.code
+    `, societyID, vehicleType, from, to)
+}
+
+const guestBookingColumns = `
+    g.id, g.slot_id, s.code, g.residence_id, g.visitor_id, g.visit_id,
+    lower(g.period), upper(g.period), g.status, g.booked_by, g.created_at,
+    g.updated_at
+`
+
+type CreateGuestBookingParams struct {
+	SocietyID   int64
+	SlotID      *int64
+	VehicleType model.VehicleType
+	ResidenceID *int64
+	VisitorID   *string
+	VisitID     *string
+	From        time.Time
+	To          time.Time
+	Status      model.ParkingBookingStatus
+	BookedBy    string
+}
+
+// CreateGuestBooking reserves a guest slot. With no SlotID the first free
+// slot of the right vehicle type is picked. Overlaps are rejected by the
+// table's exclusion constraints, so concurrent bookings can never share a
+// slot.
+func (db *DB) CreateGuestBooking(ctx context.Context, params CreateGuestBookingParams) (*model.GuestParkingBooking, error) {
+	return createGuestBooking(ctx, db.pool, params)
+}
+
+type beginner interface {
+	querier
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+func createGuestBooking(ctx context.Context, q beginner, params CreateGuestBookingParams) (*model.GuestParkingBooking, error) {
+	if params.Status == "" {
+		params.Status = model.BookingBooked
+	}
+
+	attempts := slotAllocationAttempts
+	if params.SlotID != nil {
+		attempts = 1
+	}
+
+	for i := 0; i < attempts; i++ {
+		booking, err := tryGuestBooking(ctx, q, params)
+		if errors.Is(err, ErrSlotUnavailable) && params.SlotID == nil {
+			continue
+		}
+		return booking, err
+	}
+
+	return nil, ErrNoGuestSlot
+}
+
+// tryGuestBooking runs in a savepoint so a lost race can be retried even
+// inside a caller's transaction.
+func tryGuestBooking(ctx context.Context, q beginner, params CreateGuestBookingParams) (*model.GuestParkingBooking, error) {
+	tx, err := q.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("beginning booking: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var slotID int64
+	if params.SlotID != nil {
+		err = tx.QueryRow(ctx, `
+            SELECT id FROM parking_slots
+            WHERE id = $1 AND society_id = $2 AND is_guest AND is_active
+        `, *params.SlotID, params.SocietyID).Scan(&slotID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+	} else {
+		err = tx.QueryRow(ctx, `
+            SELECT s.id
+            FROM parking_slots s
+            WHERE s.society_id = $1 AND s.vehicle_type = $2
+              AND s.is_guest AND s.is_active
+              AND NOT EXISTS (
+                  SELECT 1 FROM guest_parking_bookings g
+                  WHERE g.slot_id = s.id
+                    AND g.status IN ('BOOKED', 'CHECKED_IN')
+                    AND g.period && tstzrange($3, $4)
+              )
+            ORDER BY s.block_id, s.code
+            LIMIT 1
+        `, params.SocietyID, params.VehicleType, params.From, params.To).Scan(&slotID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNoGuestSlot
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("selecting guest slot: %w", err)
+	}
+
+	booking, err := scanGuestBooking(tx.QueryRow(ctx, `
+        WITH g AS (
+            INSERT INTO guest_parking_bookings (
+                slot_id, residence_id, visitor_id, visit_id, period, status, booked_by
+            )
+            VALUES ($1, $2, $3, $4, tstzrange($5, $6), $7, $8)
+            RETURNING *
+        )
+        SELECT `+guestBookingColumns+`
+        FROM g
+        JOIN parking_slots s ON s.id = g.slot_id
+    `,
+		slotID,
+		params.ResidenceID,
+		params.VisitorID,
+		params.VisitID,
+		params.From,
+		params.To,
+		params.Status,
+		params.BookedBy,
+	))
+	if err != nil {
+		if isPgError(err, pgExclusionViolation) {
+			if pgConstraint(err) == "guest_parking_bookings_visitor_overlap" {
+				return nil, ErrVisitorAlreadyBooked
+			}
+			return nil, ErrSlotUnavailable
+		}
+		return nil, fmt.Errorf("creating guest booking: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("committing guest booking: %w", err)
+	}
+
+	return booking, nil
+}
+
+func (db *DB) GetGuestBooking(ctx context.Context, id int64) (*model.GuestParkingBooking, error) {
+	return scanGuestBooking(db.pool.QueryRow(ctx, `
+        SELECT `+guestBookingColumns+`
+        FROM guest_parking_bookings g
+        JOIN parking_slots s ON s.id = g.slot_id
+        WHERE g.id = $1
+    `, id))
+}
+
+type GuestBookingFilter struct {
+	SocietyID    *int64
+	ResidenceID  *int64
+	OnlyUpcoming bool
+}
+
+func (db *DB) GetGuestBookings(ctx context.Context, filter GuestBookingFilter) ([]model.GuestParkingBooking, error) {
+	query := `
+        SELECT ` + guestBookingColumns + `
+        FROM guest_parking_bookings g
+        JOIN parking_slots s ON s.id = g.slot_id
+        WHERE 1=1
+    `
+	args := []interface{}{}
+	argCount := 1
+
+	if filter.SocietyID != nil {
+		query += fmt.Sprintf(" AND s.society_id = $%d", argCount)
+		args = append(args, *filter.SocietyID)
+		argCount++
+	}
+
+	if filter.ResidenceID != nil {
+		query += fmt.Sprintf(" AND g.residence_id = $%d", argCount)
+		args = append(args, *filter.ResidenceID)
+		argCount++
+	}
+
+	if filter.OnlyUpcoming {
+		query += " AND g.status IN ('BOOKED', 'CHECKED_IN') AND upper(g.period) > NOW()"
+	}
+
+	query += " ORDER BY lower(g.period) LIMIT 200"
+
+	rows, err := db.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying guest bookings: %w", err)
+	}
+	defer rows.Close()
+
+	var bookings []model.GuestParkingBooking
+	for rows.Next() {
+		b, err := scanGuestBooking(rows)
+		if err != nil {
+			return nil, err
+		}
+		bookings = append(bookings, *b)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating guest bookings: %w", err)
+	}
+
+	return bookings, nil
+}
+
+func (db *DB) CancelGuestBooking(ctx context.Context, id int64) error {
+	result, err := db.pool.Exec(ctx, `
+        UPDATE guest_parking_bookings
+        SET status = 'CANCELLED'
+        WHERE id = $1 AND status = 'BOOKED'
+    `, id)
+	if err != nil {
+		return fmt.Errorf("cancelling guest booking: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		if _, err := db.GetGuestBooking(ctx, id); err != nil {
+			return err
+		}
+		return ErrBookingClosed
+	}
+
+	return nil
+}
+
+type GuestParkingCheckInParams struct {
+	SocietyID   int64
+	Phone       string
+	VehicleType model.VehicleType
+	VisitID     string
+	Stay        time.Duration
+	LoggedBy    string
+}
+
+// CheckInGuestParking gives an arriving guest vehicle a slot as part of the
+// check-in transaction. A booking made for the visitor's phone that covers
+// now is used if there is one; otherwise a free guest slot is allocated for
+// the expected stay.
+func (db *DB) CheckInGuestParking(ctx context.Context, tx pgx.Tx, params GuestParkingCheckInParams) (*model.GuestParkingBooking, error) {
+	booking, err := scanGuestBooking(tx.QueryRow(ctx, `
+        WITH g AS (
+            UPDATE guest_parking_bookings
+            SET status = 'CHECKED_IN', visit_id = $3
+            WHERE id = (
+                SELECT g.id
+                FROM guest_parking_bookings g
+                JOIN parking_slots s ON s.id = g.slot_id
+                JOIN visitors v ON v.id = g.visitor_id
+                WHERE s.society_id = $1 AND v.phone = $2
+                  AND g.status = 'BOOKED' AND g.period @> NOW()
+                ORDER BY lower(g.period)
+                LIMIT 1
+            )
+            RETURNING *
+        )
+        SELECT `+guestBookingColumns+`
+        FROM g
+        JOIN parking_slots s ON s.id = g.slot_id
+    `, params.SocietyID, params.Phone, params.VisitID))
+	if err == nil || !errors.Is(err, ErrNotFound) {
+		return booking, err
+	}
+
+	now := time.Now()
+	return createGuestBooking(ctx, tx, CreateGuestBookingParams{
+		SocietyID:   params.SocietyID,
+		VehicleType: params.VehicleType,
+		VisitID:     &params.VisitID,
+		From:        now,
+		To:          now.Add(params.Stay),
+		Status:      model.BookingCheckedIn,
+		BookedBy:    params.LoggedBy,
+	})
+}
+
+// CompleteGuestParking frees the slot held for a visit when its vehicle
+// leaves, trimming the booking to the actual stay.
+func (db *DB) CompleteGuestParking(ctx context.Context, visitID string) error {
+	_, err := db.pool.Exec(ctx, `
+        UPDATE guest_parking_bookings
+        SET status = 'COMPLETED',
+            period = tstzrange(lower(period), GREATEST(NOW(), lower(period) + interval '1 second'))
+        WHERE visit_id = $1 AND status = 'CHECKED_IN'
+    `, visitID)
+	if err != nil {
+		return fmt.Errorf("completing guest parking: %w", err)
+	}
+
+	return nil
+}
+
+func (db *DB) queryParkingSlots(ctx context.Context, query string, args ...interface{}) ([]model.ParkingSlot, error) {
+	rows, err := db.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying parking slots: %w", err)
+	}
+	defer rows.Close()
+
+	var slots []model.ParkingSlot
+	for rows.Next() {
+		s, err := scanParkingSlot(rows)
+		if err != nil {
+			return nil, err
+		}
+		slots = append(slots, *s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating parking slots: %w", err)
+	}
+
+	return slots, nil
+}
+
+func scanParkingSlot(row pgx.Row) (*model.ParkingSlot, error) {
+	var s model.ParkingSlot
+	err := row.Scan(
+		&s.ID, &s.SocietyID, &s.BlockID, &s.Code, &s.VehicleType,
+		&s.ResidenceID, &s.IsGuest, &s.IsActive, &s.CreatedAt, &s.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scanning parking slot: %w", err)
+	}
+
+	return &s, nil
+}
+
+func scanGuestBooking(row pgx.Row) (*model.GuestParkingBooking, error) {
+	var b model.GuestParkingBooking
+	err := row.Scan(
+		&b.ID, &b.SlotID, &b.SlotCode, &b.ResidenceID, &b.VisitorID, &b.VisitID,
+		&b.StartsAt, &b.EndsAt, &b.Status, &b.BookedBy, &b.CreatedAt,
+		&b.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scanning guest booking: %w", err)
+	}
+
+	return &b, nil
+}
@@ -0,0 +1,515 @@
+package store
+
+import (
+	"context"
+	"dooreye-backend/internal/ballot"
+	"dooreye-backend/internal/model"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/jackc/pgx/v4"
+)
+
+var (
+	ErrPollNotOpen     = errors.New("poll is not open for voting")
+	ErrNotEligible     = errors.New("not eligible to vote in this poll")
+	ErrAlreadyVoted    = errors.New("this residence has already voted")
+	ErrInvalidOption   = errors.New("option does not belong to this poll")
+	ErrPollNotEditable = errors.New("poll has already closed or been cancelled")
+)
+
+const pollColumns = `
+    id, society_id, title, description, eligibility, is_anonymous, opens_at,
+    closes_at, cancelled_at, ballot_count, head_hash, sealed_at, created_by,
+    created_at, updated_at
+`
+
+type CreatePollParams struct {
+	SocietyID   int64
+	Title       string
+	Description *string
+	Eligibility model.PollEligibility
+	IsAnonymous bool
+	OpensAt     time.Time
+	ClosesAt    time.Time
+	Options     []string
+	CreatedBy   string
+}
+
+func (db *DB) CreatePoll(ctx context.Context, params CreatePollParams) (*model.Poll, error) {
+	var poll *model.Poll
+
+	err := db.RunInTx(ctx, func(tx pgx.Tx) error {
+		var err error
+		poll, err = scanPoll(tx.QueryRow(ctx, `
+            INSERT INTO polls (
+                society_id, title, description, eligibility, is_anonymous,
+                opens_at, closes_at, created_by
+            )
+            VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+            RETURNING `+pollColumns,
+			params.SocietyID,
+			params.Title,
+			params.Description,
+			params.Eligibility,
+			params.IsAnonymous,
+			params.OpensAt,
+			params.ClosesAt,
+			params.CreatedBy,
+		))
+		if err != nil {
+			return fmt.Errorf("creating poll: %w", err)
+		}
+
+		for i, label := range params.Options {
+			var opt model.PollOption
+			err := tx.QueryRow(ctx, `
+                INSERT INTO poll_options (poll_id, label, position)
+                VALUES ($1, $2, $3)
+                RETURNING id, poll_id, label, position
+            `, poll.ID, label, i+1).Scan(&opt.ID, &opt.PollID, &opt.Label, &opt.Position)
+			if err != nil {
+				return fmt.Errorf("adding poll option: %w", err)
+			}
+			poll.Options = append(poll.Options, opt)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return poll, nil
+}
+
+func (db *DB) GetPoll(ctx context.Context, id int64) (*model.Poll, error) {
+	poll, err := scanPoll(db.pool.QueryRow(ctx, `SELECT `+pollColumns+` FROM polls WHERE id = $1`, id))
+	if err != nil {
+		return nil, err
+	}
+
+	polls := []model.Poll{*poll}
+	if err := db.loadPollOptions(ctx, polls); err != nil {
+		return nil, err
+	}
+
+	return &polls[0], nil
+}
+
+type PollFilter struct {
+	SocietyID int64
+	// OPEN, SCHEDULED, CLOSED or CANCELLED; empty for all.
+	Status model.PollStatus
+	Limit  int
+	Offset int
+}
+
+func (db *DB) GetPolls(ctx context.Context, filter PollFilter) ([]model.Poll, error) {
+	query := `SELECT ` + pollColumns + ` FROM polls WHERE society_id = $1`
+
+	switch filter.Status {
+	case model.PollScheduled:
+		query += " AND cancelled_at IS NULL AND opens_at > NOW()"
+	case model.PollOpen:
+		query += " AND cancelled_at IS NULL AND opens_at <= NOW() AND closes_at > NOW()"
+	case model.PollClosed:
+		query += " AND cancelled_at IS NULL AND closes_at <= NOW()"
+	case model.PollCancelled:
+		query += " AND cancelled_at IS NOT NULL"
+	}
+
+	if filter.Limit <= 0 || filter.Limit > 100 {
+		filter.Limit = 20
+	}
+	query += " ORDER BY closes_at DESC LIMIT $2 OFFSET $3"
+
+	rows, err := db.pool.Query(ctx, query, filter.SocietyID, filter.Limit, filter.Offset)
+	if err != nil {
+		return nil, fmt.Errorf("querying polls: %w", err)
+	}
+	defer rows.Close()
+
+	var polls []model.Poll
+	for rows.Next() {
+		p, err := scanPoll(rows)
+		if err != nil {
+			return nil, err
+		}
+		polls = append(polls, *p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating polls: %w", err)
+	}
+
+	if err := db.loadPollOptions(ctx, polls); err != nil {
+		return nil, err
+	}
+
+	return polls, nil
+}
+
+// ClosePoll ends voting now instead of at the scheduled close. A poll that
+// has not opened yet can only be cancelled.
+func (db *DB) ClosePoll(ctx context.Context, id int64) (*model.Poll, error) {
+	return db.endPoll(ctx, id,
+		`closes_at = GREATEST(NOW(), opens_at + interval '1 microsecond')`,
+		`opens_at <= NOW()`)
+}
+
+// CancelPoll voids a poll that has not closed yet. Its ballots are kept for
+// the record but no result is published.
+func (db *DB) CancelPoll(ctx context.Context, id int64) (*model.Poll, error) {
+	return db.endPoll(ctx, id, `cancelled_at = NOW()`, `TRUE`)
+}
+
+func (db *DB) endPoll(ctx context.Context, id int64, set, cond string) (*model.Poll, error) {
+	_, err := scanPoll(db.pool.QueryRow(ctx, `
+        UPDATE polls SET `+set+`
+        WHERE id = $1 AND cancelled_at IS NULL AND closes_at > NOW() AND `+cond+`
+        RETURNING `+pollColumns, id))
+	if errors.Is(err, ErrNotFound) {
+		poll, err := db.GetPoll(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if poll.Status == model.PollScheduled {
+			return nil, ErrPollNotOpen
+		}
+		return nil, ErrPollNotEditable
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ending poll: %w", err)
+	}
+
+	return db.GetPoll(ctx, id)
+}
+
+type CastVoteParams struct {
+	PollID      int64
+	OptionID    int64
+	ResidenceID int64
+	VoterID     string
+}
+
+// CastVote records a residence's vote and appends its ballot to the poll's
+// hash chain. The poll row is locked while voting so ballots are chained in
+// a single, gapless order. It returns the ballot, whose hash is the voter's
+// receipt.
+func (db *DB) CastVote(ctx context.Context, params CastVoteParams) (*model.PollBallot, error) {
+	var b *model.PollBallot
+
+	err := db.RunInTx(ctx, func(tx pgx.Tx) error {
+		poll, err := scanPoll(tx.QueryRow(ctx, `SELECT `+pollColumns+` FROM polls WHERE id = $1 FOR UPDATE`, params.PollID))
+		if err != nil {
+			return err
+		}
+		if poll.Status != model.PollOpen {
+			return ErrPollNotOpen
+		}
+
+		var role model.UserRole
+		err = tx.QueryRow(ctx, `
+            SELECT ur.role
+            FROM user_residences ur
+            JOIN residences r ON r.id = ur.residence_id
+            JOIN blocks bl ON bl.id = r.block_id
+            WHERE ur.user_id = $1 AND ur.residence_id = $2 AND bl.society_id = $3
+        `, params.VoterID, params.ResidenceID, poll.SocietyID).Scan(&role)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotEligible
+		}
+		if err != nil {
+			return fmt.Errorf("checking eligibility: %w", err)
+		}
+		if !poll.Eligibility.Allows(role) {
+			return ErrNotEligible
+		}
+
+		var valid bool
+		err = tx.QueryRow(ctx, `
+            SELECT EXISTS (SELECT 1 FROM poll_options WHERE id = $1 AND poll_id = $2)
+        `, params.OptionID, params.PollID).Scan(&valid)
+		if err != nil {
+			return fmt.Errorf("checking poll option: %w", err)
+		}
+		if !valid {
+			return ErrInvalidOption
+		}
+
+		castAt := time.Now().UTC().Truncate(time.Microsecond)
+		voterID, err := uuid.FromString(params.VoterID)
+		if err != nil {
+			return fmt.Errorf("parsing voter id: %w", err)
+		}
+
+		b = &model.PollBallot{
+			PollID:   params.PollID,
+			Seq:      poll.BallotCount + 1,
+			OptionID: params.OptionID,
+			PrevHash: poll.HeadHash,
+		}
+
+		var participantID *string
+		var participatedAt *time.Time
+		if !poll.IsAnonymous {
+			b.ResidenceID = &params.ResidenceID
+			b.VoterID = &voterID
+			b.CastAt = &castAt
+			participantID = &params.VoterID
+			participatedAt = &castAt
+		}
+		b.Hash = ballot.Hash(b.PrevHash, *b)
+
+		_, err = tx.Exec(ctx, `
+            INSERT INTO poll_participation (poll_id, residence_id, voter_id, voted_at)
+            VALUES ($1, $2, $3, $4)
+        `, params.PollID, params.ResidenceID, participantID, participatedAt)
+		if err != nil {
+			if isPgError(err, pgUniqueViolation) {
+				return ErrAlreadyVoted
+			}
+			return fmt.Errorf("recording participation: %w", err)
+		}
+
+		_, err = tx.Exec(ctx, `
+            INSERT INTO poll_ballots (
+                poll_id, seq, option_id, residence_id, voter_id, cast_at, prev_hash, hash
+            )
+            VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+        `, b.PollID, b.Seq, b.OptionID, b.ResidenceID, b.VoterID, b.CastAt, []byte(b.PrevHash), []byte(b.Hash))
+		if err != nil {
+			return fmt.Errorf("recording ballot: %w", err)
+		}
+
+		_, err = tx.Exec(ctx, `
+            UPDATE polls SET ballot_count = $2, head_hash = $3 WHERE id = $1
+        `, params.PollID, b.Seq, []byte(b.Hash))
+		if err != nil {
+			return fmt.Errorf("advancing ballot chain: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// HasVoted reports whether a residence has voted in a poll.
+func (db *DB) HasVoted(ctx context.Context, pollID, residenceID int64) (bool, error) {
+	var voted bool
+	err := db.pool.QueryRow(ctx, `
+        SELECT EXISTS (
+            SELECT 1 FROM poll_participation WHERE poll_id = $1 AND residence_id = $2
+        )
+    `, pollID, residenceID).Scan(&voted)
+	if err != nil {
+		return false, fmt.Errorf("checking participation: %w", err)
+	}
+
+	return voted, nil
+}
+
+// GetPollResults tallies the ballots per option and counts the residences
+// that could have voted.
+func (db *DB) GetPollResults(ctx context.Context, poll *model.Poll) (*model.PollResults, error) {
+	results := &model.PollResults{
+		PollID:      poll.ID,
+		Status:      poll.Status,
+		BallotCount: poll.BallotCount,
+		HeadHash:    poll.HeadHash,
+		Options:     []model.PollOptionResult{},
+	}
+
+	rows, err := db.pool.Query(ctx, `
+        SELECT o.id, o.label, COUNT(b.seq)
+        FROM poll_options o
+        LEFT JOIN poll_ballots b ON b.option_id = o.id
+        WHERE o.poll_id = $1
+        GROUP BY o.id, o.label, o.position
+        ORDER BY o.position
+    `, poll.ID)
+	if err != nil {
+		return nil, fmt.Errorf("tallying poll: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r model.PollOptionResult
+		if err := rows.Scan(&r.OptionID, &r.Label, &r.Votes); err != nil {
+			return nil, fmt.Errorf("scanning poll tally: %w", err)
+		}
+		results.Options = append(results.Options, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating poll tally: %w", err)
+	}
+
+	roles := []string{string(model.RoleOwner)}
+	if poll.Eligibility == model.PollAllResidents {
+		roles = append(roles, string(model.RoleResident))
+	}
+
+	err = db.pool.QueryRow(ctx, `
+        SELECT COUNT(DISTINCT ur.residence_id)
+        FROM user_residences ur
+        JOIN users u ON u.id = ur.user_id
+        JOIN residences r ON r.id = ur.residence_id
+        JOIN blocks b ON b.id = r.block_id
+        WHERE b.society_id = $1 AND u.is_active AND ur.role::text = ANY($2)
+    `, poll.SocietyID, roles).Scan(&results.EligibleResidences)
+	if err != nil {
+		return nil, fmt.Errorf("counting eligible residences: %w", err)
+	}
+
+	return results, nil
+}
+
+// GetPollBallots returns a poll's ballot chain in order.
+func (db *DB) GetPollBallots(ctx context.Context, pollID int64) ([]model.PollBallot, error) {
+	rows, err := db.pool.Query(ctx, `
+        SELECT poll_id, seq, option_id, residence_id, voter_id, cast_at, prev_hash, hash
+        FROM poll_ballots
+        WHERE poll_id = $1
+        ORDER BY seq
+    `, pollID)
+	if err != nil {
+		return nil, fmt.Errorf("querying ballots: %w", err)
+	}
+	defer rows.Close()
+
+	var ballots []model.PollBallot
+	for rows.Next() {
+		var b model.PollBallot
+		var prev, hash []byte
+		if err := rows.Scan(
+			&b.PollID, &b.Seq, &b.OptionID, &b.ResidenceID, &b.VoterID, &b.CastAt,
+			&prev, &hash,
+		); err != nil {
+			return nil, fmt.Errorf("scanning ballot: %w", err)
+		}
+		b.PrevHash, b.Hash = prev, hash
+		ballots = append(ballots, b)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating ballots: %w", err)
+	}
+
+	return ballots, nil
+}
+
+// ClaimClosedPolls stamps polls that have closed since the last call and
+// returns them, so each poll's final head is published once. Votes are
+// refused once a poll closes, so the head returned is final.
+func (db *DB) ClaimClosedPolls(ctx context.Context) ([]model.Poll, error) {
+	rows, err := db.pool.Query(ctx, `
+        UPDATE polls
+        SET sealed_at = NOW()
+        WHERE closes_at <= NOW() AND cancelled_at IS NULL AND sealed_at IS NULL
+        RETURNING `+pollColumns)
+	if err != nil {
+		return nil, fmt.Errorf("claiming closed polls: %w", err)
+	}
+	defer rows.Close()
+
+	var polls []model.Poll
+	for rows.Next() {
+		p, err := scanPoll(rows)
+		if err != nil {
+			return nil, err
+		}
+		polls = append(polls, *p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating closed polls: %w", err)
+	}
+
+	return polls, nil
+}
+
+// GetPollVoterIDs returns the active users who may vote in a poll.
+func (db *DB) GetPollVoterIDs(ctx context.Context, poll *model.Poll) ([]string, error) {
+	roles := []string{string(model.RoleOwner)}
+	if poll.Eligibility == model.PollAllResidents {
+		roles = append(roles, string(model.RoleResident))
+	}
+
+	return db.queryUserIDs(ctx, `
+        SELECT DISTINCT u.id
+        FROM user_residences ur
+        JOIN users u ON u.id = ur.user_id
+        JOIN residences r ON r.id = ur.residence_id
+        JOIN blocks b ON b.id = r.block_id
+        WHERE b.society_id = $1 AND u.is_active AND ur.role::text = ANY($2)
+    `, poll.SocietyID, roles)
+}
+
+func (db *DB) loadPollOptions(ctx context.Context, polls []model.Poll) error {
+	if len(polls) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(polls))
+	index := make(map[int64]int, len(polls))
+	for i, p := range polls {
+		ids[i] = p.ID
+		index[p.ID] = i
+		polls[i].Options = []model.PollOption{}
+	}
+
+	rows, err := db.pool.Query(ctx, `
+        SELECT id, poll_id, label, position
+        FROM poll_options
+        WHERE poll_id = ANY($1)
+        ORDER BY poll_id, position
+    `, ids)
+	if err != nil {
+		return fmt.Errorf("querying poll options: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var o model.PollOption
+		if err := rows.Scan(&o.ID, &o.PollID, &o.Label, &o.Position); err != nil {
+			return fmt.Errorf("scanning poll option: %w", err)
+		}
+		i := index[o.PollID]
+		polls[i].Options = append(polls[i].Options, o)
+	}
+
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("iterating poll options: %w", err)
+	}
+
+	return nil
+}
+
+func scanPoll(row pgx.Row) (*model.Poll, error) {
+	var p model.Poll
+	var head []byte
+	err := row.Scan(
+		&p.ID, &p.SocietyID, &p.Title, &p.Description, &p.Eligibility,
+		&p.IsAnonymous, &p.OpensAt, &p.ClosesAt, &p.CancelledAt, &p.BallotCount,
+		&head, &p.SealedAt, &p.CreatedBy, &p.CreatedAt, &p.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scanning poll: %w", err)
+	}
+
+	p.HeadHash = head
+	p.Status = p.StatusAt(time.Now())
+
+	return &p, nil
+}
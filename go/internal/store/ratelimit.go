@@ -0,0 +1,129 @@
+package store
+
+import (
+	"context"
+	"dooreye-backend/internal/ratelimit/bucket"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// TakeRateToken takes a token from key's bucket, creating it full on first
+// use. The row lock serialises instances sharing the key.
+func (db *DB) TakeRateToken(ctx context.Context, key string, perSecond float64, burst int, now time.Time) (bool, time.Duration, error) {
+	var allowed bool
+	var retry time.Duration
+	err := db.RunInTx(ctx, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `
+            INSERT INTO rate_limit_buckets (key, tokens, updated_at)
+            VALUES ($1, $2, $3)
+            ON CONFLICT (key) DO NOTHING
+        `, key, burst, now)
+		if err != nil {
+			return err
+		}
+
+		var b bucket.Bucket
+		err = tx.QueryRow(ctx, `
+            SELECT tokens, updated_at FROM rate_limit_buckets WHERE key = $1 FOR UPDATE
+        `, key).Scan(&b.Tokens, &b.UpdatedAt)
+		if err != nil {
+			return err
+		}
+
+		allowed, retry = b.Take(perSecond, burst, now)
+
+		_, err = tx.Exec(ctx, `
+            UPDATE rate_limit_buckets SET tokens = $2, updated_at = $3 WHERE key = $1
+        `, key, b.Tokens, b.UpdatedAt)
+		return err
+	})
+	if err != nil {
+		return false, 0, fmt.Errorf("taking rate limit token: %w", err)
+	}
+
+	return allowed, retry, nil
+}
+
+// RecordAuthFailure counts a failed attempt for key and returns the number
+// of failures in a row. A failure more than window after the previous one
+// starts the count again.
+func (db *DB) RecordAuthFailure(ctx context.Context, key string, now time.Time, window time.Duration) (int, error) {
+	var failures int
+	err := db.pool.QueryRow(ctx, `
+        INSERT INTO auth_lockouts AS l (key, failures, last_failed_at)
+        VALUES ($1, 1, $2)
+        ON CONFLICT (key) DO UPDATE SET
+            failures = CASE WHEN l.last_failed_at < $2 - $3::interval THEN 1 ELSE l.failures + 1 END,
+            last_failed_at = $2
+        RETURNING failures
+    `, key, now, window).Scan(&failures)
+	if err != nil {
+		return 0, fmt.Errorf("recording auth failure: %w", err)
+	}
+
+	return failures, nil
+}
+
+// LockAuthKey refuses attempts for key until the given time.
+func (db *DB) LockAuthKey(ctx context.Context, key string, until time.Time) error {
+	_, err := db.pool.Exec(ctx, `UPDATE auth_lockouts SET locked_until = $2 WHERE key = $1`, key, until)
+	if err != nil {
+		return fmt.Errorf("locking auth key: %w", err)
+	}
+	return nil
+}
+
+// AuthLockedUntil returns when key's lockout ends, or the zero time when
+// it is not locked at now.
+func (db *DB) AuthLockedUntil(ctx context.Context, key string, now time.Time) (time.Time, error) {
+	var until time.Time
+	err := db.pool.QueryRow(ctx, `
+        SELECT locked_until FROM auth_lockouts WHERE key = $1 AND locked_until > $2
+    `, key, now).Scan(&until)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("reading auth lockout: %w", err)
+	}
+
+	return until, nil
+}
+
+// ClearAuthFailures forgets key's failures after a successful attempt.
+func (db *DB) ClearAuthFailures(ctx context.Context, key string) error {
+	_, err := db.pool.Exec(ctx, `DELETE FROM auth_lockouts WHERE key = $1`, key)
+	if err != nil {
+		return fmt.Errorf("clearing auth failures: %w", err)
+	}
+	return nil
+}
+
+// PurgeRateLimits deletes buckets idle since before and lockouts whose last
+// failure and lock both ended before it.
+func (db *DB) PurgeRateLimits(ctx context.Context, before time.Time) (int64, error) {
+	var n int64
+	err := db.RunInTx(ctx, func(tx pgx.Tx) error {
+		buckets, err := tx.Exec(ctx, `DELETE FROM rate_limit_buckets WHERE updated_at < $1`, before)
+		if err != nil {
+			return err
+		}
+		lockouts, err := tx.Exec(ctx, `
+            DELETE FROM auth_lockouts
+            WHERE last_failed_at < $1 AND (locked_until IS NULL OR locked_until < $1)
+        `, before)
+		if err != nil {
+			return err
+		}
+		n = buckets.RowsAffected() + lockouts.RowsAffected()
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("purging rate limits: %w", err)
+	}
+
+	return n, nil
+}
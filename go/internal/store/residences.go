@@ -0,0 +1,133 @@
+package store
+
+import (
+	"context"
+	"dooreye-backend/internal/model"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// GetResidenceSocietyID resolves the society a residence belongs to through
+// its block.
+func (db *DB) GetResidenceSocietyID(ctx context.Context, residenceID int64) (int64, error) {
+	var societyID int64
+	err := db.pool.QueryRow(ctx, `
+        SELECT b.society_id
+        FROM residences r
+        JOIN blocks b ON b.id = r.block_id
+        WHERE r.id = $1
+    `, residenceID).Scan(&societyID)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("getting residence society: %w", err)
+	}
+
+	return societyID, nil
+}
+
+func (db *DB) CreateGate(ctx context.Context, societyID int64, name string) (*model.Gate, error) {
+	var g model.Gate
+	err := db.pool.QueryRow(ctx, `
+        INSERT INTO gates (society_id, name)
+        VALUES ($1, $2)
+        RETURNING id, society_id, name, created_at
+    `, societyID, name).Scan(&g.ID, &g.SocietyID, &g.Name, &g.CreatedAt)
+
+	if err != nil {
+		if isPgError(err, pgUniqueViolation) {
+			return nil, ErrAlreadyExists
+		}
+		return nil, fmt.Errorf("creating gate: %w", err)
+	}
+
+	return &g, nil
+}
+
+func (db *DB) GetGate(ctx context.Context, id int64) (*model.Gate, error) {
+	var g model.Gate
+	err := db.pool.QueryRow(ctx, `
+        SELECT id, society_id, name, created_at
+        FROM gates
+        WHERE id = $1
+    `, id).Scan(&g.ID, &g.SocietyID, &g.Name, &g.CreatedAt)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting gate: %w", err)
+	}
+
+	return &g, nil
+}
+
+func (db *DB) GetGates(ctx context.Context, societyID int64) ([]model.Gate, error) {
+	rows, err := db.pool.Query(ctx, `
+        SELECT id, society_id, name, created_at
+        FROM gates
+        WHERE society_id = $1
+        ORDER BY name
+    `, societyID)
+	if err != nil {
+		return nil, fmt.Errorf("querying gates: %w", err)
+	}
+	defer rows.Close()
+
+	var gates []model.Gate
+	for rows.Next() {
+		var g model.Gate
+		if err := rows.Scan(&g.ID, &g.SocietyID, &g.Name, &g.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scanning gate row: %w", err)
+		}
+		gates = append(gates, g)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating gates: %w", err)
+	}
+
+	return gates, nil
+}
+
+type CreateSocietyParams struct {
+	City     string
+	Name     string
+	Address  *string
+	Timezone string
+}
+
+// CreateSociety adds a society, creating its city on first use.
+func (db *DB) CreateSociety(ctx context.Context, params CreateSocietyParams) (*model.Society, error) {
+	var s model.Society
+	err := db.RunInTx(ctx, func(tx pgx.Tx) error {
+		var cityID int64
+		err := tx.QueryRow(ctx, `SELECT id FROM cities WHERE name = $1 ORDER BY id LIMIT 1`, params.City).Scan(&cityID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = tx.QueryRow(ctx, `INSERT INTO cities (name) VALUES ($1) RETURNING id`, params.City).Scan(&cityID)
+		}
+		if err != nil {
+			return fmt.Errorf("resolving city: %w", err)
+		}
+
+		return tx.QueryRow(ctx, `
+            INSERT INTO societies (city_id, name, address, timezone)
+            VALUES ($1, $2, $3, COALESCE(NULLIF($4, ''), 'Asia/Kolkata'))
+            RETURNING id, city_id, name, COALESCE(address, ''), timezone, created_at
+        `, cityID, params.Name, params.Address, params.Timezone).Scan(
+			&s.ID, &s.CityID, &s.Name, &s.Address, &s.Timezone, &s.CreatedAt,
+		)
+	})
+	if err != nil {
+		if isPgError(err, pgUniqueViolation) {
+			return nil, ErrAlreadyExists
+		}
+		return nil, fmt.Errorf("creating society: %w", err)
+	}
+
+	return &s, nil
+}
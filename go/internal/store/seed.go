@@ -0,0 +1,60 @@
+package store
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// BulkTable is one table's rows for BulkLoad. Rows are read as they are
+// copied, so a generator can stream more rows than fit in memory.
+type BulkTable struct {
+	Name    string
+	Columns []string
+	Rows    pgx.CopyFromSource
+}
+
+// BulkLoad copies rows into each table in order, in one transaction, and
+// returns how many rows went into each. It is for loading generated data,
+// such as seeds, and skips the checks the per-entity methods make.
+func (db *DB) BulkLoad(ctx context.Context, tables []BulkTable) (map[string]int64, error) {
+	counts := make(map[string]int64, len(tables))
+	err := db.RunInTx(ctx, func(tx pgx.Tx) error {
+		for _, t := range tables {
+			n, err := tx.CopyFrom(ctx, pgx.Identifier{t.Name}, t.Columns, t.Rows)
+			if err != nil {
+				return fmt.Errorf("copying into %s: %w", t.Name, err)
+			}
+			counts[t.Name] += n
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+// ReserveIDs takes n consecutive values from the id sequence of table and
+// returns the first, so rows can be generated with their IDs before they
+// are copied in. Concurrent inserts into the table may break the run, so
+// it is only meant for seeding.
+func (db *DB) ReserveIDs(ctx context.Context, table string, n int64) (int64, error) {
+	if n <= 0 {
+		return 0, nil
+	}
+
+	var first int64
+	err := db.pool.QueryRow(ctx, `
+        WITH seq AS (SELECT pg_get_serial_sequence($1, 'id') AS name)
+        SELECT setval(seq.name, nextval(seq.name) + $2 - 1) - $2 + 1
+        FROM seq
+    `, table, n).Scan(&first)
+	if err != nil {
+		return 0, fmt.Errorf("reserving %s IDs: %w", table, err)
+	}
+
+	return first, nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/jackc/pgconn"
@@ -16,20 +17,50 @@ var (
 	ErrAlreadyExists = errors.New("record already exists")
 )
 
+// Postgres error codes checked with isPgError.
+const (
+	pgUniqueViolation     = "23505"
+	pgForeignKeyViolation = "23503"
+	pgCheckViolation      = "23514"
+	pgExclusionViolation  = "23P01"
+)
+
 type DB struct {
 	pool *pgxpool.Pool
 }
 
-func New(ctx context.Context, dbURL string) (*DB, error) {
+// Options sizes the connection pool. Zero fields take the defaults, except
+// MinConns, where zero keeps no idle connections open.
+type Options struct {
+	MaxConns        int32
+	MinConns        int32
+	MaxConnLifetime time.Duration
+	MaxConnIdleTime time.Duration
+}
+
+func New(ctx context.Context, dbURL string, opts Options) (*DB, error) {
+	if opts.MaxConns <= 0 {
+		opts.MaxConns = 25
+	}
+	if opts.MaxConnLifetime <= 0 {
+		opts.MaxConnLifetime = 5 * time.Minute
+	}
+	if opts.MaxConnIdleTime <= 0 {
+		opts.MaxConnIdleTime = 10 * time.Minute
+	}
+
 	config, err := pgxpool.ParseConfig(dbURL)
 	if err != nil {
 		return nil, fmt.Errorf("parsing database URL: %w", err)
 	}
 
-	config.MaxConns = 25
-	config.MinConns = 5
-	config.MaxConnLifetime = 5 * time.Minute
-	config.MaxConnIdleTime = 10 * time.Minute
+	config.MaxConns = opts.MaxConns
+	config.MinConns = opts.MinConns
+	config.MaxConnLifetime = opts.MaxConnLifetime
+	config.MaxConnIdleTime = opts.MaxConnIdleTime
+
+	config.ConnConfig.Logger = newQueryTracer()
+	config.ConnConfig.LogLevel = pgx.LogLevelInfo
 
 	pool, err := pgxpool.ConnectConfig(ctx, config)
 	if err != nil {
@@ -47,6 +78,29 @@ func (db *DB) Close() {
 	db.pool.Close()
 }
 
+// Ping checks that a connection can be acquired and used.
+func (db *DB) Ping(ctx context.Context) error {
+	return db.pool.Ping(ctx)
+}
+
+// MigrationVersion reads the schema version recorded by golang-migrate.
+// dirty is set when a migration failed part way.
+func (db *DB) MigrationVersion(ctx context.Context) (version int64, dirty bool, err error) {
+	err = db.pool.QueryRow(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, false, ErrNotFound
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("reading migration version: %w", err)
+	}
+	return version, dirty, nil
+}
+
+// PoolStats reports the connection pool's current state.
+func (db *DB) PoolStats() *pgxpool.Stat {
+	return db.pool.Stat()
+}
+
 func (db *DB) BeginTx(ctx context.Context) (pgx.Tx, error) {
 	return db.pool.Begin(ctx)
 }
@@ -81,3 +135,23 @@ func isPgError(err error, code string) bool {
 	}
 	return false
 }
+
+// pgConstraint returns the name of the constraint a Postgres error was
+// raised by, or "" when there is none.
+func pgConstraint(err error) string {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.ConstraintName
+	}
+	return ""
+}
+
+// prefixColumns qualifies a comma separated column list with a table alias,
+// so shared column lists can be reused in joins.
+func prefixColumns(alias, columns string) string {
+	parts := strings.Split(columns, ",")
+	for i, p := range parts {
+		parts[i] = alias + "." + strings.TrimSpace(p)
+	}
+	return strings.Join(parts, ", ")
+}
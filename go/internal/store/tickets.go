@@ -0,0 +1,617 @@
+package store
+
+import (
+	"context"
+	"dooreye-backend/internal/model"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+var (
+	ErrInvalidTransition = errors.New("ticket cannot move to that status")
+	ErrTicketClosed      = errors.New("ticket is closed")
+)
+
+const ticketColumns = `
+    id, society_id, residence_id, common_area, category, priority, title,
+    description, status, raised_by, assigned_to, respond_by, resolve_by,
+    first_response_at, resolved_at, closed_at, response_breached_at,
+    resolution_breached_at, held_since, rating, rating_comment, created_at,
+    updated_at
+`
+
+type CreateTicketParams struct {
+	SocietyID   int64
+	ResidenceID *int64
+	CommonArea  *string
+	Category    model.TicketCategory
+	Priority    model.TicketPriority
+	Title       string
+	Description *string
+	PhotoURLs   []string
+	RaisedBy    string
+}
+
+// CreateTicket opens a ticket with SLA deadlines taken from the society's
+// policy for its priority, or the global default.
+func (db *DB) CreateTicket(ctx context.Context, params CreateTicketParams) (*model.Ticket, error) {
+	var ticket *model.Ticket
+
+	err := db.RunInTx(ctx, func(tx pgx.Tx) error {
+		var err error
+		ticket, err = scanTicket(tx.QueryRow(ctx, `
+            INSERT INTO tickets (
+                society_id, residence_id, common_area, category, priority, title,
+                description, raised_by, respond_by, resolve_by
+            )
+            SELECT $1, $2, $3, $4, $5, $6, $7, $8,
+                   NOW() + make_interval(mins => p.response_minutes),
+                   NOW() + make_interval(mins => p.resolution_minutes)
+            FROM (
+                SELECT response_minutes, resolution_minutes
+                FROM ticket_sla_policies
+                WHERE priority = $5 AND (society_id = $1 OR society_id IS NULL)
+                ORDER BY society_id NULLS LAST
+                LIMIT 1
+            ) p
+            RETURNING `+ticketColumns,
+			params.SocietyID,
+			params.ResidenceID,
+			params.CommonArea,
+			params.Category,
+			params.Priority,
+			params.Title,
+			params.Description,
+			params.RaisedBy,
+		))
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				return fmt.Errorf("no SLA policy for priority %s", params.Priority)
+			}
+			return fmt.Errorf("creating ticket: %w", err)
+		}
+
+		if err := addTicketEvent(ctx, tx, ticket.ID, nil, model.TicketOpen, &params.RaisedBy, nil); err != nil {
+			return err
+		}
+
+		for _, url := range params.PhotoURLs {
+			photo, err := addTicketPhoto(ctx, tx, ticket.ID, url, params.RaisedBy)
+			if err != nil {
+				return err
+			}
+			ticket.Photos = append(ticket.Photos, *photo)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ticket, nil
+}
+
+// GetTicket returns a ticket with its photos.
+func (db *DB) GetTicket(ctx context.Context, id int64) (*model.Ticket, error) {
+	ticket, err := scanTicket(db.pool.QueryRow(ctx, `SELECT `+ticketColumns+` FROM tickets WHERE id = $1`, id))
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.pool.Query(ctx, `
+        SELECT id, ticket_id, url, uploaded_by, created_at
+        FROM ticket_photos
+        WHERE ticket_id = $1
+        ORDER BY created_at
+    `, id)
+	if err != nil {
+		return nil, fmt.Errorf("querying ticket photos: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		p, err := scanTicketPhoto(rows)
+		if err != nil {
+			return nil, err
+		}
+		ticket.Photos = append(ticket.Photos, *p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating ticket photos: %w", err)
+	}
+
+	return ticket, nil
+}
+
+type TicketFilter struct {
+	SocietyID    *int64
+	ResidenceID  *int64
+	RaisedBy     *string
+	AssignedTo   *string
+	Status       *model.TicketStatus
+	Category     *model.TicketCategory
+	OnlyOpen     bool
+	OnlyBreached bool
+	Limit        int
+	Offset       int
+}
+
+func (db *DB) GetTickets(ctx context.Context, filter TicketFilter) ([]model.Ticket, error) {
+	query := `SELECT ` + ticketColumns + ` FROM tickets WHERE 1=1`
+	args := []interface{}{}
+	argCount := 1
+
+	if filter.SocietyID != nil {
+		query += fmt.Sprintf(" AND society_id = $%d", argCount)
+		args = append(args, *filter.SocietyID)
+		argCount++
+	}
+
+	// Residents see their residence's tickets and the common area tickets
+	// they raised themselves.
+	if filter.ResidenceID != nil && filter.RaisedBy != nil {
+		query += fmt.Sprintf(" AND (residence_id = $%d OR raised_by = $%d)", argCount, argCount+1)
+		args = append(args, *filter.ResidenceID, *filter.RaisedBy)
+		argCount += 2
+	} else if filter.ResidenceID != nil {
+		query += fmt.Sprintf(" AND residence_id = $%d", argCount)
+		args = append(args, *filter.ResidenceID)
+		argCount++
+	} else if filter.RaisedBy != nil {
+		query += fmt.Sprintf(" AND raised_by = $%d", argCount)
+		args = append(args, *filter.RaisedBy)
+		argCount++
+	}
+
+	if filter.AssignedTo != nil {
+		query += fmt.Sprintf(" AND assigned_to = $%d", argCount)
+		args = append(args, *filter.AssignedTo)
+		argCount++
+	}
+
+	if filter.Status != nil {
+		query += fmt.Sprintf(" AND status = $%d", argCount)
+		args = append(args, *filter.Status)
+		argCount++
+	}
+
+	if filter.Category != nil {
+		query += fmt.Sprintf(" AND category = $%d", argCount)
+		args = append(args, *filter.Category)
+		argCount++
+	}
+
+	if filter.OnlyOpen {
+		query += " AND status NOT IN ('RESOLVED', 'CLOSED')"
+	}
+
+	if filter.OnlyBreached {
+		query += " AND (response_breached_at IS NOT NULL OR resolution_breached_at IS NOT NULL)"
+	}
+
+	if filter.Limit <= 0 || filter.Limit > 200 {
+		filter.Limit = 50
+	}
+	query += fmt.Sprintf(" ORDER BY created_at DESC LIMIT $%d OFFSET $%d", argCount, argCount+1)
+	args = append(args, filter.Limit, filter.Offset)
+
+	rows, err := db.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying tickets: %w", err)
+	}
+	defer rows.Close()
+
+	var tickets []model.Ticket
+	for rows.Next() {
+		t, err := scanTicket(rows)
+		if err != nil {
+			return nil, err
+		}
+		tickets = append(tickets, *t)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating tickets: %w", err)
+	}
+
+	return tickets, nil
+}
+
+type TransitionTicketParams struct {
+	ID      int64
+	To      model.TicketStatus
+	Actor   string
+	ByStaff bool
+	// Set when assigning; kept as is otherwise.
+	AssignedTo    *string
+	Note          *string
+	Rating        *int
+	RatingComment *string
+}
+
+// TransitionTicket moves a ticket through the helpdesk workflow and records
+// the change in its history. Any staff action counts as the first response.
+// Time spent ON_HOLD does not count against the SLA, so leaving a hold
+// moves the deadlines back by its length.
+func (db *DB) TransitionTicket(ctx context.Context, params TransitionTicketParams) (*model.Ticket, error) {
+	var ticket *model.Ticket
+
+	err := db.RunInTx(ctx, func(tx pgx.Tx) error {
+		var from model.TicketStatus
+		err := tx.QueryRow(ctx, `SELECT status FROM tickets WHERE id = $1 FOR UPDATE`, params.ID).Scan(&from)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("locking ticket: %w", err)
+		}
+
+		if from == model.TicketClosed {
+			return ErrTicketClosed
+		}
+		if !from.CanMoveTo(params.To) {
+			return fmt.Errorf("%w: %s to %s", ErrInvalidTransition, from, params.To)
+		}
+
+		ticket, err = scanTicket(tx.QueryRow(ctx, `
+            UPDATE tickets
+            SET status = $2,
+                respond_by = respond_by + COALESCE(NOW() - held_since, INTERVAL '0'),
+                resolve_by = resolve_by + COALESCE(NOW() - held_since, INTERVAL '0'),
+                held_since = CASE WHEN $2 = 'ON_HOLD' THEN NOW() END,
+                assigned_to = COALESCE($3, assigned_to),
+                first_response_at = CASE WHEN $4 THEN COALESCE(first_response_at, NOW()) ELSE first_response_at END,
+                resolved_at = CASE
+                    WHEN $2 = 'RESOLVED' THEN NOW()
+                    WHEN $2 = 'CLOSED' THEN resolved_at
+                    ELSE NULL
+                END,
+                closed_at = CASE WHEN $2 = 'CLOSED' THEN NOW() ELSE NULL END,
+                rating = COALESCE($5, rating),
+                rating_comment = COALESCE($6, rating_comment)
+            WHERE id = $1
+            RETURNING `+ticketColumns,
+			params.ID,
+			params.To,
+			params.AssignedTo,
+			params.ByStaff,
+			params.Rating,
+			params.RatingComment,
+		))
+		if err != nil {
+			return fmt.Errorf("updating ticket status: %w", err)
+		}
+
+		return addTicketEvent(ctx, tx, params.ID, &from, params.To, &params.Actor, params.Note)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ticket, nil
+}
+
+// CloseResolvedTickets closes tickets left in RESOLVED since before the
+// cutoff without the resident confirming or reopening them.
+func (db *DB) CloseResolvedTickets(ctx context.Context, resolvedBefore time.Time) (int64, error) {
+	result, err := db.pool.Exec(ctx, `
+        WITH closed AS (
+            UPDATE tickets
+            SET status = 'CLOSED', closed_at = NOW()
+            WHERE status = 'RESOLVED' AND resolved_at < $1
+            RETURNING id
+        )
+        INSERT INTO ticket_events (ticket_id, from_status, to_status, note)
+        SELECT id, 'RESOLVED', 'CLOSED', 'closed automatically'
+        FROM closed
+    `, resolvedBefore)
+	if err != nil {
+		return 0, fmt.Errorf("closing resolved tickets: %w", err)
+	}
+
+	return result.RowsAffected(), nil
+}
+
+func (db *DB) AddTicketComment(ctx context.Context, ticketID int64, authorID, body string, internal, byStaff bool) (*model.TicketComment, error) {
+	var comment model.TicketComment
+
+	err := db.RunInTx(ctx, func(tx pgx.Tx) error {
+		err := tx.QueryRow(ctx, `
+            WITH c AS (
+                INSERT INTO ticket_comments (ticket_id, author_id, body, is_internal)
+                VALUES ($1, $2, $3, $4)
+                RETURNING *
+            )
+            SELECT c.id, c.ticket_id, c.author_id, u.name, c.body, c.is_internal, c.created_at
+            FROM c
+            JOIN users u ON u.id = c.author_id
+        `, ticketID, authorID, body, internal).Scan(
+			&comment.ID, &comment.TicketID, &comment.AuthorID, &comment.AuthorName,
+			&comment.Body, &comment.IsInternal, &comment.CreatedAt,
+		)
+		if err != nil {
+			if isPgError(err, pgForeignKeyViolation) {
+				return ErrNotFound
+			}
+			return fmt.Errorf("adding ticket comment: %w", err)
+		}
+
+		// A reply the resident can see is a response for SLA purposes;
+		// internal notes are not.
+		if byStaff && !internal {
+			if _, err := tx.Exec(ctx, `
+                UPDATE tickets SET first_response_at = NOW()
+                WHERE id = $1 AND first_response_at IS NULL
+            `, ticketID); err != nil {
+				return fmt.Errorf("recording first response: %w", err)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &comment, nil
+}
+
+func (db *DB) GetTicketComments(ctx context.Context, ticketID int64, includeInternal bool) ([]model.TicketComment, error) {
+	query := `
+        SELECT c.id, c.ticket_id, c.author_id, u.name, c.body, c.is_internal, c.created_at
+        FROM ticket_comments c
+        JOIN users u ON u.id = c.author_id
+        WHERE c.ticket_id = $1
+    `
+	if !includeInternal {
+		query += " AND NOT c.is_internal"
+	}
+	query += " ORDER BY c.created_at"
+
+	rows, err := db.pool.Query(ctx, query, ticketID)
+	if err != nil {
+		return nil, fmt.Errorf("querying ticket comments: %w", err)
+	}
+	defer rows.Close()
+
+	var comments []model.TicketComment
+	for rows.Next() {
+		var c model.TicketComment
+		if err := rows.Scan(
+			&c.ID, &c.TicketID, &c.AuthorID, &c.AuthorName, &c.Body,
+			&c.IsInternal, &c.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("scanning ticket comment: %w", err)
+		}
+		comments = append(comments, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating ticket comments: %w", err)
+	}
+
+	return comments, nil
+}
+
+func (db *DB) GetTicketEvents(ctx context.Context, ticketID int64) ([]model.TicketEvent, error) {
+	rows, err := db.pool.Query(ctx, `
+        SELECT id, ticket_id, from_status, to_status, actor_id, note, created_at
+        FROM ticket_events
+        WHERE ticket_id = $1
+        ORDER BY created_at, id
+    `, ticketID)
+	if err != nil {
+		return nil, fmt.Errorf("querying ticket events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []model.TicketEvent
+	for rows.Next() {
+		var e model.TicketEvent
+		if err := rows.Scan(
+			&e.ID, &e.TicketID, &e.FromStatus, &e.ToStatus, &e.ActorID, &e.Note,
+			&e.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("scanning ticket event: %w", err)
+		}
+		events = append(events, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating ticket events: %w", err)
+	}
+
+	return events, nil
+}
+
+func (db *DB) AddTicketPhoto(ctx context.Context, ticketID int64, url, uploadedBy string) (*model.TicketPhoto, error) {
+	photo, err := addTicketPhoto(ctx, db.pool, ticketID, url, uploadedBy)
+	if isPgError(err, pgForeignKeyViolation) {
+		return nil, ErrNotFound
+	}
+	return photo, err
+}
+
+// GetTicketVisits lists the maintenance visits made for a ticket.
+func (db *DB) GetTicketVisits(ctx context.Context, ticketID int64) ([]model.VisitWithVisitor, error) {
+	return db.GetVisits(ctx, VisitFilter{TicketID: &ticketID})
+}
+
+// GetSLATickets lists open tickets with a deadline that has not been
+// reported yet and that had passed by now as raised. Holds only move
+// deadlines later, so the SLA rules see every ticket that may have missed
+// one.
+func (db *DB) GetSLATickets(ctx context.Context, now time.Time) ([]model.Ticket, error) {
+	rows, err := db.pool.Query(ctx, `
+        SELECT `+ticketColumns+`
+        FROM tickets
+        WHERE status NOT IN ('RESOLVED', 'CLOSED')
+          AND ((first_response_at IS NULL AND response_breached_at IS NULL AND respond_by < $1)
+               OR (resolution_breached_at IS NULL AND resolve_by < $1))
+    `, now)
+	if err != nil {
+		return nil, fmt.Errorf("querying SLA tickets: %w", err)
+	}
+	defer rows.Close()
+
+	var tickets []model.Ticket
+	for rows.Next() {
+		t, err := scanTicket(rows)
+		if err != nil {
+			return nil, err
+		}
+		tickets = append(tickets, *t)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating SLA tickets: %w", err)
+	}
+
+	return tickets, nil
+}
+
+// ClaimTicketBreach stamps a missed deadline on an open ticket and reports
+// whether this call did, so each breach is reported once.
+func (db *DB) ClaimTicketBreach(ctx context.Context, ticketID int64, kind string) (bool, error) {
+	query := `
+        UPDATE tickets
+        SET response_breached_at = NOW()
+        WHERE id = $1 AND response_breached_at IS NULL AND first_response_at IS NULL
+          AND status NOT IN ('RESOLVED', 'CLOSED')
+    `
+	if kind == model.BreachResolution {
+		query = `
+            UPDATE tickets
+            SET resolution_breached_at = NOW()
+            WHERE id = $1 AND resolution_breached_at IS NULL
+              AND status NOT IN ('RESOLVED', 'CLOSED')
+        `
+	}
+
+	result, err := db.pool.Exec(ctx, query, ticketID)
+	if err != nil {
+		return false, fmt.Errorf("claiming ticket breach: %w", err)
+	}
+
+	return result.RowsAffected() > 0, nil
+}
+
+// GetTicketSLAPolicies returns the effective policy for every priority in a
+// society, falling back to the global default where no override exists.
+func (db *DB) GetTicketSLAPolicies(ctx context.Context, societyID int64) ([]model.TicketSLAPolicy, error) {
+	rows, err := db.pool.Query(ctx, `
+        SELECT DISTINCT ON (priority)
+               id, society_id, priority, response_minutes, resolution_minutes, updated_at
+        FROM ticket_sla_policies
+        WHERE society_id = $1 OR society_id IS NULL
+        ORDER BY priority, society_id NULLS LAST
+    `, societyID)
+	if err != nil {
+		return nil, fmt.Errorf("querying ticket SLA policies: %w", err)
+	}
+	defer rows.Close()
+
+	var policies []model.TicketSLAPolicy
+	for rows.Next() {
+		var p model.TicketSLAPolicy
+		if err := rows.Scan(
+			&p.ID, &p.SocietyID, &p.Priority, &p.ResponseMinutes,
+			&p.ResolutionMinutes, &p.UpdatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("scanning ticket SLA policy: %w", err)
+		}
+		policies = append(policies, p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating ticket SLA policies: %w", err)
+	}
+
+	return policies, nil
+}
+
+func (db *DB) UpsertTicketSLAPolicy(ctx context.Context, societyID int64, priority model.TicketPriority, responseMinutes, resolutionMinutes int) (*model.TicketSLAPolicy, error) {
+	var p model.TicketSLAPolicy
+	err := db.pool.QueryRow(ctx, `
+        INSERT INTO ticket_sla_policies (society_id, priority, response_minutes, resolution_minutes)
+        VALUES ($1, $2, $3, $4)
+        ON CONFLICT (COALESCE(society_id, 0), priority)
+        DO UPDATE SET response_minutes = EXCLUDED.response_minutes,
+                      resolution_minutes = EXCLUDED.resolution_minutes
+        RETURNING id, society_id, priority, response_minutes, resolution_minutes, updated_at
+    `, societyID, priority, responseMinutes, resolutionMinutes).Scan(
+		&p.ID, &p.SocietyID, &p.Priority, &p.ResponseMinutes,
+		&p.ResolutionMinutes, &p.UpdatedAt,
+	)
+	if err != nil {
+		if isPgError(err, pgCheckViolation) {
+			return nil, errors.New("resolution_minutes must not be less than response_minutes")
+		}
+		return nil, fmt.Errorf("upserting ticket SLA policy: %w", err)
+	}
+
+	return &p, nil
+}
+
+func addTicketEvent(ctx context.Context, q querier, ticketID int64, from *model.TicketStatus, to model.TicketStatus, actor *string, note *string) error {
+	_, err := q.Exec(ctx, `
+        INSERT INTO ticket_events (ticket_id, from_status, to_status, actor_id, note)
+        VALUES ($1, $2, $3, $4, $5)
+    `, ticketID, from, to, actor, note)
+	if err != nil {
+		return fmt.Errorf("recording ticket event: %w", err)
+	}
+
+	return nil
+}
+
+func addTicketPhoto(ctx context.Context, q querier, ticketID int64, url, uploadedBy string) (*model.TicketPhoto, error) {
+	photo, err := scanTicketPhoto(q.QueryRow(ctx, `
+        INSERT INTO ticket_photos (ticket_id, url, uploaded_by)
+        VALUES ($1, $2, $3)
+        RETURNING id, ticket_id, url, uploaded_by, created_at
+    `, ticketID, url, uploadedBy))
+	if err != nil && !isPgError(err, pgForeignKeyViolation) {
+		return nil, fmt.Errorf("adding ticket photo: %w", err)
+	}
+
+	return photo, err
+}
+
+func scanTicket(row pgx.Row) (*model.Ticket, error) {
+	var t model.Ticket
+	err := row.Scan(
+		&t.ID, &t.SocietyID, &t.ResidenceID, &t.CommonArea, &t.Category,
+		&t.Priority, &t.Title, &t.Description, &t.Status, &t.RaisedBy,
+		&t.AssignedTo, &t.RespondBy, &t.ResolveBy, &t.FirstResponseAt,
+		&t.ResolvedAt, &t.ClosedAt, &t.ResponseBreachedAt,
+		&t.ResolutionBreachedAt, &t.HeldSince, &t.Rating, &t.RatingComment, &t.CreatedAt,
+		&t.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scanning ticket: %w", err)
+	}
+
+	return &t, nil
+}
+
+func scanTicketPhoto(row pgx.Row) (*model.TicketPhoto, error) {
+	var p model.TicketPhoto
+	err := row.Scan(&p.ID, &p.TicketID, &p.URL, &p.UploadedBy, &p.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scanning ticket photo: %w", err)
+	}
+
+	return &p, nil
+}
@@ -0,0 +1,77 @@
+package store
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// queryTracer turns pgx's per-statement log events into spans. pgx v4 has
+// no tracing hooks, but it logs every statement with the caller's context
+// and its duration once it completes, which is enough to place a span.
+// Query arguments are left out of spans.
+type queryTracer struct {
+	tracer trace.Tracer
+}
+
+func newQueryTracer() queryTracer {
+	return queryTracer{tracer: otel.Tracer("dooreye-backend/internal/store")}
+}
+
+func (t queryTracer) Log(ctx context.Context, level pgx.LogLevel, msg string, data map[string]interface{}) {
+	switch msg {
+	case "Query", "Exec", "SendBatch", "CopyFrom":
+	default:
+		return
+	}
+	if !trace.SpanFromContext(ctx).IsRecording() {
+		return
+	}
+
+	end := time.Now()
+	elapsed, _ := data["time"].(time.Duration)
+
+	sql, _ := data["sql"].(string)
+	operation := sqlOperation(sql)
+	if operation == "" {
+		operation = msg
+	}
+
+	attrs := []attribute.KeyValue{
+		semconv.DBSystemPostgreSQL,
+		semconv.DBOperationName(operation),
+	}
+	if sql != "" {
+		attrs = append(attrs, semconv.DBQueryText(sql))
+	}
+	if rows, ok := data["rowCount"].(int); ok {
+		attrs = append(attrs, attribute.Int("db.response.rows", rows))
+	}
+
+	_, span := t.tracer.Start(ctx, operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithTimestamp(end.Add(-elapsed)),
+		trace.WithAttributes(attrs...),
+	)
+	if err, ok := data["err"].(error); ok {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End(trace.WithTimestamp(end))
+}
+
+// sqlOperation returns the leading keyword of a statement, e.g. SELECT.
+func sqlOperation(sql string) string {
+	fields := strings.Fields(sql)
+	if len(fields) == 0 {
+		return ""
+	}
+	return strings.ToUpper(fields[0])
+}
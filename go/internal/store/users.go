@@ -7,7 +7,7 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/jackc/pgx"
+	"github.com/jackc/pgx/v4"
 )
 
 var (
@@ -16,23 +16,58 @@ var (
 )
 
 type AuthUser struct {
-	ID        string `json:"id"`
-	Role      string `json:"role"`
-	SocietyID *int64 `json:"society_id,omitempty"`
-	IsActive  bool   `json:"is_active"`
+	ID          string `json:"id"`
+	Role        string `json:"role"`
+	SocietyID   *int64 `json:"society_id,omitempty"`
+	ResidenceID *int64 `json:"residence_id,omitempty"`
+	IsActive    bool   `json:"is_active"`
+
+	// DeviceRowID and DeviceLastSeenAt identify the device the request
+	// authenticated with.
+	DeviceRowID      int64      `json:"-"`
+	DeviceLastSeenAt *time.Time `json:"-"`
 }
 
 type User struct {
-	ID          string     `json:"id"`
-	AccessCode  string     `json:"access_code"`
-	DeviceID    string     `json:"device_id"`
-	Name        string     `json:"name"`
-	ResidenceID *int64     `json:"residence_id,omitempty"`
-	SocietyID   *int64     `json:"society_id,omitempty"`
-	Role        string     `json:"role"`
-	IsActive    bool       `json:"is_active"`
-	ActivatedBy string     `json:"activated_by"`
-	ActivatedAt *time.Time `json:"activated_at"`
+	ID            string     `json:"id"`
+	AccessCode    *string    `json:"access_code,omitempty"`
+	Name          string     `json:"name"`
+	ResidenceID   *int64     `json:"residence_id,omitempty"`
+	SocietyID     *int64     `json:"society_id,omitempty"`
+	Role          string     `json:"role"`
+	IsActive      bool       `json:"is_active"`
+	InvitedBy     *string    `json:"invited_by,omitempty"`
+	ActivatedBy   *string    `json:"activated_by"`
+	ActivatedAt   *time.Time `json:"activated_at"`
+	DeactivatedAt *time.Time `json:"deactivated_at,omitempty"`
+}
+
+const userColumns = `
+    id, access_code, name, residence_id, society_id, role,
+    is_active, invited_by, activated_by, activated_at, deactivated_at
+`
+
+// userFields returns scan destinations for userColumns, so queries that
+// select more than a user can scan it alongside.
+func userFields(u *User) []any {
+	return []any{
+		&u.ID, &u.AccessCode, &u.Name, &u.ResidenceID,
+		&u.SocietyID, &u.Role, &u.IsActive, &u.InvitedBy,
+		&u.ActivatedBy, &u.ActivatedAt, &u.DeactivatedAt,
+	}
+}
+
+func scanUser(row pgx.Row) (*User, error) {
+	var u User
+	err := row.Scan(userFields(&u)...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scanning user: %w", err)
+	}
+
+	return &u, nil
 }
 
 type CreateUserParams struct {
@@ -67,52 +102,65 @@ func (db *DB) CreateUser(ctx context.Context, params CreateUserParams) (*User, e
 		return nil, ErrDuplicateAccessCode
 	}
 
-	tx, err := db.BeginTx(ctx)
-	var user User
-	tx.QueryRow(ctx, `
+	var user *User
+	err = db.RunInTx(ctx, func(tx pgx.Tx) error {
+		var err error
+		user, err = scanUser(tx.QueryRow(ctx, `
             INSERT INTO users (
-                access_code, device_id, name, residence_id, society_id,
+                access_code, name, residence_id, society_id,
                 role, is_active, activated_by, activated_at
             )
-            VALUES ($1, $2, $3, $4, $5, $6, true, $7, NOW())
-            RETURNING id, access_code, device_id, name, residence_id,
-                      society_id, role, is_active, activated_by, activated_at
-        `,
-		params.AccessCode,
-		params.DeviceID,
-		params.Name,
-		params.ResidenceID,
-		params.SocietyID,
-		params.Role,
-		params.ActivatedBy,
-	).Scan(
-		&user.ID,
-		&user.AccessCode,
-		&user.DeviceID,
-		&user.Name,
-		&user.ResidenceID,
-		&user.SocietyID,
-		&user.Role,
-		&user.IsActive,
-		&user.ActivatedBy,
-		&user.ActivatedAt,
-	)
+            VALUES ($1, $2, $3, $4, $5, true, $6, NOW())
+            RETURNING `+userColumns,
+			params.AccessCode,
+			params.Name,
+			params.ResidenceID,
+			params.SocietyID,
+			params.Role,
+			params.ActivatedBy,
+		))
+		if err != nil {
+			return err
+		}
+
+		if err := addDevice(ctx, tx, user.ID, params.DeviceID, nil); err != nil {
+			return err
+		}
+
+		if params.ResidenceID != nil && isResidenceRole(params.Role) {
+			return addMembership(ctx, tx, user.ID, *params.ResidenceID, params.Role)
+		}
+		return nil
+	})
 
 	if err != nil {
-		if isPgError(err, "unique_violation") {
+		if errors.Is(err, ErrDeviceTaken) {
+			return nil, err
+		}
+		if isPgError(err, pgUniqueViolation) {
 			return nil, ErrDuplicateAccessCode
 		}
 		return nil, fmt.Errorf("creating user: %w", err)
 	}
 
-	return &user, nil
+	return user, nil
 }
 
+// GetUserByDeviceID authenticates a request by its device identifier. Only
+// devices that have not been revoked match. Residents whose every lease has
+// ended count as inactive.
 func (db *DB) GetUserByDeviceID(ctx context.Context, deviceID string) (*AuthUser, error) {
 	query := `
-        SELECT id, role, society_id, is_active
-        FROM users
-        WHERE device_id = $1
+        SELECT u.id, u.role, u.society_id, u.residence_id,
+               u.is_active AND (u.role NOT IN ('OWNER', 'RESIDENT') OR EXISTS (
+                   SELECT 1 FROM user_residences ur
+                   WHERE ur.user_id = u.id
+                     AND (ur.lease_end IS NULL OR ur.lease_end >= CURRENT_DATE)
+               )),
+               d.id, d.last_seen_at
+        FROM user_devices d
+        JOIN users u ON u.id = d.user_id
+        WHERE d.device_id = $1 AND d.revoked_at IS NULL
     `
 
 	var user AuthUser
@@ -120,10 +168,13 @@ func (db *DB) GetUserByDeviceID(ctx context.Context, deviceID string) (*AuthUser
 		&user.ID,
 		&user.Role,
 		&user.SocietyID,
+		&user.ResidenceID,
 		&user.IsActive,
+		&user.DeviceRowID,
+		&user.DeviceLastSeenAt,
 	)
 
-	if err == pgx.ErrNoRows {
+	if errors.Is(err, pgx.ErrNoRows) {
 		return nil, ErrNotFound
 	}
 	if err != nil {
@@ -132,3 +183,240 @@ func (db *DB) GetUserByDeviceID(ctx context.Context, deviceID string) (*AuthUser
 
 	return &user, nil
 }
+
+// GetUserMemberships lists the residences a user belongs to, with the role
+// and permissions they hold in each. Tenancies whose lease has ended are
+// left out even before the expiry job removes them.
+func (db *DB) GetUserMemberships(ctx context.Context, userID string) ([]model.Membership, error) {
+	rows, err := db.pool.Query(ctx, `
+        SELECT ur.residence_id, b.society_id, ur.role,
+               ur.can_approve_visitors, ur.can_create_passes, ur.is_tenant, ur.lease_end
+        FROM user_residences ur
+        JOIN residences r ON r.id = ur.residence_id
+        JOIN blocks b ON b.id = r.block_id
+        WHERE ur.user_id = $1
+          AND (ur.lease_end IS NULL OR ur.lease_end >= CURRENT_DATE)
+        ORDER BY ur.created_at
+    `, userID)
+	if err != nil {
+		return nil, fmt.Errorf("querying memberships: %w", err)
+	}
+	defer rows.Close()
+
+	var memberships []model.Membership
+	for rows.Next() {
+		var m model.Membership
+		err := rows.Scan(
+			&m.ResidenceID, &m.SocietyID, &m.Role,
+			&m.CanApproveVisitors, &m.CanCreatePasses, &m.IsTenant, &m.LeaseEnd,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("scanning membership row: %w", err)
+		}
+		memberships = append(memberships, m)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating memberships: %w", err)
+	}
+
+	return memberships, nil
+}
+
+func addMembership(ctx context.Context, tx pgx.Tx, userID string, residenceID int64, role model.UserRole) error {
+	_, err := tx.Exec(ctx, `
+        INSERT INTO user_residences (user_id, residence_id, role)
+        VALUES ($1, $2, $3)
+        ON CONFLICT (user_id, residence_id) DO UPDATE SET role = EXCLUDED.role
+    `, userID, residenceID, role)
+	if err != nil {
+		return fmt.Errorf("adding membership: %w", err)
+	}
+
+	return nil
+}
+
+func isResidenceRole(role model.UserRole) bool {
+	return role == model.RoleOwner || role == model.RoleResident
+}
+
+// GetResidenceUserIDs returns the active users living in a residence.
+func (db *DB) GetResidenceUserIDs(ctx context.Context, residenceID int64) ([]string, error) {
+	return db.queryUserIDs(ctx, `
+        SELECT u.id
+        FROM user_residences ur
+        JOIN users u ON u.id = ur.user_id
+        WHERE ur.residence_id = $1 AND u.is_active = true
+    `, residenceID)
+}
+
+// GetSocietyUserIDsByRole returns the active society level users holding one
+// of the given roles, e.g. the guards or managers of a society.
+func (db *DB) GetSocietyUserIDsByRole(ctx context.Context, societyID int64, roles ...model.UserRole) ([]string, error) {
+	names := make([]string, len(roles))
+	for i, r := range roles {
+		names[i] = string(r)
+	}
+
+	return db.queryUserIDs(ctx, `
+        SELECT id FROM users
+        WHERE society_id = $1 AND role::text = ANY($2) AND is_active = true
+    `, societyID, names)
+}
+
+func (db *DB) queryUserIDs(ctx context.Context, query string, args ...interface{}) ([]string, error) {
+	rows, err := db.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying user IDs: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scanning user ID: %w", err)
+		}
+		ids = append(ids, id)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating user IDs: %w", err)
+	}
+
+	return ids, nil
+}
+
+// GetUserIDsByRole returns every active user with the given role, regardless
+// of society. It is meant for platform wide roles such as ADMIN.
+func (db *DB) GetUserIDsByRole(ctx context.Context, role model.UserRole) ([]string, error) {
+	return db.queryUserIDs(ctx, `
+        SELECT id FROM users
+        WHERE role = $1 AND is_active = true
+    `, role)
+}
+
+type CreatePendingUserParams struct {
+	AccessCode  string
+	Name        string
+	Role        model.UserRole
+	SocietyID   *int64
+	ResidenceID *int64
+}
+
+// CreatePendingUser creates an inactive user who becomes active by
+// redeeming the access code on their device, like an invited household
+// member. Only ADMIN users may belong to no society or residence.
+func (db *DB) CreatePendingUser(ctx context.Context, params CreatePendingUserParams) (*User, error) {
+	if params.Role != model.RoleAdmin && params.ResidenceID == nil && params.SocietyID == nil {
+		return nil, ErrInvalidUserType
+	}
+
+	var user *User
+	err := db.RunInTx(ctx, func(tx pgx.Tx) error {
+		var err error
+		user, err = scanUser(tx.QueryRow(ctx, `
+            INSERT INTO users (access_code, name, residence_id, society_id, role, is_active)
+            VALUES ($1, $2, $3, $4, $5, false)
+            RETURNING `+userColumns,
+			params.AccessCode,
+			params.Name,
+			params.ResidenceID,
+			params.SocietyID,
+			params.Role,
+		))
+		if err != nil {
+			return err
+		}
+
+		if params.ResidenceID != nil && isResidenceRole(params.Role) {
+			return addMembership(ctx, tx, user.ID, *params.ResidenceID, params.Role)
+		}
+		return nil
+	})
+	if err != nil {
+		if isPgError(err, pgUniqueViolation) {
+			return nil, ErrDuplicateAccessCode
+		}
+		return nil, fmt.Errorf("creating pending user: %w", err)
+	}
+
+	return user, nil
+}
+
+// AccessCodeInUse reports whether any user, pending or not, holds code.
+func (db *DB) AccessCodeInUse(ctx context.Context, code string) (bool, error) {
+	var exists bool
+	err := db.pool.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM users WHERE access_code = $1)`, code).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("checking access code: %w", err)
+	}
+
+	return exists, nil
+}
+
+type ListUsersParams struct {
+	// SocietyID matches the society's staff and the members of its
+	// residences.
+	SocietyID  *int64
+	Role       *model.UserRole
+	ActiveOnly bool
+}
+
+// ListUsers lists users across societies, oldest first.
+func (db *DB) ListUsers(ctx context.Context, params ListUsersParams) ([]User, error) {
+	rows, err := db.pool.Query(ctx, `
+        SELECT `+userColumns+`
+        FROM users u
+        WHERE ($1::bigint IS NULL OR u.society_id = $1 OR EXISTS (
+                  SELECT 1
+                  FROM user_residences ur
+                  JOIN residences r ON r.id = ur.residence_id
+                  JOIN blocks b ON b.id = r.block_id
+                  WHERE ur.user_id = u.id AND b.society_id = $1
+              ))
+          AND ($2::user_role IS NULL OR u.role = $2)
+          AND (NOT $3 OR u.is_active)
+        ORDER BY u.created_at
+    `, params.SocietyID, params.Role, params.ActiveOnly)
+	if err != nil {
+		return nil, fmt.Errorf("querying users: %w", err)
+	}
+	defer rows.Close()
+
+	var users []User
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, *u)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating users: %w", err)
+	}
+
+	return users, nil
+}
+
+// DeactivateUser switches a user off and signs out all of their devices.
+// Memberships are kept, so the user can be reactivated where they were.
+func (db *DB) DeactivateUser(ctx context.Context, userID string) error {
+	return db.RunInTx(ctx, func(tx pgx.Tx) error {
+		result, err := tx.Exec(ctx, `
+            UPDATE users
+            SET is_active = false, deactivated_at = NOW()
+            WHERE id = $1 AND deactivated_at IS NULL
+        `, userID)
+		if err != nil {
+			return fmt.Errorf("deactivating user: %w", err)
+		}
+
+		if result.RowsAffected() == 0 {
+			return ErrNotFound
+		}
+
+		return revokeAllDevices(ctx, tx, userID)
+	})
+}
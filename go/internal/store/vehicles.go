@@ -0,0 +1,349 @@
+package store
+
+import (
+	"context"
+	"dooreye-backend/internal/model"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+var (
+	ErrPlateRegistered  = errors.New("vehicle with this plate is already registered")
+	ErrVehicleInside    = errors.New("vehicle is already inside")
+	ErrVehicleNotInside = errors.New("vehicle is not inside")
+)
+
+// querier is satisfied by both the pool and a transaction, for store
+// functions that handlers may call inside their own transaction.
+type querier interface {
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+	Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error)
+}
+
+const vehicleColumns = `
+    id, residence_id, plate, type, make_model, color, parking_slot_id,
+    is_active, created_by, created_at, updated_at
+`
+
+type CreateVehicleParams struct {
+	ResidenceID   int64
+	Plate         string
+	Type          model.VehicleType
+	MakeModel     *string
+	Color         *string
+	ParkingSlotID *int64
+	CreatedBy     string
+}
+
+func (db *DB) CreateVehicle(ctx context.Context, params CreateVehicleParams) (*model.Vehicle, error) {
+	vehicle, err := scanVehicle(db.pool.QueryRow(ctx, `
+        INSERT INTO vehicles (residence_id, plate, type, make_model, color, parking_slot_id, created_by)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+        RETURNING `+vehicleColumns,
+		params.ResidenceID,
+		params.Plate,
+		params.Type,
+		params.MakeModel,
+		params.Color,
+		params.ParkingSlotID,
+		params.CreatedBy,
+	))
+	if err != nil {
+		if isPgError(err, pgUniqueViolation) {
+			return nil, ErrPlateRegistered
+		}
+		return nil, fmt.Errorf("creating vehicle: %w", err)
+	}
+
+	return vehicle, nil
+}
+
+func (db *DB) GetVehicle(ctx context.Context, id int64) (*model.Vehicle, error) {
+	return scanVehicle(db.pool.QueryRow(ctx, `SELECT `+vehicleColumns+` FROM vehicles WHERE id = $1`, id))
+}
+
+type VehicleFilter struct {
+	ResidenceID *int64
+	SocietyID   *int64
+}
+
+func (db *DB) GetVehicles(ctx context.Context, filter VehicleFilter) ([]model.Vehicle, error) {
+	query := `
+        SELECT ` + prefixColumns("v", vehicleColumns) + `
+        FROM vehicles v
+        JOIN residences r ON r.id = v.residence_id
+        JOIN blocks b ON b.id = r.block_id
+        WHERE v.is_active
+    `
+	args := []interface{}{}
+	argCount := 1
+
+	if filter.ResidenceID != nil {
+		query += fmt.Sprintf(" AND v.residence_id = $%d", argCount)
+		args = append(args, *filter.ResidenceID)
+		argCount++
+	}
+
+	if filter.SocietyID != nil {
+		query += fmt.Sprintf(" AND b.society_id = $%d", argCount)
+		args = append(args, *filter.SocietyID)
+		argCount++
+	}
+
+	query += " ORDER BY v.residence_id, v.plate"
+
+	rows, err := db.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying vehicles: %w", err)
+	}
+	defer rows.Close()
+
+	var vehicles []model.Vehicle
+	for rows.Next() {
+		v, err := scanVehicle(rows)
+		if err != nil {
+			return nil, err
+		}
+		vehicles = append(vehicles, *v)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating vehicles: %w", err)
+	}
+
+	return vehicles, nil
+}
+
+func (db *DB) DeactivateVehicle(ctx context.Context, id int64) error {
+	result, err := db.pool.Exec(ctx, `
+        UPDATE vehicles SET is_active = false
+        WHERE id = $1 AND is_active
+    `, id)
+	if err != nil {
+		return fmt.Errorf("deactivating vehicle: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// GetVehicleByPlate finds the registered resident vehicle with a normalized
+// plate in a society.
+func (db *DB) GetVehicleByPlate(ctx context.Context, societyID int64, plate string) (*model.Vehicle, error) {
+	return scanVehicle(db.pool.QueryRow(ctx, `
+        SELECT `+prefixColumns("v", vehicleColumns)+`
+        FROM vehicles v
+        JOIN residences r ON r.id = v.residence_id
+        JOIN blocks b ON b.id = r.block_id
+        WHERE v.plate = $1 AND b.society_id = $2 AND v.is_active
+    `, plate, societyID))
+}
+
+const vehicleEntryColumns = `
+    id, society_id, plate, type, category, vehicle_id, visit_id, gate_id,
+    entered_at, entry_logged_by, exited_at, exit_gate_id, exit_logged_by
+`
+
+type LogVehicleEntryParams struct {
+	SocietyID int64
+	Plate     string
+	Type      model.VehicleType
+	Category  model.VehicleCategory
+	VehicleID *int64
+	VisitID   *string
+	GateID    *int64
+	LoggedBy  string
+}
+
+func (db *DB) LogVehicleEntry(ctx context.Context, params LogVehicleEntryParams) (*model.VehicleEntry, error) {
+	return logVehicleEntry(ctx, db.pool, params)
+}
+
+// LogVehicleEntryTx logs an entry as part of a caller's transaction, e.g.
+// a guest's car at visit check-in.
+func (db *DB) LogVehicleEntryTx(ctx context.Context, tx pgx.Tx, params LogVehicleEntryParams) (*model.VehicleEntry, error) {
+	return logVehicleEntry(ctx, tx, params)
+}
+
+func logVehicleEntry(ctx context.Context, q querier, params LogVehicleEntryParams) (*model.VehicleEntry, error) {
+	entry, err := scanVehicleEntry(q.QueryRow(ctx, `
+        INSERT INTO vehicle_entries (
+            society_id, plate, type, category, vehicle_id, visit_id, gate_id,
+            entry_logged_by
+        )
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+        RETURNING `+vehicleEntryColumns,
+		params.SocietyID,
+		params.Plate,
+		params.Type,
+		params.Category,
+		params.VehicleID,
+		params.VisitID,
+		params.GateID,
+		params.LoggedBy,
+	))
+	if err != nil {
+		if isPgError(err, pgUniqueViolation) {
+			return nil, ErrVehicleInside
+		}
+		return nil, fmt.Errorf("logging vehicle entry: %w", err)
+	}
+
+	return entry, nil
+}
+
+func (db *DB) LogVehicleExit(ctx context.Context, societyID int64, plate string, gateID *int64, loggedBy string) (*model.VehicleEntry, error) {
+	entry, err := scanVehicleEntry(db.pool.QueryRow(ctx, `
+        UPDATE vehicle_entries
+        SET exited_at = NOW(), exit_gate_id = $3, exit_logged_by = $4
+        WHERE society_id = $1 AND plate = $2 AND exited_at IS NULL
+        RETURNING `+vehicleEntryColumns, societyID, plate, gateID, loggedBy))
+	if errors.Is(err, ErrNotFound) {
+		return nil, ErrVehicleNotInside
+	}
+
+	return entry, err
+}
+
+type VehicleEntryFilter struct {
+	SocietyID  int64
+	Plate      string
+	OnlyInside bool
+	Limit      int
+}
+
+func (db *DB) GetVehicleEntries(ctx context.Context, filter VehicleEntryFilter) ([]model.VehicleEntry, error) {
+	query := `SELECT ` + vehicleEntryColumns + ` FROM vehicle_entries WHERE society_id = $1`
+	args := []interface{}{filter.SocietyID}
+	argCount := 2
+
+	if filter.Plate != "" {
+		query += fmt.Sprintf(" AND plate = $%d", argCount)
+		args = append(args, filter.Plate)
+		argCount++
+	}
+
+	if filter.OnlyInside {
+		query += " AND exited_at IS NULL"
+	}
+
+	if filter.Limit <= 0 || filter.Limit > 500 {
+		filter.Limit = 100
+	}
+	query += fmt.Sprintf(" ORDER BY entered_at DESC LIMIT $%d", argCount)
+	args = append(args, filter.Limit)
+
+	rows, err := db.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying vehicle entries: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []model.VehicleEntry
+	for rows.Next() {
+		e, err := scanVehicleEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, *e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating vehicle entries: %w", err)
+	}
+
+	return entries, nil
+}
+
+func (db *DB) GetParkingOccupancy(ctx context.Context, societyID int64) (*model.ParkingOccupancy, error) {
+	occ := &model.ParkingOccupancy{
+		SocietyID:  societyID,
+		ByCategory: make(map[model.VehicleCategory]int),
+		ByType:     make(map[model.VehicleType]int),
+		Breakdown:  make(map[model.VehicleCategory]map[model.VehicleType]int),
+	}
+
+	rows, err := db.pool.Query(ctx, `
+        SELECT category, type, COUNT(*)
+        FROM vehicle_entries
+        WHERE society_id = $1 AND exited_at IS NULL
+        GROUP BY category, type
+    `, societyID)
+	if err != nil {
+		return nil, fmt.Errorf("querying occupancy: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var category model.VehicleCategory
+		var vehicleType model.VehicleType
+		var n int
+		if err := rows.Scan(&category, &vehicleType, &n); err != nil {
+			return nil, fmt.Errorf("scanning occupancy row: %w", err)
+		}
+
+		occ.Inside += n
+		occ.ByCategory[category] += n
+		occ.ByType[vehicleType] += n
+		if occ.Breakdown[category] == nil {
+			occ.Breakdown[category] = make(map[model.VehicleType]int)
+		}
+		occ.Breakdown[category][vehicleType] = n
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating occupancy: %w", err)
+	}
+
+	err = db.pool.QueryRow(ctx, `
+        SELECT COUNT(*)
+        FROM vehicles v
+        JOIN residences r ON r.id = v.residence_id
+        JOIN blocks b ON b.id = r.block_id
+        WHERE b.society_id = $1 AND v.is_active
+    `, societyID).Scan(&occ.Registered)
+	if err != nil {
+		return nil, fmt.Errorf("counting registered vehicles: %w", err)
+	}
+
+	return occ, nil
+}
+
+func scanVehicle(row pgx.Row) (*model.Vehicle, error) {
+	var v model.Vehicle
+	err := row.Scan(
+		&v.ID, &v.ResidenceID, &v.Plate, &v.Type, &v.MakeModel, &v.Color,
+		&v.ParkingSlotID, &v.IsActive, &v.CreatedBy, &v.CreatedAt, &v.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scanning vehicle: %w", err)
+	}
+
+	return &v, nil
+}
+
+func scanVehicleEntry(row pgx.Row) (*model.VehicleEntry, error) {
+	var e model.VehicleEntry
+	err := row.Scan(
+		&e.ID, &e.SocietyID, &e.Plate, &e.Type, &e.Category, &e.VehicleID,
+		&e.VisitID, &e.GateID, &e.EnteredAt, &e.EntryLoggedBy, &e.ExitedAt,
+		&e.ExitGateID, &e.ExitLoggedBy,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scanning vehicle entry: %w", err)
+	}
+
+	return &e, nil
+}
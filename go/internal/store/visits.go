@@ -3,12 +3,15 @@ package store
 import (
 	"context"
 	"dooreye-backend/internal/model"
+	"errors"
 	"fmt"
 	"time"
 
-	"github.com/jackc/pgx"
+	"github.com/jackc/pgx/v4"
 )
 
+var ErrVisitApproved = errors.New("visit has already been approved")
+
 func (db *DB) CheckoutVisit(ctx context.Context, visitID int64) error {
 	query := `
 		UPDATE visits
@@ -28,15 +31,83 @@ func (db *DB) CheckoutVisit(ctx context.Context, visitID int64) error {
 	return nil
 }
 
+// ApproveVisit records a resident's approval of a visit to their residence.
+// A visit is approved once; ErrNotFound means it is not the residence's.
+func (db *DB) ApproveVisit(ctx context.Context, visitID string, residenceID int64, approvedBy string) (*model.Visit, error) {
+	var v model.Visit
+	err := db.pool.QueryRow(ctx, `
+        UPDATE visits
+        SET approved_by = $3, approved_at = NOW()
+        WHERE id = $1 AND residence_id = $2 AND approved_by IS NULL
+        RETURNING id, residence_id, gate_id, ticket_id, visitor_id, checked_in_by,
+                  approved_by, approved_at, check_in_time, check_out_time,
+                  COALESCE(purpose, ''), created_at, updated_at
+    `, visitID, residenceID, approvedBy).Scan(
+		&v.ID, &v.ResidenceID, &v.GateID, &v.TicketID, &v.VisitorID, &v.CheckedInBy,
+		&v.ApprovedBy, &v.ApprovedAt, &v.CheckInTime, &v.CheckOutTime,
+		&v.Purpose, &v.CreatedAt, &v.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		var approved bool
+		err := db.pool.QueryRow(ctx, `
+            SELECT approved_by IS NOT NULL FROM visits WHERE id = $1 AND residence_id = $2
+        `, visitID, residenceID).Scan(&approved)
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return nil, ErrNotFound
+		case err != nil:
+			return nil, fmt.Errorf("checking visit: %w", err)
+		}
+		return nil, ErrVisitApproved
+	}
+	if err != nil {
+		return nil, fmt.Errorf("approving visit: %w", err)
+	}
+
+	return &v, nil
+}
+
+// CountOpenVisitsBySociety returns how many visitors are inside each
+// society, resolving the society the same way as GetOverstayingVisits.
+func (db *DB) CountOpenVisitsBySociety(ctx context.Context) (map[int64]int, error) {
+	rows, err := db.pool.Query(ctx, `
+        SELECT COALESCE(b.society_id, u.society_id) AS society_id, COUNT(*)
+        FROM visits v
+        JOIN users u ON u.id = v.checked_in_by
+        LEFT JOIN residences r ON r.id = v.residence_id
+        LEFT JOIN blocks b ON b.id = r.block_id
+        WHERE v.check_out_time IS NULL
+          AND COALESCE(b.society_id, u.society_id) IS NOT NULL
+        GROUP BY 1
+    `)
+	if err != nil {
+		return nil, fmt.Errorf("counting open visits: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[int64]int)
+	for rows.Next() {
+		var societyID int64
+		var n int
+		if err := rows.Scan(&societyID, &n); err != nil {
+			return nil, fmt.Errorf("scanning open visit count: %w", err)
+		}
+		counts[societyID] = n
+	}
+
+	return counts, rows.Err()
+}
+
 type VisitFilter struct {
 	ResidenceID *int64 // pointer to handle empty case
+	TicketID    *int64
 	OnlyOngoing bool
 }
 
 func (db *DB) GetVisits(ctx context.Context, filter VisitFilter) ([]model.VisitWithVisitor, error) {
 	query := `
         SELECT v.id, v.residence_id, v.visitor_id, v.checked_in_by,
-               v.check_in_time, v.check_out_time, v.purpose,
+               v.check_in_time, v.check_out_time, v.purpose, v.ticket_id,
                vis.name, vis.phone, vis.type
         FROM visits v
         JOIN visitors vis ON v.visitor_id = vis.id
@@ -51,6 +122,12 @@ func (db *DB) GetVisits(ctx context.Context, filter VisitFilter) ([]model.VisitW
 		argCount++
 	}
 
+	if filter.TicketID != nil {
+		query += fmt.Sprintf(" AND v.ticket_id = $%d", argCount)
+		args = append(args, *filter.TicketID)
+		argCount++
+	}
+
 	if filter.OnlyOngoing {
 		query += " AND v.check_out_time IS NULL"
 	}
@@ -68,7 +145,7 @@ func (db *DB) GetVisits(ctx context.Context, filter VisitFilter) ([]model.VisitW
 		var v model.VisitWithVisitor
 		if err := rows.Scan(
 			&v.ID, &v.ResidenceID, &v.VisitorID, &v.CheckedInBy,
-			&v.CheckInTime, &v.CheckOutTime, &v.Purpose,
+			&v.CheckInTime, &v.CheckOutTime, &v.Purpose, &v.TicketID,
 			&v.Name, &v.Phone, &v.Type,
 		); err != nil {
 			return nil, fmt.Errorf("scanning visit row: %w", err)
@@ -107,6 +184,28 @@ func (db *DB) GetVisitorByPhone(ctx context.Context, phone string) (*model.Visit
 	return &visitor, nil
 }
 
+func (db *DB) GetVisitor(ctx context.Context, id string) (*model.Visitor, error) {
+	var visitor model.Visitor
+
+	err := db.pool.QueryRow(ctx, `
+    SELECT id, name, phone, photo_url, type, pre_approved_till, created_by
+    FROM visitors
+    WHERE id = $1
+    `, id).Scan(
+		&visitor.ID, &visitor.Name, &visitor.Phone, &visitor.PhotoURL,
+		&visitor.Type, &visitor.PreApprovedTill, &visitor.CreatedBy,
+	)
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("getting visitor: %w", err)
+	}
+
+	return &visitor, nil
+}
+
 type PreApprovedVisitor struct {
 	ID              int64      `json:"id"`
 	Name            string     `json:"name"`
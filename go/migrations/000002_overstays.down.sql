@@ -0,0 +1,7 @@
+DROP TRIGGER IF EXISTS update_stay_limits_updated_at ON stay_limits;
+
+DROP INDEX IF EXISTS idx_visits_open;
+
+ALTER TABLE visits DROP COLUMN IF EXISTS overstay_notified_at;
+
+DROP TABLE IF EXISTS stay_limits;
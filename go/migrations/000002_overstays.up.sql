@@ -0,0 +1,27 @@
+CREATE TABLE stay_limits (
+    id BIGSERIAL PRIMARY KEY,
+    society_id BIGINT REFERENCES societies(id),
+    visitor_type visitor_type NOT NULL,
+    max_stay_minutes INTEGER NOT NULL CHECK (max_stay_minutes > 0),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- A NULL society_id row is the global default for that visitor type.
+CREATE UNIQUE INDEX idx_stay_limits_society_type ON stay_limits(COALESCE(society_id, 0), visitor_type);
+
+INSERT INTO stay_limits (society_id, visitor_type, max_stay_minutes) VALUES
+    (NULL, 'DELIVERY', 30),
+    (NULL, 'CAB', 15),
+    (NULL, 'MAINTENANCE', 240),
+    (NULL, 'GUEST', 720),
+    (NULL, 'STAFF', 720);
+
+ALTER TABLE visits ADD COLUMN overstay_notified_at TIMESTAMPTZ;
+
+CREATE INDEX idx_visits_open ON visits(check_in_time) WHERE check_out_time IS NULL;
+
+CREATE TRIGGER update_stay_limits_updated_at
+    BEFORE UPDATE ON stay_limits
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
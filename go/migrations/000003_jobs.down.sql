@@ -0,0 +1,5 @@
+DROP TRIGGER IF EXISTS update_jobs_updated_at ON jobs;
+
+DROP TABLE IF EXISTS jobs;
+
+DROP TYPE IF EXISTS job_status;
@@ -0,0 +1,11 @@
+DROP TRIGGER IF EXISTS update_alerts_updated_at ON alerts;
+
+DROP TABLE IF EXISTS alert_escalation_steps;
+
+DROP TABLE IF EXISTS alerts;
+
+DROP TABLE IF EXISTS gates;
+
+DROP TYPE IF EXISTS alert_status;
+
+DROP TYPE IF EXISTS alert_type;
@@ -0,0 +1,66 @@
+CREATE TYPE alert_type AS ENUM (
+    'PANIC',
+    'FIRE',
+    'INTRUSION',
+    'MEDICAL',
+    'OTHER'
+);
+
+CREATE TYPE alert_status AS ENUM (
+    'RAISED',
+    'ACKNOWLEDGED',
+    'RESOLVED'
+);
+
+CREATE TABLE gates (
+    id BIGSERIAL PRIMARY KEY,
+    society_id BIGINT NOT NULL REFERENCES societies(id),
+    name VARCHAR(50) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE(society_id, name)
+);
+
+CREATE TABLE alerts (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    society_id BIGINT NOT NULL REFERENCES societies(id),
+    type alert_type NOT NULL,
+    status alert_status NOT NULL DEFAULT 'RAISED',
+    residence_id BIGINT REFERENCES residences(id),
+    gate_id BIGINT REFERENCES gates(id),
+    message TEXT,
+    raised_by UUID NOT NULL REFERENCES users(id),
+    escalation_level INTEGER NOT NULL DEFAULT 0,
+    last_escalated_at TIMESTAMPTZ,
+    acknowledged_by UUID REFERENCES users(id),
+    acknowledged_at TIMESTAMPTZ,
+    resolved_by UUID REFERENCES users(id),
+    resolved_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK (residence_id IS NOT NULL OR gate_id IS NOT NULL)
+);
+
+-- Who gets paged, and when, if an alert stays unacknowledged. Rows with a
+-- NULL society_id are the default chain for societies without their own.
+CREATE TABLE alert_escalation_steps (
+    id BIGSERIAL PRIMARY KEY,
+    society_id BIGINT REFERENCES societies(id),
+    level INTEGER NOT NULL CHECK (level > 0),
+    after_seconds INTEGER NOT NULL CHECK (after_seconds > 0),
+    roles user_role[] NOT NULL
+);
+
+CREATE UNIQUE INDEX idx_alert_escalation_society_level ON alert_escalation_steps(COALESCE(society_id, 0), level);
+
+INSERT INTO alert_escalation_steps (society_id, level, after_seconds, roles) VALUES
+    (NULL, 1, 60, '{SECURITY,SOCIETY_MANAGER}'),
+    (NULL, 2, 180, '{SECURITY,SOCIETY_MANAGER,ADMIN}');
+
+CREATE INDEX idx_gates_society ON gates(society_id);
+CREATE INDEX idx_alerts_society_created ON alerts(society_id, created_at DESC);
+CREATE INDEX idx_alerts_unacknowledged ON alerts(created_at) WHERE status = 'RAISED';
+
+CREATE TRIGGER update_alerts_updated_at
+    BEFORE UPDATE ON alerts
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,11 @@
+DROP TABLE IF EXISTS headcount_marks;
+
+DROP TYPE IF EXISTS headcount_subject;
+
+DROP TABLE IF EXISTS headcount_events;
+
+DROP TABLE IF EXISTS resident_presence;
+
+DROP INDEX IF EXISTS idx_visits_gate;
+
+ALTER TABLE visits DROP COLUMN IF EXISTS gate_id;
@@ -0,0 +1,37 @@
+ALTER TABLE visits ADD COLUMN gate_id BIGINT REFERENCES gates(id);
+
+CREATE TABLE resident_presence (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    is_home BOOLEAN NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE headcount_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    society_id BIGINT NOT NULL REFERENCES societies(id),
+    reason TEXT,
+    include_residents BOOLEAN NOT NULL DEFAULT true,
+    started_by UUID NOT NULL REFERENCES users(id),
+    started_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    closed_by UUID REFERENCES users(id),
+    closed_at TIMESTAMPTZ
+);
+
+CREATE TYPE headcount_subject AS ENUM (
+    'VISIT',
+    'RESIDENT'
+);
+
+-- subject_id is a visit id or a user id depending on subject_type.
+CREATE TABLE headcount_marks (
+    event_id UUID NOT NULL REFERENCES headcount_events(id) ON DELETE CASCADE,
+    subject_type headcount_subject NOT NULL,
+    subject_id UUID NOT NULL,
+    marked_by UUID NOT NULL REFERENCES users(id),
+    marked_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (event_id, subject_type, subject_id)
+);
+
+CREATE INDEX idx_visits_gate ON visits(gate_id) WHERE gate_id IS NOT NULL;
+CREATE INDEX idx_headcount_events_society ON headcount_events(society_id, started_at DESC);
+CREATE UNIQUE INDEX idx_headcount_events_open ON headcount_events(society_id) WHERE closed_at IS NULL;
@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_users_lease_end;
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS deactivated_at,
+    DROP COLUMN IF EXISTS invited_by,
+    DROP COLUMN IF EXISTS lease_end,
+    DROP COLUMN IF EXISTS is_tenant,
+    DROP COLUMN IF EXISTS can_create_passes,
+    DROP COLUMN IF EXISTS can_approve_visitors;
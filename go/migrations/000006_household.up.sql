@@ -0,0 +1,9 @@
+ALTER TABLE users
+    ADD COLUMN can_approve_visitors BOOLEAN NOT NULL DEFAULT true,
+    ADD COLUMN can_create_passes BOOLEAN NOT NULL DEFAULT true,
+    ADD COLUMN is_tenant BOOLEAN NOT NULL DEFAULT false,
+    ADD COLUMN lease_end DATE,
+    ADD COLUMN invited_by UUID REFERENCES users(id),
+    ADD COLUMN deactivated_at TIMESTAMPTZ;
+
+CREATE INDEX idx_users_lease_end ON users(lease_end) WHERE is_tenant AND is_active;
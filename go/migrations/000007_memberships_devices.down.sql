@@ -0,0 +1,16 @@
+ALTER TABLE users ADD COLUMN device_id VARCHAR(255) UNIQUE;
+
+-- Only one device per user survives the downgrade: the most recently used.
+UPDATE users u
+SET device_id = d.device_id
+FROM (
+    SELECT DISTINCT ON (user_id) user_id, device_id
+    FROM user_devices
+    WHERE revoked_at IS NULL
+    ORDER BY user_id, last_seen_at DESC NULLS LAST, created_at DESC
+) d
+WHERE d.user_id = u.id;
+
+DROP TABLE IF EXISTS user_devices;
+
+DROP TABLE IF EXISTS user_residences;
@@ -0,0 +1,35 @@
+CREATE TABLE user_residences (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    residence_id BIGINT NOT NULL REFERENCES residences(id),
+    role user_role NOT NULL CHECK (role IN ('OWNER', 'RESIDENT')),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, residence_id)
+);
+
+CREATE TABLE user_devices (
+    id BIGSERIAL PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    device_id VARCHAR(255) NOT NULL,
+    name VARCHAR(100),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_seen_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ
+);
+
+-- A device identifier authenticates exactly one user while it is live.
+CREATE UNIQUE INDEX idx_user_devices_device ON user_devices(device_id) WHERE revoked_at IS NULL;
+CREATE INDEX idx_user_devices_user ON user_devices(user_id);
+CREATE INDEX idx_user_residences_residence ON user_residences(residence_id);
+
+INSERT INTO user_residences (user_id, residence_id, role)
+SELECT id, residence_id, role
+FROM users
+WHERE residence_id IS NOT NULL AND role IN ('OWNER', 'RESIDENT');
+
+INSERT INTO user_devices (user_id, device_id, created_at)
+SELECT id, device_id, COALESCE(activated_at, created_at)
+FROM users
+WHERE device_id IS NOT NULL;
+
+-- users.residence_id stays as the user's default residence context.
+ALTER TABLE users DROP COLUMN device_id;
@@ -0,0 +1,9 @@
+DROP TRIGGER IF EXISTS update_vehicles_updated_at ON vehicles;
+
+DROP TABLE IF EXISTS vehicle_entries;
+
+DROP TABLE IF EXISTS vehicles;
+
+DROP TYPE IF EXISTS vehicle_category;
+
+DROP TYPE IF EXISTS vehicle_type;
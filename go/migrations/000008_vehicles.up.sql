@@ -0,0 +1,56 @@
+CREATE TYPE vehicle_type AS ENUM (
+    'CAR',
+    'BIKE',
+    'OTHER'
+);
+
+CREATE TYPE vehicle_category AS ENUM (
+    'RESIDENT',
+    'GUEST',
+    'UNKNOWN'
+);
+
+CREATE TABLE vehicles (
+    id BIGSERIAL PRIMARY KEY,
+    residence_id BIGINT NOT NULL REFERENCES residences(id),
+    plate VARCHAR(16) NOT NULL,
+    type vehicle_type NOT NULL,
+    make_model VARCHAR(100),
+    color VARCHAR(30),
+    parking_slot VARCHAR(20),
+    is_active BOOLEAN NOT NULL DEFAULT true,
+    created_by UUID NOT NULL REFERENCES users(id),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- plate is stored normalized (see internal/plate), so one index catches
+-- "MH 12 AB 1234" and "mh-12-ab-1234" alike.
+CREATE UNIQUE INDEX idx_vehicles_plate_active ON vehicles(plate) WHERE is_active;
+CREATE INDEX idx_vehicles_residence ON vehicles(residence_id);
+
+CREATE TABLE vehicle_entries (
+    id BIGSERIAL PRIMARY KEY,
+    society_id BIGINT NOT NULL REFERENCES societies(id),
+    plate VARCHAR(16) NOT NULL,
+    type vehicle_type NOT NULL,
+    category vehicle_category NOT NULL,
+    vehicle_id BIGINT REFERENCES vehicles(id),
+    visit_id UUID REFERENCES visits(id),
+    gate_id BIGINT REFERENCES gates(id),
+    entered_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    entry_logged_by UUID NOT NULL REFERENCES users(id),
+    exited_at TIMESTAMPTZ,
+    exit_gate_id BIGINT REFERENCES gates(id),
+    exit_logged_by UUID REFERENCES users(id)
+);
+
+-- A vehicle can only be inside once.
+CREATE UNIQUE INDEX idx_vehicle_entries_inside ON vehicle_entries(society_id, plate) WHERE exited_at IS NULL;
+CREATE INDEX idx_vehicle_entries_plate ON vehicle_entries(plate, entered_at DESC);
+CREATE INDEX idx_vehicle_entries_society_entered ON vehicle_entries(society_id, entered_at DESC);
+
+CREATE TRIGGER update_vehicles_updated_at
+    BEFORE UPDATE ON vehicles
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
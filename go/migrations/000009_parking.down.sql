@@ -0,0 +1,12 @@
+DROP TRIGGER IF EXISTS update_guest_parking_bookings_updated_at ON guest_parking_bookings;
+
+DROP TRIGGER IF EXISTS update_parking_slots_updated_at ON parking_slots;
+
+ALTER TABLE vehicles DROP COLUMN IF EXISTS parking_slot_id;
+ALTER TABLE vehicles ADD COLUMN parking_slot VARCHAR(20);
+
+DROP TABLE IF EXISTS guest_parking_bookings;
+
+DROP TABLE IF EXISTS parking_slots;
+
+DROP TYPE IF EXISTS parking_booking_status;
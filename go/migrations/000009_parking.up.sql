@@ -0,0 +1,67 @@
+CREATE EXTENSION IF NOT EXISTS btree_gist;
+
+CREATE TYPE parking_booking_status AS ENUM (
+    'BOOKED',
+    'CHECKED_IN',
+    'COMPLETED',
+    'CANCELLED'
+);
+
+CREATE TABLE parking_slots (
+    id BIGSERIAL PRIMARY KEY,
+    society_id BIGINT NOT NULL REFERENCES societies(id),
+    block_id BIGINT NOT NULL REFERENCES blocks(id),
+    code VARCHAR(20) NOT NULL,
+    vehicle_type vehicle_type NOT NULL,
+    residence_id BIGINT REFERENCES residences(id),
+    is_guest BOOLEAN NOT NULL DEFAULT false,
+    is_active BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE(block_id, code),
+    -- Referenced by bookings so only guest slots can be booked.
+    UNIQUE(id, is_guest),
+    -- A slot is either in the guest pool or assigned, never both.
+    CHECK (NOT (is_guest AND residence_id IS NOT NULL))
+);
+
+CREATE TABLE guest_parking_bookings (
+    id BIGSERIAL PRIMARY KEY,
+    slot_id BIGINT NOT NULL,
+    slot_is_guest BOOLEAN NOT NULL DEFAULT true CHECK (slot_is_guest),
+    residence_id BIGINT REFERENCES residences(id),
+    visitor_id UUID REFERENCES visitors(id),
+    visit_id UUID REFERENCES visits(id),
+    period TSTZRANGE NOT NULL CHECK (NOT isempty(period)),
+    status parking_booking_status NOT NULL DEFAULT 'BOOKED',
+    booked_by UUID NOT NULL REFERENCES users(id),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    FOREIGN KEY (slot_id, slot_is_guest) REFERENCES parking_slots(id, is_guest),
+    -- No slot is handed out twice for overlapping times.
+    CONSTRAINT guest_parking_bookings_slot_overlap
+        EXCLUDE USING gist (slot_id WITH =, period WITH &&)
+        WHERE (status IN ('BOOKED', 'CHECKED_IN')),
+    -- A visitor holds at most one slot at a time.
+    CONSTRAINT guest_parking_bookings_visitor_overlap
+        EXCLUDE USING gist (visitor_id WITH =, period WITH &&)
+        WHERE (status IN ('BOOKED', 'CHECKED_IN') AND visitor_id IS NOT NULL)
+);
+
+ALTER TABLE vehicles DROP COLUMN parking_slot;
+ALTER TABLE vehicles ADD COLUMN parking_slot_id BIGINT REFERENCES parking_slots(id);
+
+CREATE INDEX idx_parking_slots_society ON parking_slots(society_id) WHERE is_active;
+CREATE INDEX idx_parking_slots_residence ON parking_slots(residence_id) WHERE residence_id IS NOT NULL;
+CREATE INDEX idx_guest_parking_bookings_residence ON guest_parking_bookings(residence_id, created_at DESC);
+CREATE INDEX idx_guest_parking_bookings_visit ON guest_parking_bookings(visit_id) WHERE visit_id IS NOT NULL;
+
+CREATE TRIGGER update_parking_slots_updated_at
+    BEFORE UPDATE ON parking_slots
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TRIGGER update_guest_parking_bookings_updated_at
+    BEFORE UPDATE ON guest_parking_bookings
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,11 @@
+DROP TRIGGER IF EXISTS update_amenity_bookings_updated_at ON amenity_bookings;
+
+DROP TRIGGER IF EXISTS update_amenities_updated_at ON amenities;
+
+DROP TABLE IF EXISTS amenity_bookings;
+
+DROP TABLE IF EXISTS amenities;
+
+DROP TYPE IF EXISTS amenity_booking_status;
+
+ALTER TABLE societies DROP COLUMN IF EXISTS timezone;
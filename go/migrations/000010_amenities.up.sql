@@ -0,0 +1,72 @@
+-- Operating hours and booking windows are evaluated in the society's local
+-- time.
+ALTER TABLE societies ADD COLUMN timezone TEXT NOT NULL DEFAULT 'Asia/Kolkata';
+
+CREATE TYPE amenity_booking_status AS ENUM (
+    'CONFIRMED',
+    'CANCELLED'
+);
+
+CREATE TABLE amenities (
+    id BIGSERIAL PRIMARY KEY,
+    society_id BIGINT NOT NULL REFERENCES societies(id),
+    name VARCHAR(100) NOT NULL,
+    description TEXT,
+    -- Number of parallel bookings, e.g. courts or party hall sections.
+    capacity INT NOT NULL DEFAULT 1 CHECK (capacity > 0),
+    slot_minutes INT NOT NULL CHECK (slot_minutes > 0),
+    -- Minutes after local midnight.
+    opens_at SMALLINT NOT NULL CHECK (opens_at BETWEEN 0 AND 1439),
+    closes_at SMALLINT NOT NULL CHECK (closes_at BETWEEN 1 AND 1440),
+    max_per_week INT CHECK (max_per_week > 0),
+    advance_days INT NOT NULL DEFAULT 7 CHECK (advance_days >= 0),
+    fee_paise BIGINT NOT NULL DEFAULT 0 CHECK (fee_paise >= 0),
+    -- Residents may cancel up to this many hours before the start; NULL
+    -- means only managers can cancel.
+    cancel_cutoff_hours INT CHECK (cancel_cutoff_hours >= 0),
+    is_active BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE(society_id, name),
+    CHECK (closes_at > opens_at),
+    CHECK ((closes_at - opens_at) >= slot_minutes)
+);
+
+CREATE TABLE amenity_bookings (
+    id BIGSERIAL PRIMARY KEY,
+    amenity_id BIGINT NOT NULL REFERENCES amenities(id),
+    -- Which of the amenity's parallel units is held, 1..capacity.
+    unit INT NOT NULL CHECK (unit > 0),
+    residence_id BIGINT NOT NULL REFERENCES residences(id),
+    booked_by UUID NOT NULL REFERENCES users(id),
+    period TSTZRANGE NOT NULL CHECK (NOT isempty(period)),
+    status amenity_booking_status NOT NULL DEFAULT 'CONFIRMED',
+    fee_paise BIGINT NOT NULL DEFAULT 0,
+    notes TEXT,
+    cancelled_at TIMESTAMPTZ,
+    cancelled_by UUID REFERENCES users(id),
+    cancel_reason TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    -- A unit is never booked twice for overlapping times.
+    CONSTRAINT amenity_bookings_unit_overlap
+        EXCLUDE USING gist (amenity_id WITH =, unit WITH =, period WITH &&)
+        WHERE (status = 'CONFIRMED'),
+    -- A residence holds at most one unit of an amenity at a time.
+    CONSTRAINT amenity_bookings_residence_overlap
+        EXCLUDE USING gist (amenity_id WITH =, residence_id WITH =, period WITH &&)
+        WHERE (status = 'CONFIRMED')
+);
+
+CREATE INDEX idx_amenities_society ON amenities(society_id) WHERE is_active;
+CREATE INDEX idx_amenity_bookings_residence ON amenity_bookings(residence_id, lower(period));
+
+CREATE TRIGGER update_amenities_updated_at
+    BEFORE UPDATE ON amenities
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TRIGGER update_amenity_bookings_updated_at
+    BEFORE UPDATE ON amenity_bookings
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
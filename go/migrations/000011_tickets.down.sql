@@ -0,0 +1,21 @@
+DROP TRIGGER IF EXISTS update_tickets_updated_at ON tickets;
+
+DROP TRIGGER IF EXISTS update_ticket_sla_policies_updated_at ON ticket_sla_policies;
+
+ALTER TABLE visits DROP COLUMN IF EXISTS ticket_id;
+
+DROP TABLE IF EXISTS ticket_events;
+
+DROP TABLE IF EXISTS ticket_comments;
+
+DROP TABLE IF EXISTS ticket_photos;
+
+DROP TABLE IF EXISTS tickets;
+
+DROP TABLE IF EXISTS ticket_sla_policies;
+
+DROP TYPE IF EXISTS ticket_status;
+
+DROP TYPE IF EXISTS ticket_priority;
+
+DROP TYPE IF EXISTS ticket_category;
@@ -0,0 +1,125 @@
+CREATE TYPE ticket_category AS ENUM (
+    'PLUMBING',
+    'ELECTRICAL',
+    'LIFT',
+    'CARPENTRY',
+    'HOUSEKEEPING',
+    'SECURITY',
+    'PARKING',
+    'OTHER'
+);
+
+CREATE TYPE ticket_priority AS ENUM (
+    'LOW',
+    'MEDIUM',
+    'HIGH',
+    'URGENT'
+);
+
+CREATE TYPE ticket_status AS ENUM (
+    'OPEN',
+    'ASSIGNED',
+    'IN_PROGRESS',
+    'ON_HOLD',
+    'RESOLVED',
+    'CLOSED'
+);
+
+CREATE TABLE ticket_sla_policies (
+    id BIGSERIAL PRIMARY KEY,
+    society_id BIGINT REFERENCES societies(id),
+    priority ticket_priority NOT NULL,
+    response_minutes INTEGER NOT NULL CHECK (response_minutes > 0),
+    resolution_minutes INTEGER NOT NULL CHECK (resolution_minutes > 0),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK (resolution_minutes >= response_minutes)
+);
+
+-- A NULL society_id row is the global default for that priority.
+CREATE UNIQUE INDEX idx_ticket_sla_policies_society_priority ON ticket_sla_policies(COALESCE(society_id, 0), priority);
+
+INSERT INTO ticket_sla_policies (society_id, priority, response_minutes, resolution_minutes) VALUES
+    (NULL, 'LOW', 1440, 10080),
+    (NULL, 'MEDIUM', 480, 4320),
+    (NULL, 'HIGH', 120, 1440),
+    (NULL, 'URGENT', 30, 240);
+
+CREATE TABLE tickets (
+    id BIGSERIAL PRIMARY KEY,
+    society_id BIGINT NOT NULL REFERENCES societies(id),
+    residence_id BIGINT REFERENCES residences(id),
+    common_area VARCHAR(100),
+    category ticket_category NOT NULL,
+    priority ticket_priority NOT NULL DEFAULT 'MEDIUM',
+    title VARCHAR(200) NOT NULL,
+    description TEXT,
+    status ticket_status NOT NULL DEFAULT 'OPEN',
+    raised_by UUID NOT NULL REFERENCES users(id),
+    assigned_to UUID REFERENCES users(id),
+    -- SLA deadlines are fixed when the ticket is raised.
+    respond_by TIMESTAMPTZ NOT NULL,
+    resolve_by TIMESTAMPTZ NOT NULL,
+    first_response_at TIMESTAMPTZ,
+    resolved_at TIMESTAMPTZ,
+    closed_at TIMESTAMPTZ,
+    response_breached_at TIMESTAMPTZ,
+    resolution_breached_at TIMESTAMPTZ,
+    rating SMALLINT CHECK (rating BETWEEN 1 AND 5),
+    rating_comment TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    -- Raised either against a residence or a common area.
+    CHECK ((residence_id IS NULL) <> (common_area IS NULL))
+);
+
+CREATE TABLE ticket_photos (
+    id BIGSERIAL PRIMARY KEY,
+    ticket_id BIGINT NOT NULL REFERENCES tickets(id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    uploaded_by UUID NOT NULL REFERENCES users(id),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE ticket_comments (
+    id BIGSERIAL PRIMARY KEY,
+    ticket_id BIGINT NOT NULL REFERENCES tickets(id) ON DELETE CASCADE,
+    author_id UUID NOT NULL REFERENCES users(id),
+    body TEXT NOT NULL,
+    -- Internal notes are visible to society staff only.
+    is_internal BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Status history of a ticket.
+CREATE TABLE ticket_events (
+    id BIGSERIAL PRIMARY KEY,
+    ticket_id BIGINT NOT NULL REFERENCES tickets(id) ON DELETE CASCADE,
+    from_status ticket_status,
+    to_status ticket_status NOT NULL,
+    -- NULL for changes made by the system, e.g. auto-closing.
+    actor_id UUID REFERENCES users(id),
+    note TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Maintenance visitors attending a ticket.
+ALTER TABLE visits ADD COLUMN ticket_id BIGINT REFERENCES tickets(id);
+
+CREATE INDEX idx_tickets_society_status ON tickets(society_id, status, created_at DESC);
+CREATE INDEX idx_tickets_residence ON tickets(residence_id, created_at DESC) WHERE residence_id IS NOT NULL;
+CREATE INDEX idx_tickets_assigned ON tickets(assigned_to) WHERE assigned_to IS NOT NULL;
+CREATE INDEX idx_tickets_open_sla ON tickets(resolve_by) WHERE resolved_at IS NULL;
+CREATE INDEX idx_ticket_comments_ticket ON ticket_comments(ticket_id, created_at);
+CREATE INDEX idx_ticket_events_ticket ON ticket_events(ticket_id, created_at);
+CREATE INDEX idx_visits_ticket ON visits(ticket_id) WHERE ticket_id IS NOT NULL;
+
+CREATE TRIGGER update_ticket_sla_policies_updated_at
+    BEFORE UPDATE ON ticket_sla_policies
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TRIGGER update_tickets_updated_at
+    BEFORE UPDATE ON tickets
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
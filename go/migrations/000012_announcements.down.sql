@@ -0,0 +1,7 @@
+DROP TRIGGER IF EXISTS update_announcements_updated_at ON announcements;
+
+DROP TABLE IF EXISTS announcement_reads;
+
+DROP TABLE IF EXISTS announcement_attachments;
+
+DROP TABLE IF EXISTS announcements;
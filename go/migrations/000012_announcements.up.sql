@@ -0,0 +1,47 @@
+CREATE TABLE announcements (
+    id BIGSERIAL PRIMARY KEY,
+    society_id BIGINT NOT NULL REFERENCES societies(id),
+    title VARCHAR(200) NOT NULL,
+    body TEXT NOT NULL,
+    -- Empty arrays mean everyone in the society. Block targeting applies to
+    -- residents through their residence; society staff match on role only.
+    block_ids BIGINT[] NOT NULL DEFAULT '{}',
+    roles user_role[] NOT NULL DEFAULT '{}',
+    is_urgent BOOLEAN NOT NULL DEFAULT false,
+    is_pinned BOOLEAN NOT NULL DEFAULT false,
+    -- NULL while a draft. May lie in the future to schedule publishing.
+    published_at TIMESTAMPTZ,
+    expires_at TIMESTAMPTZ,
+    notified_at TIMESTAMPTZ,
+    withdrawn_at TIMESTAMPTZ,
+    created_by UUID NOT NULL REFERENCES users(id),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK (expires_at IS NULL OR published_at IS NULL OR expires_at > published_at)
+);
+
+CREATE TABLE announcement_attachments (
+    id BIGSERIAL PRIMARY KEY,
+    announcement_id BIGINT NOT NULL REFERENCES announcements(id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    name VARCHAR(200) NOT NULL,
+    content_type VARCHAR(100),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE announcement_reads (
+    announcement_id BIGINT NOT NULL REFERENCES announcements(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id),
+    read_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (announcement_id, user_id)
+);
+
+CREATE INDEX idx_announcements_feed ON announcements(society_id, published_at DESC)
+    WHERE published_at IS NOT NULL AND withdrawn_at IS NULL;
+CREATE INDEX idx_announcement_attachments_announcement ON announcement_attachments(announcement_id);
+CREATE INDEX idx_announcement_reads_user ON announcement_reads(user_id);
+
+CREATE TRIGGER update_announcements_updated_at
+    BEFORE UPDATE ON announcements
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
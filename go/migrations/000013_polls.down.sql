@@ -0,0 +1,11 @@
+DROP TRIGGER IF EXISTS update_polls_updated_at ON polls;
+
+DROP TABLE IF EXISTS poll_ballots;
+
+DROP TABLE IF EXISTS poll_participation;
+
+DROP TABLE IF EXISTS poll_options;
+
+DROP TABLE IF EXISTS polls;
+
+DROP TYPE IF EXISTS poll_eligibility;
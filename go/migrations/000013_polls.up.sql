@@ -0,0 +1,69 @@
+CREATE TYPE poll_eligibility AS ENUM (
+    'OWNERS',
+    'ALL_RESIDENTS'
+);
+
+CREATE TABLE polls (
+    id BIGSERIAL PRIMARY KEY,
+    society_id BIGINT NOT NULL REFERENCES societies(id),
+    title VARCHAR(200) NOT NULL,
+    description TEXT,
+    eligibility poll_eligibility NOT NULL DEFAULT 'ALL_RESIDENTS',
+    is_anonymous BOOLEAN NOT NULL DEFAULT false,
+    opens_at TIMESTAMPTZ NOT NULL,
+    closes_at TIMESTAMPTZ NOT NULL,
+    cancelled_at TIMESTAMPTZ,
+    -- Head of the ballot hash chain and its length, advanced with every
+    -- vote. Once the poll closes they seal the result.
+    ballot_count INT NOT NULL DEFAULT 0,
+    head_hash BYTEA NOT NULL DEFAULT '\x0000000000000000000000000000000000000000000000000000000000000000',
+    created_by UUID NOT NULL REFERENCES users(id),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK (closes_at > opens_at)
+);
+
+CREATE TABLE poll_options (
+    id BIGSERIAL PRIMARY KEY,
+    poll_id BIGINT NOT NULL REFERENCES polls(id) ON DELETE CASCADE,
+    label VARCHAR(200) NOT NULL,
+    position INT NOT NULL,
+    UNIQUE(poll_id, position),
+    UNIQUE(id, poll_id)
+);
+
+-- Who has voted. One row per residence enforces one vote per residence.
+-- For anonymous polls voter and time are not kept here or on the ballot, so
+-- the API never shows who voted for what. This is not secrecy from anyone
+-- who can read the database: the participation row and the ballot are
+-- written in one transaction, so xmin and seq still line them up.
+CREATE TABLE poll_participation (
+    poll_id BIGINT NOT NULL REFERENCES polls(id) ON DELETE CASCADE,
+    residence_id BIGINT NOT NULL REFERENCES residences(id),
+    voter_id UUID REFERENCES users(id),
+    voted_at TIMESTAMPTZ,
+    PRIMARY KEY (poll_id, residence_id)
+);
+
+-- The ballots, hash chained per poll: each hash covers the previous one,
+-- so altering, removing or reordering a ballot breaks every later hash.
+CREATE TABLE poll_ballots (
+    poll_id BIGINT NOT NULL REFERENCES polls(id) ON DELETE CASCADE,
+    seq INT NOT NULL CHECK (seq > 0),
+    option_id BIGINT NOT NULL,
+    -- NULL on anonymous polls.
+    residence_id BIGINT REFERENCES residences(id),
+    voter_id UUID REFERENCES users(id),
+    cast_at TIMESTAMPTZ,
+    prev_hash BYTEA NOT NULL,
+    hash BYTEA NOT NULL,
+    PRIMARY KEY (poll_id, seq),
+    FOREIGN KEY (option_id, poll_id) REFERENCES poll_options(id, poll_id)
+);
+
+CREATE INDEX idx_polls_society ON polls(society_id, closes_at DESC);
+
+CREATE TRIGGER update_polls_updated_at
+    BEFORE UPDATE ON polls
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,37 @@
+DROP TRIGGER IF EXISTS update_payments_updated_at ON payments;
+
+DROP TRIGGER IF EXISTS update_invoices_updated_at ON invoices;
+
+DROP TRIGGER IF EXISTS update_billing_rules_updated_at ON billing_rules;
+
+DROP TRIGGER IF EXISTS update_billing_settings_updated_at ON billing_settings;
+
+DROP TRIGGER IF EXISTS ledger_entries_balanced ON ledger_entries;
+
+DROP FUNCTION IF EXISTS check_ledger_transaction_balanced();
+
+DROP TABLE IF EXISTS ledger_entries;
+
+DROP TABLE IF EXISTS ledger_transactions;
+
+DROP TABLE IF EXISTS payments;
+
+DROP TABLE IF EXISTS invoice_lines;
+
+DROP TABLE IF EXISTS invoices;
+
+DROP TABLE IF EXISTS billing_rules;
+
+DROP TABLE IF EXISTS billing_settings;
+
+DROP TYPE IF EXISTS payment_status;
+
+DROP TYPE IF EXISTS payment_method;
+
+DROP TYPE IF EXISTS ledger_entry_kind;
+
+DROP TYPE IF EXISTS ledger_account;
+
+DROP TYPE IF EXISTS billing_rule_kind;
+
+ALTER TABLE residences DROP COLUMN IF EXISTS area_sqft;
@@ -0,0 +1,178 @@
+-- Built-up area, used by per-sqft maintenance rules.
+ALTER TABLE residences ADD COLUMN area_sqft INT CHECK (area_sqft > 0);
+
+CREATE TYPE billing_rule_kind AS ENUM (
+    'FLAT',
+    'PER_SQFT',
+    'PER_FLOOR'
+);
+
+CREATE TYPE ledger_account AS ENUM (
+    'RECEIVABLE',
+    'MAINTENANCE_INCOME',
+    'PENALTY_INCOME',
+    'CASH',
+    'BANK',
+    'GATEWAY'
+);
+
+CREATE TYPE ledger_entry_kind AS ENUM (
+    'CHARGE',
+    'PENALTY',
+    'PAYMENT'
+);
+
+CREATE TYPE payment_method AS ENUM (
+    'CASH',
+    'CHEQUE',
+    'BANK_TRANSFER',
+    'GATEWAY'
+);
+
+CREATE TYPE payment_status AS ENUM (
+    'PENDING',
+    'SUCCEEDED',
+    'FAILED'
+);
+
+-- A society is billed once it has settings and at least one active rule.
+CREATE TABLE billing_settings (
+    society_id BIGINT PRIMARY KEY REFERENCES societies(id),
+    -- Invoices cover this many months, aligned to January.
+    cycle_months INT NOT NULL DEFAULT 1 CHECK (cycle_months IN (1, 3, 6, 12)),
+    -- Days after the period starts that the invoice falls due.
+    due_days INT NOT NULL DEFAULT 10 CHECK (due_days BETWEEN 0 AND 90),
+    -- Charged once per invoice still unpaid grace_days after its due date.
+    late_fee_paise BIGINT NOT NULL DEFAULT 0 CHECK (late_fee_paise >= 0),
+    grace_days INT NOT NULL DEFAULT 0 CHECK (grace_days >= 0),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Monthly charge components. Rates are per month and scaled by the cycle.
+CREATE TABLE billing_rules (
+    id BIGSERIAL PRIMARY KEY,
+    society_id BIGINT NOT NULL REFERENCES societies(id),
+    -- Limits the rule to one block; NULL applies it society wide.
+    block_id BIGINT REFERENCES blocks(id),
+    name VARCHAR(100) NOT NULL,
+    kind billing_rule_kind NOT NULL,
+    -- Per residence, per sqft or per floor depending on kind.
+    rate_paise BIGINT NOT NULL CHECK (rate_paise > 0),
+    is_active BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE invoices (
+    id BIGSERIAL PRIMARY KEY,
+    society_id BIGINT NOT NULL REFERENCES societies(id),
+    residence_id BIGINT NOT NULL REFERENCES residences(id),
+    -- [period_start, period_end)
+    period_start DATE NOT NULL,
+    period_end DATE NOT NULL,
+    due_date DATE NOT NULL,
+    total_paise BIGINT NOT NULL CHECK (total_paise >= 0),
+    -- Payments are allocated to the oldest unpaid invoices first.
+    paid_paise BIGINT NOT NULL DEFAULT 0 CHECK (paid_paise >= 0),
+    late_fee_applied_at TIMESTAMPTZ,
+    issued_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE(residence_id, period_start),
+    CHECK (period_end > period_start),
+    CHECK (paid_paise <= total_paise)
+);
+
+CREATE TABLE invoice_lines (
+    id BIGSERIAL PRIMARY KEY,
+    invoice_id BIGINT NOT NULL REFERENCES invoices(id) ON DELETE CASCADE,
+    kind ledger_entry_kind NOT NULL CHECK (kind <> 'PAYMENT'),
+    rule_id BIGINT REFERENCES billing_rules(id),
+    description VARCHAR(200) NOT NULL,
+    amount_paise BIGINT NOT NULL CHECK (amount_paise > 0)
+);
+
+CREATE TABLE payments (
+    id BIGSERIAL PRIMARY KEY,
+    society_id BIGINT NOT NULL REFERENCES societies(id),
+    residence_id BIGINT NOT NULL REFERENCES residences(id),
+    amount_paise BIGINT NOT NULL CHECK (amount_paise > 0),
+    method payment_method NOT NULL,
+    status payment_status NOT NULL DEFAULT 'PENDING',
+    -- Gateway payments name the provider and its payment reference; offline
+    -- ones may carry a cheque or transfer number in reference.
+    provider VARCHAR(50),
+    reference VARCHAR(100),
+    failure_reason TEXT,
+    created_by UUID NOT NULL REFERENCES users(id),
+    completed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE(provider, reference),
+    CHECK ((method = 'GATEWAY') = (provider IS NOT NULL))
+);
+
+-- Double-entry ledger. Every transaction's entries sum to zero: debits are
+-- positive, credits negative. Each residence has its own receivable, so a
+-- positive receivable balance is what the residence owes.
+CREATE TABLE ledger_transactions (
+    id BIGSERIAL PRIMARY KEY,
+    society_id BIGINT NOT NULL REFERENCES societies(id),
+    residence_id BIGINT NOT NULL REFERENCES residences(id),
+    kind ledger_entry_kind NOT NULL,
+    memo VARCHAR(200) NOT NULL,
+    invoice_id BIGINT REFERENCES invoices(id),
+    payment_id BIGINT REFERENCES payments(id),
+    posted_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE ledger_entries (
+    id BIGSERIAL PRIMARY KEY,
+    transaction_id BIGINT NOT NULL REFERENCES ledger_transactions(id),
+    account ledger_account NOT NULL,
+    amount_paise BIGINT NOT NULL CHECK (amount_paise <> 0)
+);
+
+CREATE OR REPLACE FUNCTION check_ledger_transaction_balanced()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF (SELECT SUM(amount_paise) FROM ledger_entries WHERE transaction_id = NEW.transaction_id) <> 0 THEN
+        RAISE EXCEPTION 'ledger transaction % does not balance', NEW.transaction_id
+            USING ERRCODE = 'check_violation';
+    END IF;
+    RETURN NULL;
+END;
+$$ language 'plpgsql';
+
+-- Checked at commit, once all of a transaction's entries are in.
+CREATE CONSTRAINT TRIGGER ledger_entries_balanced
+    AFTER INSERT OR UPDATE ON ledger_entries
+    DEFERRABLE INITIALLY DEFERRED
+    FOR EACH ROW
+    EXECUTE FUNCTION check_ledger_transaction_balanced();
+
+CREATE INDEX idx_billing_rules_society ON billing_rules(society_id) WHERE is_active;
+CREATE INDEX idx_invoices_society ON invoices(society_id, period_start DESC);
+CREATE INDEX idx_invoices_unpaid ON invoices(due_date) WHERE paid_paise < total_paise;
+CREATE INDEX idx_payments_residence ON payments(residence_id, created_at DESC);
+CREATE INDEX idx_ledger_transactions_residence ON ledger_transactions(residence_id, posted_at);
+CREATE INDEX idx_ledger_entries_transaction ON ledger_entries(transaction_id);
+
+CREATE TRIGGER update_billing_settings_updated_at
+    BEFORE UPDATE ON billing_settings
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TRIGGER update_billing_rules_updated_at
+    BEFORE UPDATE ON billing_rules
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TRIGGER update_invoices_updated_at
+    BEFORE UPDATE ON invoices
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TRIGGER update_payments_updated_at
+    BEFORE UPDATE ON payments
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
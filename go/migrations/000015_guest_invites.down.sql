@@ -0,0 +1,5 @@
+DROP TRIGGER IF EXISTS update_guest_invites_updated_at ON guest_invites;
+
+DROP TABLE IF EXISTS guest_registrations;
+
+DROP TABLE IF EXISTS guest_invites;
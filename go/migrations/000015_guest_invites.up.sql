@@ -0,0 +1,44 @@
+-- Shareable links a resident sends to guests, who register themselves and
+-- get a gate pass instead of being entered one by one.
+CREATE TABLE guest_invites (
+    id BIGSERIAL PRIMARY KEY,
+    -- Unguessable part of the public link.
+    token VARCHAR(64) NOT NULL UNIQUE,
+    society_id BIGINT NOT NULL REFERENCES societies(id),
+    residence_id BIGINT NOT NULL REFERENCES residences(id),
+    title VARCHAR(200) NOT NULL,
+    -- 1 for a single-use link.
+    max_uses INT NOT NULL DEFAULT 1 CHECK (max_uses BETWEEN 1 AND 500),
+    use_count INT NOT NULL DEFAULT 0,
+    valid_from TIMESTAMPTZ NOT NULL,
+    valid_until TIMESTAMPTZ NOT NULL,
+    revoked_at TIMESTAMPTZ,
+    created_by UUID NOT NULL REFERENCES users(id),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK (valid_until > valid_from),
+    CHECK (use_count <= max_uses)
+);
+
+CREATE TABLE guest_registrations (
+    id BIGSERIAL PRIMARY KEY,
+    invite_id BIGINT NOT NULL REFERENCES guest_invites(id) ON DELETE CASCADE,
+    visitor_id UUID NOT NULL REFERENCES visitors(id),
+    name VARCHAR(100) NOT NULL,
+    phone VARCHAR(20) NOT NULL,
+    photo_url TEXT,
+    -- Shown as a QR code and scanned at the gate.
+    pass_code CHAR(8) NOT NULL UNIQUE,
+    registered_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    arrived_at TIMESTAMPTZ,
+    visit_id UUID REFERENCES visits(id),
+    UNIQUE(invite_id, phone)
+);
+
+CREATE INDEX idx_guest_invites_residence ON guest_invites(residence_id, valid_until DESC);
+CREATE INDEX idx_guest_registrations_invite ON guest_registrations(invite_id);
+
+CREATE TRIGGER update_guest_invites_updated_at
+    BEFORE UPDATE ON guest_invites
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
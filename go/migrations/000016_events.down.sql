@@ -0,0 +1,5 @@
+DROP TRIGGER IF EXISTS update_events_updated_at ON events;
+
+DROP TABLE IF EXISTS event_guests;
+
+DROP TABLE IF EXISTS events;
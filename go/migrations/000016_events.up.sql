@@ -0,0 +1,47 @@
+-- Weddings, parties and society functions: a guest list the gate checks
+-- people in against, without asking the host about each guest.
+CREATE TABLE events (
+    id BIGSERIAL PRIMARY KEY,
+    society_id BIGINT NOT NULL REFERENCES societies(id),
+    -- Host residence; NULL for society events.
+    residence_id BIGINT REFERENCES residences(id),
+    -- Where it is held when not at the residence, e.g. the clubhouse.
+    amenity_id BIGINT REFERENCES amenities(id),
+    title VARCHAR(200) NOT NULL,
+    starts_at TIMESTAMPTZ NOT NULL,
+    ends_at TIMESTAMPTZ NOT NULL,
+    -- Most people allowed in, counting each guest's party.
+    capacity INT NOT NULL CHECK (capacity BETWEEN 1 AND 5000),
+    arrived_count INT NOT NULL DEFAULT 0,
+    closed_at TIMESTAMPTZ,
+    created_by UUID NOT NULL REFERENCES users(id),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK (residence_id IS NOT NULL OR amenity_id IS NOT NULL),
+    CHECK (ends_at > starts_at),
+    CHECK (arrived_count <= capacity)
+);
+
+CREATE TABLE event_guests (
+    id BIGSERIAL PRIMARY KEY,
+    event_id BIGINT NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    phone VARCHAR(20),
+    -- Sent to the guest and typed or scanned at the gate.
+    code CHAR(8) NOT NULL UNIQUE,
+    party_size SMALLINT NOT NULL DEFAULT 1 CHECK (party_size BETWEEN 1 AND 20),
+    arrived_at TIMESTAMPTZ,
+    visit_id UUID REFERENCES visits(id),
+    checked_in_by UUID REFERENCES users(id),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE(event_id, phone)
+);
+
+CREATE INDEX idx_events_society ON events(society_id, starts_at DESC);
+CREATE INDEX idx_events_residence ON events(residence_id, starts_at DESC) WHERE residence_id IS NOT NULL;
+CREATE INDEX idx_event_guests_event ON event_guests(event_id, lower(name));
+
+CREATE TRIGGER update_events_updated_at
+    BEFORE UPDATE ON events
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
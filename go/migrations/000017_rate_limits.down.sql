@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS auth_lockouts;
+
+DROP TABLE IF EXISTS rate_limit_buckets;
@@ -0,0 +1,19 @@
+-- Shared state for rate limiting and brute-force lockouts, used when
+-- several API instances must enforce one limit.
+
+CREATE TABLE rate_limit_buckets (
+    key TEXT PRIMARY KEY,
+    tokens DOUBLE PRECISION NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idx_rate_limit_buckets_updated ON rate_limit_buckets(updated_at);
+
+CREATE TABLE auth_lockouts (
+    key TEXT PRIMARY KEY,
+    failures INT NOT NULL CHECK (failures > 0),
+    last_failed_at TIMESTAMPTZ NOT NULL,
+    locked_until TIMESTAMPTZ
+);
+
+CREATE INDEX idx_auth_lockouts_last_failed ON auth_lockouts(last_failed_at);
@@ -0,0 +1,24 @@
+ALTER TABLE users
+    ADD COLUMN can_approve_visitors BOOLEAN NOT NULL DEFAULT true,
+    ADD COLUMN can_create_passes BOOLEAN NOT NULL DEFAULT true,
+    ADD COLUMN is_tenant BOOLEAN NOT NULL DEFAULT false,
+    ADD COLUMN lease_end DATE;
+
+-- Users keep the settings of their default residence.
+UPDATE users u
+SET can_approve_visitors = ur.can_approve_visitors,
+    can_create_passes = ur.can_create_passes,
+    is_tenant = ur.is_tenant,
+    lease_end = ur.lease_end
+FROM user_residences ur
+WHERE ur.user_id = u.id AND ur.residence_id = u.residence_id;
+
+CREATE INDEX idx_users_lease_end ON users(lease_end) WHERE is_tenant AND is_active;
+
+DROP INDEX IF EXISTS idx_user_residences_lease_end;
+
+ALTER TABLE user_residences
+    DROP COLUMN IF EXISTS lease_end,
+    DROP COLUMN IF EXISTS is_tenant,
+    DROP COLUMN IF EXISTS can_create_passes,
+    DROP COLUMN IF EXISTS can_approve_visitors;
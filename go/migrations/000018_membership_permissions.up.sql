@@ -0,0 +1,27 @@
+-- Permissions and tenancy belong to a membership, not to the user: a
+-- member of two flats may be a tenant in one and an owner's family in the
+-- other, and each owner manages only their own flat's settings.
+
+ALTER TABLE user_residences
+    ADD COLUMN can_approve_visitors BOOLEAN NOT NULL DEFAULT true,
+    ADD COLUMN can_create_passes BOOLEAN NOT NULL DEFAULT true,
+    ADD COLUMN is_tenant BOOLEAN NOT NULL DEFAULT false,
+    ADD COLUMN lease_end DATE;
+
+UPDATE user_residences ur
+SET can_approve_visitors = u.can_approve_visitors,
+    can_create_passes = u.can_create_passes,
+    is_tenant = u.is_tenant,
+    lease_end = u.lease_end
+FROM users u
+WHERE u.id = ur.user_id;
+
+CREATE INDEX idx_user_residences_lease_end ON user_residences(lease_end) WHERE is_tenant;
+
+DROP INDEX IF EXISTS idx_users_lease_end;
+
+ALTER TABLE users
+    DROP COLUMN lease_end,
+    DROP COLUMN is_tenant,
+    DROP COLUMN can_create_passes,
+    DROP COLUMN can_approve_visitors;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_polls_unsealed;
+
+ALTER TABLE polls DROP COLUMN IF EXISTS sealed_at;
@@ -0,0 +1,5 @@
+-- The head hash only proves the ballots untouched against a copy kept
+-- outside this database, so it is sent to the voters when the poll closes.
+ALTER TABLE polls ADD COLUMN sealed_at TIMESTAMPTZ;
+
+CREATE INDEX idx_polls_unsealed ON polls(closes_at) WHERE sealed_at IS NULL AND cancelled_at IS NULL;
@@ -0,0 +1,6 @@
+-- When a resident approved the visit, so the wait at the gate can be
+-- measured. Visits approved before this column existed were approved on
+-- arrival.
+ALTER TABLE visits ADD COLUMN approved_at TIMESTAMPTZ;
+
+UPDATE visits SET approved_at = check_in_time WHERE approved_by IS NOT NULL;
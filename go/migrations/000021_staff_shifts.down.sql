@@ -0,0 +1,4 @@
+-- Enum values cannot be dropped; STAFF stays in headcount_subject unused.
+DELETE FROM headcount_marks WHERE subject_type = 'STAFF';
+
+DROP TABLE IF EXISTS staff_shifts;
@@ -0,0 +1,16 @@
+-- Society staff punch in when they arrive and out when they leave, so a
+-- headcount knows who is on premises without a visit.
+CREATE TABLE staff_shifts (
+    id BIGSERIAL PRIMARY KEY,
+    society_id BIGINT NOT NULL REFERENCES societies(id),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    gate_id BIGINT REFERENCES gates(id),
+    started_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    ended_at TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX idx_staff_shifts_open ON staff_shifts(user_id) WHERE ended_at IS NULL;
+CREATE INDEX idx_staff_shifts_society_open ON staff_shifts(society_id) WHERE ended_at IS NULL;
+
+-- subject_id of a STAFF mark is the staff member's user id.
+ALTER TYPE headcount_subject ADD VALUE 'STAFF';
@@ -0,0 +1,6 @@
+-- The SLA clock stops while a ticket is ON_HOLD, e.g. waiting on a spare
+-- part or the resident. held_since marks the start of the hold; leaving it
+-- pushes the deadlines back by the time spent on hold.
+ALTER TABLE tickets ADD COLUMN held_since TIMESTAMPTZ;
+
+UPDATE tickets SET held_since = updated_at WHERE status = 'ON_HOLD';